@@ -49,6 +49,7 @@ that estimating the FP rate will clear the Bloom filter.
 package bloom
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"math"
@@ -78,6 +79,9 @@ type BloomFilter struct {
 	b BitMap
 }
 
+// BloomOption configures a BloomFilter.
+type BloomOption func(f *BloomFilter)
+
 func max(x, y uint) uint {
 	if x > y {
 		return x
@@ -87,8 +91,12 @@ func max(x, y uint) uint {
 
 // NewBloom creates a NewBloom Bloom filter with _m_ bits and _k_ hashing functions
 // We force _m_ and _k_ to be at least one to avoid panics.
-func NewBloom(b BitMap) *BloomFilter {
-	return &BloomFilter{b}
+func NewBloom(b BitMap, opts ...BloomOption) *BloomFilter {
+	f := &BloomFilter{b}
+	for _, fn := range opts {
+		fn(f)
+	}
+	return f
 }
 
 // baseHashes returns the four hash values of data that are used to create k
@@ -170,6 +178,43 @@ func (f *BloomFilter) ClearAll() error {
 	return f.b.ClearAll()
 }
 
+// AddCtx is Add passing ctx to the BitMap of the filter when it takes the
+// context of each operation, e.g. to trace it in the span of the request.
+func (f *BloomFilter) AddCtx(ctx context.Context, data []byte) error {
+	return setAll(ctx, f.b, baseHashes(data))
+}
+
+// AddStringCtx is AddString passing ctx to the BitMap, see AddCtx.
+func (f *BloomFilter) AddStringCtx(ctx context.Context, data string) error {
+	return f.AddCtx(ctx, []byte(data))
+}
+
+// TestCtx is Test passing ctx to the BitMap, see AddCtx.
+func (f *BloomFilter) TestCtx(ctx context.Context, data []byte) (bool, error) {
+	return testAll(ctx, f.b, baseHashes(data))
+}
+
+// TestStringCtx is TestString passing ctx to the BitMap, see AddCtx.
+func (f *BloomFilter) TestStringCtx(ctx context.Context, data string) (bool, error) {
+	return f.TestCtx(ctx, []byte(data))
+}
+
+// TestAndAddCtx is TestAndAdd passing ctx to the BitMap, see AddCtx.
+func (f *BloomFilter) TestAndAddCtx(ctx context.Context, data []byte) (bool, error) {
+	return testAddAll(ctx, f.b, baseHashes(data))
+}
+
+// TestAndAddStringCtx is TestAndAddString passing ctx to the BitMap, see
+// AddCtx.
+func (f *BloomFilter) TestAndAddStringCtx(ctx context.Context, data string) (bool, error) {
+	return f.TestAndAddCtx(ctx, []byte(data))
+}
+
+// ClearAllCtx is ClearAll passing ctx to the BitMap, see AddCtx.
+func (f *BloomFilter) ClearAllCtx(ctx context.Context) error {
+	return clearAll(ctx, f.b)
+}
+
 // EstimateFalsePositiveRate returns, for a BloomFilter with a estimate of m bits
 // and k hash functions, what the false positive rate will be
 // while storing n entries; runs 100,000 tests. This is an empirical
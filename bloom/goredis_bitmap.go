@@ -53,19 +53,19 @@ type GoredisBloom struct {
 	client redis.UniversalClient
 }
 
-func NewGoredis(m, k uint, redisKey string, client redis.UniversalClient) *BloomFilter {
+func NewGoredis(m, k uint, redisKey string, client redis.UniversalClient, opts ...BloomOption) *BloomFilter {
 	gb := &GoredisBloom{
 		k:      max(1, k),
 		m:      max(1, m),
 		key:    redisKey,
 		client: client,
 	}
-	return NewBloom(gb)
+	return NewBloom(gb, opts...)
 }
 
-func NewGoredisWithEstimates(n uint, fp float64, redisKey string, client redis.UniversalClient) *BloomFilter {
+func NewGoredisWithEstimates(n uint, fp float64, redisKey string, client redis.UniversalClient, opts ...BloomOption) *BloomFilter {
 	m, k := EstimateParameters(n, fp)
-	return NewGoredis(m, k, redisKey, client)
+	return NewGoredis(m, k, redisKey, client, opts...)
 }
 
 func (l *GoredisBloom) K() uint {
@@ -0,0 +1,155 @@
+package bloom
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoItem struct {
+	present    bool
+	expireTime time.Time
+}
+
+// MemoBitMap remembers recent TestAll results of another BitMap for a short
+// ttl, so hot keys do not hit a remote filter on every Test. Positive results
+// are exact since bits are never unset, negative results may be stale for up
+// to ttl when other clients add the same key.
+type MemoBitMap struct {
+	mtx  sync.Mutex
+	ttl  time.Duration
+	size int
+	b    BitMap
+	memo map[[4]uint64]*memoItem
+}
+
+// BloomWithMemo keeps up to size recent Test results in front of the filter's
+// BitMap for ttl.
+func BloomWithMemo(ttl time.Duration, size int) BloomOption {
+	return func(f *BloomFilter) {
+		f.b = NewMemoBitMap(f.b, ttl, size)
+	}
+}
+
+func NewMemoBitMap(b BitMap, ttl time.Duration, size int) *MemoBitMap {
+	return &MemoBitMap{
+		ttl:  ttl,
+		size: int(max(1, uint(size))),
+		b:    b,
+		memo: map[[4]uint64]*memoItem{},
+	}
+}
+
+func (l *MemoBitMap) K() uint {
+	return l.b.K()
+}
+
+func (l *MemoBitMap) M() uint {
+	return l.b.M()
+}
+
+func (l *MemoBitMap) SetAll(h [4]uint64) error {
+	return l.setAll(h, l.b.SetAll)
+}
+
+func (l *MemoBitMap) TestAll(h [4]uint64) (bool, error) {
+	return l.testAll(h, l.b.TestAll)
+}
+
+func (l *MemoBitMap) TestAddAll(h [4]uint64) (bool, error) {
+	return l.testAddAll(h, l.b.TestAddAll)
+}
+
+func (l *MemoBitMap) ClearAll() error {
+	return l.clearAll(l.b.ClearAll)
+}
+
+// SetAllCtx is SetAll passing ctx to the wrapped BitMap, as are the other
+// Ctx methods.
+func (l *MemoBitMap) SetAllCtx(ctx context.Context, h [4]uint64) error {
+	return l.setAll(h, func(h [4]uint64) error { return setAll(ctx, l.b, h) })
+}
+
+func (l *MemoBitMap) TestAllCtx(ctx context.Context, h [4]uint64) (bool, error) {
+	return l.testAll(h, func(h [4]uint64) (bool, error) { return testAll(ctx, l.b, h) })
+}
+
+func (l *MemoBitMap) TestAddAllCtx(ctx context.Context, h [4]uint64) (bool, error) {
+	return l.testAddAll(h, func(h [4]uint64) (bool, error) { return testAddAll(ctx, l.b, h) })
+}
+
+func (l *MemoBitMap) ClearAllCtx(ctx context.Context) error {
+	return l.clearAll(func() error { return clearAll(ctx, l.b) })
+}
+
+func (l *MemoBitMap) setAll(h [4]uint64, set func(h [4]uint64) error) error {
+	err := set(h)
+	if err == nil {
+		l.store(h, true)
+	}
+	return err
+}
+
+func (l *MemoBitMap) testAll(h [4]uint64, test func(h [4]uint64) (bool, error)) (bool, error) {
+	if present, ok := l.load(h); ok {
+		return present, nil
+	}
+	present, err := test(h)
+	if err == nil {
+		l.store(h, present)
+	}
+	return present, err
+}
+
+func (l *MemoBitMap) testAddAll(h [4]uint64, testAdd func(h [4]uint64) (bool, error)) (bool, error) {
+	if present, ok := l.load(h); ok && present {
+		return true, nil
+	}
+	present, err := testAdd(h)
+	if err == nil {
+		l.store(h, true)
+	}
+	return present, err
+}
+
+func (l *MemoBitMap) clearAll(clear func() error) error {
+	l.mtx.Lock()
+	l.memo = map[[4]uint64]*memoItem{}
+	l.mtx.Unlock()
+	return clear()
+}
+
+func (l *MemoBitMap) load(h [4]uint64) (bool, bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	item, ok := l.memo[h]
+	if !ok {
+		return false, false
+	}
+	if time.Now().After(item.expireTime) {
+		delete(l.memo, h)
+		return false, false
+	}
+	return item.present, true
+}
+
+func (l *MemoBitMap) store(h [4]uint64, present bool) {
+	now := time.Now()
+	l.mtx.Lock()
+	if _, ok := l.memo[h]; !ok && len(l.memo) >= l.size {
+		for k, v := range l.memo {
+			if now.After(v.expireTime) {
+				delete(l.memo, k)
+			}
+		}
+		// still full, drop an arbitrary entry
+		for k := range l.memo {
+			if len(l.memo) < l.size {
+				break
+			}
+			delete(l.memo, k)
+		}
+	}
+	l.memo[h] = &memoItem{present: present, expireTime: now.Add(l.ttl)}
+	l.mtx.Unlock()
+}
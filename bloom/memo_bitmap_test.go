@@ -0,0 +1,51 @@
+package bloom
+
+import (
+	"testing"
+	"time"
+)
+
+type countBitMap struct {
+	BitMap
+	tests int
+}
+
+func (c *countBitMap) TestAll(h [4]uint64) (bool, error) {
+	c.tests++
+	return c.BitMap.TestAll(h)
+}
+
+func TestMemo(t *testing.T) {
+	cb := &countBitMap{BitMap: NewLocal(1000, 4).b}
+	f := NewBloom(cb, BloomWithMemo(100*time.Millisecond, 10))
+	n1 := []byte("Bess")
+	n2 := []byte("Jane")
+	for i := 0; i < 5; i++ {
+		if r, _ := f.Test(n1); r {
+			t.Errorf("%v should not be in.", n1)
+		}
+	}
+	if cb.tests != 1 {
+		t.Errorf("%v tests should be 1", cb.tests)
+	}
+	f.Add(n1)
+	if r, _ := f.Test(n1); !r {
+		t.Errorf("%v should be in.", n1)
+	}
+	if cb.tests != 1 {
+		t.Errorf("%v tests should be 1", cb.tests)
+	}
+	cb.BitMap.SetAll(baseHashes(n2))
+	if r, _ := f.Test(n2); !r {
+		t.Errorf("%v should be in.", n2)
+	}
+	f.ClearAll()
+	if r, _ := f.Test(n1); r {
+		t.Errorf("%v should not be in after clear.", n1)
+	}
+	time.Sleep(150 * time.Millisecond)
+	f.Test(n1)
+	if cb.tests != 4 {
+		t.Errorf("%v tests should be 4", cb.tests)
+	}
+}
@@ -17,19 +17,19 @@ type RedigoBloom struct {
 	getConn GetRedisConn
 }
 
-func NewRedisgo(m, k uint, redisKey string, getConn GetRedisConn) *BloomFilter {
+func NewRedisgo(m, k uint, redisKey string, getConn GetRedisConn, opts ...BloomOption) *BloomFilter {
 	rb := &RedigoBloom{
 		k:       max(1, k),
 		m:       max(1, m),
 		key:     redisKey,
 		getConn: getConn,
 	}
-	return NewBloom(rb)
+	return NewBloom(rb, opts...)
 }
 
-func NewRedisgoWithEstimates(n uint, fp float64, redisKey string, getConn GetRedisConn) *BloomFilter {
+func NewRedisgoWithEstimates(n uint, fp float64, redisKey string, getConn GetRedisConn, opts ...BloomOption) *BloomFilter {
 	m, k := EstimateParameters(n, fp)
-	return NewRedisgo(m, k, redisKey, getConn)
+	return NewRedisgo(m, k, redisKey, getConn, opts...)
 }
 
 func (l *RedigoBloom) K() uint {
@@ -0,0 +1,170 @@
+package bloom
+
+import (
+	"context"
+)
+
+// Span is the part of a tracing span used by TracedBitMap, the same as the
+// Span of the cache package. The otel package of mcache adapts the
+// OpenTelemetry spans.
+type Span interface {
+	SetAttribute(key, value string)
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans, as a child of the span in ctx if any.
+type Tracer interface {
+	Start(ctx context.Context, name string) Span
+}
+
+// ContextBitMap is implemented by the BitMaps taking the context of each
+// operation, e.g. TracedBitMap to start its spans as children of the span of
+// the request.
+type ContextBitMap interface {
+	SetAllCtx(ctx context.Context, h [4]uint64) error
+	TestAllCtx(ctx context.Context, h [4]uint64) (bool, error)
+	TestAddAllCtx(ctx context.Context, h [4]uint64) (bool, error)
+	ClearAllCtx(ctx context.Context) error
+}
+
+// setAll calls SetAllCtx of b if it takes a context, SetAll otherwise, as do
+// testAll, testAddAll and clearAll.
+func setAll(ctx context.Context, b BitMap, h [4]uint64) error {
+	if cb, ok := b.(ContextBitMap); ok {
+		return cb.SetAllCtx(ctx, h)
+	}
+	return b.SetAll(h)
+}
+
+func testAll(ctx context.Context, b BitMap, h [4]uint64) (bool, error) {
+	if cb, ok := b.(ContextBitMap); ok {
+		return cb.TestAllCtx(ctx, h)
+	}
+	return b.TestAll(h)
+}
+
+func testAddAll(ctx context.Context, b BitMap, h [4]uint64) (bool, error) {
+	if cb, ok := b.(ContextBitMap); ok {
+		return cb.TestAddAllCtx(ctx, h)
+	}
+	return b.TestAddAll(h)
+}
+
+func clearAll(ctx context.Context, b BitMap) error {
+	if cb, ok := b.(ContextBitMap); ok {
+		return cb.ClearAllCtx(ctx)
+	}
+	return b.ClearAll()
+}
+
+// TracedBitMap starts a span for each operation of another BitMap, named
+// bloom.Add, bloom.Test, bloom.TestAndAdd or bloom.ClearAll, with the
+// attributes bloom.backend and bloom.result: present, absent, ok or error.
+// The Ctx methods start their span in the context of the call, the others in
+// the context given to NewTracedBitMap.
+type TracedBitMap struct {
+	ctx     context.Context
+	tracer  Tracer
+	backend string
+	b       BitMap
+}
+
+// BloomWithTracer traces the operations of the filter with tracer. backend
+// names the filter in the bloom.backend attribute. The spans of the methods
+// without a context are children of the span in ctx: pass the context of
+// each request to AddCtx, TestCtx and the other Ctx methods of the filter
+// instead. Apply it after BloomWithMemo to trace the memo hits too.
+func BloomWithTracer(ctx context.Context, tracer Tracer, backend string) BloomOption {
+	return func(f *BloomFilter) {
+		f.b = NewTracedBitMap(ctx, f.b, tracer, backend)
+	}
+}
+
+func NewTracedBitMap(ctx context.Context, b BitMap, tracer Tracer, backend string) *TracedBitMap {
+	return &TracedBitMap{
+		ctx:     ctx,
+		tracer:  tracer,
+		backend: backend,
+		b:       b,
+	}
+}
+
+func (l *TracedBitMap) start(op string) Span {
+	return l.startCtx(l.ctx, op)
+}
+
+func (l *TracedBitMap) startCtx(ctx context.Context, op string) Span {
+	span := l.tracer.Start(ctx, "bloom."+op)
+	span.SetAttribute("bloom.backend", l.backend)
+	return span
+}
+
+func (l *TracedBitMap) end(span Span, result string, err error) {
+	if err != nil {
+		span.SetAttribute("bloom.result", "error")
+		span.RecordError(err)
+	} else {
+		span.SetAttribute("bloom.result", result)
+	}
+	span.End()
+}
+
+func (l *TracedBitMap) test(span Span, present bool, err error) (bool, error) {
+	result := "absent"
+	if present {
+		result = "present"
+	}
+	l.end(span, result, err)
+	return present, err
+}
+
+func (l *TracedBitMap) K() uint {
+	return l.b.K()
+}
+
+func (l *TracedBitMap) M() uint {
+	return l.b.M()
+}
+
+func (l *TracedBitMap) SetAll(h [4]uint64) error {
+	return l.SetAllCtx(l.ctx, h)
+}
+
+func (l *TracedBitMap) TestAll(h [4]uint64) (bool, error) {
+	return l.TestAllCtx(l.ctx, h)
+}
+
+func (l *TracedBitMap) TestAddAll(h [4]uint64) (bool, error) {
+	return l.TestAddAllCtx(l.ctx, h)
+}
+
+func (l *TracedBitMap) ClearAll() error {
+	return l.ClearAllCtx(l.ctx)
+}
+
+func (l *TracedBitMap) SetAllCtx(ctx context.Context, h [4]uint64) error {
+	span := l.startCtx(ctx, "Add")
+	err := setAll(ctx, l.b, h)
+	l.end(span, "ok", err)
+	return err
+}
+
+func (l *TracedBitMap) TestAllCtx(ctx context.Context, h [4]uint64) (bool, error) {
+	span := l.startCtx(ctx, "Test")
+	present, err := testAll(ctx, l.b, h)
+	return l.test(span, present, err)
+}
+
+func (l *TracedBitMap) TestAddAllCtx(ctx context.Context, h [4]uint64) (bool, error) {
+	span := l.startCtx(ctx, "TestAndAdd")
+	present, err := testAddAll(ctx, l.b, h)
+	return l.test(span, present, err)
+}
+
+func (l *TracedBitMap) ClearAllCtx(ctx context.Context) error {
+	span := l.startCtx(ctx, "ClearAll")
+	err := clearAll(ctx, l.b)
+	l.end(span, "ok", err)
+	return err
+}
@@ -0,0 +1,78 @@
+package bloom
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testSpan struct {
+	name   string
+	attrs  map[string]string
+	ended  bool
+	parent interface{}
+}
+
+// parentKey keys the name of the parent span in the contexts of the tests.
+type parentKey struct{}
+
+func (s *testSpan) SetAttribute(key, value string) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)          {}
+func (s *testSpan) End()                           { s.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) Span {
+	span := &testSpan{name: name, attrs: map[string]string{}, parent: ctx.Value(parentKey{})}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func TestTraced(t *testing.T) {
+	tracer := &testTracer{}
+	f := NewBloom(NewLocal(1000, 4).b, BloomWithTracer(context.Background(), tracer, "local"))
+	f.TestString("Bess")
+	f.AddString("Bess")
+	f.TestString("Bess")
+	want := []struct{ name, result string }{
+		{"bloom.Test", "absent"},
+		{"bloom.Add", "ok"},
+		{"bloom.Test", "present"},
+	}
+	if len(tracer.spans) != len(want) {
+		t.Errorf("%v spans should be %v", len(tracer.spans), len(want))
+		return
+	}
+	for i, w := range want {
+		s := tracer.spans[i]
+		if s.name != w.name || s.attrs["bloom.result"] != w.result || s.attrs["bloom.backend"] != "local" || !s.ended {
+			t.Errorf("%v %v should be %v", s.name, s.attrs, w)
+		}
+	}
+}
+
+func TestTracedCtx(t *testing.T) {
+	tracer := &testTracer{}
+	ctx := context.WithValue(context.Background(), parentKey{}, "init")
+	f := NewBloom(NewLocal(1000, 4).b, BloomWithMemo(time.Minute, 10), BloomWithTracer(ctx, tracer, "local"))
+	reqCtx := context.WithValue(context.Background(), parentKey{}, "request")
+	f.AddStringCtx(reqCtx, "Bess")
+	f.TestStringCtx(reqCtx, "Bess")
+	f.TestString("Bess")
+	want := []struct{ name, parent string }{
+		{"bloom.Add", "request"},
+		{"bloom.Test", "request"},
+		{"bloom.Test", "init"},
+	}
+	if len(tracer.spans) != len(want) {
+		t.Errorf("%v spans should be %v", len(tracer.spans), len(want))
+		return
+	}
+	for i, w := range want {
+		if s := tracer.spans[i]; s.name != w.name || s.parent != w.parent {
+			t.Errorf("%v %v should be %v", s.name, s.parent, w)
+		}
+	}
+}
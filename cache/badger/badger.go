@@ -0,0 +1,165 @@
+// Package badger stores the entries of a cache.Cache in a BadgerDB.
+package badger
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+
+	dgraph "github.com/dgraph-io/badger/v3"
+
+	"mcache/cache"
+)
+
+// badgerHeaderLen is the size of the ttl header BadgerCache keeps in front of
+// each value, so Get can slide the expiration by the ttl the key was set with.
+const badgerHeaderLen = 8
+
+// BadgerCache stores encoded values in a BadgerDB, which keeps its values on
+// disk and suits datasets larger than memory. Expiration uses Badger's native
+// per entry ttl, expired entries are dropped by Badger's own compaction.
+type BadgerCache struct {
+	cache.ByteGetters
+	expire   time.Duration
+	absolute bool
+	prefix   string
+	db       *dgraph.DB
+	m        sync.Mutex
+	r        *rand.Rand
+}
+
+type BadgerOption func(c *BadgerCache)
+
+func BadgerWithExpire(expireSecond int) BadgerOption {
+	return func(c *BadgerCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func BadgerWithTTL(ttl time.Duration) BadgerOption {
+	return func(c *BadgerCache) {
+		c.expire = ttl
+	}
+}
+
+// BadgerWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads.
+func BadgerWithAbsoluteExpire() BadgerOption {
+	return func(c *BadgerCache) {
+		c.absolute = true
+	}
+}
+
+// BadgerWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func BadgerWithKeyPrefix(prefix string) BadgerOption {
+	return func(c *BadgerCache) {
+		c.prefix = prefix
+	}
+}
+
+func NewBadgerCache(db *dgraph.DB, opts ...BadgerOption) *cache.Cache {
+	c := &BadgerCache{
+		db: db,
+		r:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = cache.NewByteGetters(c.Get)
+	for _, fn := range opts {
+		fn(c)
+	}
+	return cache.NewCache(c)
+}
+
+func (c *BadgerCache) Set(key string, value interface{}) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c *BadgerCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *BadgerCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if c.db == nil {
+		return cache.ErrNoClient
+	}
+	return c.db.Update(func(txn *dgraph.Txn) error {
+		return txn.SetEntry(badgerEntry([]byte(c.prefix+key), cache.EncodeBytes(value), ttl))
+	})
+}
+
+// badgerEntry builds an entry holding value behind its ttl header. A zero
+// ttl never expires.
+func badgerEntry(key, value []byte, ttl time.Duration) *dgraph.Entry {
+	data := make([]byte, badgerHeaderLen+len(value))
+	binary.BigEndian.PutUint64(data, uint64(ttl))
+	copy(data[badgerHeaderLen:], value)
+	e := dgraph.NewEntry(key, data)
+	if ttl > 0 {
+		e = e.WithTTL(ttl)
+	}
+	return e
+}
+
+// Get returns the value of key, rewriting the entry to extend its
+// expiration unless the cache uses absolute expiration.
+func (c *BadgerCache) Get(key string) (interface{}, error) {
+	if c.db == nil {
+		return nil, cache.ErrNoClient
+	}
+	var value []byte
+	var ttl time.Duration
+	err := c.db.View(func(txn *dgraph.Txn) error {
+		item, err := txn.Get([]byte(c.prefix + key))
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if len(data) < badgerHeaderLen {
+			return cache.ErrDataType
+		}
+		ttl = time.Duration(binary.BigEndian.Uint64(data))
+		value = data[badgerHeaderLen:]
+		return nil
+	})
+	if err == dgraph.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 && !c.absolute {
+		err = c.db.Update(func(txn *dgraph.Txn) error {
+			return txn.SetEntry(badgerEntry([]byte(c.prefix+key), value, ttl))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// Clear drops the keys under the key prefix, every key of db without one.
+func (c *BadgerCache) Clear() error {
+	if c.db == nil {
+		return cache.ErrNoClient
+	}
+	return c.db.DropPrefix([]byte(c.prefix))
+}
+
+func (c *BadgerCache) Del(key string) error {
+	if c.db == nil {
+		return cache.ErrNoClient
+	}
+	return c.db.Update(func(txn *dgraph.Txn) error {
+		return txn.Delete([]byte(c.prefix + key))
+	})
+}
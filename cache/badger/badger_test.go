@@ -0,0 +1,101 @@
+package badger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	dgraph "github.com/dgraph-io/badger/v3"
+
+	"mcache/cache/cachetest"
+)
+
+func getBadgerT(t *testing.T) *dgraph.DB {
+	db, err := dgraph.Open(dgraph.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestBadgerSetInt(t *testing.T) {
+	c := NewBadgerCache(getBadgerT(t), BadgerWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestBadgerSetBytes(t *testing.T) {
+	c := NewBadgerCache(getBadgerT(t), BadgerWithExpire(10))
+	v := []byte("test")
+	c.Set("test:123", v)
+	data, _ := c.GetBytes("test:123")
+	if data == nil || !bytes.Equal(v, data) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestBadgerSetBool(t *testing.T) {
+	c := NewBadgerCache(getBadgerT(t), BadgerWithExpire(10))
+	v := true
+	c.Set("test:123", v)
+	data, _ := c.GetBool("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestBadgerDel(t *testing.T) {
+	c := NewBadgerCache(getBadgerT(t), BadgerWithExpire(10))
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestBadgerExpire(t *testing.T) {
+	c := NewBadgerCache(getBadgerT(t), BadgerWithTTL(2*time.Second))
+	key := "test:123"
+	c.Set(key, true)
+	data, _ := c.GetBool(key)
+	if data == nil || !*data {
+		t.Errorf("%v value error", data)
+		return
+	}
+	time.Sleep(3 * time.Second)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestBadgerExtend(t *testing.T) {
+	c := NewBadgerCache(getBadgerT(t), BadgerWithTTL(2*time.Second))
+	key := "test:123"
+	c.Set(key, true)
+	for i := 0; i < 4; i++ {
+		time.Sleep(time.Second)
+		data, _ := c.GetBool(key)
+		if data == nil || !*data {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestBadgerClear(t *testing.T) {
+	db := getBadgerT(t)
+	cachetest.Clear(t, NewBadgerCache(db, BadgerWithKeyPrefix("svcA:")), NewBadgerCache(db, BadgerWithKeyPrefix("svcB:")))
+}
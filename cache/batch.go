@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrBatchPending = errors.New("batch not executed error")
+
+// TxCache queues the writes of a transaction, applied together once the
+// transaction function returns nil.
+type TxCache interface {
+	Set(key string, value interface{})
+	SetWithTTL(key string, value interface{}, ttl time.Duration)
+	Del(key string)
+}
+
+// Batch queues writes and reads sent to the cache together by Exec, in one
+// round trip. The queued commands are not run before Exec.
+type Batch interface {
+	Set(key string, value interface{})
+	SetWithTTL(key string, value interface{}, ttl time.Duration)
+	Get(key string) *BatchResult
+	Del(key string)
+	Exec() error
+}
+
+// BatchResult is the result of a Get queued in a Batch, set by Exec.
+type BatchResult struct {
+	value interface{}
+	err   error
+}
+
+func newBatchResult() *BatchResult {
+	return &BatchResult{err: ErrBatchPending}
+}
+
+// Value returns the value read by Exec, as the Get of the cache returns it,
+// nil for a missing key, or ErrBatchPending before Exec.
+func (r *BatchResult) Value() (interface{}, error) {
+	return r.value, r.err
+}
+
+// Tx calls fn and applies the writes it queued atomically when it returns
+// nil, none when it returns an error, which Tx returns. Caches implementing
+// ITx apply them their own way, caches implementing IBatch as a TxPipeline,
+// for which a command failing at run time, e.g. on a key of another type,
+// does not undo the others. It returns ErrNotSupported for other caches.
+func (c *Cache) Tx(fn func(tx TxCache) error) error {
+	if t, ok := c.cache.(ITx); ok {
+		return t.Tx(fn)
+	}
+	b, ok := c.cache.(IBatch)
+	if !ok {
+		return ErrNotSupported
+	}
+	tx := b.TxPipeline()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Exec()
+}
+
+// Pipeline returns a batch sent as a pipeline, whose commands may be
+// interleaved with those of other clients. It returns ErrNotSupported if the
+// underlying cache can not batch.
+func (c *Cache) Pipeline() (Batch, error) {
+	b, ok := c.cache.(IBatch)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return b.Pipeline(), nil
+}
+
+// TxPipeline returns a batch run as a transaction, no other client sees its
+// writes half done. It returns ErrNotSupported if the underlying cache can
+// not batch.
+func (c *Cache) TxPipeline() (Batch, error) {
+	b, ok := c.cache.(IBatch)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return b.TxPipeline(), nil
+}
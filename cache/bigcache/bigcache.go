@@ -0,0 +1,149 @@
+// Package bigcache stores the entries of a cache.Cache in a BigCache.
+package bigcache
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	allegro "github.com/allegro/bigcache/v3"
+
+	"mcache/cache"
+)
+
+// BigCacheCache stores encoded values in a BigCache, which keeps millions of
+// entries off the GC heap. BigCache only has a global life window, so the
+// per key expiration is kept in an entry header and checked on read. Reads
+// do not extend it: bigcache appends every write to its shards, so
+// rewriting the entry on each read would fill them and could overwrite a
+// concurrent Set with the value read. The expired entries are dropped by
+// bigcache once its life window passed.
+type BigCacheCache struct {
+	cache.ByteGetters
+	expire time.Duration
+	prefix string
+	client *allegro.BigCache
+	m      sync.Mutex
+	r      *rand.Rand
+}
+
+type BigCacheOption func(c *BigCacheCache)
+
+func BigCacheWithExpire(expireSecond int) BigCacheOption {
+	return func(c *BigCacheCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func BigCacheWithTTL(ttl time.Duration) BigCacheOption {
+	return func(c *BigCacheCache) {
+		c.expire = ttl
+	}
+}
+
+// BigCacheWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func BigCacheWithKeyPrefix(prefix string) BigCacheOption {
+	return func(c *BigCacheCache) {
+		c.prefix = prefix
+	}
+}
+
+func NewBigCache(client *allegro.BigCache, opts ...BigCacheOption) *cache.Cache {
+	c := &BigCacheCache{
+		client: client,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = cache.NewByteGetters(c.Get)
+	for _, fn := range opts {
+		fn(c)
+	}
+	return cache.NewCache(c)
+}
+
+func (c *BigCacheCache) Set(key string, value interface{}) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c *BigCacheCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *BigCacheCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	return c.client.Set(c.prefix+key, cache.EncodeEntry(cache.EncodeBytes(value), ttl))
+}
+
+func (c *BigCacheCache) Get(key string) (interface{}, error) {
+	if c.client == nil {
+		return nil, cache.ErrNoClient
+	}
+	entry, err := c.client.Get(c.prefix + key)
+	if err == allegro.ErrEntryNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	value, _, ok := cache.DecodeEntry(entry)
+	if !ok {
+		return nil, nil
+	}
+	return value, nil
+}
+
+// Close closes the bigcache client, stopping its cleanup goroutine.
+func (c *BigCacheCache) Close() error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	return c.client.Close()
+}
+
+func (c *BigCacheCache) Del(key string) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	err := c.client.Delete(c.prefix + key)
+	if err == allegro.ErrEntryNotFound {
+		return nil
+	}
+	return err
+}
+
+// Clear deletes the entries under the key prefix, resetting the whole
+// bigcache without one.
+func (c *BigCacheCache) Clear() error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	if c.prefix == "" {
+		return c.client.Reset()
+	}
+	var keys []string
+	it := c.client.Iterator()
+	for it.SetNext() {
+		entry, err := it.Value()
+		if err != nil {
+			// removed while iterating
+			continue
+		}
+		if strings.HasPrefix(entry.Key(), c.prefix) {
+			keys = append(keys, entry.Key())
+		}
+	}
+	for _, k := range keys {
+		if err := c.client.Delete(k); err != nil && err != allegro.ErrEntryNotFound {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package bigcache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	allegro "github.com/allegro/bigcache/v3"
+
+	"mcache/cache"
+	"mcache/cache/cachetest"
+)
+
+func getBigCacheT(t *testing.T) *allegro.BigCache {
+	c, err := allegro.New(context.Background(), allegro.DefaultConfig(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestBigCacheSetInt(t *testing.T) {
+	c := NewBigCache(getBigCacheT(t), BigCacheWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestBigCacheSetBytes(t *testing.T) {
+	c := NewBigCache(getBigCacheT(t), BigCacheWithExpire(10))
+	v := []byte("test")
+	c.Set("test:123", v)
+	data, _ := c.GetBytes("test:123")
+	if data == nil || !bytes.Equal(v, data) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestBigCacheSetBool(t *testing.T) {
+	c := NewBigCache(getBigCacheT(t), BigCacheWithExpire(10))
+	v := true
+	c.Set("test:123", v)
+	data, _ := c.GetBool("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestBigCacheDel(t *testing.T) {
+	c := NewBigCache(getBigCacheT(t), BigCacheWithExpire(10))
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestBigCacheExpire(t *testing.T) {
+	c := NewBigCache(getBigCacheT(t), BigCacheWithTTL(200*time.Millisecond))
+	key := "test:123"
+	c.Set(key, true)
+	data, _ := c.GetBool(key)
+	if data == nil || !*data {
+		t.Errorf("%v value error", data)
+		return
+	}
+	time.Sleep(300 * time.Millisecond)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestBigCacheReadKeepsExpire(t *testing.T) {
+	c := NewBigCache(getBigCacheT(t), BigCacheWithTTL(200*time.Millisecond))
+	key := "test:123"
+	c.Set(key, true)
+	time.Sleep(100 * time.Millisecond)
+	if data, _ := c.GetBool(key); data == nil || !*data {
+		t.Errorf("%v value error", data)
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+	if data, _ := c.GetBool(key); data != nil {
+		t.Errorf("%v value error", *data)
+	}
+}
+
+func TestBigCacheCloseNoClient(t *testing.T) {
+	c := NewBigCache(nil)
+	if err := c.Close(); err != cache.ErrNoClient {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestBigCacheClear(t *testing.T) {
+	client := getBigCacheT(t)
+	cachetest.Clear(t, NewBigCache(client, BigCacheWithKeyPrefix("svcA:")), NewBigCache(client, BigCacheWithKeyPrefix("svcB:")))
+}
@@ -0,0 +1,197 @@
+// Package bolt stores the entries of a cache.Cache in a bbolt database.
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"mcache/cache"
+)
+
+const (
+	DefaultBoltBucket = "mcache"
+)
+
+// BoltCache stores encoded values in a bbolt database, so the cache survives
+// restarts. Expired entries are ignored on read and removed by a background
+// sweeper like cache.LocalCache's.
+type BoltCache struct {
+	cache.ByteGetters
+	expire   time.Duration
+	absolute bool
+	prefix   string
+	bucket   []byte
+	db       *bbolt.DB
+	m        sync.Mutex
+	r        *rand.Rand
+	bg       cache.Background
+}
+
+type BoltOption func(c *BoltCache)
+
+func BoltWithExpire(expireSecond int) BoltOption {
+	return func(c *BoltCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func BoltWithTTL(ttl time.Duration) BoltOption {
+	return func(c *BoltCache) {
+		c.expire = ttl
+	}
+}
+
+// BoltWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads.
+func BoltWithAbsoluteExpire() BoltOption {
+	return func(c *BoltCache) {
+		c.absolute = true
+	}
+}
+
+// BoltWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func BoltWithKeyPrefix(prefix string) BoltOption {
+	return func(c *BoltCache) {
+		c.prefix = prefix
+	}
+}
+
+// BoltWithBucket sets the bucket holding the entries, DefaultBoltBucket by
+// default.
+func BoltWithBucket(bucket string) BoltOption {
+	return func(c *BoltCache) {
+		c.bucket = []byte(bucket)
+	}
+}
+
+// NewBoltCache returns a cache stored in db. The sweeper runs until ctx is
+// done.
+func NewBoltCache(ctx context.Context, db *bbolt.DB, opts ...BoltOption) (*cache.Cache, error) {
+	c := &BoltCache{
+		bucket: []byte(DefaultBoltBucket),
+		db:     db,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = cache.NewByteGetters(c.Get)
+	for _, fn := range opts {
+		fn(c)
+	}
+	if db == nil {
+		return nil, cache.ErrNoClient
+	}
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(c.bucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.bg.Sweep(ctx, c.expire, c.deleteExpired)
+	return cache.NewCache(c), nil
+}
+
+func (c *BoltCache) Set(key string, value interface{}) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c *BoltCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *BoltCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(c.bucket).Put([]byte(c.prefix+key), cache.EncodeEntry(cache.EncodeBytes(value), ttl))
+	})
+}
+
+// Get returns the value of key, rewriting the entry to extend its
+// expiration unless the cache uses absolute expiration.
+func (c *BoltCache) Get(key string) (interface{}, error) {
+	var value []byte
+	var ttl time.Duration
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		entry := tx.Bucket(c.bucket).Get([]byte(c.prefix + key))
+		data, exp, ok := cache.DecodeEntry(entry)
+		if ok {
+			// bolt memory is only valid inside the transaction
+			value = append([]byte{}, data...)
+			ttl = exp
+		}
+		return nil
+	})
+	if err != nil || value == nil {
+		return nil, err
+	}
+	if ttl > 0 && !c.absolute {
+		err = c.db.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket(c.bucket).Put([]byte(c.prefix+key), cache.EncodeEntry(value, ttl))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+func (c *BoltCache) Del(key string) error {
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(c.bucket).Delete([]byte(c.prefix + key))
+	})
+}
+
+// Clear deletes the entries of the bucket under the key prefix.
+func (c *BoltCache) Clear() error {
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(c.bucket)
+		prefix := []byte(c.prefix)
+		tmpDel := [][]byte{}
+		cur := b.Cursor()
+		for k, _ := cur.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			tmpDel = append(tmpDel, append([]byte{}, k...))
+		}
+		for _, k := range tmpDel {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close stops the expiration sweeper and waits for it to return. It does
+// not close db, which was opened by the caller.
+func (c *BoltCache) Close() error {
+	c.bg.Stop()
+	return nil
+}
+
+// deleteExpired deletes the expired entries of the bucket.
+func (c *BoltCache) deleteExpired(ctx context.Context) {
+	c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(c.bucket)
+		tmpDel := [][]byte{}
+		b.ForEach(func(k, v []byte) error {
+			if _, _, ok := cache.DecodeEntry(v); !ok {
+				tmpDel = append(tmpDel, append([]byte{}, k...))
+			}
+			return nil
+		})
+		for _, k := range tmpDel {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
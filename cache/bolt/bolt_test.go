@@ -0,0 +1,117 @@
+package bolt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"mcache/cache/cachetest"
+)
+
+func getBoltT(t *testing.T) *bbolt.DB {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "cache.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestBoltSetInt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewBoltCache(ctx, getBoltT(t), BoltWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestBoltDel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewBoltCache(ctx, getBoltT(t), BoltWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestBoltReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, _ := NewBoltCache(ctx, db)
+	c.Set("test:123", "test")
+	db.Close()
+	db, err = bbolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c, _ = NewBoltCache(ctx, db)
+	data, _ := c.GetString("test:123")
+	if data != "test" {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestBoltExpire(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := getBoltT(t)
+	c, err := NewBoltCache(ctx, db, BoltWithTTL(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test:123"
+	c.Set(key, true)
+	time.Sleep(500 * time.Millisecond)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+	count := 0
+	db.View(func(tx *bbolt.Tx) error {
+		count = tx.Bucket([]byte(DefaultBoltBucket)).Stats().KeyN
+		return nil
+	})
+	if count != 0 {
+		t.Errorf("%v entries not swept", count)
+		return
+	}
+}
+
+func TestBoltClear(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := getBoltT(t)
+	a, err := NewBoltCache(ctx, db, BoltWithKeyPrefix("svcA:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewBoltCache(ctx, db, BoltWithKeyPrefix("svcB:"))
+	cachetest.Clear(t, a, b)
+}
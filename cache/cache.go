@@ -1,66 +1,1083 @@
 package cache
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"strings"
+	"time"
 )
 
 var (
 	ErrNoRedis  = errors.New("no redis client error")
+	ErrNoClient = errors.New("no cache client error")
 	ErrDataType = errors.New("data type error")
+	ErrOverflow = errors.New("value overflow error")
+	ErrNoMaster = errors.New("no redis master error")
+
+	ErrNotSupported = errors.New("operation not supported error")
+	// ErrCacheMiss is returned by Get instead of a nil value when the key is
+	// not cached, by the caches built with an option asking for it, so a
+	// cached nil can be told from a miss.
+	ErrCacheMiss = errors.New("cache miss error")
+	// ErrSweeperStopped is returned by Ping when the goroutine removing the
+	// expired entries of a local cache is not running.
+	ErrSweeperStopped = errors.New("expiration sweeper stopped error")
+	// ErrRateLimited is returned by a RateLimitedCache for the operations
+	// over its rate.
+	ErrRateLimited = errors.New("rate limit exceeded error")
+	// ErrValueTooLarge is returned by the redis caches for the values longer
+	// than their maximum value size, which are not written.
+	ErrValueTooLarge = errors.New("value too large error")
+	// ErrDecrypt is returned by an EncryptedCache for the values it can not
+	// decrypt, e.g. encrypted with a key it no longer has.
+	ErrDecrypt = errors.New("value decryption error")
+	// ErrCorrupt is returned by a ChecksumCache for the values not matching
+	// their checksum, e.g. truncated.
+	ErrCorrupt = errors.New("value checksum error")
+	// ErrInvalidKey is returned by a KeyCache for the keys failing its
+	// validation, which are not passed to the wrapped cache.
+	ErrInvalidKey = errors.New("invalid key error")
 )
 
+// ICache is implemented by every cache. Keys are byte strings like any Go
+// string: binary keys, e.g. hashes or encoded composite keys, need not be
+// valid UTF-8 and are passed as string(b) without loss.
 type ICache interface {
 	Set(key string, value interface{}) error
 	SetWithExpire(key string, value interface{}, expireSec int) error
+	SetWithTTL(key string, value interface{}, ttl time.Duration) error
 	Get(key string) (interface{}, error)
 	GetInt(key string) (*int64, error)
+	GetUint(key string) (*uint64, error)
 	GetFloat(key string) (*float64, error)
 	GetString(key string) (string, error)
 	GetBytes(key string) ([]byte, error)
 	GetBool(key string) (*bool, error)
+	GetTime(key string) (*time.Time, error)
+	GetDuration(key string) (*time.Duration, error)
+	GetStringSlice(key string) ([]string, error)
+	GetIntSlice(key string) ([]int64, error)
 	Del(key string) error
 }
 
+// TTLSeconds converts ttl to whole seconds for backends with second
+// precision, rounding up so a short ttl does not become "no expiration".
+func TTLSeconds(ttl time.Duration) int {
+	if ttl <= 0 {
+		return 0
+	}
+	return int((ttl + time.Second - 1) / time.Second)
+}
+
+// ttlMillis converts ttl to whole milliseconds, rounding up like TTLSeconds.
+func ttlMillis(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return int64((ttl + time.Millisecond - 1) / time.Millisecond)
+}
+
+// encodeValue converts values without a stable redis encoding, time.Time is
+// stored as RFC3339 in UTC, so an instant always has the same encoding,
+// time.Duration as nanoseconds and slices as JSON arrays. Pointers to times
+// and durations are encoded like the values, nil ones as nil.
+func encodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []string, []int, []int64:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return value
+		}
+		return data
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return encodeValue(*v)
+	case time.Duration:
+		return int64(v)
+	case *time.Duration:
+		if v == nil {
+			return nil
+		}
+		return int64(*v)
+	}
+	return value
+}
+
+// encodeValueMax encodes value as encodeValue does, returning
+// ErrValueTooLarge when the encoding is longer than max bytes, 0 being
+// unlimited.
+func encodeValueMax(value interface{}, max int) (interface{}, error) {
+	v := encodeValue(value)
+	if max <= 0 {
+		return v, nil
+	}
+	size := 0
+	switch data := v.(type) {
+	case string:
+		size = len(data)
+	case []byte:
+		size = len(data)
+	default:
+		size = len(EncodeBytes(data))
+	}
+	if size > max {
+		return nil, ErrValueTooLarge
+	}
+	return v, nil
+}
+
+// scanCount is the SCAN batch size used when deleting key families.
+const scanCount = 100
+
+// memorySample is how many keys the redis caches pass to MEMORY USAGE to
+// estimate the memory of all their keys.
+const memorySample = 100
+
+// memoryEstimate extrapolates to n keys the bytes used by sampled of them.
+func memoryEstimate(n, sampled, bytes int64) int64 {
+	if sampled == 0 {
+		return 0
+	}
+	return bytes * n / sampled
+}
+
+// escapeGlob escapes the redis glob special characters of s. It works on
+// bytes, keys need not be valid UTF-8.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// globMatch reports whether s matches the redis glob pattern (*, ?, [...]
+// and \ escapes). Like redis it compares bytes, so binary keys match the
+// bytes of the pattern.
+func globMatch(pattern, s string) bool {
+	// star and next are where to resume after a mismatch, matching one more
+	// byte with the last *
+	star, next := -1, 0
+	p, i := 0, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch c := pattern[p]; c {
+			case '*':
+				star, next = p, i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if end, ok := globClass(pattern, p, s[i]); end > 0 {
+					if ok {
+						p = end
+						i++
+						continue
+					}
+					break
+				}
+				if s[i] == '[' {
+					p++
+					i++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) {
+					c = pattern[p+1]
+					if s[i] == c {
+						p += 2
+						i++
+						continue
+					}
+					break
+				}
+				fallthrough
+			default:
+				if s[i] == c {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		next++
+		p, i = star+1, next
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// globClass matches b against the class starting with the '[' at
+// pattern[p], returning the index after its ']', 0 when it is not closed.
+func globClass(pattern string, p int, b byte) (int, bool) {
+	p++
+	not := p < len(pattern) && pattern[p] == '^'
+	if not {
+		p++
+	}
+	match := false
+	for ; p < len(pattern) && pattern[p] != ']'; p++ {
+		c := pattern[p]
+		if c == '\\' && p+1 < len(pattern) {
+			p++
+			c = pattern[p]
+		}
+		if p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']' {
+			lo, hi := c, pattern[p+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			match = match || (b >= lo && b <= hi)
+			p += 2
+			continue
+		}
+		match = match || b == c
+	}
+	if p >= len(pattern) {
+		return 0, false
+	}
+	return p + 1, match != not
+}
+
+// IRange is implemented by caches that can iterate over their entries.
+type IRange interface {
+	Range(fn func(key string, value interface{}) bool)
+}
+
+// IForEach is implemented by caches that can iterate over their entries with
+// their expiration.
+type IForEach interface {
+	ForEach(fn func(key string, value interface{}, expireAt time.Time) bool) error
+}
+
+// IRangeTTL is implemented by caches that can iterate over their entries
+// with their remaining ttl, 0 for entries that do not expire.
+type IRangeTTL interface {
+	RangeTTL(fn func(key string, value interface{}, ttl time.Duration) bool) error
+}
+
+// IMulti is implemented by caches that read and write several keys in one
+// round trip. MGet returns the values in the order of keys, nil for the
+// missing ones.
+type IMulti interface {
+	MGet(keys ...string) ([]interface{}, error)
+	MSet(entries map[string]interface{}) error
+	MDel(keys ...string) error
+}
+
+// IBatch is implemented by caches that can send several commands in one
+// round trip.
+type IBatch interface {
+	Pipeline() Batch
+	TxPipeline() Batch
+}
+
+// ITx is implemented by caches that apply a group of writes atomically.
+type ITx interface {
+	Tx(fn func(tx TxCache) error) error
+}
+
+// IHash is implemented by caches that support field level hash entries.
+type IHash interface {
+	HSet(key, field string, value interface{}) error
+	HGet(key, field string) (interface{}, error)
+	HGetAll(key string) (map[string]interface{}, error)
+}
+
+// IList is implemented by caches that support list entries.
+type IList interface {
+	LPush(key string, values ...interface{}) error
+	RPop(key string) (interface{}, error)
+	LRange(key string, start, stop int64) ([]interface{}, error)
+}
+
+// ISet is implemented by caches that support set entries.
+type ISet interface {
+	SAdd(key string, members ...interface{}) error
+	SIsMember(key string, member interface{}) (bool, error)
+	SMembers(key string) ([]interface{}, error)
+}
+
+// Z is a member of a sorted set entry.
+type Z struct {
+	Score  float64
+	Member interface{}
+}
+
+// IZSet is implemented by caches that support sorted set entries.
+type IZSet interface {
+	ZAdd(key string, members ...Z) error
+	ZRangeByScore(key string, min, max float64) ([]Z, error)
+}
+
+// IAppend is implemented by caches that can append to string values.
+type IAppend interface {
+	Append(key, suffix string) error
+}
+
+// IDelPattern is implemented by caches that can delete key families.
+type IDelPattern interface {
+	DelByPrefix(prefix string) error
+	DelByPattern(pattern string) error
+}
+
+// IClear is implemented by caches that can delete every entry under their
+// key prefix.
+type IClear interface {
+	Clear() error
+}
+
+// IContext is implemented by caches taking the context of each operation,
+// e.g. to start its tracing span as a child of the span of the request.
+type IContext interface {
+	GetCtx(ctx context.Context, key string) (interface{}, error)
+	SetCtx(ctx context.Context, key string, value interface{}) error
+	SetWithTTLCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	DelCtx(ctx context.Context, key string) error
+}
+
+// IRename is implemented by caches that can move an entry to a new key.
+type IRename interface {
+	Rename(oldKey, newKey string) error
+}
+
+// ICost is implemented by caches that evict entries by cost.
+type ICost interface {
+	SetWithCost(key string, value interface{}, cost int64) error
+}
+
+// IVersioned is implemented by caches that can drop all their entries at
+// once by moving to a new key version.
+type IVersioned interface {
+	BumpVersion() error
+}
+
+// IDepend is implemented by caches that invalidate an entry with the entries
+// it depends on.
+type IDepend interface {
+	AddDependency(key string, deps ...string) error
+}
+
+// IWarm is implemented by caches that can store a batch of entries in one
+// round trip.
+type IWarm interface {
+	Warm(ctx context.Context, entries map[string]ValueWithTTL) error
+}
+
+// ISnapshot is implemented by caches that can persist their entries.
+type ISnapshot interface {
+	Save(w io.Writer) error
+	Load(r io.Reader) error
+}
+
+// IPing is implemented by caches that can check they are able to serve
+// requests, e.g. that their backend is reachable.
+type IPing interface {
+	Ping(ctx context.Context) error
+}
+
+// ping pings c, assumed healthy when it can not be pinged.
+func ping(ctx context.Context, c ICache) error {
+	if p, ok := c.(IPing); ok {
+		if err := p.Ping(ctx); err != ErrNotSupported {
+			return err
+		}
+	}
+	return nil
+}
+
+// ICloser is implemented by caches holding resources, e.g. goroutines,
+// connections or the clients they were built with, released by Close. The
+// databases given to the SQL, bolt and badger caches are left open as they
+// usually hold more than the cache.
+type ICloser interface {
+	Close() error
+}
+
+// closeCache closes c, doing nothing when it holds nothing to release.
+func closeCache(c ICache) error {
+	if cl, ok := c.(ICloser); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// ICount is implemented by caches that can count their entries.
+type ICount interface {
+	Count() (int, error)
+}
+
+// IMemory is implemented by caches that can estimate the memory they use.
+type IMemory interface {
+	MemoryUsage() (int64, error)
+}
+
+// IInfo is implemented by caches that can describe their content.
+type IInfo interface {
+	Info() CacheInfo
+}
+
+// LoaderFunc loads the value of key when it is missing from the cache.
+type LoaderFunc func(key string) (interface{}, error)
+
 type Cache struct {
-	cache ICache
+	stats   cacheStats // first, for the alignment of its counters
+	cache   ICache
+	flight  flightGroup
+	missErr bool
+	clk     Clock
+}
+
+// missErrorer is implemented by the caches returning ErrCacheMiss on a miss.
+type missErrorer interface {
+	missError() bool
 }
 
 func NewCache(c ICache) *Cache {
-	return &Cache{cache: c}
+	ret := &Cache{cache: c, clk: ClockOf(c)}
+	if m, ok := c.(missErrorer); ok {
+		ret.missErr = m.missError()
+	}
+	return ret
+}
+
+func (c *Cache) missError() bool {
+	return c.missErr
+}
+
+func (c *Cache) Clock() Clock {
+	return c.clk
+}
+
+// found reports whether value, err returned by Get is a cached value. A nil
+// value is a miss unless the cache returns ErrCacheMiss on a miss.
+func (c *Cache) found(value interface{}, err error) (bool, error) {
+	switch {
+	case err == ErrCacheMiss:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return value != nil || c.missErr, nil
 }
 
 func (c *Cache) Set(key string, value interface{}) error {
-	return c.cache.Set(key, value)
+	err := c.cache.Set(key, value)
+	c.stats.write(1, err)
+	return err
 }
 
 func (c *Cache) SetWithExpire(key string, value interface{}, expireSec int) error {
-	return c.cache.SetWithExpire(key, value, expireSec)
+	err := c.cache.SetWithExpire(key, value, expireSec)
+	c.stats.write(1, err)
+	return err
+}
+
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	err := c.cache.SetWithTTL(key, value, ttl)
+	c.stats.write(1, err)
+	return err
 }
 
 func (c *Cache) Get(key string) (interface{}, error) {
-	return c.cache.Get(key)
+	value, err := c.cache.Get(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
 }
 
 func (c *Cache) GetInt(key string) (*int64, error) {
-	return c.cache.GetInt(key)
+	value, err := c.cache.GetInt(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
+}
+
+func (c *Cache) GetUint(key string) (*uint64, error) {
+	value, err := c.cache.GetUint(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
 }
 
 func (c *Cache) GetFloat(key string) (*float64, error) {
-	return c.cache.GetFloat(key)
+	value, err := c.cache.GetFloat(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
 }
 
 func (c *Cache) GetBool(key string) (*bool, error) {
-	return c.cache.GetBool(key)
+	value, err := c.cache.GetBool(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
 }
 
 func (c *Cache) GetString(key string) (string, error) {
-	return c.cache.GetString(key)
+	value, err := c.cache.GetString(key)
+	c.stats.read(value == "" && !c.missErr, err)
+	return value, err
 }
 
 func (c *Cache) GetBytes(key string) ([]byte, error) {
-	return c.cache.GetBytes(key)
+	value, err := c.cache.GetBytes(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
+}
+
+func (c *Cache) GetTime(key string) (*time.Time, error) {
+	value, err := c.cache.GetTime(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
+}
+
+func (c *Cache) GetDuration(key string) (*time.Duration, error) {
+	value, err := c.cache.GetDuration(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
+}
+
+func (c *Cache) GetStringSlice(key string) ([]string, error) {
+	value, err := c.cache.GetStringSlice(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
+}
+
+func (c *Cache) GetIntSlice(key string) ([]int64, error) {
+	value, err := c.cache.GetIntSlice(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
+}
+
+// GetIntOrDefault returns the int value of key, or def when the key is not
+// cached, is not an int or can not be read.
+func (c *Cache) GetIntOrDefault(key string, def int64) int64 {
+	value, err := c.GetInt(key)
+	if value == nil || err != nil {
+		return def
+	}
+	return *value
+}
+
+// GetStringOrDefault returns the string value of key, or def when the key is
+// not cached, is an empty string or can not be read.
+func (c *Cache) GetStringOrDefault(key string, def string) string {
+	value, err := c.GetString(key)
+	if value == "" || err != nil {
+		return def
+	}
+	return value
+}
+
+// GetBoolOrDefault returns the bool value of key, or def when the key is not
+// cached, is not a bool or can not be read.
+func (c *Cache) GetBoolOrDefault(key string, def bool) bool {
+	value, err := c.GetBool(key)
+	if value == nil || err != nil {
+		return def
+	}
+	return *value
 }
 
 func (c *Cache) Del(key string) error {
-	return c.cache.Del(key)
+	err := c.cache.Del(key)
+	c.stats.del(1, err)
+	return err
+}
+
+// GetCtx is Get passing ctx to the underlying cache when it takes the
+// context of each operation, e.g. a TracedCache.
+func (c *Cache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	cc, ok := c.cache.(IContext)
+	if !ok {
+		return c.Get(key)
+	}
+	value, err := cc.GetCtx(ctx, key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, err
+}
+
+// SetCtx is Set passing ctx to the underlying cache, see GetCtx.
+func (c *Cache) SetCtx(ctx context.Context, key string, value interface{}) error {
+	cc, ok := c.cache.(IContext)
+	if !ok {
+		return c.Set(key, value)
+	}
+	err := cc.SetCtx(ctx, key, value)
+	c.stats.write(1, err)
+	return err
+}
+
+// SetWithTTLCtx is SetWithTTL passing ctx to the underlying cache, see
+// GetCtx.
+func (c *Cache) SetWithTTLCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	cc, ok := c.cache.(IContext)
+	if !ok {
+		return c.SetWithTTL(key, value, ttl)
+	}
+	err := cc.SetWithTTLCtx(ctx, key, value, ttl)
+	c.stats.write(1, err)
+	return err
+}
+
+// DelCtx is Del passing ctx to the underlying cache, see GetCtx.
+func (c *Cache) DelCtx(ctx context.Context, key string) error {
+	cc, ok := c.cache.(IContext)
+	if !ok {
+		return c.Del(key)
+	}
+	err := cc.DelCtx(ctx, key)
+	c.stats.del(1, err)
+	return err
+}
+
+// GetOrSet returns the cached value of key. On a miss the loader is called
+// and its result is stored. Concurrent misses on the same key share a single
+// loader invocation. An expired value is not kept, so a failing loader fails
+// GetOrSet: StaleCache with StaleWithStaleIfError serves it instead.
+func (c *Cache) GetOrSet(key string, loader LoaderFunc) (interface{}, error) {
+	value, err := c.Get(key)
+	if ok, err := c.found(value, err); ok || err != nil {
+		return value, err
+	}
+	return c.flight.Do(key, func() (interface{}, error) {
+		value, err := c.stats.load(key, loader)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Set(key, value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	})
+}
+
+// Range calls fn for each entry of the cache until fn returns false. It
+// returns ErrNotSupported if the underlying cache can not be iterated.
+func (c *Cache) Range(fn func(key string, value interface{}) bool) error {
+	r, ok := c.cache.(IRange)
+	if !ok {
+		return ErrNotSupported
+	}
+	r.Range(fn)
+	return nil
+}
+
+// MGet returns the values of keys in their order, nil for the missing ones,
+// in one round trip when the underlying cache implements IMulti and with a
+// Get per key otherwise.
+func (c *Cache) MGet(keys ...string) ([]interface{}, error) {
+	if m, ok := c.cache.(IMulti); ok {
+		values, err := m.MGet(keys...)
+		if err != nil {
+			c.stats.read(false, err)
+			return nil, err
+		}
+		for _, value := range values {
+			c.stats.read(value == nil, nil)
+		}
+		return values, nil
+	}
+	ret := make([]interface{}, len(keys))
+	for i, key := range keys {
+		value, err := c.Get(key)
+		if err != nil && err != ErrCacheMiss {
+			return nil, err
+		}
+		ret[i] = value
+	}
+	return ret, nil
+}
+
+// mgetEach calls fn with each key found by MGet and the typed getters of its
+// value, returning the errors of fn by key.
+func (c *Cache) mgetEach(keys []string, fn func(key string, g typedGetters) error) (map[string]error, error) {
+	values, err := c.MGet(keys...)
+	if err != nil {
+		return nil, err
+	}
+	errs := map[string]error{}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if err := fn(keys[i], gettersOf(value)); err != nil {
+			errs[keys[i]] = err
+		}
+	}
+	return errs, nil
+}
+
+// gettersOf returns the typed getters of a value returned by Get, encoded as
+// a string or []byte by the backends storing bytes, else stored as it is.
+func gettersOf(value interface{}) typedGetters {
+	if s, ok := value.(string); ok {
+		value = []byte(s)
+	}
+	get := func(string) (interface{}, error) {
+		return value, nil
+	}
+	if _, ok := value.([]byte); ok {
+		return ByteGetters{get: get}
+	}
+	return ValueGetters{get: get}
+}
+
+// MGetInt returns the integers of keys by key, fetched as MGet does, and the
+// errors of the values that are not integers. Missing keys are in neither.
+func (c *Cache) MGetInt(keys ...string) (map[string]int64, map[string]error, error) {
+	ret := make(map[string]int64, len(keys))
+	errs, err := c.mgetEach(keys, func(key string, g typedGetters) error {
+		v, err := g.GetInt(key)
+		if err != nil {
+			return err
+		}
+		ret[key] = *v
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return ret, errs, nil
+}
+
+// MGetString returns the strings of keys by key, fetched as MGet does, and
+// the errors of the values that are not strings. Missing keys are in
+// neither.
+func (c *Cache) MGetString(keys ...string) (map[string]string, map[string]error, error) {
+	ret := make(map[string]string, len(keys))
+	errs, err := c.mgetEach(keys, func(key string, g typedGetters) error {
+		v, err := g.GetString(key)
+		if err != nil {
+			return err
+		}
+		ret[key] = v
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return ret, errs, nil
+}
+
+// MSet stores entries with the default expiration, in one round trip when
+// the underlying cache implements IMulti.
+func (c *Cache) MSet(entries map[string]interface{}) error {
+	if m, ok := c.cache.(IMulti); ok {
+		err := m.MSet(entries)
+		c.stats.write(len(entries), err)
+		return err
+	}
+	for key, value := range entries {
+		if err := c.Set(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MDel removes keys, in one round trip when the underlying cache implements
+// IMulti.
+func (c *Cache) MDel(keys ...string) error {
+	if m, ok := c.cache.(IMulti); ok {
+		err := m.MDel(keys...)
+		c.stats.del(len(keys), err)
+		return err
+	}
+	for _, key := range keys {
+		if err := c.Del(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RangeTTL calls fn for each entry with its remaining ttl until fn returns
+// false. It returns ErrNotSupported if the underlying cache can not iterate
+// with ttls.
+func (c *Cache) RangeTTL(fn func(key string, value interface{}, ttl time.Duration) bool) error {
+	r, ok := c.cache.(IRangeTTL)
+	if !ok {
+		return ErrNotSupported
+	}
+	return r.RangeTTL(fn)
+}
+
+// ForEach calls fn for each entry with its expiration, zero when it does not
+// expire, until fn returns false, e.g. to export the entries. It returns
+// ErrNotSupported if the underlying cache can not iterate with expirations.
+func (c *Cache) ForEach(fn func(key string, value interface{}, expireAt time.Time) bool) error {
+	f, ok := c.cache.(IForEach)
+	if !ok {
+		return ErrNotSupported
+	}
+	return f.ForEach(fn)
+}
+
+// HSet sets field of the hash stored at key. It returns ErrNotSupported if
+// the underlying cache has no hash entries.
+func (c *Cache) HSet(key, field string, value interface{}) error {
+	h, ok := c.cache.(IHash)
+	if !ok {
+		return ErrNotSupported
+	}
+	return h.HSet(key, field, value)
+}
+
+// HGet returns field of the hash stored at key, nil if it does not exist.
+func (c *Cache) HGet(key, field string) (interface{}, error) {
+	h, ok := c.cache.(IHash)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return h.HGet(key, field)
+}
+
+// HGetAll returns all fields of the hash stored at key, nil if it does not
+// exist.
+func (c *Cache) HGetAll(key string) (map[string]interface{}, error) {
+	h, ok := c.cache.(IHash)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return h.HGetAll(key)
+}
+
+// LPush inserts values at the head of the list stored at key. It returns
+// ErrNotSupported if the underlying cache has no list entries.
+func (c *Cache) LPush(key string, values ...interface{}) error {
+	l, ok := c.cache.(IList)
+	if !ok {
+		return ErrNotSupported
+	}
+	return l.LPush(key, values...)
+}
+
+// RPop removes and returns the last element of the list stored at key, nil if
+// the list is empty.
+func (c *Cache) RPop(key string) (interface{}, error) {
+	l, ok := c.cache.(IList)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return l.RPop(key)
+}
+
+// LRange returns the elements of the list stored at key between start and
+// stop inclusive, negative offsets count from the end like redis LRANGE.
+func (c *Cache) LRange(key string, start, stop int64) ([]interface{}, error) {
+	l, ok := c.cache.(IList)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return l.LRange(key, start, stop)
+}
+
+// SAdd adds members to the set stored at key. It returns ErrNotSupported if
+// the underlying cache has no set entries.
+func (c *Cache) SAdd(key string, members ...interface{}) error {
+	st, ok := c.cache.(ISet)
+	if !ok {
+		return ErrNotSupported
+	}
+	return st.SAdd(key, members...)
+}
+
+// SIsMember reports whether member is in the set stored at key.
+func (c *Cache) SIsMember(key string, member interface{}) (bool, error) {
+	st, ok := c.cache.(ISet)
+	if !ok {
+		return false, ErrNotSupported
+	}
+	return st.SIsMember(key, member)
+}
+
+// SMembers returns all members of the set stored at key, nil if it does not
+// exist.
+func (c *Cache) SMembers(key string) ([]interface{}, error) {
+	st, ok := c.cache.(ISet)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return st.SMembers(key)
+}
+
+// ZAdd adds members to the sorted set stored at key, updating the score of
+// existing members. It returns ErrNotSupported if the underlying cache has no
+// sorted set entries.
+func (c *Cache) ZAdd(key string, members ...Z) error {
+	z, ok := c.cache.(IZSet)
+	if !ok {
+		return ErrNotSupported
+	}
+	return z.ZAdd(key, members...)
+}
+
+// ZRangeByScore returns the members of the sorted set stored at key with a
+// score between min and max inclusive, ordered by score.
+func (c *Cache) ZRangeByScore(key string, min, max float64) ([]Z, error) {
+	z, ok := c.cache.(IZSet)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return z.ZRangeByScore(key, min, max)
+}
+
+// Append atomically appends suffix to the string value stored at key,
+// creating it if it does not exist. It returns ErrNotSupported if the
+// underlying cache can not append.
+func (c *Cache) Append(key, suffix string) error {
+	a, ok := c.cache.(IAppend)
+	if !ok {
+		return ErrNotSupported
+	}
+	return a.Append(key, suffix)
+}
+
+// DelByPrefix deletes every key starting with prefix. It returns
+// ErrNotSupported if the underlying cache can not delete by pattern.
+func (c *Cache) DelByPrefix(prefix string) error {
+	d, ok := c.cache.(IDelPattern)
+	if !ok {
+		return ErrNotSupported
+	}
+	return d.DelByPrefix(prefix)
+}
+
+// DelByPattern deletes every key matching the redis glob pattern, e.g.
+// "user:42:*".
+func (c *Cache) DelByPattern(pattern string) error {
+	d, ok := c.cache.(IDelPattern)
+	if !ok {
+		return ErrNotSupported
+	}
+	return d.DelByPattern(pattern)
+}
+
+// Clear deletes every entry of the cache. With a key prefix only the keys
+// under it are deleted, leaving those of the other prefixes sharing the
+// backend. It returns ErrNotSupported if the underlying cache can not list
+// its keys.
+func (c *Cache) Clear() error {
+	cl, ok := c.cache.(IClear)
+	if !ok {
+		return ErrNotSupported
+	}
+	return cl.Clear()
+}
+
+// Rename moves the entry of oldKey to newKey keeping its value and
+// expiration, replacing any entry at newKey. It does nothing if oldKey does not
+// exist and returns ErrNotSupported if the underlying cache can not rename.
+func (c *Cache) Rename(oldKey, newKey string) error {
+	r, ok := c.cache.(IRename)
+	if !ok {
+		return ErrNotSupported
+	}
+	return r.Rename(oldKey, newKey)
+}
+
+// Count returns the number of entries of the cache, for capacity monitoring.
+// It returns ErrNotSupported if the underlying cache can not count them.
+func (c *Cache) Count() (int, error) {
+	n, ok := c.cache.(ICount)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	return n.Count()
+}
+
+// MemoryUsage returns an estimate of the bytes used by the entries of the
+// cache, to alert before eviction or out of memory. It returns
+// ErrNotSupported if the underlying cache can not estimate it.
+func (c *Cache) MemoryUsage() (int64, error) {
+	m, ok := c.cache.(IMemory)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	return m.MemoryUsage()
+}
+
+// SetWithCost stores value charging cost against the cache capacity. It
+// returns ErrNotSupported if the underlying cache has no cost based eviction.
+func (c *Cache) SetWithCost(key string, value interface{}, cost int64) error {
+	cc, ok := c.cache.(ICost)
+	if !ok {
+		return ErrNotSupported
+	}
+	err := cc.SetWithCost(key, value, cost)
+	c.stats.write(1, err)
+	return err
+}
+
+// AddDependency declares that key is built from deps, so writing or deleting
+// any of them deletes key. It returns ErrNotSupported if the underlying cache
+// does not track dependencies.
+func (c *Cache) AddDependency(key string, deps ...string) error {
+	d, ok := c.cache.(IDepend)
+	if !ok {
+		return ErrNotSupported
+	}
+	return d.AddDependency(key, deps...)
+}
+
+// BumpVersion invalidates every entry of a versioned cache. It returns
+// ErrNotSupported if the underlying cache is not versioned.
+func (c *Cache) BumpVersion() error {
+	v, ok := c.cache.(IVersioned)
+	if !ok {
+		return ErrNotSupported
+	}
+	return v.BumpVersion()
+}
+
+// Ping checks the cache is able to serve requests, for readiness probes. It
+// returns ErrNotSupported if the underlying cache can not be checked.
+func (c *Cache) Ping(ctx context.Context) error {
+	p, ok := c.cache.(IPing)
+	if !ok {
+		return ErrNotSupported
+	}
+	return p.Ping(ctx)
+}
+
+// Close releases the resources of the underlying cache, stopping its
+// goroutines and closing its clients. The caches wrapping other caches close
+// them too. Caches holding nothing to release are closed doing nothing.
+func (c *Cache) Close() error {
+	return closeCache(c.cache)
+}
+
+// Save writes the entries of the cache to w. It returns ErrNotSupported if
+// the underlying cache can not be saved.
+func (c *Cache) Save(w io.Writer) error {
+	s, ok := c.cache.(ISnapshot)
+	if !ok {
+		return ErrNotSupported
+	}
+	return s.Save(w)
+}
+
+// Load adds the entries saved to r to the cache. It returns ErrNotSupported
+// if the underlying cache can not be loaded.
+func (c *Cache) Load(r io.Reader) error {
+	s, ok := c.cache.(ISnapshot)
+	if !ok {
+		return ErrNotSupported
+	}
+	return s.Load(r)
 }
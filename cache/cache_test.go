@@ -0,0 +1,318 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrSetSingleflight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	var calls int32
+	loader := func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		return 3, nil
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := c.GetOrSet("test:123", loader)
+			if err != nil || data != 3 {
+				t.Errorf("%v value error:%v", data, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("%v loader calls", calls)
+		return
+	}
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"user:42:*", "user:42:name", true},
+		{"user:42:*", "user:421:name", false},
+		{"user:?:name", "user:4:name", true},
+		{"user:[0-9]:name", "user:4:name", true},
+		{"user:[^0-9]:name", "user:4:name", false},
+		{"user:\\*", "user:*", true},
+		{"user:\\*", "user:42", false},
+		{escapeGlob("a.b[c]*") + "*", "a.b[c]*:1", true},
+		{"*:name", "user:42:name", true},
+		{"*:name", "user:42:names", false},
+		{"user:[a-c]*", "user:b", true},
+		{"user:[a\\]]", "user:]", true},
+		{escapeGlob("\xff\x00*") + "*", "\xff\x00*\x80", true},
+		{"\xff?", "\xfe\x80", false},
+		{"?\x80", "\xfe\x80", true},
+	}
+	for _, x := range cases {
+		if globMatch(x.pattern, x.key) != x.match {
+			t.Errorf("%v %v match error", x.pattern, x.key)
+		}
+	}
+}
+
+func TestEncodeBytes(t *testing.T) {
+	cases := map[string]interface{}{
+		"1":                    true,
+		"0":                    false,
+		"3":                    3,
+		"3.5":                  3.5,
+		"test":                 "test",
+		"1500000000":           1500 * time.Millisecond,
+		`["a","b"]`:            []string{"a", "b"},
+		"18446744073709551615": uint64(18446744073709551615),
+	}
+	for want, v := range cases {
+		if data := string(EncodeBytes(v)); data != want {
+			t.Errorf("%v value error:%v", v, data)
+		}
+	}
+}
+
+func TestSetCostNotSupported(t *testing.T) {
+	c := NewCache(&SyncMapCache{})
+	if err := c.SetWithCost("test:123", 3, 1); err != ErrNotSupported {
+		t.Errorf("%v error", err)
+		return
+	}
+}
+
+func TestGetOrSetWithTTLEarly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithAbsoluteExpire())
+	var calls int32
+	loader := func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return 3, nil
+	}
+	data, err := c.GetOrSetWithTTL("test:123", 10*time.Second, loader)
+	if err != nil || data != 3 {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+	for i := 0; i < 10; i++ {
+		c.GetOrSetWithTTL("test:123", 10*time.Second, loader)
+	}
+	if calls != 1 {
+		t.Errorf("%v loader calls", calls)
+		return
+	}
+	// the cost is about as long as the remaining ttl, readers recompute
+	c.SetWithTTL("test:123"+xfetchSuffix, "10000000000:"+strconv.FormatInt(time.Now().Add(10*time.Millisecond).UnixNano(), 10), 10*time.Second)
+	for i := 0; i < 10; i++ {
+		c.GetOrSetWithTTL("test:123", 10*time.Second, loader)
+	}
+	if calls < 2 {
+		t.Errorf("%v loader calls", calls)
+		return
+	}
+}
+
+func TestGetOrSetWithTTLEarlyError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithAbsoluteExpire())
+	c.SetWithTTL("test:123", 3, 10*time.Second)
+	// the cost is about as long as the remaining ttl, readers recompute
+	c.SetWithTTL("test:123"+xfetchSuffix, "10000000000:"+strconv.FormatInt(time.Now().Add(10*time.Millisecond).UnixNano(), 10), 10*time.Second)
+	loader := func(key string) (interface{}, error) {
+		return nil, errors.New("load error")
+	}
+	for i := 0; i < 10; i++ {
+		data, err := c.GetOrSetWithTTL("test:123", 10*time.Second, loader)
+		if err != nil || data != 3 {
+			t.Errorf("%v value error:%v", data, err)
+			return
+		}
+	}
+	if s := c.Stats(); s.LoadErrors == 0 {
+		t.Errorf("%+v value error", s)
+		return
+	}
+}
+
+func TestCacheMultiFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	c.MSet(map[string]interface{}{"test:1": 1, "test:2": 2})
+	values, err := c.MGet("test:1", "test:missing", "test:2")
+	if err != nil || len(values) != 3 || values[0] != 1 || values[1] != nil || values[2] != 2 {
+		t.Errorf("%v %v value error", values, err)
+		return
+	}
+	c.MDel("test:1", "test:2")
+	values, _ = c.MGet("test:1", "test:2")
+	if values[0] != nil || values[1] != nil {
+		t.Errorf("%v value error", values)
+		return
+	}
+}
+
+func testMGetTyped(t *testing.T, c *Cache) {
+	c.MSet(map[string]interface{}{"test:1": 1, "test:2": "2", "test:3": "abc"})
+	ints, errs, err := c.MGetInt("test:1", "test:2", "test:3", "test:missing")
+	if err != nil || len(ints) != 2 || ints["test:1"] != 1 || ints["test:2"] != 2 {
+		t.Errorf("%v %v value error", ints, err)
+		return
+	}
+	if len(errs) != 1 || errs["test:3"] == nil {
+		t.Errorf("%v error", errs)
+		return
+	}
+	strs, errs, err := c.MGetString("test:2", "test:3", "test:missing")
+	if err != nil || len(errs) != 0 || len(strs) != 2 || strs["test:2"] != "2" || strs["test:3"] != "abc" {
+		t.Errorf("%v %v %v value error", strs, errs, err)
+		return
+	}
+	c.MDel("test:1", "test:2", "test:3")
+}
+
+// testClear checks that Clear of a deletes its keys and leaves those of b,
+// a cache on the same backend with another key prefix.
+func testClear(t *testing.T, a, b *Cache) {
+	a.Set("test:123", 1)
+	b.Set("test:123", 2)
+	if err := a.Clear(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := a.GetInt("test:123"); data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+	if data, _ := b.GetInt("test:123"); data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	b.Del("test:123")
+}
+
+func TestCacheMGetTyped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	testMGetTyped(t, NewLocalCache(ctx, LocalWithEncoding()))
+	testMGetTyped(t, NewTieredCache(NewLocalCache(ctx), NewLocalCache(ctx, LocalWithEncoding())))
+}
+
+func TestCachePipelineNotSupported(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx)
+	if _, err := c.Pipeline(); err != ErrNotSupported {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	c.Set("test:1", 1)
+	c.GetInt("test:1")
+	c.GetInt("test:missing")
+	c.GetString("test:missing")
+	c.Del("test:1")
+	c.GetOrSet("test:2", func(key string) (interface{}, error) {
+		time.Sleep(10 * time.Millisecond)
+		return 2, nil
+	})
+	c.GetOrSet("test:2", func(key string) (interface{}, error) {
+		return 3, nil
+	})
+	s := c.Stats()
+	if s.Hits != 2 || s.Misses != 3 || s.Sets != 2 || s.Dels != 1 || s.Errors != 0 {
+		t.Errorf("%+v value error", s)
+		return
+	}
+	if s.Loads != 1 || s.LoadErrors != 0 || s.MaxLoadTime < 10*time.Millisecond || s.AvgLoadTime() != s.LoadTime {
+		t.Errorf("%+v value error", s)
+		return
+	}
+	if r := s.HitRatio(); r != 0.4 {
+		t.Errorf("%v value error", r)
+	}
+}
+
+func TestCacheStatsMissError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10), LocalWithMissError())
+	c.Set("test:empty", "")
+	c.GetString("test:empty")
+	c.GetString("test:missing")
+	c.GetBytes("test:empty")
+	c.GetBytes("test:missing")
+	if s := c.Stats(); s.Hits != 2 || s.Misses != 2 || s.Errors != 0 {
+		t.Errorf("%+v value error", s)
+	}
+}
+
+func TestGetOrDefault(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	c.Set("test:int", 3)
+	c.Set("test:string", "a")
+	c.Set("test:bool", true)
+	c.Set("test:other", []string{"a"})
+	if v := c.GetIntOrDefault("test:int", 1); v != 3 {
+		t.Errorf("%v value error", v)
+	}
+	if v := c.GetStringOrDefault("test:string", "b"); v != "a" {
+		t.Errorf("%v value error", v)
+	}
+	if v := c.GetBoolOrDefault("test:bool", false); !v {
+		t.Errorf("%v value error", v)
+	}
+	for _, key := range []string{"test:miss", "test:other"} {
+		if v := c.GetIntOrDefault(key, 1); v != 1 {
+			t.Errorf("%v value error", v)
+		}
+		if v := c.GetBoolOrDefault(key, true); !v {
+			t.Errorf("%v value error", v)
+		}
+	}
+	if v := c.GetStringOrDefault("test:miss", "b"); v != "b" {
+		t.Errorf("%v value error", v)
+	}
+}
+
+func TestEncodeValueMax(t *testing.T) {
+	for _, v := range []interface{}{"abcd", []byte("abcd"), 1234, []int{1}} {
+		if _, err := encodeValueMax(v, 4); err != nil {
+			t.Errorf("%v %v value error", v, err)
+		}
+		if _, err := encodeValueMax(v, 0); err != nil {
+			t.Errorf("%v %v value error", v, err)
+		}
+	}
+	for _, v := range []interface{}{"abcde", []byte("abcde"), 12345, []int{1, 2}} {
+		if _, err := encodeValueMax(v, 4); err != ErrValueTooLarge {
+			t.Errorf("%v %v value error", v, err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Package cachetest holds the checks shared by the tests of the cache
+// backends.
+package cachetest
+
+import (
+	"testing"
+
+	"mcache/cache"
+)
+
+// Clear checks that Clear of a deletes its keys and leaves those of b, a
+// cache on the same backend with another key prefix.
+func Clear(t *testing.T, a, b *cache.Cache) {
+	a.Set("test:123", 1)
+	b.Set("test:123", 2)
+	if err := a.Clear(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := a.GetInt("test:123"); data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+	if data, _ := b.GetInt("test:123"); data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	b.Del("test:123")
+}
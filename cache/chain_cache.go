@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// ChainPolicy is what a ChainCache does when a tier fails.
+type ChainPolicy int
+
+const (
+	// ChainSkipOnError treats a failing read as a miss and ignores a failing
+	// write, so an unreachable tier does not fail the chain.
+	ChainSkipOnError ChainPolicy = iota
+	// ChainFailOnError returns the error of the tier.
+	ChainFailOnError
+)
+
+// ChainCache reads from an ordered list of caches, e.g. local, redis then
+// memcached, returning the value of the first one that has it. Writes and
+// deletes go to every tier, the last one first.
+type ChainCache struct {
+	tiers    []ICache
+	policies []ChainPolicy
+	backfill bool
+}
+
+type ChainOption func(c *ChainCache)
+
+// ChainWithBackfill stores a value read from a tier in the tiers before it.
+// Pointer results are stored dereferenced.
+func ChainWithBackfill() ChainOption {
+	return func(c *ChainCache) {
+		c.backfill = true
+	}
+}
+
+// ChainWithErrorPolicy sets the policy of the tier at index tier,
+// ChainSkipOnError by default.
+func ChainWithErrorPolicy(tier int, policy ChainPolicy) ChainOption {
+	return func(c *ChainCache) {
+		if tier >= 0 && tier < len(c.policies) {
+			c.policies[tier] = policy
+		}
+	}
+}
+
+// NewChainCache returns a cache reading tiers in order. Any may be a *Cache.
+func NewChainCache(tiers []ICache, opts ...ChainOption) *Cache {
+	c := &ChainCache{
+		tiers:    tiers,
+		policies: make([]ChainPolicy, len(tiers)),
+	}
+	for _, fn := range opts {
+		fn(c)
+	}
+	return NewCache(c)
+}
+
+// write calls fn on every tier, the last one first, stopping at the first
+// error of a ChainFailOnError tier.
+func (c *ChainCache) write(fn func(ICache) error) error {
+	for i := len(c.tiers) - 1; i >= 0; i-- {
+		if err := fn(c.tiers[i]); err != nil && c.policies[i] == ChainFailOnError {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ChainCache) Set(key string, value interface{}) error {
+	return c.write(func(t ICache) error { return t.Set(key, value) })
+}
+
+func (c *ChainCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *ChainCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.write(func(t ICache) error { return t.SetWithTTL(key, value, ttl) })
+}
+
+// get calls get on each tier until one has key, back-filling the tiers
+// before it. A miss returns nil.
+func (c *ChainCache) get(key string, get func(ICache) (interface{}, error)) (interface{}, error) {
+	for i, t := range c.tiers {
+		value, err := get(t)
+		if err != nil {
+			if c.policies[i] == ChainFailOnError {
+				return nil, err
+			}
+			continue
+		}
+		if tieredMiss(value) {
+			continue
+		}
+		if c.backfill && i > 0 {
+			fill := value
+			if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr {
+				fill = v.Elem().Interface()
+			}
+			for j := i - 1; j >= 0; j-- {
+				c.tiers[j].Set(key, fill)
+			}
+		}
+		return value, nil
+	}
+	return nil, nil
+}
+
+func (c *ChainCache) Get(key string) (interface{}, error) {
+	return c.get(key, func(t ICache) (interface{}, error) { return t.Get(key) })
+}
+
+func (c *ChainCache) GetInt(key string) (*int64, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetInt(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*int64), nil
+}
+
+func (c *ChainCache) GetUint(key string) (*uint64, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetUint(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*uint64), nil
+}
+
+func (c *ChainCache) GetFloat(key string) (*float64, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetFloat(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*float64), nil
+}
+
+func (c *ChainCache) GetString(key string) (string, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetString(key) })
+	if value == nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (c *ChainCache) GetBytes(key string) ([]byte, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetBytes(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
+
+func (c *ChainCache) GetBool(key string) (*bool, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetBool(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*bool), nil
+}
+
+func (c *ChainCache) GetTime(key string) (*time.Time, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetTime(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Time), nil
+}
+
+func (c *ChainCache) GetDuration(key string) (*time.Duration, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetDuration(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Duration), nil
+}
+
+func (c *ChainCache) GetStringSlice(key string) ([]string, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetStringSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]string), nil
+}
+
+func (c *ChainCache) GetIntSlice(key string) ([]int64, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetIntSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]int64), nil
+}
+
+// Ping pings every tier.
+func (c *ChainCache) Ping(ctx context.Context) error {
+	for _, tier := range c.tiers {
+		if err := ping(ctx, tier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every tier, returning the first error.
+func (c *ChainCache) Close() error {
+	var firstErr error
+	for _, tier := range c.tiers {
+		if err := closeCache(tier); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (c *ChainCache) Del(key string) error {
+	return c.write(func(t ICache) error { return t.Del(key) })
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChainGet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l1 := NewLocalCache(ctx, LocalWithExpire(10))
+	l2 := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	l3 := NewSyncMapCache(ctx, SyncMapWithExpire(10))
+	c := NewChainCache([]ICache{l1, l2, l3})
+	l3.Set("test:123", 3)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = l1.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v backfill error", *data)
+		return
+	}
+	c.Set("test:1", "a")
+	for _, l := range []*Cache{l1, l2, l3} {
+		data, _ := l.GetString("test:1")
+		if data != "a" {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+	c.Del("test:1")
+	for _, l := range []*Cache{l1, l2, l3} {
+		data, _ := l.GetString("test:1")
+		if data != "" {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestChainBackfill(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l1 := NewLocalCache(ctx, LocalWithExpire(10))
+	l2 := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	l3 := NewSyncMapCache(ctx, SyncMapWithExpire(10))
+	c := NewChainCache([]ICache{l1, l2, l3}, ChainWithBackfill())
+	l3.Set("test:123", 3)
+	c.GetInt("test:123")
+	data, _ := l1.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v l1 value error", data)
+		return
+	}
+	data, _ = l2.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v l2 value error", data)
+		return
+	}
+}
+
+func TestChainErrorPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	down := NewGoredisCache(nil)
+	l := NewLocalCache(ctx, LocalWithExpire(10))
+	c := NewChainCache([]ICache{down, l})
+	l.Set("test:123", 3)
+	data, err := c.GetInt("test:123")
+	if data == nil || *data != 3 || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+	if err := c.Set("test:123", 4); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	c = NewChainCache([]ICache{down, l}, ChainWithErrorPolicy(0, ChainFailOnError))
+	if _, err := c.GetInt("test:123"); err != ErrNoRedis {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := c.Set("test:123", 4); err != ErrNoRedis {
+		t.Errorf("%v error", err)
+		return
+	}
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"time"
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumCache stores the values of the wrapped cache with a CRC-32C
+// checksum and verifies it on read, so a truncated or altered value fails
+// with ErrCorrupt instead of being parsed. A stored value is the big endian
+// checksum followed by the encoding of the value, as written by EncodeBytes.
+// Get returns the encoding as a []byte, which the typed getters parse.
+type ChecksumCache struct {
+	ByteGetters
+	c ICache
+}
+
+// NewChecksumCache returns c with checksummed values.
+func NewChecksumCache(c ICache) *Cache {
+	cc := &ChecksumCache{c: c}
+	cc.ByteGetters = ByteGetters{get: cc.Get}
+	return NewCache(cc)
+}
+
+// sum prefixes the encoding of value with its checksum.
+func (c *ChecksumCache) sum(value interface{}) []byte {
+	data := EncodeBytes(value)
+	ret := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(ret, crc32.Checksum(data, crcTable))
+	return append(ret, data...)
+}
+
+// verify returns the encoding in data written by sum.
+func (c *ChecksumCache) verify(data []byte) ([]byte, error) {
+	if len(data) < 4 || binary.BigEndian.Uint32(data) != crc32.Checksum(data[4:], crcTable) {
+		return nil, ErrCorrupt
+	}
+	return data[4:], nil
+}
+
+func (c *ChecksumCache) Set(key string, value interface{}) error {
+	return c.c.Set(key, c.sum(value))
+}
+
+func (c *ChecksumCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.c.SetWithExpire(key, c.sum(value), expireSec)
+}
+
+func (c *ChecksumCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.c.SetWithTTL(key, c.sum(value), ttl)
+}
+
+func (c *ChecksumCache) Get(key string) (interface{}, error) {
+	value, err := c.c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data, err = c.verify(v)
+	case string:
+		data, err = c.verify([]byte(v))
+	default:
+		err = ErrCorrupt
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Ping pings the wrapped cache.
+func (c *ChecksumCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.c)
+}
+
+// Close closes the wrapped cache.
+func (c *ChecksumCache) Close() error {
+	return closeCache(c.c)
+}
+
+func (c *ChecksumCache) Del(key string) error {
+	return c.c.Del(key)
+}
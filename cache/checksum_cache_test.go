@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChecksumCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	local := NewLocalCache(ctx, LocalWithExpire(10))
+	c := NewChecksumCache(local)
+	c.Set("test:int", 3)
+	if data, err := c.GetInt("test:int"); err != nil || data == nil || *data != 3 {
+		t.Errorf("%v %v value error", data, err)
+		return
+	}
+	stored, _ := local.GetBytes("test:int")
+	local.Set("test:int", stored[:len(stored)-1])
+	if _, err := c.GetInt("test:int"); err != ErrCorrupt {
+		t.Errorf("%v value error", err)
+		return
+	}
+	local.Set("test:int", "3")
+	if _, err := c.Get("test:int"); err != ErrCorrupt {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if data, err := c.Get("test:miss"); data != nil || err != nil {
+		t.Errorf("%v %v value error", data, err)
+	}
+}
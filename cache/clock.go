@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time source of the expirations, the system clock by default.
+// Tests pass a FakeClock to expire entries without sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the subset of *time.Timer used by the caches.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Clocker is implemented by the caches with a Clock, used by the Cache
+// wrapping them.
+type Clocker interface {
+	Clock() Clock
+}
+
+// ClockOf returns the Clock of c, the system clock when it has none.
+func ClockOf(c interface{}) Clock {
+	if cl, ok := c.(Clocker); ok {
+		if clk := cl.Clock(); clk != nil {
+			return clk
+		}
+	}
+	return systemClock{}
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) NewTimer(d time.Duration) Timer         { return systemTimer{time.NewTimer(d)} }
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time        { return t.t.C }
+func (t systemTimer) Stop() bool                 { return t.t.Stop() }
+func (t systemTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// FakeClock is a Clock standing still until Advance moves it, firing the
+// timers due.
+type FakeClock struct {
+	m      sync.Mutex
+	now    time.Time
+	timers map[*fakeTimer]struct{}
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, timers: map[*fakeTimer]struct{}{}}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d and fires the timers due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.now = c.now.Add(d)
+	for t := range c.timers {
+		if !t.at.After(c.now) {
+			delete(c.timers, t)
+			select {
+			case t.ch <- c.now:
+			default:
+			}
+		}
+	}
+}
+
+// fakeTimer is a Timer of a FakeClock, pending while in its timers.
+type fakeTimer struct {
+	clock *FakeClock
+	ch    chan time.Time
+	at    time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.m.Lock()
+	defer t.clock.m.Unlock()
+	_, ok := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return ok
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.m.Lock()
+	defer c.m.Unlock()
+	_, ok := c.timers[t]
+	t.at = c.now.Add(d)
+	if d <= 0 {
+		delete(c.timers, t)
+		select {
+		case t.ch <- c.now:
+		default:
+		}
+		return ok
+	}
+	c.timers[t] = struct{}{}
+	return ok
+}
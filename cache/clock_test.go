@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFakeClockTimer(t *testing.T) {
+	clk := NewFakeClock(time.Unix(0, 0))
+	timer := clk.NewTimer(10 * time.Second)
+	clk.Advance(9 * time.Second)
+	select {
+	case <-timer.C():
+		t.Errorf("timer fired early error")
+		return
+	default:
+	}
+	clk.Advance(time.Second)
+	select {
+	case now := <-timer.C():
+		if !now.Equal(time.Unix(10, 0)) {
+			t.Errorf("%v value error", now)
+			return
+		}
+	default:
+		t.Errorf("timer not fired error")
+		return
+	}
+	if timer.Stop() {
+		t.Errorf("fired timer stopped error")
+		return
+	}
+	timer.Reset(time.Second)
+	if !timer.Stop() {
+		t.Errorf("pending timer not stopped error")
+	}
+}
+
+func TestLocalClockSweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := NewFakeClock(time.Now())
+	expired := make(chan string, 1)
+	c := NewLocalCache(ctx, LocalWithClock(clk), LocalExpireNotify(func(key string, value interface{}) {
+		expired <- key
+	}))
+	c.SetWithTTL("test:1", 1, time.Hour)
+	// advance until the sweeper, woken by the Set, waits on the new expiry
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		clk.Advance(time.Hour)
+		select {
+		case key := <-expired:
+			if key != "test:1" {
+				t.Errorf("%v value error", key)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Errorf("not expired error")
+}
+
+func TestGetOrSetWithTTLClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := NewFakeClock(time.Now())
+	c := NewTieredCache(NewLocalCache(ctx, LocalWithClock(clk)), NewLocalCache(ctx, LocalWithClock(clk)))
+	var calls int32
+	loader := func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return 3, nil
+	}
+	c.GetOrSetWithTTL("test:123", 10*time.Second, loader)
+	clk.Advance(5 * time.Second)
+	c.GetOrSetWithTTL("test:123", 10*time.Second, loader)
+	if calls != 1 {
+		t.Errorf("%v loader calls", calls)
+		return
+	}
+	clk.Advance(20 * time.Second)
+	data, err := c.GetOrSetWithTTL("test:123", 10*time.Second, loader)
+	if err != nil || data != 3 || calls != 2 {
+		t.Errorf("%v %v value error:%v", data, calls, err)
+	}
+}
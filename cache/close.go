@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Background runs the goroutines of a cache, e.g. its sweeper, until the
+// context of the cache is done or the cache is closed.
+type Background struct {
+	m      sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// run starts a goroutine calling each of fns with a context done with ctx or
+// on Stop.
+func (b *Background) run(ctx context.Context, fns ...func(ctx context.Context)) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	ctx, b.cancel = context.WithCancel(ctx)
+	b.wg.Add(len(fns))
+	for _, fn := range fns {
+		go func(fn func(ctx context.Context)) {
+			defer b.wg.Done()
+			fn(ctx)
+		}(fn)
+	}
+}
+
+// Stop cancels the goroutines and waits for them to return.
+func (b *Background) Stop() {
+	b.m.Lock()
+	cancel := b.cancel
+	b.m.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	b.wg.Wait()
+}
+
+// Sweep starts a goroutine calling fn every sweepInterval(expire), with a
+// context done with ctx or on Stop.
+func (b *Background) Sweep(ctx context.Context, expire time.Duration, fn func(ctx context.Context)) {
+	b.run(ctx, func(ctx context.Context) {
+		sweepEvery(ctx, expire, func() { fn(ctx) })
+	})
+}
+
+// sweepEvery calls fn every sweepInterval(expire), counted from the end of
+// the previous call, until ctx is done.
+func sweepEvery(ctx context.Context, expire time.Duration, fn func()) {
+	timer := time.NewTimer(sweepInterval(expire))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			fn()
+			timer.Reset(sweepInterval(expire))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sweepInterval returns the interval between the expiration sweeps of a
+// cache whose default expiration is expire: half of it, at least
+// minCheckInterval, or DefaultCheckSecond when entries do not expire by
+// default.
+func sweepInterval(expire time.Duration) time.Duration {
+	exp := expire / 2
+	if exp <= 0 {
+		return DefaultCheckSecond * time.Second
+	}
+	if exp < minCheckInterval {
+		return minCheckInterval
+	}
+	return exp
+}
@@ -0,0 +1,40 @@
+package cache
+
+import "strings"
+
+// clusterSlots is the number of hash slots of a Redis Cluster.
+const clusterSlots = 16384
+
+// clusterSlot returns the Redis Cluster hash slot of key. Only the part
+// between the first "{" and the following "}" is hashed when it is not
+// empty, so keys sharing a hash tag like "{svcA}:" share a slot.
+func clusterSlot(key string) int {
+	return int(crc16(hashTag(key)) % clusterSlots)
+}
+
+// hashTag returns the part of key that is hashed, the hash tag between the
+// first "{" and the following "}" when it is not empty, else key.
+func hashTag(key string) string {
+	if s := strings.IndexByte(key, '{'); s >= 0 {
+		if e := strings.IndexByte(key[s+1:], '}'); e > 0 {
+			return key[s+1 : s+1+e]
+		}
+	}
+	return key
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum Redis Cluster hashes keys with.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
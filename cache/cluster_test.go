@@ -0,0 +1,19 @@
+package cache
+
+import "testing"
+
+func TestClusterSlot(t *testing.T) {
+	cases := map[string]int{
+		"123456789":     12739,
+		"foo":           12182,
+		"{user1000}.a":  clusterSlot("user1000"),
+		"{user1000}.b":  clusterSlot("user1000"),
+		"foo{{bar}}zap": clusterSlot("{bar"),
+		"foo{bar}{zap}": clusterSlot("bar"),
+	}
+	for k, v := range cases {
+		if slot := clusterSlot(k); slot != v {
+			t.Errorf("%v slot %v error, want %v", k, slot, v)
+		}
+	}
+}
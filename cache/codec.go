@@ -0,0 +1,564 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// EncodeBytes encodes value the way the redis clients do, so the typed
+// getters parse the same text on every backend that stores raw bytes.
+func EncodeBytes(value interface{}) []byte {
+	switch v := encodeValue(value).(type) {
+	case nil:
+		return []byte{}
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	case bool:
+		if v {
+			return []byte("1")
+		}
+		return []byte("0")
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// parseBool converts a cached value to a bool the same way for every backend.
+// Numbers are true unless zero, strings and []byte are parsed with
+// strconv.ParseBool, or as a number when that fails, so true, 1, "1", "t",
+// "true" and "1.0" are all true whether the value was cached locally or read
+// back from redis. Other values return ErrDataType.
+func parseBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case int:
+		return v != 0, nil
+	case int8:
+		return v != 0, nil
+	case int16:
+		return v != 0, nil
+	case int32:
+		return v != 0, nil
+	case int64:
+		return v != 0, nil
+	case uint:
+		return v != 0, nil
+	case uint8:
+		return v != 0, nil
+	case uint16:
+		return v != 0, nil
+	case uint32:
+		return v != 0, nil
+	case uint64:
+		return v != 0, nil
+	case float32:
+		return v != 0, nil
+	case float64:
+		return v != 0, nil
+	case []byte:
+		return parseBool(string(v))
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f != 0, nil
+		}
+	}
+	return false, ErrDataType
+}
+
+// typedGetters are the typed getters of ICache, implemented by ByteGetters
+// and ValueGetters.
+type typedGetters interface {
+	GetInt(key string) (*int64, error)
+	GetUint(key string) (*uint64, error)
+	GetFloat(key string) (*float64, error)
+	GetString(key string) (string, error)
+	GetBytes(key string) ([]byte, error)
+	GetBool(key string) (*bool, error)
+	GetTime(key string) (*time.Time, error)
+	GetDuration(key string) (*time.Duration, error)
+	GetStringSlice(key string) ([]string, error)
+	GetIntSlice(key string) ([]int64, error)
+}
+
+// ByteGetters implements the typed getters of ICache for backends whose Get
+// returns the []byte written by EncodeBytes.
+type ByteGetters struct {
+	get func(key string) (interface{}, error)
+}
+
+// NewByteGetters returns the typed getters reading with get, for backends
+// outside of this package.
+func NewByteGetters(get func(key string) (interface{}, error)) ByteGetters {
+	return ByteGetters{get: get}
+}
+
+func (g ByteGetters) GetInt(key string) (*int64, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseInt(string(value.([]byte)), 10, 64)
+	return &data, err
+}
+
+func (g ByteGetters) GetUint(key string) (*uint64, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseUint(string(value.([]byte)), 10, 64)
+	return &data, err
+}
+
+func (g ByteGetters) GetFloat(key string) (*float64, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseFloat(string(value.([]byte)), 64)
+	return &data, err
+}
+
+func (g ByteGetters) GetString(key string) (string, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return "", err
+	}
+	return string(value.([]byte)), err
+}
+
+func (g ByteGetters) GetBytes(key string) ([]byte, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	return value.([]byte), err
+}
+
+func (g ByteGetters) GetBool(key string) (*bool, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := parseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (g ByteGetters) GetTime(key string) (*time.Time, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := time.Parse(time.RFC3339Nano, string(value.([]byte)))
+	return &data, err
+}
+
+func (g ByteGetters) GetDuration(key string) (*time.Duration, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseInt(string(value.([]byte)), 10, 64)
+	ret := time.Duration(data)
+	return &ret, err
+}
+
+func (g ByteGetters) GetStringSlice(key string) ([]string, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	var data []string
+	err = json.Unmarshal(value.([]byte), &data)
+	return data, err
+}
+
+func (g ByteGetters) GetIntSlice(key string) ([]int64, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	var data []int64
+	err = json.Unmarshal(value.([]byte), &data)
+	return data, err
+}
+
+// entryHeaderLen is the size of the expiration header written by
+// EncodeEntry.
+const entryHeaderLen = 16
+
+// EncodeEntry prefixes value with its expiration time and ttl in unix
+// nanoseconds, for stores without per-key expiration. A zero ttl never
+// expires.
+func EncodeEntry(value []byte, ttl time.Duration) []byte {
+	return EncodeEntryAt(time.Now(), value, ttl)
+}
+
+// EncodeEntryAt is EncodeEntry at the time now.
+func EncodeEntryAt(now time.Time, value []byte, ttl time.Duration) []byte {
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = now.Add(ttl).UnixNano()
+	}
+	ret := make([]byte, entryHeaderLen+len(value))
+	binary.BigEndian.PutUint64(ret, uint64(expireAt))
+	binary.BigEndian.PutUint64(ret[8:], uint64(ttl))
+	copy(ret[entryHeaderLen:], value)
+	return ret
+}
+
+// DecodeEntry splits an entry written by EncodeEntry. ok is false when the
+// entry is malformed or expired.
+func DecodeEntry(entry []byte) (value []byte, ttl time.Duration, ok bool) {
+	return DecodeEntryAt(time.Now(), entry)
+}
+
+// DecodeEntryAt is DecodeEntry at the time now.
+func DecodeEntryAt(now time.Time, entry []byte) (value []byte, ttl time.Duration, ok bool) {
+	if len(entry) < entryHeaderLen {
+		return nil, 0, false
+	}
+	expireAt := int64(binary.BigEndian.Uint64(entry))
+	if expireAt != 0 && now.UnixNano() > expireAt {
+		return nil, 0, false
+	}
+	return entry[entryHeaderLen:], time.Duration(binary.BigEndian.Uint64(entry[8:])), true
+}
+
+// ValueGetters implements the typed getters of ICache for in-process
+// backends whose Get returns the value as it was Set.
+type ValueGetters struct {
+	get func(key string) (interface{}, error)
+}
+
+// NewValueGetters returns the typed getters reading with get, for backends
+// outside of this package.
+func NewValueGetters(get func(key string) (interface{}, error)) ValueGetters {
+	return ValueGetters{get: get}
+}
+
+func (g ValueGetters) GetInt(key string) (*int64, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	var ret int64
+	switch v := value.(type) {
+	case int:
+		ret = int64(v)
+	case int8:
+		ret = int64(v)
+	case int16:
+		ret = int64(v)
+	case int32:
+		ret = int64(v)
+	case int64:
+		ret = int64(v)
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return nil, ErrOverflow
+		}
+		ret = int64(v)
+	case uint8:
+		ret = int64(v)
+	case uint16:
+		ret = int64(v)
+	case uint32:
+		ret = int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, ErrOverflow
+		}
+		ret = int64(v)
+	default:
+		return nil, ErrDataType
+	}
+	return &ret, nil
+}
+
+func (g ValueGetters) GetUint(key string) (*uint64, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	var ret uint64
+	switch v := value.(type) {
+	case uint:
+		ret = uint64(v)
+	case uint8:
+		ret = uint64(v)
+	case uint16:
+		ret = uint64(v)
+	case uint32:
+		ret = uint64(v)
+	case uint64:
+		ret = v
+	case int:
+		if v < 0 {
+			return nil, ErrOverflow
+		}
+		ret = uint64(v)
+	case int8:
+		if v < 0 {
+			return nil, ErrOverflow
+		}
+		ret = uint64(v)
+	case int16:
+		if v < 0 {
+			return nil, ErrOverflow
+		}
+		ret = uint64(v)
+	case int32:
+		if v < 0 {
+			return nil, ErrOverflow
+		}
+		ret = uint64(v)
+	case int64:
+		if v < 0 {
+			return nil, ErrOverflow
+		}
+		ret = uint64(v)
+	default:
+		return nil, ErrDataType
+	}
+	return &ret, nil
+}
+
+func (g ValueGetters) GetFloat(key string) (*float64, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	var ret float64
+	switch v := value.(type) {
+	case float32:
+		ret = float64(v)
+	case float64:
+		ret = float64(v)
+	default:
+		return nil, ErrDataType
+	}
+	return &ret, nil
+}
+
+func (g ValueGetters) GetString(key string) (string, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return "", err
+	}
+	var ret string
+	switch v := value.(type) {
+	case string:
+		ret = v
+	case []byte:
+		ret = *(*string)(unsafe.Pointer(&v))
+	default:
+		return "", ErrDataType
+	}
+	return ret, nil
+}
+
+func (g ValueGetters) GetBytes(key string) ([]byte, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	var ret []byte
+	switch v := value.(type) {
+	case string:
+		ret = []byte(v)
+	case []byte:
+		ret = v
+	default:
+		return nil, ErrDataType
+	}
+	return ret, nil
+}
+
+func (g ValueGetters) GetBool(key string) (*bool, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	ret, err := parseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func (g ValueGetters) GetTime(key string) (*time.Time, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	var ret time.Time
+	switch v := value.(type) {
+	case time.Time:
+		ret = v
+	case *time.Time:
+		if v == nil {
+			return nil, err
+		}
+		ret = *v
+	case string:
+		ret, err = time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, err
+		}
+	case []byte:
+		ret, err = time.Parse(time.RFC3339Nano, string(v))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrDataType
+	}
+	return &ret, nil
+}
+
+func (g ValueGetters) GetDuration(key string) (*time.Duration, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	var ret time.Duration
+	switch v := value.(type) {
+	case time.Duration:
+		ret = v
+	case *time.Duration:
+		if v == nil {
+			return nil, err
+		}
+		ret = *v
+	case int64:
+		ret = time.Duration(v)
+	case int:
+		ret = time.Duration(v)
+	case string:
+		data, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ret = time.Duration(data)
+	default:
+		return nil, ErrDataType
+	}
+	return &ret, nil
+}
+
+func (g ValueGetters) GetStringSlice(key string) ([]string, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	var ret []string
+	switch v := value.(type) {
+	case []string:
+		ret = v
+	case string:
+		if err := json.Unmarshal([]byte(v), &ret); err != nil {
+			return nil, err
+		}
+	case []byte:
+		if err := json.Unmarshal(v, &ret); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrDataType
+	}
+	return ret, nil
+}
+
+func (g ValueGetters) GetIntSlice(key string) ([]int64, error) {
+	value, err := g.get(key)
+	if value == nil {
+		return nil, err
+	}
+	var ret []int64
+	switch v := value.(type) {
+	case []int64:
+		ret = v
+	case []int:
+		ret = make([]int64, len(v))
+		for i, x := range v {
+			ret[i] = int64(x)
+		}
+	case string:
+		if err := json.Unmarshal([]byte(v), &ret); err != nil {
+			return nil, err
+		}
+	case []byte:
+		if err := json.Unmarshal(v, &ret); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrDataType
+	}
+	return ret, nil
+}
+
+// Cloner is implemented by values that copy themselves, for the caches
+// copying values on read and write.
+type Cloner interface {
+	Clone() interface{}
+}
+
+// copyValue returns a deep copy of value. Immutable values are returned as
+// they are, slices of basic types are copied, Cloner values cloned and other
+// values copied through a gob round trip, their types must then be
+// registered with gob.Register.
+func copyValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case Cloner:
+		return v.Clone(), nil
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, time.Time, time.Duration:
+		return value, nil
+	case []byte:
+		ret := make([]byte, len(v))
+		copy(ret, v)
+		return ret, nil
+	case []string:
+		ret := make([]string, len(v))
+		copy(ret, v)
+		return ret, nil
+	case []int64:
+		ret := make([]int64, len(v))
+		copy(ret, v)
+		return ret, nil
+	case []int:
+		ret := make([]int, len(v))
+		copy(ret, v)
+		return ret, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	var ret interface{}
+	if err := gob.NewDecoder(&buf).Decode(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
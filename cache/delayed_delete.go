@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+	redigo "github.com/gomodule/redigo/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultDelayedKey      = "mcache:delayed-del"
+	defaultDelayedInterval = 100 * time.Millisecond
+	delayedBatch           = 100
+
+	// claimDelayedStr removes and returns the members of the zset KEYS[1]
+	// due at ARGV[1], at most ARGV[2], so a key is claimed by one process.
+	claimDelayedStr string = `
+	local keys = redis.call('zrangebyscore', KEYS[1], '-inf', ARGV[1], 'limit', 0, ARGV[2])
+	if #keys > 0
+	then
+		redis.call('zrem', KEYS[1], unpack(keys))
+	end
+	return keys
+	`
+)
+
+var (
+	luaClaimDelayed    = redis.NewScript(claimDelayedStr)
+	luaV9ClaimDelayed  = redisv9.NewScript(claimDelayedStr)
+	redigoClaimDelayed = redigo.NewScript(1, claimDelayedStr)
+)
+
+// delayedClient runs the commands of a DelayedDeleter on one of the redis
+// clients. The times are unix milliseconds.
+type delayedClient interface {
+	schedule(ctx context.Context, zset, key string, at int64) error
+	claim(ctx context.Context, zset string, now int64, n int) ([]string, error)
+}
+
+type goredisDelayedClient struct {
+	client redis.UniversalClient
+}
+
+func (c goredisDelayedClient) schedule(ctx context.Context, zset, key string, at int64) error {
+	return c.client.ZAdd(zset, redis.Z{Score: float64(at), Member: key}).Err()
+}
+
+func (c goredisDelayedClient) claim(ctx context.Context, zset string, now int64, n int) ([]string, error) {
+	reply, err := luaClaimDelayed.Run(c.client, []string{zset}, now, n).Result()
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]interface{})
+	keys := make([]string, 0, len(items))
+	for _, x := range items {
+		if key, ok := x.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+type goredisV9DelayedClient struct {
+	client redisv9.UniversalClient
+}
+
+func (c goredisV9DelayedClient) schedule(ctx context.Context, zset, key string, at int64) error {
+	return c.client.ZAdd(ctx, zset, redisv9.Z{Score: float64(at), Member: key}).Err()
+}
+
+func (c goredisV9DelayedClient) claim(ctx context.Context, zset string, now int64, n int) ([]string, error) {
+	return luaV9ClaimDelayed.Run(ctx, c.client, []string{zset}, now, n).StringSlice()
+}
+
+type redigoDelayedClient struct {
+	getConn GetRedisConn
+}
+
+func (c redigoDelayedClient) schedule(ctx context.Context, zset, key string, at int64) error {
+	conn := c.getConn()
+	if conn == nil {
+		return ErrNoRedis
+	}
+	defer conn.Close()
+	_, err := conn.Do("ZADD", zset, at, key)
+	return err
+}
+
+func (c redigoDelayedClient) claim(ctx context.Context, zset string, now int64, n int) ([]string, error) {
+	conn := c.getConn()
+	if conn == nil {
+		return nil, ErrNoRedis
+	}
+	defer conn.Close()
+	return redigo.Strings(redigoClaimDelayed.Do(conn, zset, now, n))
+}
+
+// DelayedDeleter deletes keys of a cache twice, now and again after a delay,
+// to remove the values cached from a replica lagging behind the database
+// write invalidating them. The second deletes are scheduled in a redis
+// sorted set, scored by their time, and run by the deleters polling it, so
+// they survive the restart of the process scheduling them. Each due key is
+// claimed atomically by one deleter.
+type DelayedDeleter struct {
+	client   delayedClient
+	c        ICache
+	zset     string
+	interval time.Duration
+	errFn    WriteErrorFunc
+	bg       Background
+}
+
+type DelayedOption func(d *DelayedDeleter)
+
+// DelayedWithKey names the sorted set of the scheduled deletes,
+// "mcache:delayed-del" by default. The deleters of different caches must use
+// different sets.
+func DelayedWithKey(key string) DelayedOption {
+	return func(d *DelayedDeleter) {
+		d.zset = key
+	}
+}
+
+// DelayedWithPollInterval sets how often the due deletes are polled, 100ms
+// by default, the delays are rounded up to it.
+func DelayedWithPollInterval(interval time.Duration) DelayedOption {
+	return func(d *DelayedDeleter) {
+		d.interval = interval
+	}
+}
+
+// DelayedWithErrorFunc reports the scheduled deletes failing, they are
+// retried at the next poll.
+func DelayedWithErrorFunc(fn WriteErrorFunc) DelayedOption {
+	return func(d *DelayedDeleter) {
+		d.errFn = fn
+	}
+}
+
+func newDelayedDeleter(ctx context.Context, client delayedClient, c ICache, opts []DelayedOption) *DelayedDeleter {
+	d := &DelayedDeleter{
+		client:   client,
+		c:        c,
+		zset:     defaultDelayedKey,
+		interval: defaultDelayedInterval,
+	}
+	for _, fn := range opts {
+		fn(d)
+	}
+	d.bg.run(ctx, d.run)
+	return d
+}
+
+// NewGoredisDelayedDeleter returns a deleter of the keys of c scheduling
+// the second deletes with client, polled until ctx is done or Close.
+func NewGoredisDelayedDeleter(ctx context.Context, client redis.UniversalClient, c ICache, opts ...DelayedOption) *DelayedDeleter {
+	return newDelayedDeleter(ctx, goredisDelayedClient{client: client}, c, opts)
+}
+
+func NewGoredisV9DelayedDeleter(ctx context.Context, client redisv9.UniversalClient, c ICache, opts ...DelayedOption) *DelayedDeleter {
+	return newDelayedDeleter(ctx, goredisV9DelayedClient{client: client}, c, opts)
+}
+
+func NewRedigoDelayedDeleter(ctx context.Context, getConn GetRedisConn, c ICache, opts ...DelayedOption) *DelayedDeleter {
+	return newDelayedDeleter(ctx, redigoDelayedClient{getConn: getConn}, c, opts)
+}
+
+// DelDelayed deletes key now and schedules its second delete after delay.
+// The delete is scheduled first, so it still runs when the first one fails.
+func (d *DelayedDeleter) DelDelayed(key string, delay time.Duration) error {
+	at := time.Now().Add(delay).UnixNano() / int64(time.Millisecond)
+	if err := d.client.schedule(context.Background(), d.zset, key, at); err != nil {
+		return err
+	}
+	return d.c.Del(key)
+}
+
+// Close stops polling the scheduled deletes and waits for the running ones.
+// The deletes not due yet stay scheduled for the next deleter.
+func (d *DelayedDeleter) Close() error {
+	d.bg.Stop()
+	return nil
+}
+
+func (d *DelayedDeleter) run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.runDue(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runDue deletes the keys due, claimed in batches. A failed delete is
+// scheduled again for the next poll.
+func (d *DelayedDeleter) runDue(ctx context.Context) {
+	for {
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		keys, err := d.client.claim(ctx, d.zset, now, delayedBatch)
+		if err != nil {
+			if d.errFn != nil {
+				d.errFn(d.zset, err)
+			}
+			return
+		}
+		for _, key := range keys {
+			if err := d.c.Del(key); err != nil {
+				if d.errFn != nil {
+					d.errFn(key, err)
+				}
+				d.client.schedule(ctx, d.zset, key, now)
+			}
+		}
+		if len(keys) < delayedBatch || ctx.Err() != nil {
+			return
+		}
+	}
+}
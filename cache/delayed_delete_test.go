@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func testDelayedDeleter(t *testing.T, c *Cache, newDeleter func(ctx context.Context) *DelayedDeleter) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := newDeleter(ctx)
+	c.Set("test:delayed", 1)
+	if err := d.DelDelayed("test:delayed", 200*time.Millisecond); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := c.GetInt("test:delayed"); data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+	// a stale read caching the old value again
+	c.Set("test:delayed", 1)
+	if data, _ := c.GetInt("test:delayed"); data == nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+	time.Sleep(400 * time.Millisecond)
+	if data, _ := c.GetInt("test:delayed"); data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+
+	// scheduled by a deleter closed before the delay, run by the next one
+	if err := d.DelDelayed("test:delayed", 200*time.Millisecond); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	d.Close()
+	c.Set("test:delayed", 1)
+	time.Sleep(300 * time.Millisecond)
+	if data, _ := c.GetInt("test:delayed"); data == nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+	d = newDeleter(ctx)
+	defer d.Close()
+	time.Sleep(200 * time.Millisecond)
+	if data, _ := c.GetInt("test:delayed"); data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+}
+
+func TestGoredisDelayedDeleter(t *testing.T) {
+	client := getGoRedisT(t)
+	c := NewGoredisCache(client, GoredisWithExpire(10))
+	testDelayedDeleter(t, c, func(ctx context.Context) *DelayedDeleter {
+		return NewGoredisDelayedDeleter(ctx, client, c, DelayedWithKey("test:delayed-del"))
+	})
+}
+
+func TestGoredisV9DelayedDeleter(t *testing.T) {
+	client := getGoRedisV9T(t)
+	c := NewGoredisV9Cache(client, GoredisV9WithExpire(10))
+	testDelayedDeleter(t, c, func(ctx context.Context) *DelayedDeleter {
+		return NewGoredisV9DelayedDeleter(ctx, client, c, DelayedWithKey("test:delayed-del"))
+	})
+}
+
+func TestRedigoDelayedDeleter(t *testing.T) {
+	getConn := getRedigoT(t)
+	c := NewRedigoCache(getConn, RedigoWithExpire(10))
+	testDelayedDeleter(t, c, func(ctx context.Context) *DelayedDeleter {
+		return NewRedigoDelayedDeleter(ctx, getConn, c, DelayedWithKey("test:delayed-del"))
+	})
+}
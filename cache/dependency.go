@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// depsSuffix names the set holding the keys depending on a key.
+const depsSuffix = ":deps"
+
+// DependencyCache deletes the entries depending on a key, transitively, when
+// the key is written or deleted. The dependents of a key are a set entry of
+// the wrapped cache, so every process sharing e.g. redis sees them. The set
+// expires like other entries, declare the dependencies of a key each time it
+// is built.
+type DependencyCache struct {
+	ICache
+	sets ISet
+}
+
+// NewDependencyCache returns c invalidating dependents. c must support set
+// entries, else AddDependency returns ErrNotSupported.
+func NewDependencyCache(c ICache) *Cache {
+	dc := &DependencyCache{ICache: c}
+	dc.sets, _ = c.(ISet)
+	return NewCache(dc)
+}
+
+func (c *DependencyCache) AddDependency(key string, deps ...string) error {
+	if c.sets == nil {
+		return ErrNotSupported
+	}
+	for _, dep := range deps {
+		if err := c.sets.SAdd(dep+depsSuffix, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidate deletes the dependents of key and theirs. seen guards against
+// dependency cycles.
+func (c *DependencyCache) invalidate(key string, seen map[string]bool) error {
+	if c.sets == nil || seen[key] {
+		return nil
+	}
+	seen[key] = true
+	members, err := c.sets.SMembers(key + depsSuffix)
+	if err != nil || len(members) == 0 {
+		return err
+	}
+	if err := c.ICache.Del(key + depsSuffix); err != nil {
+		return err
+	}
+	for _, m := range members {
+		dep, ok := m.(string)
+		if !ok {
+			dep = fmt.Sprint(m)
+		}
+		if err := c.invalidate(dep, seen); err != nil {
+			return err
+		}
+		if err := c.ICache.Del(dep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *DependencyCache) Set(key string, value interface{}) error {
+	if err := c.ICache.Set(key, value); err != nil {
+		return err
+	}
+	return c.invalidate(key, map[string]bool{})
+}
+
+func (c *DependencyCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *DependencyCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if err := c.ICache.SetWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+	return c.invalidate(key, map[string]bool{})
+}
+
+// Ping pings the wrapped cache.
+func (c *DependencyCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.ICache)
+}
+
+// Close closes the wrapped cache.
+func (c *DependencyCache) Close() error {
+	return closeCache(c.ICache)
+}
+
+func (c *DependencyCache) Del(key string) error {
+	if err := c.ICache.Del(key); err != nil {
+		return err
+	}
+	return c.invalidate(key, map[string]bool{})
+}
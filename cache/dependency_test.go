@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDependencyDel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewDependencyCache(NewLocalCache(ctx, LocalWithExpire(10)))
+	c.Set("user:1", "a")
+	c.Set("view:1", "a")
+	c.Set("page:1", "a")
+	c.AddDependency("view:1", "user:1")
+	c.AddDependency("page:1", "view:1")
+	c.Del("user:1")
+	data, _ := c.GetString("view:1")
+	if data != "" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.GetString("page:1")
+	if data != "" {
+		t.Errorf("%v transitive value error", data)
+		return
+	}
+}
+
+func TestDependencySet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewDependencyCache(NewLocalCache(ctx, LocalWithExpire(10)))
+	c.Set("user:1", "a")
+	c.Set("view:1", "a")
+	c.AddDependency("view:1", "user:1", "user:2")
+	c.AddDependency("user:1", "view:1")
+	c.Set("user:2", "b")
+	data, _ := c.GetString("view:1")
+	if data != "" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.GetString("user:2")
+	if data != "b" {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestDependencyNotSupported(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewDependencyCache(NewSyncMapCache(ctx))
+	if err := c.AddDependency("view:1", "user:1"); err != ErrNotSupported {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := NewLocalCache(ctx).AddDependency("view:1", "user:1"); err != ErrNotSupported {
+		t.Errorf("%v error", err)
+		return
+	}
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"time"
+)
+
+// EncryptionKey is an AES key of 16, 24 or 32 bytes. ID names it in the
+// values it encrypts, so values encrypted with an older key can still be
+// decrypted after a rotation.
+type EncryptionKey struct {
+	ID  string
+	Key []byte
+}
+
+// EncryptedCache encrypts the values of the wrapped cache with AES-GCM, so a
+// shared redis only stores ciphertext. A stored value is the length of the key
+// ID, the key ID, the nonce and the sealed encoding of the value, as written
+// by EncodeBytes. Get returns the decrypted encoding as a []byte, which the
+// typed getters parse.
+type EncryptedCache struct {
+	ByteGetters
+	c       ICache
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewEncryptedCache returns c encrypting the values with keys[0] and
+// decrypting them with the key named in the value. To rotate keys, put the
+// new key first and keep the old ones until their values expired.
+func NewEncryptedCache(c ICache, keys ...EncryptionKey) (*Cache, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption key error")
+	}
+	ec := &EncryptedCache{
+		c:       c,
+		current: keys[0].ID,
+		aeads:   make(map[string]cipher.AEAD, len(keys)),
+	}
+	for _, k := range keys {
+		if len(k.ID) > 255 {
+			return nil, errors.New("encryption key id too long error")
+		}
+		block, err := aes.NewCipher(k.Key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		ec.aeads[k.ID] = aead
+	}
+	ec.ByteGetters = ByteGetters{get: ec.Get}
+	return NewCache(ec), nil
+}
+
+// encrypt seals the encoding of value with the current key.
+func (c *EncryptedCache) encrypt(value interface{}) ([]byte, error) {
+	aead := c.aeads[c.current]
+	plain := EncodeBytes(value)
+	head := len(c.current) + 1
+	ret := make([]byte, head+aead.NonceSize(), head+aead.NonceSize()+len(plain)+aead.Overhead())
+	ret[0] = byte(len(c.current))
+	copy(ret[1:], c.current)
+	nonce := ret[head:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(ret, nonce, plain, nil), nil
+}
+
+// decrypt opens a value sealed by encrypt.
+func (c *EncryptedCache) decrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data) < int(data[0])+1 {
+		return nil, ErrDecrypt
+	}
+	head := int(data[0]) + 1
+	aead, ok := c.aeads[string(data[1:head])]
+	if !ok || len(data) < head+aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce := data[head : head+aead.NonceSize()]
+	ret, err := aead.Open(nil, nonce, data[head+aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return ret, nil
+}
+
+func (c *EncryptedCache) Set(key string, value interface{}) error {
+	data, err := c.encrypt(value)
+	if err != nil {
+		return err
+	}
+	return c.c.Set(key, data)
+}
+
+func (c *EncryptedCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	data, err := c.encrypt(value)
+	if err != nil {
+		return err
+	}
+	return c.c.SetWithExpire(key, data, expireSec)
+}
+
+func (c *EncryptedCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	data, err := c.encrypt(value)
+	if err != nil {
+		return err
+	}
+	return c.c.SetWithTTL(key, data, ttl)
+}
+
+func (c *EncryptedCache) Get(key string) (interface{}, error) {
+	value, err := c.c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data, err = c.decrypt(v)
+	case string:
+		data, err = c.decrypt([]byte(v))
+	default:
+		err = ErrDecrypt
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Ping pings the wrapped cache.
+func (c *EncryptedCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.c)
+}
+
+// Close closes the wrapped cache.
+func (c *EncryptedCache) Close() error {
+	return closeCache(c.c)
+}
+
+func (c *EncryptedCache) Del(key string) error {
+	return c.c.Del(key)
+}
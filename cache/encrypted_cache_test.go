@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestEncryptedCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	local := NewLocalCache(ctx, LocalWithExpire(10))
+	old := EncryptionKey{ID: "k1", Key: bytes.Repeat([]byte{1}, 32)}
+	c, err := NewEncryptedCache(local, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("test:int", 3)
+	c.Set("test:string", "secret")
+	if data, _ := local.Get("test:string"); bytes.Contains(data.([]byte), []byte("secret")) {
+		t.Errorf("%q value error", data)
+		return
+	}
+	rotated, err := NewEncryptedCache(local, EncryptionKey{ID: "k2", Key: bytes.Repeat([]byte{2}, 16)}, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := rotated.GetInt("test:int"); err != nil || data == nil || *data != 3 {
+		t.Errorf("%v %v value error", data, err)
+		return
+	}
+	rotated.Set("test:string", "secret")
+	if data, _ := rotated.GetString("test:string"); data != "secret" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if _, err := c.GetInt("test:string"); err != ErrDecrypt {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if data, err := c.Get("test:miss"); data != nil || err != nil {
+		t.Errorf("%v %v value error", data, err)
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+)
+
+const (
+	// getVersionStr returns {etag, value} for the key, its value and the
+	// SHA-1 of the value, or {etag} when ARGV[2] is already the etag. It
+	// extends a sliding expiration as getCacheStr does.
+	getVersionStr string = `
+	local key,slide,version = KEYS[1],ARGV[1],ARGV[2]
+	local value = redis.call('hget', key, 'data')
+	if value == false
+	then
+		return false
+	end
+	if slide ~= '0'
+	then
+		local expire = redis.call('hmget', key, 'exp', 'pexp')
+		if (expire[2] ~= false) and (tonumber(expire[2]) ~= 0)
+		then
+			redis.call('pexpire', key, expire[2])
+		elseif tonumber(expire[1]) ~= 0
+		then
+			redis.call('expire', key, expire[1])
+		end
+	end
+	local etag = redis.sha1hex(value)
+	if etag == version
+	then
+		return {etag}
+	end
+	return {etag, value}
+	`
+)
+
+// ErrNotModified is returned by GetIfChanged when the value of the key still
+// has the version given.
+var ErrNotModified = errors.New("not modified error")
+
+// IVersionGet is implemented by caches returning an opaque version, e.g. an
+// ETag, with the values, changing whenever the value of the key does, so a
+// reader holding a large value can skip reading it again.
+type IVersionGet interface {
+	GetWithVersion(key string) (value interface{}, version string, err error)
+	GetIfChanged(key, version string) (value interface{}, newVersion string, err error)
+}
+
+// versionReply returns the result of a getVersionStr script from its reply.
+func versionReply(reply interface{}) (interface{}, string, error) {
+	r, ok := reply.([]interface{})
+	if !ok || len(r) == 0 {
+		return nil, "", ErrDataType
+	}
+	var version string
+	switch v := r[0].(type) {
+	case string:
+		version = v
+	case []byte:
+		version = string(v)
+	default:
+		return nil, "", ErrDataType
+	}
+	if len(r) == 1 {
+		return nil, version, ErrNotModified
+	}
+	return r[1], version, nil
+}
+
+// localVersion returns the version of a LocalCache entry from the epoch of
+// the cache and the sequence number of its last write.
+func localVersion(epoch string, seq uint64) string {
+	return epoch + "-" + strconv.FormatUint(seq, 36)
+}
+
+// GetWithVersion returns the value of key and its version, for
+// GetIfChanged. It returns ErrNotSupported if the underlying cache has no
+// versions.
+func (c *Cache) GetWithVersion(key string) (interface{}, string, error) {
+	v, ok := c.cache.(IVersionGet)
+	if !ok {
+		return nil, "", ErrNotSupported
+	}
+	value, version, err := v.GetWithVersion(key)
+	c.stats.read(value == nil && !c.missErr, err)
+	return value, version, err
+}
+
+// GetIfChanged returns the value of key and its version unless version is
+// still the version of the value, then it returns ErrNotModified without
+// the value. It returns ErrNotSupported if the underlying cache has no
+// versions.
+func (c *Cache) GetIfChanged(key, version string) (interface{}, string, error) {
+	v, ok := c.cache.(IVersionGet)
+	if !ok {
+		return nil, "", ErrNotSupported
+	}
+	value, newVersion, err := v.GetIfChanged(key, version)
+	if err == ErrNotModified {
+		c.stats.read(false, nil)
+	} else {
+		c.stats.read(value == nil && !c.missErr, err)
+	}
+	return value, newVersion, err
+}
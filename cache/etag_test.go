@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func testVersionGet(t *testing.T, c *Cache) {
+	c.Del("test:etag")
+	if value, version, err := c.GetWithVersion("test:etag"); value != nil || version != "" || err != nil {
+		t.Errorf("%v %v %v error", value, version, err)
+		return
+	}
+	c.Set("test:etag", "abc")
+	value, version, err := c.GetWithVersion("test:etag")
+	if fmt.Sprintf("%s", value) != "abc" || version == "" || err != nil {
+		t.Errorf("%v %v %v error", value, version, err)
+		return
+	}
+	if value, v, err := c.GetIfChanged("test:etag", version); value != nil || v != version || err != ErrNotModified {
+		t.Errorf("%v %v %v error", value, v, err)
+		return
+	}
+	c.Set("test:etag", "abcd")
+	value, v, err := c.GetIfChanged("test:etag", version)
+	if fmt.Sprintf("%s", value) != "abcd" || v == version || err != nil {
+		t.Errorf("%v %v %v error", value, v, err)
+		return
+	}
+	c.Del("test:etag")
+	if value, v, err := c.GetIfChanged("test:etag", v); value != nil || v != "" || err != nil {
+		t.Errorf("%v %v %v error", value, v, err)
+		return
+	}
+}
+
+func TestLocalVersionGet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx)
+	testVersionGet(t, c)
+
+	// changes in place change the version
+	c.LPush("test:list", 1)
+	_, version, _ := c.GetWithVersion("test:list")
+	c.LPush("test:list", 2)
+	if _, v, err := c.GetIfChanged("test:list", version); v == version || err != nil {
+		t.Errorf("%v %v error", v, err)
+	}
+}
+
+func TestGoredisVersionGet(t *testing.T) {
+	testVersionGet(t, NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10)))
+}
+
+func TestGoredisV9VersionGet(t *testing.T) {
+	testVersionGet(t, NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(10)))
+}
+
+func TestRedigoVersionGet(t *testing.T) {
+	testVersionGet(t, NewRedigoCache(getRedigoT(t), RedigoWithExpire(10)))
+}
@@ -0,0 +1,219 @@
+// Package etcd stores the entries of a cache.Cache in etcd.
+package etcd
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"mcache/cache"
+)
+
+const defaultEtcdTimeout = 5 * time.Second
+
+// EtcdCache stores encoded values in etcd, for small strongly consistent
+// cached config and metadata. Every key with an expiration is attached to
+// its own lease, Get keeps the lease alive to slide the expiration.
+type EtcdCache struct {
+	cache.ByteGetters
+	expireSec int
+	absolute  bool
+	prefix    string
+	ctx       context.Context
+	timeout   time.Duration
+	client    *clientv3.Client
+	m         sync.Mutex
+	r         *rand.Rand
+}
+
+type EtcdOption func(c *EtcdCache)
+
+func EtcdWithExpire(expireSecond int) EtcdOption {
+	return func(c *EtcdCache) {
+		c.expireSec = expireSecond
+	}
+}
+
+// EtcdWithTTL sets the default expiration, rounded up to whole seconds.
+func EtcdWithTTL(ttl time.Duration) EtcdOption {
+	return func(c *EtcdCache) {
+		c.expireSec = cache.TTLSeconds(ttl)
+	}
+}
+
+// EtcdWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads.
+func EtcdWithAbsoluteExpire() EtcdOption {
+	return func(c *EtcdCache) {
+		c.absolute = true
+	}
+}
+
+// EtcdWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func EtcdWithKeyPrefix(prefix string) EtcdOption {
+	return func(c *EtcdCache) {
+		c.prefix = prefix
+	}
+}
+
+// EtcdWithContext sets the context passed to every etcd request,
+// context.Background() by default.
+func EtcdWithContext(ctx context.Context) EtcdOption {
+	return func(c *EtcdCache) {
+		c.ctx = ctx
+	}
+}
+
+// EtcdWithTimeout bounds each request to etcd, 5s by default, so the
+// operations fail instead of blocking while etcd can not be reached.
+func EtcdWithTimeout(d time.Duration) EtcdOption {
+	return func(c *EtcdCache) {
+		c.timeout = d
+	}
+}
+
+func NewEtcdCache(client *clientv3.Client, opts ...EtcdOption) *cache.Cache {
+	c := &EtcdCache{
+		client:  client,
+		ctx:     context.Background(),
+		timeout: defaultEtcdTimeout,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = cache.NewByteGetters(c.Get)
+	for _, fn := range opts {
+		fn(c)
+	}
+	return cache.NewCache(c)
+}
+
+func (c *EtcdCache) Set(key string, value interface{}) error {
+	exp := c.expireSec
+	if exp != 0 {
+		c.m.Lock()
+		exp += c.r.Intn(int(exp/10 + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithExpire(key, value, exp)
+}
+
+// opContext returns the context of one request, bounded by the timeout.
+func (c *EtcdCache) opContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.ctx, c.timeout)
+}
+
+// SetWithExpire stores value under a new lease of expireSec seconds, 0 never
+// expires. The lease of the value replaced is revoked, each lease holds one
+// key.
+func (c *EtcdCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	ctx, cancel := c.opContext()
+	defer cancel()
+	opts := []clientv3.OpOption{clientv3.WithPrevKV()}
+	var lease clientv3.LeaseID
+	if expireSec > 0 {
+		resp, err := c.client.Grant(ctx, int64(expireSec))
+		if err != nil {
+			return err
+		}
+		lease = resp.ID
+		opts = append(opts, clientv3.WithLease(lease))
+	}
+	resp, err := c.client.Put(ctx, c.prefix+key, string(cache.EncodeBytes(value)), opts...)
+	if err != nil {
+		if lease != 0 {
+			c.client.Revoke(ctx, lease)
+		}
+		return err
+	}
+	if resp.PrevKv != nil && resp.PrevKv.Lease != 0 && clientv3.LeaseID(resp.PrevKv.Lease) != lease {
+		c.client.Revoke(ctx, clientv3.LeaseID(resp.PrevKv.Lease))
+	}
+	return nil
+}
+
+// SetWithTTL stores value with the given ttl, rounded up to whole seconds.
+func (c *EtcdCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.SetWithExpire(key, value, cache.TTLSeconds(ttl))
+}
+
+func (c *EtcdCache) Get(key string) (interface{}, error) {
+	if c.client == nil {
+		return nil, cache.ErrNoClient
+	}
+	ctx, cancel := c.opContext()
+	defer cancel()
+	resp, err := c.client.Get(ctx, c.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	kv := resp.Kvs[0]
+	if kv.Lease != 0 && !c.absolute {
+		_, err = c.client.KeepAliveOnce(ctx, clientv3.LeaseID(kv.Lease))
+		if err == rpctypes.ErrLeaseNotFound {
+			// the lease expired between the read and the keep alive
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return kv.Value, nil
+}
+
+// Close closes the etcd client of the cache.
+func (c *EtcdCache) Close() error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	return c.client.Close()
+}
+
+// Del removes key, revoking its lease so it does not linger until expiry.
+func (c *EtcdCache) Del(key string) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	ctx, cancel := c.opContext()
+	defer cancel()
+	resp, err := c.client.Delete(ctx, c.prefix+key, clientv3.WithPrevKV())
+	if err != nil {
+		return err
+	}
+	for _, kv := range resp.PrevKvs {
+		if kv.Lease != 0 {
+			c.client.Revoke(ctx, clientv3.LeaseID(kv.Lease))
+		}
+	}
+	return nil
+}
+
+// Clear deletes the keys under the key prefix, every key of the cluster
+// without one, revoking their leases.
+func (c *EtcdCache) Clear() error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	ctx, cancel := c.opContext()
+	defer cancel()
+	resp, err := c.client.Delete(ctx, c.prefix, clientv3.WithPrefix(), clientv3.WithPrevKV())
+	if err != nil {
+		return err
+	}
+	revoked := make(map[int64]bool)
+	for _, kv := range resp.PrevKvs {
+		if kv.Lease != 0 && !revoked[kv.Lease] {
+			revoked[kv.Lease] = true
+			c.client.Revoke(ctx, clientv3.LeaseID(kv.Lease))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"mcache/cache/cachetest"
+)
+
+var etcdAddr string = "192.168.3.105:2379"
+
+func getEtcdT(t *testing.T) *clientv3.Client {
+	c, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{etcdAddr},
+		DialTimeout: 3 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	// the dial does not block, fail here instead of in the first request
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := c.Status(ctx, etcdAddr); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEtcdSetInt(t *testing.T) {
+	c := NewEtcdCache(getEtcdT(t), EtcdWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestEtcdSetFloat(t *testing.T) {
+	c := NewEtcdCache(getEtcdT(t), EtcdWithExpire(10))
+	v := 3.5
+	c.Set("test:123", v)
+	data, _ := c.GetFloat("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestEtcdSetBytes(t *testing.T) {
+	c := NewEtcdCache(getEtcdT(t), EtcdWithExpire(10))
+	v := []byte("test")
+	c.Set("test:123", v)
+	data, _ := c.GetBytes("test:123")
+	if data == nil || !bytes.Equal(v, data) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestEtcdSetBool(t *testing.T) {
+	c := NewEtcdCache(getEtcdT(t), EtcdWithExpire(10))
+	v := true
+	c.Set("test:123", v)
+	data, _ := c.GetBool("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestEtcdDel(t *testing.T) {
+	c := NewEtcdCache(getEtcdT(t), EtcdWithExpire(10))
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestEtcdExpire(t *testing.T) {
+	c := NewEtcdCache(getEtcdT(t), EtcdWithExpire(2))
+	key := "test:123"
+	c.Set(key, true)
+	time.Sleep(4 * time.Second)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestEtcdSetRevokesLease(t *testing.T) {
+	client := getEtcdT(t)
+	c := NewEtcdCache(client, EtcdWithExpire(10))
+	c.Set("test:lease", 1)
+	resp, err := client.Get(context.Background(), "test:lease")
+	if err != nil || len(resp.Kvs) != 1 || resp.Kvs[0].Lease == 0 {
+		t.Errorf("%v value error:%v", resp, err)
+		return
+	}
+	c.Set("test:lease", 2)
+	ttl, err := client.TimeToLive(context.Background(), clientv3.LeaseID(resp.Kvs[0].Lease))
+	if err != nil || ttl.TTL != -1 {
+		t.Errorf("%v value error:%v", ttl, err)
+	}
+	c.Del("test:lease")
+}
+
+func TestEtcdUnreachableTimeout(t *testing.T) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{"127.0.0.1:1"},
+		DialTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	c := NewEtcdCache(client, EtcdWithTimeout(200*time.Millisecond))
+	start := time.Now()
+	if _, err := c.Get("test:123"); err == nil {
+		t.Errorf("%v error", err)
+	}
+	if err := c.Set("test:123", 1); err == nil {
+		t.Errorf("%v error", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("%v value error", d)
+	}
+}
+
+func TestEtcdClear(t *testing.T) {
+	client := getEtcdT(t)
+	cachetest.Clear(t, NewEtcdCache(client, EtcdWithKeyPrefix("svcA:")), NewEtcdCache(client, EtcdWithKeyPrefix("svcB:")))
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// EventType is the kind of change an Event reports.
+type EventType int
+
+const (
+	EventSet    EventType = iota + 1 // key written
+	EventDel                         // key deleted
+	EventExpire                      // key expired
+	EventEvict                       // key evicted to free memory
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDel:
+		return "del"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	}
+	return "unknown"
+}
+
+// Event is a change of a key. Value is the value written for EventSet and
+// the value removed otherwise, when the cache knows it.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value interface{}
+}
+
+// EventFunc receives the events of a subscription.
+type EventFunc func(e Event)
+
+// eventBuffer is how many events a subscription of a LocalCache queues, the
+// events beyond are dropped until fn catches up.
+const eventBuffer = 1024
+
+// IEvents is implemented by caches that report the changes of their keys.
+type IEvents interface {
+	Subscribe(ctx context.Context, fn EventFunc) error
+}
+
+// Subscribe calls fn, from a single goroutine, with the changes of the keys
+// of the cache until ctx is done. It returns ErrNotSupported if the
+// underlying cache does not report them.
+func (c *Cache) Subscribe(ctx context.Context, fn EventFunc) error {
+	e, ok := c.cache.(IEvents)
+	if !ok {
+		return ErrNotSupported
+	}
+	return e.Subscribe(ctx, fn)
+}
+
+// notifyExpire calls fn with the keys of s expiring, as reported by its
+// Subscribe, until ctx is done. The value is nil. A failed subscription,
+// e.g. to a server not up yet, is retried every second.
+func notifyExpire(ctx context.Context, s IEvents, fn CacheExpireFunc) {
+	expired := func(e Event) {
+		if e.Type == EventExpire {
+			fn(e.Key, nil)
+		}
+	}
+	go func() {
+		for s.Subscribe(ctx, expired) != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+}
+
+// keyspacePrefix starts the channels of the redis keyspace notifications,
+// "__keyspace@<db>__:<key>".
+const keyspacePrefix = "__keyspace@"
+
+// keyspacePattern matches the keyspace notification channels of the keys
+// starting with prefix, in any database.
+func keyspacePattern(prefix string) string {
+	return keyspacePrefix + "*__:" + escapeGlob(prefix) + "*"
+}
+
+// keyspaceEvent converts a keyspace notification, the channel and the
+// command in the payload, to an Event of a key without its prefix. The
+// notifications not changing the value, e.g. the version bumps and the ttl
+// updates of the writes, are skipped.
+func keyspaceEvent(channel, payload, prefix string) (Event, bool) {
+	i := strings.Index(channel, "__:")
+	if !strings.HasPrefix(channel, keyspacePrefix) || i < 0 {
+		return Event{}, false
+	}
+	key := strings.TrimPrefix(channel[i+3:], prefix)
+	switch payload {
+	case "expire", "persist", "hincrby", "new":
+		return Event{}, false
+	case "del", "rename_from":
+		return Event{Type: EventDel, Key: key}, true
+	case "expired":
+		return Event{Type: EventExpire, Key: key}, true
+	case "evicted":
+		return Event{Type: EventEvict, Key: key}, true
+	}
+	return Event{Type: EventSet, Key: key}, true
+}
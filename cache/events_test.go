@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestKeyspaceEvent(t *testing.T) {
+	tests := []struct {
+		channel, payload string
+		want             Event
+		ok               bool
+	}{
+		{"__keyspace@0__:svc:test:1", "hset", Event{Type: EventSet, Key: "test:1"}, true},
+		{"__keyspace@0__:svc:test:1", "del", Event{Type: EventDel, Key: "test:1"}, true},
+		{"__keyspace@3__:svc:test:1", "expired", Event{Type: EventExpire, Key: "test:1"}, true},
+		{"__keyspace@0__:svc:test:1", "evicted", Event{Type: EventEvict, Key: "test:1"}, true},
+		{"__keyspace@0__:svc:test:1", "hincrby", Event{}, false},
+		{"__keyspace@0__:svc:test:1", "expire", Event{}, false},
+		{"other", "del", Event{}, false},
+	}
+	for _, tt := range tests {
+		e, ok := keyspaceEvent(tt.channel, tt.payload, "svc:")
+		if ok != tt.ok || e != tt.want {
+			t.Errorf("%v %v value error", e, ok)
+		}
+	}
+}
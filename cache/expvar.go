@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"expvar"
+	"time"
+)
+
+// CacheInfo describes the content of a cache. Entries may include expired
+// entries not swept yet, Bytes is an estimate.
+type CacheInfo struct {
+	Entries       int
+	Bytes         int64
+	Sweeps        uint64        // expiration sweeps run
+	Swept         uint64        // entries removed by the sweeps
+	LastSweep     time.Time     // start of the last sweep
+	LastSweepTime time.Duration // duration of the last sweep
+}
+
+// Info describes the content of the cache. It returns ErrNotSupported if the
+// underlying cache can not describe it.
+func (c *Cache) Info() (CacheInfo, error) {
+	i, ok := c.cache.(IInfo)
+	if !ok {
+		return CacheInfo{}, ErrNotSupported
+	}
+	return i.Info(), nil
+}
+
+// expvarStats is the value published by Publish.
+type expvarStats struct {
+	Stats
+	HitRatio float64
+	Info     *CacheInfo `json:",omitempty"`
+}
+
+// Publish exports the Stats of the cache, its hit ratio and its Info if the
+// underlying cache supports it under name with expvar, so they show in
+// /debug/vars. Like expvar.Publish it panics if name is already published.
+func (c *Cache) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		s := c.Stats()
+		v := expvarStats{Stats: s, HitRatio: s.HitRatio()}
+		if info, err := c.Info(); err == nil {
+			v.Info = &info
+		}
+		return v
+	}))
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPublish(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithTTL(10*time.Millisecond))
+	c.Set("test:1", "abc")
+	c.Set("test:2", "abc")
+	c.GetString("test:1")
+	c.GetString("test:missing")
+	name := fmt.Sprint("test_cache_", time.Now().UnixNano())
+	c.Publish(name)
+	var v expvarStats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &v); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if v.Hits != 1 || v.Misses != 1 || v.Sets != 2 || v.HitRatio != 0.5 || v.Info == nil || v.Info.Entries != 2 || v.Info.Bytes == 0 {
+		t.Errorf("%+v value error", v)
+		return
+	}
+	time.Sleep(50 * time.Millisecond)
+	info, err := c.Info()
+	if err != nil || info.Entries != 0 || info.Bytes != 0 || info.Sweeps == 0 || info.Swept != 2 || info.LastSweep.IsZero() {
+		t.Errorf("%+v %v value error", info, err)
+	}
+}
@@ -0,0 +1,269 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileBuckets is the number of directories the entry files are spread over,
+// named by the first byte of the hashed key.
+const fileBuckets = 256
+
+// FileCache stores encoded values as files, one per key, spread over hashed
+// bucket directories. It is meant for large blobs that are too big for memory
+// or Redis. The expiration is kept in an entry header, expired files are
+// removed by a background sweeper and a bucket over its share of the size cap
+// drops its least recently used files.
+type FileCache struct {
+	ByteGetters
+	expire   time.Duration
+	absolute bool
+	prefix   string
+	maxSize  int64
+	dir      string
+	m        sync.Mutex
+	r        *rand.Rand
+	bg       Background
+}
+
+type FileOption func(c *FileCache)
+
+func FileWithExpire(expireSecond int) FileOption {
+	return func(c *FileCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func FileWithTTL(ttl time.Duration) FileOption {
+	return func(c *FileCache) {
+		c.expire = ttl
+	}
+}
+
+// FileWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads.
+func FileWithAbsoluteExpire() FileOption {
+	return func(c *FileCache) {
+		c.absolute = true
+	}
+}
+
+// FileWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func FileWithKeyPrefix(prefix string) FileOption {
+	return func(c *FileCache) {
+		c.prefix = prefix
+	}
+}
+
+// FileWithMaxSize caps the total size of the entry files to about maxSize
+// bytes, each bucket directory gets an even share. 0 is unlimited.
+func FileWithMaxSize(maxSize int64) FileOption {
+	return func(c *FileCache) {
+		c.maxSize = maxSize
+	}
+}
+
+// NewFileCache returns a cache stored under dir, creating it when missing.
+// The sweeper runs until ctx is done.
+func NewFileCache(ctx context.Context, dir string, opts ...FileOption) (*Cache, error) {
+	c := &FileCache{
+		dir: dir,
+		r:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = ByteGetters{get: c.Get}
+	for _, fn := range opts {
+		fn(c)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	c.bg.Sweep(ctx, c.expire, func(ctx context.Context) { c.sweep() })
+	return NewCache(c), nil
+}
+
+// path returns the bucket directory and file of key.
+func (c *FileCache) path(key string) (string, string) {
+	sum := sha1.Sum([]byte(c.prefix + key))
+	name := hex.EncodeToString(sum[:])
+	bucket := filepath.Join(c.dir, name[:2])
+	return bucket, filepath.Join(bucket, name)
+}
+
+func (c *FileCache) Set(key string, value interface{}) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c *FileCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+// SetWithTTL writes the entry to a temporary file renamed over the key's
+// file, so readers never see a partial entry.
+func (c *FileCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	bucket, file := c.path(key)
+	if err := os.MkdirAll(bucket, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(bucket, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(EncodeEntry(EncodeBytes(value), ttl))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if c.maxSize > 0 {
+		return c.evict(bucket)
+	}
+	return nil
+}
+
+// Get returns the value of key, rewriting the expiration header to extend it
+// unless the cache uses absolute expiration. Reads also bump the file's
+// modification time, which the size cap evicts by.
+func (c *FileCache) Get(key string) (interface{}, error) {
+	_, file := c.path(key)
+	entry, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	value, ttl, ok := DecodeEntry(entry)
+	if !ok {
+		os.Remove(file)
+		return nil, nil
+	}
+	now := time.Now()
+	if ttl > 0 && !c.absolute {
+		f, err := os.OpenFile(file, os.O_WRONLY, 0)
+		if os.IsNotExist(err) {
+			return value, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var header [8]byte
+		binary.BigEndian.PutUint64(header[:], uint64(now.Add(ttl).UnixNano()))
+		_, err = f.WriteAt(header[:], 0)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		os.Chtimes(file, now, now)
+	}
+	return value, nil
+}
+
+func (c *FileCache) Del(key string) error {
+	_, file := c.path(key)
+	err := os.Remove(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// evict removes the least recently used files of bucket until it is within
+// its share of the size cap.
+func (c *FileCache) evict(bucket string) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	infos, err := ioutil.ReadDir(bucket)
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, info := range infos {
+		size += info.Size()
+	}
+	limit := c.maxSize / fileBuckets
+	if size <= limit {
+		return nil
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		if size <= limit {
+			break
+		}
+		if strings.HasPrefix(info.Name(), ".tmp-") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(bucket, info.Name())); err == nil {
+			size -= info.Size()
+		}
+	}
+	return nil
+}
+
+// sweep removes the expired files of every bucket. Only the header of each
+// file is read.
+func (c *FileCache) sweep() {
+	buckets, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	var header [8]byte
+	for _, b := range buckets {
+		if !b.IsDir() {
+			continue
+		}
+		bucket := filepath.Join(c.dir, b.Name())
+		infos, err := ioutil.ReadDir(bucket)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			if strings.HasPrefix(info.Name(), ".tmp-") {
+				continue
+			}
+			file := filepath.Join(bucket, info.Name())
+			f, err := os.Open(file)
+			if err != nil {
+				continue
+			}
+			_, err = f.ReadAt(header[:], 0)
+			f.Close()
+			expireAt := int64(binary.BigEndian.Uint64(header[:]))
+			if err != nil || (expireAt != 0 && now > expireAt) {
+				os.Remove(file)
+			}
+		}
+	}
+}
+
+// Close stops the expiration sweeper and waits for it to return.
+func (c *FileCache) Close() error {
+	c.bg.Stop()
+	return nil
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSetInt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewFileCache(ctx, t.TempDir(), FileWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestFileSetBytes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewFileCache(ctx, t.TempDir(), FileWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := bytes.Repeat([]byte("test"), 1<<16)
+	c.Set("test:123", v)
+	data, _ := c.GetBytes("test:123")
+	if !bytes.Equal(v, data) {
+		t.Errorf("%v value error", len(data))
+		return
+	}
+}
+
+func TestFileDel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewFileCache(ctx, t.TempDir(), FileWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+// fileCount returns the number and total size of the files under dir.
+func fileCount(dir string) (int, int64) {
+	count, size := 0, int64(0)
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+			size += info.Size()
+		}
+		return nil
+	})
+	return count, size
+}
+
+func TestFileExpire(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	c, err := NewFileCache(ctx, dir, FileWithTTL(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("test:123", true)
+	c.Set("test:456", true)
+	time.Sleep(500 * time.Millisecond)
+	data, err := c.GetBool("test:123")
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+	if count, _ := fileCount(dir); count != 0 {
+		t.Errorf("%v files not swept", count)
+		return
+	}
+}
+
+func TestFileExtend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewFileCache(ctx, t.TempDir(), FileWithTTL(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test:123"
+	c.Set(key, true)
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		data, _ := c.GetBool(key)
+		if data == nil || !*data {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestFileMaxSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	maxSize := int64(fileBuckets * 150)
+	c, err := NewFileCache(ctx, dir, FileWithExpire(10), FileWithMaxSize(maxSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := bytes.Repeat([]byte("t"), 50)
+	for i := 0; i < 2000; i++ {
+		c.Set(fmt.Sprintf("test:%d", i), v)
+	}
+	if _, size := fileCount(dir); size > maxSize {
+		t.Errorf("%v size over %v", size, maxSize)
+		return
+	}
+	data, _ := c.GetBytes("test:1999")
+	if !bytes.Equal(v, data) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
@@ -0,0 +1,155 @@
+// Package freecache stores the entries of a cache.Cache in a FreeCache.
+package freecache
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+	"time"
+
+	coocood "github.com/coocood/freecache"
+
+	"mcache/cache"
+)
+
+// FreeCacheCache stores encoded values in a FreeCache, which preallocates
+// its memory and keeps entries off the GC heap. FreeCache expires entries in
+// whole seconds, the exact expiration is kept in an entry header and checked
+// on read. Reads do not extend it, rewriting the entry on each read could
+// overwrite a concurrent Set with the value read.
+type FreeCacheCache struct {
+	cache.ByteGetters
+	expire time.Duration
+	prefix string
+	clk    cache.Clock
+	client *coocood.Cache
+	m      sync.Mutex
+	r      *rand.Rand
+}
+
+type FreeCacheOption func(c *FreeCacheCache)
+
+func FreeCacheWithExpire(expireSecond int) FreeCacheOption {
+	return func(c *FreeCacheCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func FreeCacheWithTTL(ttl time.Duration) FreeCacheOption {
+	return func(c *FreeCacheCache) {
+		c.expire = ttl
+	}
+}
+
+// FreeCacheWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func FreeCacheWithKeyPrefix(prefix string) FreeCacheOption {
+	return func(c *FreeCacheCache) {
+		c.prefix = prefix
+	}
+}
+
+// FreeCacheWithClock takes the time of the expirations from clk instead of
+// the system clock, e.g. a FakeClock in tests.
+func FreeCacheWithClock(clk cache.Clock) FreeCacheOption {
+	return func(c *FreeCacheCache) {
+		c.clk = clk
+	}
+}
+
+func NewFreeCache(client *coocood.Cache, opts ...FreeCacheOption) *cache.Cache {
+	c := &FreeCacheCache{
+		client: client,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = cache.NewByteGetters(c.Get)
+	for _, fn := range opts {
+		fn(c)
+	}
+	c.clk = cache.ClockOf(c)
+	return cache.NewCache(c)
+}
+
+func (c *FreeCacheCache) Clock() cache.Clock {
+	return c.clk
+}
+
+// freecacheExpire returns the expiration of an entry of ttl in freecache, a
+// second later than the header. FreeCache expires entries on whole second
+// boundaries, so the exact expiration of the header must come first.
+func freecacheExpire(ttl time.Duration) int {
+	if ttl <= 0 {
+		return 0
+	}
+	return cache.TTLSeconds(ttl) + 1
+}
+
+func (c *FreeCacheCache) Set(key string, value interface{}) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c *FreeCacheCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *FreeCacheCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	return c.client.Set([]byte(c.prefix+key), cache.EncodeEntryAt(c.clk.Now(), cache.EncodeBytes(value), ttl), freecacheExpire(ttl))
+}
+
+func (c *FreeCacheCache) Get(key string) (interface{}, error) {
+	if c.client == nil {
+		return nil, cache.ErrNoClient
+	}
+	entry, err := c.client.Get([]byte(c.prefix + key))
+	if err == coocood.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// an expired entry is left to freecache, dropping it a second later
+	value, _, ok := cache.DecodeEntryAt(c.clk.Now(), entry)
+	if !ok {
+		return nil, nil
+	}
+	return value, nil
+}
+
+func (c *FreeCacheCache) Del(key string) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	c.client.Del([]byte(c.prefix + key))
+	return nil
+}
+
+// Clear deletes the entries under the key prefix, clearing the whole
+// freecache without one.
+func (c *FreeCacheCache) Clear() error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	if c.prefix == "" {
+		c.client.Clear()
+		return nil
+	}
+	var keys [][]byte
+	it := c.client.NewIterator()
+	for entry := it.Next(); entry != nil; entry = it.Next() {
+		if bytes.HasPrefix(entry.Key, []byte(c.prefix)) {
+			keys = append(keys, entry.Key)
+		}
+	}
+	for _, k := range keys {
+		c.client.Del(k)
+	}
+	return nil
+}
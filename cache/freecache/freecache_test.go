@@ -0,0 +1,100 @@
+package freecache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	coocood "github.com/coocood/freecache"
+
+	"mcache/cache"
+	"mcache/cache/cachetest"
+)
+
+func getFreeCacheT(t *testing.T) *coocood.Cache {
+	return coocood.NewCache(1024 * 1024)
+}
+
+func TestFreeCacheSetInt(t *testing.T) {
+	c := NewFreeCache(getFreeCacheT(t), FreeCacheWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestFreeCacheSetBytes(t *testing.T) {
+	c := NewFreeCache(getFreeCacheT(t), FreeCacheWithExpire(10))
+	v := []byte("test")
+	c.Set("test:123", v)
+	data, _ := c.GetBytes("test:123")
+	if data == nil || !bytes.Equal(v, data) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestFreeCacheSetBool(t *testing.T) {
+	c := NewFreeCache(getFreeCacheT(t), FreeCacheWithExpire(10))
+	v := true
+	c.Set("test:123", v)
+	data, _ := c.GetBool("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestFreeCacheDel(t *testing.T) {
+	c := NewFreeCache(getFreeCacheT(t), FreeCacheWithExpire(10))
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestFreeCacheExpire(t *testing.T) {
+	clk := cache.NewFakeClock(time.Now())
+	c := NewFreeCache(getFreeCacheT(t), FreeCacheWithTTL(200*time.Millisecond), FreeCacheWithClock(clk))
+	key := "test:123"
+	c.Set(key, true)
+	data, _ := c.GetBool(key)
+	if data == nil || !*data {
+		t.Errorf("%v value error", data)
+		return
+	}
+	clk.Advance(300 * time.Millisecond)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestFreeCacheReadKeepsExpire(t *testing.T) {
+	clk := cache.NewFakeClock(time.Now())
+	c := NewFreeCache(getFreeCacheT(t), FreeCacheWithTTL(200*time.Millisecond), FreeCacheWithClock(clk))
+	key := "test:123"
+	c.Set(key, true)
+	clk.Advance(100 * time.Millisecond)
+	if data, _ := c.GetBool(key); data == nil || !*data {
+		t.Errorf("%v value error", data)
+		return
+	}
+	clk.Advance(200 * time.Millisecond)
+	if data, _ := c.GetBool(key); data != nil {
+		t.Errorf("%v value error", *data)
+	}
+}
+
+func TestFreeCacheClear(t *testing.T) {
+	client := getFreeCacheT(t)
+	cachetest.Clear(t, NewFreeCache(client, FreeCacheWithKeyPrefix("svcA:")), NewFreeCache(client, FreeCacheWithKeyPrefix("svcB:")))
+}
@@ -1,8 +1,15 @@
 package cache
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"math/rand"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -10,19 +17,194 @@ import (
 
 const (
 	getCacheStr string = `
-	local key = KEYS[1]
+	local key,slide = KEYS[1],ARGV[1]
 	local value = redis.call('hget', key, 'data')
-	local expire = redis.call('hget', key, 'exp')
-	if (value ~= false) and (tonumber(expire) ~= 0)
+	local expire = redis.call('hmget', key, 'exp', 'pexp')
+	if (value ~= false) and (slide ~= '0')
 	then
-		redis.call('expire', key, expire)
+		if (expire[2] ~= false) and (tonumber(expire[2]) ~= 0)
+		then
+			redis.call('pexpire', key, expire[2])
+		elseif tonumber(expire[1]) ~= 0
+		then
+			redis.call('expire', key, expire[1])
+		end
 	end
+	--after_get
+	return value
+	`
+
+	// getFixedCacheStr is getCacheStr for absolute expiration: it only reads
+	// the entry, leaving the ttl set by the write.
+	getFixedCacheStr string = `
+	local key = KEYS[1]
+	local value = redis.call('hget', key, 'data')
+	--after_get
 	return value
 	`
 
 	setCacheStr string = `
-	local key,value,expire = KEYS[1],ARGV[1],ARGV[2]
-	redis.call('hmset', key, 'data', value, 'exp', expire)
+	local key,value,expire,unit = KEYS[1],ARGV[1],ARGV[2],ARGV[3]
+	--before_set
+	if unit == 'ms'
+	then
+		redis.call('hmset', key, 'data', value, 'exp', 0, 'pexp', expire)
+	else
+		redis.call('hmset', key, 'data', value, 'exp', expire)
+		if redis.call('hexists', key, 'pexp') == 1
+		then
+			redis.call('hdel', key, 'pexp')
+		end
+	end
+	redis.call('hincrby', key, 'ver', 1)
+	if (tonumber(expire) ~= 0) and (unit == 'ms')
+	then
+		redis.call('pexpire', key, expire)
+	elseif tonumber(expire) ~= 0
+	then
+		redis.call('expire', key, expire)
+	end
+	--after_set
+	`
+
+	appendCacheStr string = `
+	local key,suffix,expire = KEYS[1],ARGV[1],ARGV[2]
+	local value = redis.call('hget', key, 'data')
+	if value == false
+	then
+		redis.call('hmset', key, 'data', suffix, 'exp', expire)
+		redis.call('hincrby', key, 'ver', 1)
+		if tonumber(expire) ~= 0
+		then
+			redis.call('expire', key, expire)
+		end
+		return string.len(suffix)
+	end
+	value = value .. suffix
+	redis.call('hset', key, 'data', value)
+	redis.call('hincrby', key, 'ver', 1)
+	return string.len(value)
+	`
+
+	// renameCacheStr renames KEYS[1] to KEYS[2], then fixes up the entry as
+	// renameFixupStr does.
+	renameCacheStr string = `
+	local key = KEYS[2]
+	if redis.call('exists', KEYS[1]) == 0
+	then
+		return 0
+	end
+	local ver = 0
+	if redis.call('type', key).ok == 'hash'
+	then
+		ver = tonumber(redis.call('hget', key, 'ver') or '0')
+	end
+	redis.call('rename', KEYS[1], key)
+	` + renameFixupBody
+
+	// renameFixupStr fixes up the entry moved to KEYS[1] over an entry of
+	// version ARGV[1]: its version is bumped past both, so the near caches
+	// of the replaced entry see the change, and a moved entry without ttl
+	// loses its exp fields, so Get does not start expiring it.
+	renameFixupStr string = `
+	local key,ver = KEYS[1],tonumber(ARGV[1])
+	` + renameFixupBody
+
+	renameFixupBody string = `
+	if (redis.call('type', key).ok ~= 'hash') or (redis.call('hexists', key, 'data') == 0)
+	then
+		return 1
+	end
+	local cur = tonumber(redis.call('hget', key, 'ver') or '0')
+	if cur < ver
+	then
+		cur = ver
+	end
+	redis.call('hset', key, 'ver', cur + 1)
+	if redis.call('pttl', key) < 0
+	then
+		redis.call('hset', key, 'exp', 0)
+		redis.call('hdel', key, 'pexp')
+	end
+	return 1
+	`
+
+	// the fields set by HSet are tagged with hashFieldPrefix, apart from
+	// the fields of the entries written by Set, and the hash scripts return
+	// -1 on a key holding such an entry
+	hsetCacheStr string = `
+	local key,field,value,expire = KEYS[1],ARGV[1],ARGV[2],ARGV[3]
+	if redis.call('hexists', key, 'data') == 1
+	then
+		return -1
+	end
+	redis.call('hset', key, 'f:' .. field, value)
+	if tonumber(expire) ~= 0
+	then
+		redis.call('expire', key, expire)
+	end
+	return 1
+	`
+
+	hgetCacheStr string = `
+	local key,field = KEYS[1],ARGV[1]
+	if redis.call('hexists', key, 'data') == 1
+	then
+		return -1
+	end
+	return redis.call('hget', key, 'f:' .. field)
+	`
+
+	hgetallCacheStr string = `
+	local key = KEYS[1]
+	if redis.call('hexists', key, 'data') == 1
+	then
+		return -1
+	end
+	return redis.call('hgetall', key)
+	`
+
+	lpushCacheStr string = `
+	local key,expire = KEYS[1],ARGV[1]
+	for i=2,#ARGV do
+		redis.call('lpush', key, ARGV[i])
+	end
+	if tonumber(expire) ~= 0
+	then
+		redis.call('expire', key, expire)
+	end
+	`
+
+	saddCacheStr string = `
+	local key,expire = KEYS[1],ARGV[1]
+	for i=2,#ARGV do
+		redis.call('sadd', key, ARGV[i])
+	end
+	if tonumber(expire) ~= 0
+	then
+		redis.call('expire', key, expire)
+	end
+	`
+
+	rangeCacheStr string = `
+	local key = KEYS[1]
+	if redis.call('type', key).ok ~= 'hash'
+	then
+		return false
+	end
+	local value = redis.call('hget', key, 'data')
+	if value == false
+	then
+		return false
+	end
+	return {value, redis.call('pttl', key)}
+	`
+
+	zaddCacheStr string = `
+	local key,expire = KEYS[1],ARGV[1]
+	for i=2,#ARGV,2 do
+		redis.call('zadd', key, ARGV[i], ARGV[i+1])
+	end
 	if tonumber(expire) ~= 0
 	then
 		redis.call('expire', key, expire)
@@ -30,15 +212,163 @@ const (
 	`
 )
 
+// zaddArgs flattens members into score/member pairs for zaddCacheStr,
+// returning ErrValueTooLarge for a member longer than max bytes.
+func zaddArgs(args []interface{}, members []Z, max int) ([]interface{}, error) {
+	for _, m := range members {
+		member, err := encodeValueMax(m.Member, max)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, m.Score, member)
+	}
+	return args, nil
+}
+
+// scoreArg formats a score bound for ZRANGEBYSCORE.
+func scoreArg(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// rangeEntry parses a rangeCacheStr result into the value and remaining
+// ttl of an entry, 0 when it does not expire.
+func rangeEntry(result interface{}) ([]byte, time.Duration, bool) {
+	pair, ok := result.([]interface{})
+	if !ok || len(pair) != 2 {
+		return nil, 0, false
+	}
+	var data []byte
+	switch v := pair[0].(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return nil, 0, false
+	}
+	pttl, ok := pair[1].(int64)
+	if !ok {
+		return nil, 0, false
+	}
+	if pttl < 0 {
+		pttl = 0
+	}
+	return data, time.Duration(pttl) * time.Millisecond, true
+}
+
+// errStopRange ends a scan when the range function returns false.
+var errStopRange = errors.New("range stopped")
+
+// newRangeVisitor returns a function passing the key k and rangeCacheStr
+// result of an entry to fn, without the key prefix. Calls are serialized,
+// as the masters of a cluster are scanned concurrently, and fail with
+// errStopRange once fn returned false.
+func newRangeVisitor(prefix string, fn func(key string, value interface{}, ttl time.Duration) bool) func(k string, result interface{}) error {
+	var m sync.Mutex
+	stopped := false
+	return func(k string, result interface{}) error {
+		data, ttl, ok := rangeEntry(result)
+		if !ok {
+			return ErrDataType
+		}
+		m.Lock()
+		defer m.Unlock()
+		if stopped || !fn(strings.TrimPrefix(k, prefix), data, ttl) {
+			stopped = true
+			return errStopRange
+		}
+		return nil
+	}
+}
+
+// ScriptHooks are lua snippets run by the redis caches inside the scripts
+// of Get and Set, e.g. to stamp metadata fields on the hash of an entry or
+// to enforce a quota. Each runs in its own block seeing the locals of the
+// script: key, the hash of the entry, and value, with expire and unit, the
+// expiration and "s" or "ms", in the Set hooks. A hook may assign value, or
+// return to end the script, e.g. with redis.error_reply to reject a write.
+type ScriptHooks struct {
+	BeforeSet string // before the entry is written
+	AfterSet  string // after the entry is written and its ttl set
+	AfterGet  string // after the read, value is false on a miss
+}
+
+// script returns the lua source of the script str with the hooks inserted.
+func (h ScriptHooks) script(str string) string {
+	block := func(hook string) string {
+		if hook == "" {
+			return ""
+		}
+		return "do\n" + hook + "\nend"
+	}
+	return strings.NewReplacer(
+		"--before_set", block(h.BeforeSet),
+		"--after_set", block(h.AfterSet),
+		"--after_get", block(h.AfterGet),
+	).Replace(str)
+}
+
+// slideArg returns the getCacheStr argument that enables or disables
+// refreshing the expiration on read.
+func slideArg(absolute bool) int {
+	if absolute {
+		return 0
+	}
+	return 1
+}
+
+// getCacheScript returns the lua source of the Get script, getFixedCacheStr
+// with absolute expiration so reads do not write.
+func getCacheScript(absolute bool) string {
+	if absolute {
+		return getFixedCacheStr
+	}
+	return getCacheStr
+}
+
 var (
-	luaGetCache = redis.NewScript(getCacheStr)
 	luaSetCache = redis.NewScript(setCacheStr)
+
+	luaRangeCache = redis.NewScript(rangeCacheStr)
+
+	luaAppendCache = redis.NewScript(appendCacheStr)
+	luaRenameCache = redis.NewScript(renameCacheStr)
+	luaRenameFixup = redis.NewScript(renameFixupStr)
+	luaHSetCache   = redis.NewScript(hsetCacheStr)
+	luaHGetCache   = redis.NewScript(hgetCacheStr)
+	luaHGetAll     = redis.NewScript(hgetallCacheStr)
+	luaLPushCache  = redis.NewScript(lpushCacheStr)
+	luaSAddCache   = redis.NewScript(saddCacheStr)
+	luaZAddCache   = redis.NewScript(zaddCacheStr)
+
+	luaGetLease   = redis.NewScript(getLeaseStr)
+	luaSetLease   = redis.NewScript(setLeaseStr(setCacheStr))
+	luaInvalidate = redis.NewScript(invalidateStr)
+
+	luaGetVersion = redis.NewScript(getVersionStr)
 )
 
 type GoredisCache struct {
 	expireSec int
+	ttl       time.Duration
+	ms        bool
+	absolute  bool
+	hooks     ScriptHooks
+	prefix    string
+	tlsConfig *tls.Config
+	username  string
+	password  string
+	maxValue  int
+	expireCtx context.Context
+	expireFn  CacheExpireFunc
+	timeout   time.Duration
+	retries   int
+	backoff   time.Duration
 	client    redis.UniversalClient
 	r         *rand.Rand
+	getScript *redis.Script
+	setScript *redis.Script
+	setLease  *redis.Script
 }
 
 type GoredisOption func(c *GoredisCache)
@@ -46,43 +376,337 @@ type GoredisOption func(c *GoredisCache)
 func GoredisWithExpire(expireSecond int) GoredisOption {
 	return func(c *GoredisCache) {
 		c.expireSec = expireSecond
+		c.ttl = time.Duration(expireSecond) * time.Second
+	}
+}
+
+// GoredisExpireNotify calls fn with the keys of the cache expiring in
+// redis, read from the keyspace notifications like Subscribe, until ctx is
+// done. The value is nil. The server must have notify-keyspace-events
+// including "Kx".
+func GoredisExpireNotify(ctx context.Context, fn CacheExpireFunc) GoredisOption {
+	return func(c *GoredisCache) {
+		c.expireCtx = ctx
+		c.expireFn = fn
+	}
+}
+
+// GoredisWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads. Get then runs a script only
+// reading the entry, without the EXPIRE write of every read.
+func GoredisWithAbsoluteExpire() GoredisOption {
+	return func(c *GoredisCache) {
+		c.absolute = true
+	}
+}
+
+// GoredisWithTTL sets the default expiration, rounded up to whole seconds.
+func GoredisWithTTL(ttl time.Duration) GoredisOption {
+	return func(c *GoredisCache) {
+		c.expireSec = TTLSeconds(ttl)
+		c.ttl = ttl
 	}
 }
 
+// GoredisWithMillisecondTTL expires the entries written by Set, SetWithTTL,
+// Warm and pipelines with PEXPIRE, keeping the milliseconds of their ttl and
+// of the default expiration instead of rounding them up to seconds. Hashes,
+// lists, sets and sorted sets still expire in whole seconds.
+func GoredisWithMillisecondTTL() GoredisOption {
+	return func(c *GoredisCache) {
+		c.ms = true
+	}
+}
+
+// GoredisWithMaxValueSize rejects with ErrValueTooLarge the values, and the
+// members of lists, sets and sorted sets, longer than n bytes once encoded,
+// and the Append suffixes longer than n bytes, without sending them to redis.
+// 0 is unlimited.
+func GoredisWithMaxValueSize(n int) GoredisOption {
+	return func(c *GoredisCache) {
+		c.maxValue = n
+	}
+}
+
+// GoredisWithScriptHooks runs hooks inside the scripts of Get and Set, and
+// of the batches, MGet, MSet and Warm using them.
+func GoredisWithScriptHooks(hooks ScriptHooks) GoredisOption {
+	return func(c *GoredisCache) {
+		c.hooks = hooks
+		c.setScript = redis.NewScript(hooks.script(setCacheStr))
+		c.setLease = redis.NewScript(setLeaseStr(hooks.script(setCacheStr)))
+	}
+}
+
+// GoredisWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func GoredisWithKeyPrefix(prefix string) GoredisOption {
+	return func(c *GoredisCache) {
+		c.prefix = prefix
+	}
+}
+
+// GoredisWithTLSConfig connects over TLS with cfg. It applies to the
+// constructors that create their own client, e.g. NewGoredisCacheAddr.
+func GoredisWithTLSConfig(cfg *tls.Config) GoredisOption {
+	return func(c *GoredisCache) {
+		c.tlsConfig = cfg
+	}
+}
+
+// GoredisWithUsernamePassword authenticates as the ACL user username, or
+// with the legacy requirepass password when username is empty. It applies to
+// the constructors that create their own client, e.g. NewGoredisCacheAddr.
+func GoredisWithUsernamePassword(username, password string) GoredisOption {
+	return func(c *GoredisCache) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// GoredisWithTimeout bounds the network reads and writes of each command, and
+// the wait for a pooled connection, to d instead of the 3 seconds default of
+// go-redis, so a slow redis fails the operations quickly rather than hanging
+// the callers. go-redis v6 sets timeouts per client, so NewGoredisCache runs
+// on a copy of a *redis.Client or *redis.ClusterClient with its own connection
+// pool unless the client already uses them. Other clients are used as they
+// are.
+func GoredisWithTimeout(d time.Duration) GoredisOption {
+	return func(c *GoredisCache) {
+		c.timeout = d
+	}
+}
+
+// GoredisWithRetry retries a command failing on a network error or a timeout
+// up to n times, waiting backoff before each retry. A retried write may be
+// applied twice. It applies to the client as GoredisWithTimeout does.
+func GoredisWithRetry(n int, backoff time.Duration) GoredisOption {
+	return func(c *GoredisCache) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// goredisConnOptions returns the cache configured by opts, for the
+// constructors that need the connection options before the client exists.
+func goredisConnOptions(opts []GoredisOption) *GoredisCache {
+	c := &GoredisCache{}
+	for _, fn := range opts {
+		fn(c)
+	}
+	return c
+}
+
+// applyConn sets the TLS and auth options on the fields of a client options
+// struct, leaving them alone when the matching option is not set. go-redis
+// v6 only sends AUTH with a password, so ACL users authenticate in onConnect.
+func (c *GoredisCache) applyConn(tlsConfig **tls.Config, password *string, onConnect *func(*redis.Conn) error) {
+	if c.tlsConfig != nil {
+		*tlsConfig = c.tlsConfig
+	}
+	if c.username == "" {
+		if c.password != "" {
+			*password = c.password
+		}
+		return
+	}
+	*password = ""
+	username, pass, next := c.username, c.password, *onConnect
+	*onConnect = func(conn *redis.Conn) error {
+		if err := conn.Do("AUTH", username, pass).Err(); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(conn)
+		}
+		return nil
+	}
+}
+
+// applyRetry sets the timeout and retry options on the fields of a client
+// options struct, leaving them alone when the matching option is not set. It
+// reports whether it changed any of them.
+func (c *GoredisCache) applyRetry(readTimeout, writeTimeout, poolTimeout *time.Duration, maxRetries *int, minBackoff, maxBackoff *time.Duration) bool {
+	changed := false
+	if c.timeout > 0 && (*readTimeout != c.timeout || *writeTimeout != c.timeout || *poolTimeout != c.timeout) {
+		*readTimeout, *writeTimeout, *poolTimeout = c.timeout, c.timeout, c.timeout
+		changed = true
+	}
+	if c.retries > 0 && (*maxRetries != c.retries || *minBackoff != c.backoff || *maxBackoff != c.backoff) {
+		*maxRetries, *minBackoff, *maxBackoff = c.retries, c.backoff, c.backoff
+		if c.backoff <= 0 {
+			// go-redis takes 0 as its default backoff, -1 as none
+			*minBackoff, *maxBackoff = -1, -1
+		}
+		changed = true
+	}
+	return changed
+}
+
+// goredisRetry returns client, or a copy of it with its own connection pool
+// when client does not already use the timeout and retry options of c, so
+// they do not change the commands of the other users of client.
+func (c *GoredisCache) goredisRetry(client redis.UniversalClient) redis.UniversalClient {
+	switch cl := client.(type) {
+	case *redis.Client:
+		opt := *cl.Options()
+		if c.applyRetry(&opt.ReadTimeout, &opt.WriteTimeout, &opt.PoolTimeout, &opt.MaxRetries, &opt.MinRetryBackoff, &opt.MaxRetryBackoff) {
+			return redis.NewClient(&opt)
+		}
+	case *redis.ClusterClient:
+		opt := *cl.Options()
+		if c.applyRetry(&opt.ReadTimeout, &opt.WriteTimeout, &opt.PoolTimeout, &opt.MaxRetries, &opt.MinRetryBackoff, &opt.MaxRetryBackoff) {
+			return redis.NewClusterClient(&opt)
+		}
+	}
+	return client
+}
+
 func NewGoredisCache(client redis.UniversalClient, opts ...GoredisOption) *Cache {
 	c := &GoredisCache{
-		client: client,
-		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		client:    client,
+		r:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		setScript: luaSetCache,
+		setLease:  luaSetLease,
 	}
 	for _, fn := range opts {
 		fn(c)
 	}
+	c.getScript = redis.NewScript(c.hooks.script(getCacheScript(c.absolute)))
+	c.client = c.goredisRetry(client)
+	if c.expireFn != nil {
+		notifyExpire(c.expireCtx, c, c.expireFn)
+	}
 	return NewCache(c)
 }
 
+// NewGoredisClusterCache returns a cache on a Redis Cluster. The client
+// routes every script by its key and follows MOVED and ASK redirections.
+// Use a hash tagged prefix like "{svcA}:" to keep all keys in one slot.
+func NewGoredisClusterCache(opt *redis.ClusterOptions, opts ...GoredisOption) *Cache {
+	conn := goredisConnOptions(opts)
+	conn.applyConn(&opt.TLSConfig, &opt.Password, &opt.OnConnect)
+	conn.applyRetry(&opt.ReadTimeout, &opt.WriteTimeout, &opt.PoolTimeout, &opt.MaxRetries, &opt.MinRetryBackoff, &opt.MaxRetryBackoff)
+	return NewGoredisCache(redis.NewClusterClient(opt), opts...)
+}
+
+// NewGoredisCacheSentinel returns a cache on the master monitored by the
+// sentinels at opt.SentinelAddrs as opt.MasterName. The client asks the
+// sentinels for the master and follows failovers.
+func NewGoredisCacheSentinel(opt *redis.FailoverOptions, opts ...GoredisOption) *Cache {
+	conn := goredisConnOptions(opts)
+	conn.applyConn(&opt.TLSConfig, &opt.Password, &opt.OnConnect)
+	conn.applyRetry(&opt.ReadTimeout, &opt.WriteTimeout, &opt.PoolTimeout, &opt.MaxRetries, &opt.MinRetryBackoff, &opt.MaxRetryBackoff)
+	return NewGoredisCache(redis.NewFailoverClient(opt), opts...)
+}
+
+// NewGoredisCacheAddr returns a cache on the redis server at addr, e.g. a
+// managed service needing GoredisWithTLSConfig and
+// GoredisWithUsernamePassword.
+func NewGoredisCacheAddr(addr string, opts ...GoredisOption) *Cache {
+	opt := &redis.Options{Addr: addr}
+	conn := goredisConnOptions(opts)
+	conn.applyConn(&opt.TLSConfig, &opt.Password, &opt.OnConnect)
+	conn.applyRetry(&opt.ReadTimeout, &opt.WriteTimeout, &opt.PoolTimeout, &opt.MaxRetries, &opt.MinRetryBackoff, &opt.MaxRetryBackoff)
+	return NewGoredisCache(redis.NewClient(opt), opts...)
+}
+
 func (c *GoredisCache) Set(key string, value interface{}) error {
-	if c.client == nil {
-		return ErrNoRedis
-	}
+	exp, unit := c.defaultExpireArgs()
+	return c.set(key, value, exp, unit)
+}
+
+// defaultExpire returns the default expiration with up to 10% of jitter, so
+// keys written together do not expire together.
+func (c *GoredisCache) defaultExpire() int {
 	exp := c.expireSec
 	if exp != 0 {
 		exp += c.r.Intn(int(exp/10 + 1))
 	}
-	return luaSetCache.Run(c.client, []string{key}, value, exp).Err()
+	return exp
+}
+
+// expireArgs returns the expiration and unit arguments of setCacheStr for
+// ttl, in milliseconds with GoredisWithMillisecondTTL, else in seconds.
+func (c *GoredisCache) expireArgs(ttl time.Duration) (int64, string) {
+	if c.ms {
+		return ttlMillis(ttl), "ms"
+	}
+	return int64(TTLSeconds(ttl)), "s"
+}
+
+// defaultExpireArgs returns the expireArgs of the default expiration, with
+// jitter.
+func (c *GoredisCache) defaultExpireArgs() (int64, string) {
+	if !c.ms {
+		return int64(c.defaultExpire()), "s"
+	}
+	exp := ttlMillis(c.ttl)
+	if exp != 0 {
+		exp += c.r.Int63n(exp/10 + 1)
+	}
+	return exp, "ms"
+}
+
+// Warm stores entries in a single pipeline.
+func (c *GoredisCache) Warm(ctx context.Context, entries map[string]ValueWithTTL) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	pipe := c.client.Pipeline()
+	for key, v := range entries {
+		exp, unit := c.expireArgs(v.TTL)
+		if v.TTL <= 0 {
+			exp, unit = c.defaultExpireArgs()
+		}
+		value, err := encodeValueMax(v.Value, c.maxValue)
+		if err != nil {
+			return err
+		}
+		// EVALSHA would fail on a script cache flushed mid pipeline
+		c.setScript.Eval(pipe, []string{c.prefix + key}, value, exp, unit)
+	}
+	cmds, _ := pipe.Exec()
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && err != redis.Nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *GoredisCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.set(key, value, int64(expireSec), "s")
+}
+
+// SetWithTTL stores value with the given ttl, rounded up to whole seconds
+// unless GoredisWithMillisecondTTL is set.
+func (c *GoredisCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	exp, unit := c.expireArgs(ttl)
+	return c.set(key, value, exp, unit)
+}
+
+// set stores value expiring after exp in unit, the expireArgs.
+func (c *GoredisCache) set(key string, value interface{}, exp int64, unit string) error {
 	if c.client == nil {
 		return ErrNoRedis
 	}
-	return luaSetCache.Run(c.client, []string{key}, value, expireSec).Err()
+	data, err := encodeValueMax(value, c.maxValue)
+	if err != nil {
+		return err
+	}
+	// the script returns nothing, which go-redis reports as redis.Nil
+	err = c.setScript.Run(c.client, []string{c.prefix + key}, data, exp, unit).Err()
+	if err == redis.Nil {
+		return nil
+	}
+	return err
 }
 
 func (c *GoredisCache) Get(key string) (interface{}, error) {
 	if c.client == nil {
 		return nil, ErrNoRedis
 	}
-	value, err := luaGetCache.Run(c.client, []string{key}).Result()
+	value, err := c.getScript.Run(c.client, []string{c.prefix + key}, slideArg(c.absolute)).Result()
 	if err == redis.Nil || (value == nil && err == nil) {
 		return nil, nil
 	}
@@ -96,6 +720,77 @@ func (c *GoredisCache) Get(key string) (interface{}, error) {
 	return tmp, err
 }
 
+// GetWithVersion returns the value of key and its version, the SHA-1 of
+// the value.
+func (c *GoredisCache) GetWithVersion(key string) (interface{}, string, error) {
+	return c.GetIfChanged(key, "")
+}
+
+// GetIfChanged returns the value of key and its version, or ErrNotModified
+// without reading the value when version is still the SHA-1 of the value.
+func (c *GoredisCache) GetIfChanged(key, version string) (interface{}, string, error) {
+	if c.client == nil {
+		return nil, "", ErrNoRedis
+	}
+	reply, err := luaGetVersion.Run(c.client, []string{c.prefix + key}, slideArg(c.absolute), version).Result()
+	if err == redis.Nil || (reply == nil && err == nil) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return versionReply(reply)
+}
+
+// GetLease returns the value of key, or on a miss a lease on it valid for
+// ttl, or ErrLeaseHeld and the stale value kept by Invalidate while another
+// reader holds the lease. A hit does not extend a sliding expiration.
+func (c *GoredisCache) GetLease(key string, ttl time.Duration) (interface{}, string, error) {
+	if c.client == nil {
+		return nil, "", ErrNoRedis
+	}
+	token := newLeaseToken()
+	reply, err := luaGetLease.Run(c.client, []string{c.prefix + key}, token, leaseMillis(time.Now()), ttlMillis(ttl)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	return leaseReply(reply, token)
+}
+
+// SetLease stores value with the default expiration if token is the lease
+// of key, else returns ErrLeaseInvalid.
+func (c *GoredisCache) SetLease(key string, value interface{}, token string) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	data, err := encodeValueMax(value, c.maxValue)
+	if err != nil {
+		return err
+	}
+	exp, unit := c.defaultExpireArgs()
+	n, err := c.setLease.Run(c.client, []string{c.prefix + key}, data, exp, unit, token).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseInvalid
+	}
+	return nil
+}
+
+// Invalidate deletes key and its lease, keeping its value for staleTTL to
+// be returned by GetLease while the key is loaded again.
+func (c *GoredisCache) Invalidate(key string, staleTTL time.Duration) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	err := luaInvalidate.Run(c.client, []string{c.prefix + key}, ttlMillis(staleTTL)).Err()
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
 func (c *GoredisCache) GetInt(key string) (*int64, error) {
 	value, err := c.Get(key)
 	if value == nil {
@@ -105,6 +800,15 @@ func (c *GoredisCache) GetInt(key string) (*int64, error) {
 	return &data, err
 }
 
+func (c *GoredisCache) GetUint(key string) (*uint64, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseUint(value.(string), 10, 64)
+	return &data, err
+}
+
 func (c *GoredisCache) GetFloat(key string) (*float64, error) {
 	value, err := c.Get(key)
 	if value == nil {
@@ -132,17 +836,563 @@ func (c *GoredisCache) GetBool(key string) (*bool, error) {
 	if value == nil {
 		return nil, err
 	}
-	data, err := strconv.ParseBool(value.(string))
+	data, err := parseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (c *GoredisCache) GetTime(key string) (*time.Time, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := time.Parse(time.RFC3339Nano, value.(string))
 	return &data, err
 }
 
+func (c *GoredisCache) GetDuration(key string) (*time.Duration, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseInt(value.(string), 10, 64)
+	ret := time.Duration(data)
+	return &ret, err
+}
+
+func (c *GoredisCache) GetStringSlice(key string) ([]string, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	var data []string
+	err = json.Unmarshal([]byte(value.(string)), &data)
+	return data, err
+}
+
+func (c *GoredisCache) GetIntSlice(key string) ([]int64, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	var data []int64
+	err = json.Unmarshal([]byte(value.(string)), &data)
+	return data, err
+}
+
+// Subscribe calls fn with the changes of the keys of the cache, read from
+// the redis keyspace notifications, until ctx is done. The server must have
+// notify-keyspace-events set to "KA" or similar. Events have no Value. On a
+// cluster only the keys of the node the subscription is on are reported.
+func (c *GoredisCache) Subscribe(ctx context.Context, fn EventFunc) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	pubsub := c.client.PSubscribe(keyspacePattern(c.prefix))
+	if _, err := pubsub.Receive(); err != nil {
+		pubsub.Close()
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		pubsub.Close()
+	}()
+	go func() {
+		for msg := range pubsub.Channel() {
+			if e, ok := keyspaceEvent(msg.Channel, msg.Payload, c.prefix); ok {
+				fn(e)
+			}
+		}
+	}()
+	return nil
+}
+
+// Ping sends PING to the server.
+func (c *GoredisCache) Ping(ctx context.Context) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.client.Ping().Err()
+}
+
+// Close closes the redis client of the cache.
+func (c *GoredisCache) Close() error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	return c.client.Close()
+}
+
 func (c *GoredisCache) Del(key string) error {
 	if c.client == nil {
 		return ErrNoRedis
 	}
-	err := c.client.Del(key).Err()
+	err := c.client.Del(c.prefix + key).Err()
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+// Rename moves oldKey to newKey with RENAME, which keeps both the value and
+// the remaining ttl, then fixes up the version and exp fields of the entry
+// as renameFixupStr does. On a cluster keys in different slots are moved with
+// DUMP and RESTORE instead, which is not atomic.
+func (c *GoredisCache) Rename(oldKey, newKey string) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	oldKey, newKey = c.prefix+oldKey, c.prefix+newKey
+	if _, ok := c.client.(*redis.ClusterClient); ok && clusterSlot(oldKey) != clusterSlot(newKey) {
+		return c.renameAcrossSlots(oldKey, newKey)
+	}
+	err := luaRenameCache.Run(c.client, []string{oldKey, newKey}).Err()
 	if err == redis.Nil {
 		return nil
 	}
 	return err
 }
+
+func (c *GoredisCache) renameAcrossSlots(oldKey, newKey string) error {
+	// the version of the replaced entry, for the fixup, 0 if none
+	ver, _ := c.client.HGet(newKey, "ver").Int64()
+	value, err := c.client.Dump(oldKey).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ttl, err := c.client.PTTL(oldKey).Result()
+	if err != nil {
+		return err
+	}
+	if ttl < 0 {
+		// -1 has no expiration, RESTORE takes 0 for that
+		ttl = 0
+	}
+	if err := c.client.RestoreReplace(newKey, ttl, value).Err(); err != nil {
+		return err
+	}
+	if err := luaRenameFixup.Run(c.client, []string{newKey}, ver).Err(); err != nil {
+		return err
+	}
+	return c.client.Del(oldKey).Err()
+}
+
+// Clear deletes the keys under the key prefix, every key of the database
+// without one.
+func (c *GoredisCache) Clear() error {
+	return c.DelByPattern("*")
+}
+
+func (c *GoredisCache) DelByPrefix(prefix string) error {
+	return c.DelByPattern(escapeGlob(prefix) + "*")
+}
+
+// DelByPattern deletes the keys matching pattern with SCAN and batched DEL,
+// so redis is not blocked like KEYS would. On a cluster every master is
+// scanned and the keys are deleted one by one in a pipeline, as a multi key
+// DEL fails when the keys are in different slots.
+func (c *GoredisCache) DelByPattern(pattern string) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	match := escapeGlob(c.prefix) + pattern
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(func(node *redis.Client) error {
+			return goredisScan(node, match, func(keys []string) error {
+				_, err := cluster.Pipelined(func(pipe redis.Pipeliner) error {
+					for _, k := range keys {
+						pipe.Del(k)
+					}
+					return nil
+				})
+				return err
+			})
+		})
+	}
+	return goredisScan(c.client, match, func(keys []string) error {
+		return c.client.Del(keys...).Err()
+	})
+}
+
+// Count returns the number of keys of the cache, with DBSIZE without a key
+// prefix and else by counting the keys with the prefix with SCAN, which may
+// return a key twice while redis resizes. Every master of a cluster is
+// counted. The keys of every type are counted, e.g. the ":xfetch" keys of
+// GetOrSetWithTTL.
+func (c *GoredisCache) Count() (int, error) {
+	if c.client == nil {
+		return 0, ErrNoRedis
+	}
+	var n int64
+	count := func(client redis.Cmdable) error {
+		if c.prefix == "" {
+			size, err := client.DBSize().Result()
+			atomic.AddInt64(&n, size)
+			return err
+		}
+		return goredisScan(client, escapeGlob(c.prefix)+"*", func(keys []string) error {
+			atomic.AddInt64(&n, int64(len(keys)))
+			return nil
+		})
+	}
+	var err error
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(func(node *redis.Client) error {
+			return count(node)
+		})
+	} else {
+		err = count(c.client)
+	}
+	return int(n), err
+}
+
+// MemoryUsage estimates the bytes used by the keys of the cache from the
+// MEMORY USAGE of the first memorySample keys found by SCAN, on every master
+// of a cluster, scaled to the number of keys.
+func (c *GoredisCache) MemoryUsage() (int64, error) {
+	if c.client == nil {
+		return 0, ErrNoRedis
+	}
+	var total int64
+	usage := func(client redis.Cmdable) error {
+		var n, sampled, bytes int64
+		err := goredisScan(client, escapeGlob(c.prefix)+"*", func(keys []string) error {
+			n += int64(len(keys))
+			if rest := memorySample - sampled; int64(len(keys)) > rest {
+				keys = keys[:rest]
+			}
+			if len(keys) == 0 {
+				return nil
+			}
+			cmds, _ := client.Pipelined(func(pipe redis.Pipeliner) error {
+				for _, k := range keys {
+					pipe.MemoryUsage(k)
+				}
+				return nil
+			})
+			for _, cmd := range cmds {
+				size, err := cmd.(*redis.IntCmd).Result()
+				if err == redis.Nil {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				sampled++
+				bytes += size
+			}
+			return nil
+		})
+		atomic.AddInt64(&total, memoryEstimate(n, sampled, bytes))
+		return err
+	}
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		err := cluster.ForEachMaster(func(node *redis.Client) error {
+			return usage(node)
+		})
+		return total, err
+	}
+	err := usage(c.client)
+	return total, err
+}
+
+// RangeTTL calls fn for each entry written by Set with its remaining ttl, 0
+// when it does not expire, until fn returns false. Keys are found with
+// SCAN, on every master of a cluster, and read in pipelined batches. Hash,
+// list, set and sorted set entries are skipped.
+func (c *GoredisCache) RangeTTL(fn func(key string, value interface{}, ttl time.Duration) bool) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	visit := newRangeVisitor(c.prefix, fn)
+	batch := func(keys []string) error {
+		pipe := c.client.Pipeline()
+		cmds := make([]*redis.Cmd, len(keys))
+		for i, k := range keys {
+			cmds[i] = luaRangeCache.Eval(pipe, []string{k})
+		}
+		pipe.Exec()
+		for i, cmd := range cmds {
+			result, err := cmd.Result()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := visit(keys[i], result); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	match := escapeGlob(c.prefix) + "*"
+	var err error
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(func(node *redis.Client) error {
+			return goredisScan(node, match, batch)
+		})
+	} else {
+		err = goredisScan(c.client, match, batch)
+	}
+	if err == errStopRange {
+		return nil
+	}
+	return err
+}
+
+// goredisScan scans client for keys matching match and passes every non
+// empty batch to fn.
+func goredisScan(client redis.Cmdable, match string, fn func(keys []string) error) error {
+	cursor := uint64(0)
+	for {
+		keys, next, err := client.Scan(cursor, match, scanCount).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil && err != redis.Nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Append appends suffix to the value stored at key in one round trip,
+// creating it with the default expiration if it does not exist.
+func (c *GoredisCache) Append(key, suffix string) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	if c.maxValue > 0 && len(suffix) > c.maxValue {
+		return ErrValueTooLarge
+	}
+	return luaAppendCache.Run(c.client, []string{c.prefix + key}, suffix, c.expireSec).Err()
+}
+
+// hashFieldPrefix tags the fields set by HSet in a redis hash, so they
+// never mix with the data, exp, pexp and ver fields of the entries written
+// by Set.
+const hashFieldPrefix = "f:"
+
+// hashReply returns the fields set by HSet in the reply of
+// hgetallCacheStr, or ErrDataType when the key holds an entry written by
+// Set.
+func hashReply(reply interface{}) (map[string]interface{}, error) {
+	values, ok := reply.([]interface{})
+	if !ok {
+		return nil, ErrDataType
+	}
+	ret := make(map[string]interface{}, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		var field string
+		switch f := values[i].(type) {
+		case string:
+			field = f
+		case []byte:
+			field = string(f)
+		}
+		if strings.HasPrefix(field, hashFieldPrefix) {
+			ret[field[len(hashFieldPrefix):]] = values[i+1]
+		}
+	}
+	if len(ret) == 0 {
+		return nil, nil
+	}
+	return ret, nil
+}
+
+// HSet sets field of the redis hash stored at key and refreshes its
+// expiration. Hash entries are redis hashes of tagged fields, not the
+// data/exp layout used by Set: HSet and HGet return ErrDataType on a key
+// written by Set. They are not extended on read.
+func (c *GoredisCache) HSet(key, field string, value interface{}) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	data, err := encodeValueMax(value, c.maxValue)
+	if err != nil {
+		return err
+	}
+	n, err := luaHSetCache.Run(c.client, []string{c.prefix + key}, field, data, c.expireSec).Int64()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return ErrDataType
+	}
+	return nil
+}
+
+func (c *GoredisCache) HGet(key, field string) (interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	value, err := luaHGetCache.Run(c.client, []string{c.prefix + key}, field).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := value.(int64); ok {
+		return nil, ErrDataType
+	}
+	return value, nil
+}
+
+func (c *GoredisCache) HGetAll(key string) (map[string]interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	reply, err := luaHGetAll.Run(c.client, []string{c.prefix + key}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return hashReply(reply)
+}
+
+// LPush inserts values at the head of the redis list stored at key and
+// refreshes its expiration.
+func (c *GoredisCache) LPush(key string, values ...interface{}) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	args := make([]interface{}, 0, len(values)+1)
+	args = append(args, c.expireSec)
+	for _, v := range values {
+		value, err := encodeValueMax(v, c.maxValue)
+		if err != nil {
+			return err
+		}
+		args = append(args, value)
+	}
+	err := luaLPushCache.Run(c.client, []string{c.prefix + key}, args...).Err()
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+func (c *GoredisCache) RPop(key string) (interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	value, err := c.client.RPop(c.prefix + key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c *GoredisCache) LRange(key string, start, stop int64) ([]interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	data, err := c.client.LRange(c.prefix+key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]interface{}, len(data))
+	for i, v := range data {
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+// SAdd adds members to the redis set stored at key and refreshes its
+// expiration.
+func (c *GoredisCache) SAdd(key string, members ...interface{}) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	args := make([]interface{}, 0, len(members)+1)
+	args = append(args, c.expireSec)
+	for _, m := range members {
+		member, err := encodeValueMax(m, c.maxValue)
+		if err != nil {
+			return err
+		}
+		args = append(args, member)
+	}
+	err := luaSAddCache.Run(c.client, []string{c.prefix + key}, args...).Err()
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+func (c *GoredisCache) SIsMember(key string, member interface{}) (bool, error) {
+	if c.client == nil {
+		return false, ErrNoRedis
+	}
+	return c.client.SIsMember(c.prefix+key, encodeValue(member)).Result()
+}
+
+func (c *GoredisCache) SMembers(key string) ([]interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	data, err := c.client.SMembers(c.prefix + key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	ret := make([]interface{}, len(data))
+	for i, v := range data {
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+// ZAdd adds members to the redis sorted set stored at key and refreshes its
+// expiration.
+func (c *GoredisCache) ZAdd(key string, members ...Z) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	args, err := zaddArgs([]interface{}{c.expireSec}, members, c.maxValue)
+	if err != nil {
+		return err
+	}
+	err = luaZAddCache.Run(c.client, []string{c.prefix + key}, args...).Err()
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+func (c *GoredisCache) ZRangeByScore(key string, min, max float64) ([]Z, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	data, err := c.client.ZRangeByScoreWithScores(c.prefix+key, redis.ZRangeBy{
+		Min: scoreArg(min),
+		Max: scoreArg(max),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Z, len(data))
+	for i, v := range data {
+		ret[i] = Z{Score: v.Score, Member: v.Member}
+	}
+	return ret, nil
+}
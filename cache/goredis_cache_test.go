@@ -2,7 +2,12 @@ package cache
 
 import (
 	"bytes"
+	"context"
+	"math"
+	"net"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -215,3 +220,576 @@ func TestGoredisSetExpire(t *testing.T) {
 		return
 	}
 }
+
+func TestGoredisSetTime(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	v := time.Now()
+	c.Set("test:123", v)
+	data, _ := c.GetTime("test:123")
+	if data == nil || !data.Equal(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisSetDuration(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	v := 1500 * time.Millisecond
+	c.Set("test:123", v)
+	data, _ := c.GetDuration("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisSetUint(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	v := uint64(math.MaxUint64)
+	c.Set("test:123", v)
+	data, _ := c.GetUint("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisSetSlice(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	c.Set("test:123", []string{"a", "b"})
+	strs, _ := c.GetStringSlice("test:123")
+	if len(strs) != 2 || strs[1] != "b" {
+		t.Errorf("%v value error", strs)
+		return
+	}
+	c.Set("test:123", []int64{1, 2})
+	ints, _ := c.GetIntSlice("test:123")
+	if len(ints) != 2 || ints[1] != 2 {
+		t.Errorf("%v value error", ints)
+		return
+	}
+}
+
+func TestGoredisHash(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	key := "test:hash:123"
+	c.Del(key)
+	c.HSet(key, "name", "test")
+	c.HSet(key, "age", 3)
+	data, _ := c.HGet(key, "age")
+	if data == nil || data != "3" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.HSet(key, "data", "x")
+	all, _ := c.HGetAll(key)
+	if len(all) != 3 || all["data"] == nil {
+		t.Errorf("%v value error", all)
+		return
+	}
+	if value, _ := c.Get(key); value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+	c.Set("test:456", 3)
+	if err := c.HSet("test:456", "name", "test"); err != ErrDataType {
+		t.Errorf("%v error", err)
+		return
+	}
+	if _, err := c.HGetAll("test:456"); err != ErrDataType {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := c.GetInt("test:456"); data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+	}
+	c.Del("test:456")
+}
+
+func TestGoredisList(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	key := "test:list:123"
+	c.Del(key)
+	c.LPush(key, 1, 2)
+	c.LPush(key, 3)
+	data, _ := c.LRange(key, 0, -1)
+	if len(data) != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	value, _ := c.RPop(key)
+	if value == nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+}
+
+func TestGoredisSetMembers(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	key := "test:set:123"
+	c.Del(key)
+	c.SAdd(key, 1, 2, 2)
+	ok, _ := c.SIsMember(key, 2)
+	if !ok {
+		t.Errorf("%v value error", ok)
+		return
+	}
+	data, _ := c.SMembers(key)
+	if len(data) != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisZSet(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	key := "test:zset:123"
+	c.Del(key)
+	c.ZAdd(key, Z{Score: 3, Member: "c"}, Z{Score: 1, Member: "a"}, Z{Score: 2, Member: "b"})
+	c.ZAdd(key, Z{Score: 5, Member: "a"})
+	data, _ := c.ZRangeByScore(key, 2, math.Inf(1))
+	if len(data) != 3 || data[2].Score != 5 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisAppend(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	key := "test:123"
+	c.Del(key)
+	c.Append(key, "a")
+	c.Append(key, "b")
+	data, _ := c.GetString(key)
+	if data != "ab" {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisDelByPattern(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	c.Set("test:user:42:name", "test")
+	c.Set("test:user:43:name", "test")
+	c.DelByPattern("test:user:42:*")
+	data, _ := c.Get("test:user:42:name")
+	if data != nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.DelByPrefix("test:user:")
+	data, _ = c.Get("test:user:43:name")
+	if data != nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisRename(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	c.Set("test:new", 3)
+	c.Rename("test:new", "test:123")
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisAddrSetInt(t *testing.T) {
+	c := NewGoredisCacheAddr(redisAddr, GoredisWithUsernamePassword("", redisPass), GoredisWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisWarm(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	err := c.Warm(context.Background(), map[string]ValueWithTTL{
+		"test:1": {Value: 1},
+		"test:2": {Value: "2", TTL: time.Second},
+	})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ := c.GetInt("test:1")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.GetInt("test:2")
+	if data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisRangeTTL(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10), GoredisWithKeyPrefix("range:"))
+	c.Set("test:1", 1)
+	c.SetWithTTL("test:2", "2", 0)
+	c.HSet("test:hash", "f", 1)
+	got := map[string]time.Duration{}
+	err := c.RangeTTL(func(key string, value interface{}, ttl time.Duration) bool {
+		got[key+"="+string(value.([]byte))] = ttl
+		return true
+	})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if ttl, ok := got["test:1=1"]; !ok || ttl <= 0 || ttl > 11*time.Second {
+		t.Errorf("%v value error", got)
+		return
+	}
+	if ttl, ok := got["test:2=2"]; !ok || ttl != 0 || len(got) != 2 {
+		t.Errorf("%v value error", got)
+		return
+	}
+}
+
+func TestGoredisPipeline(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	for _, tx := range []bool{false, true} {
+		var b Batch
+		var err error
+		if tx {
+			b, err = c.TxPipeline()
+		} else {
+			b, err = c.Pipeline()
+		}
+		if err != nil {
+			t.Errorf("%v error", err)
+			return
+		}
+		b.Set("test:1", 1)
+		b.SetWithTTL("test:2", "2", time.Second)
+		b.Del("test:3")
+		r1 := b.Get("test:1")
+		r3 := b.Get("test:3")
+		if _, err := r1.Value(); err != ErrBatchPending {
+			t.Errorf("%v error", err)
+			return
+		}
+		if err := b.Exec(); err != nil {
+			t.Errorf("%v error", err)
+			return
+		}
+		value, err := r1.Value()
+		if value != "1" || err != nil {
+			t.Errorf("%v %v value error", value, err)
+			return
+		}
+		value, err = r3.Value()
+		if value != nil || err != nil {
+			t.Errorf("%v %v value error", value, err)
+			return
+		}
+		data, _ := c.GetInt("test:2")
+		if data == nil || *data != 2 {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestGoredisTx(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10))
+	c.Set("test:del", 1)
+	err := c.Tx(func(tx TxCache) error {
+		tx.Set("test:1", 1)
+		tx.Del("test:del")
+		return nil
+	})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ := c.GetInt("test:1")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	value, _ := c.Get("test:del")
+	if value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+}
+
+func TestGoredisPing(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t))
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestGoredisSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := getGoRedisT(t)
+	c := NewGoredisCache(client, GoredisWithKeyPrefix("svc:"))
+	events := make(chan Event, 1)
+	if err := c.Subscribe(ctx, func(e Event) { events <- e }); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	client.Publish("__keyspace@0__:svc:test:1", "del")
+	select {
+	case e := <-events:
+		if e.Type != EventDel || e.Key != "test:1" {
+			t.Errorf("%v value error", e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no event error")
+	}
+}
+
+// stallingServer accepts connections and never replies, like an overloaded
+// redis. It returns its address and the number of connections accepted.
+func stallingServer(t *testing.T) (string, *int32) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int32
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		l.Close()
+		<-done
+	})
+	go func() {
+		defer close(done)
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&n, 1)
+			conns = append(conns, conn)
+		}
+	}()
+	return l.Addr().String(), &n
+}
+
+func TestGoredisTimeoutRetry(t *testing.T) {
+	addr, conns := stallingServer(t)
+	opts := []GoredisOption{GoredisWithTimeout(50 * time.Millisecond), GoredisWithRetry(2, 10*time.Millisecond)}
+	for i, c := range []*Cache{
+		NewGoredisCacheAddr(addr, opts...),
+		NewGoredisCache(redis.NewClient(&redis.Options{Addr: addr}), opts...),
+	} {
+		start := time.Now()
+		if _, err := c.Get("test:123"); err == nil {
+			t.Errorf("%v value error", err)
+			return
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("%v timeout error", d)
+		}
+		if n := atomic.LoadInt32(conns); n != int32(3*(i+1)) {
+			t.Errorf("%v value error", n)
+		}
+	}
+}
+
+func TestGoredisMaxValueSize(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10), GoredisWithMaxValueSize(4))
+	key := "test:max"
+	if err := c.Set(key, "abcd"); err != nil {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if err := c.Set(key, "abcde"); err != ErrValueTooLarge {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if err := c.LPush("test:maxlist", "a", "abcde"); err != ErrValueTooLarge {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if data, _ := c.GetString(key); data != "abcd" {
+		t.Errorf("%v value error", data)
+	}
+}
+
+func TestGoredisMillisecondTTL(t *testing.T) {
+	client := getGoRedisT(t)
+	c := NewGoredisCache(client, GoredisWithTTL(1500*time.Millisecond), GoredisWithMillisecondTTL())
+	key := "test:ms"
+	if err := c.SetWithTTL(key, "a", 1500*time.Millisecond); err != nil {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if ttl := client.PTTL(key).Val(); ttl <= time.Second || ttl > 1500*time.Millisecond {
+		t.Errorf("%v value error", ttl)
+		return
+	}
+	if data, _ := c.GetString(key); data != "a" {
+		t.Errorf("%v value error", data)
+	}
+	if ttl := client.PTTL(key).Val(); ttl <= time.Second || ttl > 1500*time.Millisecond {
+		t.Errorf("%v value error", ttl)
+	}
+}
+
+func TestGoredisExpireNotify(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := getGoRedisT(t)
+	keys := make(chan string, 10)
+	NewGoredisCache(client, GoredisWithKeyPrefix("svc:"), GoredisExpireNotify(ctx, func(key string, value interface{}) {
+		keys <- key
+	}))
+	// the subscription starts in the background, publish until it is up
+	for i := 0; i < 20; i++ {
+		client.Publish("__keyspace@0__:svc:test:1", "del")
+		client.Publish("__keyspace@0__:svc:test:1", "expired")
+		select {
+		case key := <-keys:
+			if key != "test:1" {
+				t.Errorf("%v value error", key)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	t.Errorf("no expire error")
+}
+
+func TestGoredisCount(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithKeyPrefix("count:"))
+	c.DelByPrefix("")
+	c.Set("test:1", 1)
+	c.Set("test:2", 2)
+	if n, err := c.Count(); n != 2 || err != nil {
+		t.Errorf("%v value error:%v", n, err)
+	}
+}
+
+func TestGoredisMemoryUsage(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithKeyPrefix("memory:"))
+	c.DelByPrefix("")
+	for i := 0; i < 200; i++ {
+		c.Set("test:"+strconv.Itoa(i), strings.Repeat("a", 1000))
+	}
+	if n, err := c.MemoryUsage(); n < 200*1000 || err != nil {
+		t.Errorf("%v value error:%v", n, err)
+	}
+}
+
+func TestGoredisScriptHooks(t *testing.T) {
+	client := getGoRedisT(t)
+	c := NewGoredisCache(client, GoredisWithExpire(10), GoredisWithScriptHooks(ScriptHooks{
+		BeforeSet: `if string.len(value) > 3 then return redis.error_reply('quota error') end`,
+		AfterSet:  `redis.call('hset', key, 'by', 'test')`,
+		AfterGet: `if value ~= false then
+			redis.call('hincrby', key, 'reads', 1)
+		end`,
+	}))
+	key := "test:hooks"
+	if err := c.Set(key, "abc"); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := c.Set(key, "abcd"); err == nil || !strings.Contains(err.Error(), "quota") {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := c.GetString(key); data != "abc" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if by := client.HGet(key, "by").Val(); by != "test" {
+		t.Errorf("%v value error", by)
+	}
+	if reads := client.HGet(key, "reads").Val(); reads != "1" {
+		t.Errorf("%v value error", reads)
+	}
+}
+
+func TestGoredisBinaryKeys(t *testing.T) {
+	c := NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10), GoredisWithKeyPrefix("\xfe:"))
+	key := string([]byte{0xff, 0x00, '*', 0x80})
+	c.Set(key, 1)
+	c.Set("\xff\x01", 2)
+	if data, _ := c.GetInt(key); data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.Del(key)
+	if data, _ := c.GetInt(key); data != nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if data, _ := c.GetInt("\xff\x01"); data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+	}
+}
+
+func TestGoredisMGetTyped(t *testing.T) {
+	testMGetTyped(t, NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10)))
+}
+
+func TestGoredisAbsoluteExpire(t *testing.T) {
+	client := getGoRedisT(t)
+	c := NewGoredisCache(client, GoredisWithExpire(10), GoredisWithAbsoluteExpire(), GoredisWithScriptHooks(ScriptHooks{
+		AfterGet: `if value ~= false then value = value .. '!' end`,
+	}))
+	key := "test:absolute"
+	c.Set(key, "abc")
+	client.Expire(key, 100*time.Second)
+	if data, _ := c.GetString(key); data != "abc!" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if ttl := client.TTL(key).Val(); ttl <= 10*time.Second {
+		t.Errorf("%v value error", ttl)
+	}
+	c.Del(key)
+}
+
+func TestGoredisRenameFixup(t *testing.T) {
+	client := getGoRedisT(t)
+	c := NewGoredisCache(client, GoredisWithExpire(10))
+	c.Set("test:123", 1)
+	c.Set("test:123", 2)
+	c.Set("test:new", 3)
+	old, _ := client.HGet("test:123", "ver").Int64()
+	if err := c.Rename("test:new", "test:123"); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if ver, _ := client.HGet("test:123", "ver").Int64(); ver <= old {
+		t.Errorf("%v value error", ver)
+		return
+	}
+	if ttl := client.TTL("test:123").Val(); ttl <= 0 || ttl > 11*time.Second {
+		t.Errorf("%v value error", ttl)
+		return
+	}
+	c.Set("test:new", 4)
+	client.Persist("test:new")
+	c.Rename("test:new", "test:123")
+	c.Get("test:123")
+	if ttl := client.TTL("test:123").Val(); ttl >= 0 {
+		t.Errorf("%v value error", ttl)
+	}
+	c.Del("test:123")
+}
+
+func TestGoredisClear(t *testing.T) {
+	client := getGoRedisT(t)
+	testClear(t, NewGoredisCache(client, GoredisWithKeyPrefix("svcA:")), NewGoredisCache(client, GoredisWithKeyPrefix("svcB:")))
+}
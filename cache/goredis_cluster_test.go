@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis"
+)
+
+var redisClusterAddrs = []string{"192.168.3.105:7000", "192.168.3.105:7001", "192.168.3.105:7002"}
+
+func getGoRedisClusterT(t *testing.T) *redis.ClusterOptions {
+	opt := &redis.ClusterOptions{
+		Addrs:    redisClusterAddrs,
+		Password: redisPass,
+	}
+	c := redis.NewClusterClient(opt)
+	defer c.Close()
+	if err := c.Ping().Err(); err != nil {
+		t.Fatal(err)
+	}
+	return opt
+}
+
+func TestGoredisClusterSetInt(t *testing.T) {
+	c := NewGoredisClusterCache(getGoRedisClusterT(t), GoredisWithExpire(10))
+	for i := 0; i < 10; i++ {
+		key := "test:" + string(rune('a'+i))
+		c.Set(key, i)
+		data, _ := c.GetInt(key)
+		if data == nil || *data != int64(i) {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestGoredisClusterDel(t *testing.T) {
+	c := NewGoredisClusterCache(getGoRedisClusterT(t), GoredisWithExpire(10))
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestGoredisClusterHashTag(t *testing.T) {
+	c := NewGoredisClusterCache(getGoRedisClusterT(t), GoredisWithExpire(10), GoredisWithKeyPrefix("{svcA}:"))
+	c.Set("test:new", 3)
+	if err := c.Rename("test:new", "test:123"); err != nil {
+		t.Errorf("rename error:%v", err)
+		return
+	}
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisClusterRenameAcrossSlots(t *testing.T) {
+	c := NewGoredisClusterCache(getGoRedisClusterT(t), GoredisWithExpire(10))
+	c.Set("test:new", 3)
+	if err := c.Rename("test:new", "test:123"); err != nil {
+		t.Errorf("rename error:%v", err)
+		return
+	}
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.GetInt("test:new")
+	if data != nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisClusterDelByPattern(t *testing.T) {
+	c := NewGoredisClusterCache(getGoRedisClusterT(t), GoredisWithExpire(10))
+	for i := 0; i < 10; i++ {
+		c.Set("test:user:"+string(rune('a'+i)), "test")
+	}
+	c.DelByPrefix("test:user:")
+	for i := 0; i < 10; i++ {
+		data, _ := c.Get("test:user:" + string(rune('a'+i)))
+		if data != nil {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestGoredisSentinelSetInt(t *testing.T) {
+	c := NewGoredisCacheSentinel(&redis.FailoverOptions{
+		MasterName:    sentinelMasterName,
+		SentinelAddrs: sentinelAddrs,
+		Password:      redisPass,
+	}, GoredisWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// goredisBatchGet is a Get queued in a goredisBatch.
+type goredisBatchGet struct {
+	cmd    *redis.Cmd
+	result *BatchResult
+}
+
+// goredisBatch queues the scripts of a GoredisCache on a go-redis pipeline.
+// Scripts are sent with EVAL rather than EVALSHA, whose failure on a
+// flushed script cache could not be retried within the pipeline.
+type goredisBatch struct {
+	c    *GoredisCache
+	pipe redis.Pipeliner
+	gets []goredisBatchGet
+	err  error
+}
+
+// Pipeline returns a batch sent on a go-redis Pipeliner.
+func (c *GoredisCache) Pipeline() Batch {
+	b := &goredisBatch{c: c}
+	if c.client == nil {
+		b.err = ErrNoRedis
+	} else {
+		b.pipe = c.client.Pipeline()
+	}
+	return b
+}
+
+// TxPipeline returns a batch sent on a go-redis Pipeliner wrapped in
+// MULTI/EXEC. On a cluster the keys of a transaction must share a slot, use
+// a hash tag.
+func (c *GoredisCache) TxPipeline() Batch {
+	b := &goredisBatch{c: c}
+	if c.client == nil {
+		b.err = ErrNoRedis
+	} else {
+		b.pipe = c.client.TxPipeline()
+	}
+	return b
+}
+
+func (b *goredisBatch) Set(key string, value interface{}) {
+	if b.err != nil {
+		return
+	}
+	data, err := encodeValueMax(value, b.c.maxValue)
+	if err != nil {
+		b.err = err
+		return
+	}
+	exp, unit := b.c.defaultExpireArgs()
+	b.c.setScript.Eval(b.pipe, []string{b.c.prefix + key}, data, exp, unit)
+}
+
+// SetWithTTL queues a write with the given ttl, rounded up to whole seconds
+// unless GoredisWithMillisecondTTL is set.
+func (b *goredisBatch) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	if b.err != nil {
+		return
+	}
+	data, err := encodeValueMax(value, b.c.maxValue)
+	if err != nil {
+		b.err = err
+		return
+	}
+	exp, unit := b.c.expireArgs(ttl)
+	b.c.setScript.Eval(b.pipe, []string{b.c.prefix + key}, data, exp, unit)
+}
+
+// Get queues a read, extending the expiration of key like GoredisCache.Get.
+func (b *goredisBatch) Get(key string) *BatchResult {
+	r := newBatchResult()
+	if b.err != nil {
+		return r
+	}
+	cmd := b.c.getScript.Eval(b.pipe, []string{b.c.prefix + key}, slideArg(b.c.absolute))
+	b.gets = append(b.gets, goredisBatchGet{cmd: cmd, result: r})
+	return r
+}
+
+func (b *goredisBatch) Del(key string) {
+	if b.err != nil {
+		return
+	}
+	b.pipe.Del(b.c.prefix + key)
+}
+
+// Exec sends the queued commands and sets the results of the reads. It
+// returns the first error of a command, the other commands still ran.
+func (b *goredisBatch) Exec() error {
+	if b.err != nil {
+		return b.err
+	}
+	cmds, _ := b.pipe.Exec()
+	var first error
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && err != redis.Nil && first == nil {
+			first = err
+		}
+	}
+	for _, g := range b.gets {
+		value, err := g.cmd.Result()
+		switch {
+		case err == redis.Nil || (value == nil && err == nil):
+			g.result.value, g.result.err = nil, nil
+		case err != nil:
+			g.result.value, g.result.err = nil, err
+		default:
+			data, ok := value.(string)
+			if !ok {
+				g.result.value, g.result.err = nil, ErrDataType
+			} else {
+				g.result.value, g.result.err = data, nil
+			}
+		}
+	}
+	b.gets = nil
+	return first
+}
@@ -0,0 +1,964 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+var (
+	luaV9SetCache = redisv9.NewScript(setCacheStr)
+
+	luaV9RangeCache = redisv9.NewScript(rangeCacheStr)
+
+	luaV9AppendCache = redisv9.NewScript(appendCacheStr)
+	luaV9RenameCache = redisv9.NewScript(renameCacheStr)
+	luaV9RenameFixup = redisv9.NewScript(renameFixupStr)
+	luaV9HSetCache   = redisv9.NewScript(hsetCacheStr)
+	luaV9HGetCache   = redisv9.NewScript(hgetCacheStr)
+	luaV9HGetAll     = redisv9.NewScript(hgetallCacheStr)
+	luaV9LPushCache  = redisv9.NewScript(lpushCacheStr)
+	luaV9SAddCache   = redisv9.NewScript(saddCacheStr)
+	luaV9ZAddCache   = redisv9.NewScript(zaddCacheStr)
+
+	luaV9GetLease   = redisv9.NewScript(getLeaseStr)
+	luaV9SetLease   = redisv9.NewScript(setLeaseStr(setCacheStr))
+	luaV9Invalidate = redisv9.NewScript(invalidateStr)
+
+	luaV9GetVersion = redisv9.NewScript(getVersionStr)
+)
+
+type GoredisV9Cache struct {
+	expireSec int
+	ttl       time.Duration
+	ms        bool
+	absolute  bool
+	hooks     ScriptHooks
+	prefix    string
+	tlsConfig *tls.Config
+	username  string
+	password  string
+	maxValue  int
+	expireCtx context.Context
+	expireFn  CacheExpireFunc
+	ctx       context.Context
+	client    redisv9.UniversalClient
+	r         *rand.Rand
+	getScript *redisv9.Script
+	setScript *redisv9.Script
+	setLease  *redisv9.Script
+}
+
+type GoredisV9Option func(c *GoredisV9Cache)
+
+func GoredisV9WithExpire(expireSecond int) GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.expireSec = expireSecond
+		c.ttl = time.Duration(expireSecond) * time.Second
+	}
+}
+
+// GoredisV9ExpireNotify calls fn with the keys of the cache expiring in
+// redis, read from the keyspace notifications like Subscribe, until ctx is
+// done. The value is nil. The server must have notify-keyspace-events
+// including "Kx".
+func GoredisV9ExpireNotify(ctx context.Context, fn CacheExpireFunc) GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.expireCtx = ctx
+		c.expireFn = fn
+	}
+}
+
+// GoredisV9WithAbsoluteExpire disables sliding expiration, entries expire at
+// a fixed time after Set regardless of reads. Get then runs a script only
+// reading the entry, without the EXPIRE write of every read.
+func GoredisV9WithAbsoluteExpire() GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.absolute = true
+	}
+}
+
+// GoredisV9WithTTL sets the default expiration, rounded up to whole seconds.
+func GoredisV9WithTTL(ttl time.Duration) GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.expireSec = TTLSeconds(ttl)
+		c.ttl = ttl
+	}
+}
+
+// GoredisV9WithMillisecondTTL expires the entries written by Set, SetWithTTL
+// and Warm with PEXPIRE, keeping the milliseconds of their ttl and of the
+// default expiration instead of rounding them up to seconds. Hashes, lists,
+// sets and sorted sets still expire in whole seconds.
+func GoredisV9WithMillisecondTTL() GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.ms = true
+	}
+}
+
+// GoredisV9WithMaxValueSize rejects with ErrValueTooLarge the values, and the
+// members of lists, sets and sorted sets, longer than n bytes once encoded,
+// and the Append suffixes longer than n bytes, without sending them to redis.
+// 0 is unlimited.
+func GoredisV9WithMaxValueSize(n int) GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.maxValue = n
+	}
+}
+
+// GoredisV9WithScriptHooks runs hooks inside the scripts of Get and Set, and
+// of MSet and Warm using them.
+func GoredisV9WithScriptHooks(hooks ScriptHooks) GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.hooks = hooks
+		c.setScript = redisv9.NewScript(hooks.script(setCacheStr))
+		c.setLease = redisv9.NewScript(setLeaseStr(hooks.script(setCacheStr)))
+	}
+}
+
+// GoredisV9WithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func GoredisV9WithKeyPrefix(prefix string) GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.prefix = prefix
+	}
+}
+
+// GoredisV9WithContext sets the context passed to every redis command,
+// context.Background() by default.
+func GoredisV9WithContext(ctx context.Context) GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.ctx = ctx
+	}
+}
+
+// GoredisV9WithTLSConfig connects over TLS with cfg. It applies to the
+// constructors that create their own client, e.g. NewGoredisV9CacheAddr.
+func GoredisV9WithTLSConfig(cfg *tls.Config) GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.tlsConfig = cfg
+	}
+}
+
+// GoredisV9WithUsernamePassword authenticates as the ACL user username, or
+// with the legacy requirepass password when username is empty. It applies to
+// the constructors that create their own client, e.g. NewGoredisV9CacheAddr.
+func GoredisV9WithUsernamePassword(username, password string) GoredisV9Option {
+	return func(c *GoredisV9Cache) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// goredisV9ConnOptions returns the cache configured by opts, for the
+// constructors that need the connection options before the client exists.
+func goredisV9ConnOptions(opts []GoredisV9Option) *GoredisV9Cache {
+	c := &GoredisV9Cache{}
+	for _, fn := range opts {
+		fn(c)
+	}
+	return c
+}
+
+// applyConn sets the TLS and auth options on the fields of a client options
+// struct, leaving them alone when the matching option is not set.
+func (c *GoredisV9Cache) applyConn(tlsConfig **tls.Config, username, password *string) {
+	if c.tlsConfig != nil {
+		*tlsConfig = c.tlsConfig
+	}
+	if c.username != "" || c.password != "" {
+		*username = c.username
+		*password = c.password
+	}
+}
+
+func NewGoredisV9Cache(client redisv9.UniversalClient, opts ...GoredisV9Option) *Cache {
+	c := &GoredisV9Cache{
+		client:    client,
+		ctx:       context.Background(),
+		r:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		setScript: luaV9SetCache,
+		setLease:  luaV9SetLease,
+	}
+	for _, fn := range opts {
+		fn(c)
+	}
+	c.getScript = redisv9.NewScript(c.hooks.script(getCacheScript(c.absolute)))
+	if c.expireFn != nil {
+		notifyExpire(c.expireCtx, c, c.expireFn)
+	}
+	return NewCache(c)
+}
+
+// NewGoredisV9ClusterCache returns a cache on a Redis Cluster. The client
+// routes every script by its key and follows MOVED and ASK redirections.
+// Use a hash tagged prefix like "{svcA}:" to keep all keys in one slot.
+func NewGoredisV9ClusterCache(opt *redisv9.ClusterOptions, opts ...GoredisV9Option) *Cache {
+	goredisV9ConnOptions(opts).applyConn(&opt.TLSConfig, &opt.Username, &opt.Password)
+	return NewGoredisV9Cache(redisv9.NewClusterClient(opt), opts...)
+}
+
+// NewGoredisV9CacheAddr returns a cache on the redis server at addr, e.g. a
+// managed service needing GoredisV9WithTLSConfig and
+// GoredisV9WithUsernamePassword.
+func NewGoredisV9CacheAddr(addr string, opts ...GoredisV9Option) *Cache {
+	opt := &redisv9.Options{Addr: addr}
+	goredisV9ConnOptions(opts).applyConn(&opt.TLSConfig, &opt.Username, &opt.Password)
+	return NewGoredisV9Cache(redisv9.NewClient(opt), opts...)
+}
+
+func (c *GoredisV9Cache) Set(key string, value interface{}) error {
+	exp, unit := c.defaultExpireArgs()
+	return c.set(key, value, exp, unit)
+}
+
+// defaultExpire returns the default expiration with up to 10% of jitter, so
+// keys written together do not expire together.
+func (c *GoredisV9Cache) defaultExpire() int {
+	exp := c.expireSec
+	if exp != 0 {
+		exp += c.r.Intn(int(exp/10 + 1))
+	}
+	return exp
+}
+
+// expireArgs returns the expiration and unit arguments of setCacheStr for
+// ttl, in milliseconds with GoredisV9WithMillisecondTTL, else in seconds.
+func (c *GoredisV9Cache) expireArgs(ttl time.Duration) (int64, string) {
+	if c.ms {
+		return ttlMillis(ttl), "ms"
+	}
+	return int64(TTLSeconds(ttl)), "s"
+}
+
+// defaultExpireArgs returns the expireArgs of the default expiration, with
+// jitter.
+func (c *GoredisV9Cache) defaultExpireArgs() (int64, string) {
+	if !c.ms {
+		return int64(c.defaultExpire()), "s"
+	}
+	exp := ttlMillis(c.ttl)
+	if exp != 0 {
+		exp += c.r.Int63n(exp/10 + 1)
+	}
+	return exp, "ms"
+}
+
+// Warm stores entries in a single pipeline.
+func (c *GoredisV9Cache) Warm(ctx context.Context, entries map[string]ValueWithTTL) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	pipe := c.client.Pipeline()
+	for key, v := range entries {
+		exp, unit := c.expireArgs(v.TTL)
+		if v.TTL <= 0 {
+			exp, unit = c.defaultExpireArgs()
+		}
+		value, err := encodeValueMax(v.Value, c.maxValue)
+		if err != nil {
+			return err
+		}
+		// EVALSHA would fail on a script cache flushed mid pipeline
+		c.setScript.Eval(ctx, pipe, []string{c.prefix + key}, value, exp, unit)
+	}
+	cmds, _ := pipe.Exec(ctx)
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && err != redisv9.Nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *GoredisV9Cache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.set(key, value, int64(expireSec), "s")
+}
+
+// SetWithTTL stores value with the given ttl, rounded up to whole seconds
+// unless GoredisV9WithMillisecondTTL is set.
+func (c *GoredisV9Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	exp, unit := c.expireArgs(ttl)
+	return c.set(key, value, exp, unit)
+}
+
+// set stores value expiring after exp in unit, the expireArgs.
+func (c *GoredisV9Cache) set(key string, value interface{}, exp int64, unit string) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	data, err := encodeValueMax(value, c.maxValue)
+	if err != nil {
+		return err
+	}
+	err = c.setScript.Run(c.ctx, c.client, []string{c.prefix + key}, data, exp, unit).Err()
+	if err == redisv9.Nil {
+		return nil
+	}
+	return err
+}
+
+func (c *GoredisV9Cache) Get(key string) (interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	value, err := c.getScript.Run(c.ctx, c.client, []string{c.prefix + key}, slideArg(c.absolute)).Result()
+	if err == redisv9.Nil || (value == nil && err == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tmp, ok := value.(string)
+	if !ok {
+		return nil, ErrDataType
+	}
+	return tmp, err
+}
+
+// GetWithVersion returns the value of key and its version, the SHA-1 of
+// the value.
+func (c *GoredisV9Cache) GetWithVersion(key string) (interface{}, string, error) {
+	return c.GetIfChanged(key, "")
+}
+
+// GetIfChanged returns the value of key and its version, or ErrNotModified
+// without reading the value when version is still the SHA-1 of the value.
+func (c *GoredisV9Cache) GetIfChanged(key, version string) (interface{}, string, error) {
+	if c.client == nil {
+		return nil, "", ErrNoRedis
+	}
+	reply, err := luaV9GetVersion.Run(c.ctx, c.client, []string{c.prefix + key}, slideArg(c.absolute), version).Result()
+	if err == redisv9.Nil || (reply == nil && err == nil) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return versionReply(reply)
+}
+
+// GetLease returns the value of key, or on a miss a lease on it valid for
+// ttl, or ErrLeaseHeld and the stale value kept by Invalidate while another
+// reader holds the lease. A hit does not extend a sliding expiration.
+func (c *GoredisV9Cache) GetLease(key string, ttl time.Duration) (interface{}, string, error) {
+	if c.client == nil {
+		return nil, "", ErrNoRedis
+	}
+	token := newLeaseToken()
+	reply, err := luaV9GetLease.Run(c.ctx, c.client, []string{c.prefix + key}, token, leaseMillis(time.Now()), ttlMillis(ttl)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	return leaseReply(reply, token)
+}
+
+// SetLease stores value with the default expiration if token is the lease
+// of key, else returns ErrLeaseInvalid.
+func (c *GoredisV9Cache) SetLease(key string, value interface{}, token string) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	data, err := encodeValueMax(value, c.maxValue)
+	if err != nil {
+		return err
+	}
+	exp, unit := c.defaultExpireArgs()
+	n, err := c.setLease.Run(c.ctx, c.client, []string{c.prefix + key}, data, exp, unit, token).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseInvalid
+	}
+	return nil
+}
+
+// Invalidate deletes key and its lease, keeping its value for staleTTL to
+// be returned by GetLease while the key is loaded again.
+func (c *GoredisV9Cache) Invalidate(key string, staleTTL time.Duration) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	err := luaV9Invalidate.Run(c.ctx, c.client, []string{c.prefix + key}, ttlMillis(staleTTL)).Err()
+	if err == redisv9.Nil {
+		return nil
+	}
+	return err
+}
+
+func (c *GoredisV9Cache) GetInt(key string) (*int64, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseInt(value.(string), 10, 64)
+	return &data, err
+}
+
+func (c *GoredisV9Cache) GetUint(key string) (*uint64, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseUint(value.(string), 10, 64)
+	return &data, err
+}
+
+func (c *GoredisV9Cache) GetFloat(key string) (*float64, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseFloat(value.(string), 64)
+	return &data, err
+}
+func (c *GoredisV9Cache) GetString(key string) (string, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return "", err
+	}
+	return value.(string), err
+}
+func (c *GoredisV9Cache) GetBytes(key string) ([]byte, error) {
+	data, err := c.GetString(key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(data), err
+}
+func (c *GoredisV9Cache) GetBool(key string) (*bool, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := parseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (c *GoredisV9Cache) GetTime(key string) (*time.Time, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := time.Parse(time.RFC3339Nano, value.(string))
+	return &data, err
+}
+
+func (c *GoredisV9Cache) GetDuration(key string) (*time.Duration, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseInt(value.(string), 10, 64)
+	ret := time.Duration(data)
+	return &ret, err
+}
+
+func (c *GoredisV9Cache) GetStringSlice(key string) ([]string, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	var data []string
+	err = json.Unmarshal([]byte(value.(string)), &data)
+	return data, err
+}
+
+func (c *GoredisV9Cache) GetIntSlice(key string) ([]int64, error) {
+	value, err := c.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	var data []int64
+	err = json.Unmarshal([]byte(value.(string)), &data)
+	return data, err
+}
+
+// Subscribe calls fn with the changes of the keys of the cache, read from
+// the redis keyspace notifications, until ctx is done. The server must have
+// notify-keyspace-events set to "KA" or similar. Events have no Value. On a
+// cluster only the keys of the node the subscription is on are reported.
+func (c *GoredisV9Cache) Subscribe(ctx context.Context, fn EventFunc) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	pubsub := c.client.PSubscribe(ctx, keyspacePattern(c.prefix))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		pubsub.Close()
+	}()
+	go func() {
+		for msg := range pubsub.Channel() {
+			if e, ok := keyspaceEvent(msg.Channel, msg.Payload, c.prefix); ok {
+				fn(e)
+			}
+		}
+	}()
+	return nil
+}
+
+// Ping sends PING to the server.
+func (c *GoredisV9Cache) Ping(ctx context.Context) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	return c.client.Ping(ctx).Err()
+}
+
+// Close closes the redis client of the cache.
+func (c *GoredisV9Cache) Close() error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	return c.client.Close()
+}
+
+func (c *GoredisV9Cache) Del(key string) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	err := c.client.Del(c.ctx, c.prefix+key).Err()
+	if err == redisv9.Nil {
+		return nil
+	}
+	return err
+}
+
+// Rename moves oldKey to newKey with RENAME, which keeps both the value and
+// the remaining ttl, then fixes up the version and exp fields of the entry
+// as renameFixupStr does. On a cluster keys in different slots are moved with
+// DUMP and RESTORE instead, which is not atomic.
+func (c *GoredisV9Cache) Rename(oldKey, newKey string) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	oldKey, newKey = c.prefix+oldKey, c.prefix+newKey
+	if _, ok := c.client.(*redisv9.ClusterClient); ok && clusterSlot(oldKey) != clusterSlot(newKey) {
+		return c.renameAcrossSlots(oldKey, newKey)
+	}
+	err := luaV9RenameCache.Run(c.ctx, c.client, []string{oldKey, newKey}).Err()
+	if err == redisv9.Nil {
+		return nil
+	}
+	return err
+}
+
+func (c *GoredisV9Cache) renameAcrossSlots(oldKey, newKey string) error {
+	// the version of the replaced entry, for the fixup, 0 if none
+	ver, _ := c.client.HGet(c.ctx, newKey, "ver").Int64()
+	value, err := c.client.Dump(c.ctx, oldKey).Result()
+	if err == redisv9.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ttl, err := c.client.PTTL(c.ctx, oldKey).Result()
+	if err != nil {
+		return err
+	}
+	if ttl < 0 {
+		// -1 has no expiration, RESTORE takes 0 for that
+		ttl = 0
+	}
+	if err := c.client.RestoreReplace(c.ctx, newKey, ttl, value).Err(); err != nil {
+		return err
+	}
+	if err := luaV9RenameFixup.Run(c.ctx, c.client, []string{newKey}, ver).Err(); err != nil {
+		return err
+	}
+	return c.client.Del(c.ctx, oldKey).Err()
+}
+
+// Clear deletes the keys under the key prefix, every key of the database
+// without one.
+func (c *GoredisV9Cache) Clear() error {
+	return c.DelByPattern("*")
+}
+
+func (c *GoredisV9Cache) DelByPrefix(prefix string) error {
+	return c.DelByPattern(escapeGlob(prefix) + "*")
+}
+
+// DelByPattern deletes the keys matching pattern with SCAN and batched DEL,
+// so redis is not blocked like KEYS would. On a cluster every master is
+// scanned and the keys are deleted one by one in a pipeline, as a multi key
+// DEL fails when the keys are in different slots.
+func (c *GoredisV9Cache) DelByPattern(pattern string) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	match := escapeGlob(c.prefix) + pattern
+	if cluster, ok := c.client.(*redisv9.ClusterClient); ok {
+		return cluster.ForEachMaster(c.ctx, func(ctx context.Context, node *redisv9.Client) error {
+			return goredisV9Scan(ctx, node, match, func(keys []string) error {
+				_, err := cluster.Pipelined(ctx, func(pipe redisv9.Pipeliner) error {
+					for _, k := range keys {
+						pipe.Del(ctx, k)
+					}
+					return nil
+				})
+				return err
+			})
+		})
+	}
+	return goredisV9Scan(c.ctx, c.client, match, func(keys []string) error {
+		return c.client.Del(c.ctx, keys...).Err()
+	})
+}
+
+// Count returns the number of keys of the cache, with DBSIZE without a key
+// prefix and else by counting the keys with the prefix with SCAN, which may
+// return a key twice while redis resizes. Every master of a cluster is
+// counted. The keys of every type are counted, e.g. the ":xfetch" keys of
+// GetOrSetWithTTL.
+func (c *GoredisV9Cache) Count() (int, error) {
+	if c.client == nil {
+		return 0, ErrNoRedis
+	}
+	var n int64
+	count := func(ctx context.Context, client redisv9.Cmdable) error {
+		if c.prefix == "" {
+			size, err := client.DBSize(ctx).Result()
+			atomic.AddInt64(&n, size)
+			return err
+		}
+		return goredisV9Scan(ctx, client, escapeGlob(c.prefix)+"*", func(keys []string) error {
+			atomic.AddInt64(&n, int64(len(keys)))
+			return nil
+		})
+	}
+	var err error
+	if cluster, ok := c.client.(*redisv9.ClusterClient); ok {
+		err = cluster.ForEachMaster(c.ctx, func(ctx context.Context, node *redisv9.Client) error {
+			return count(ctx, node)
+		})
+	} else {
+		err = count(c.ctx, c.client)
+	}
+	return int(n), err
+}
+
+// MemoryUsage estimates the bytes used by the keys of the cache from the
+// MEMORY USAGE of the first memorySample keys found by SCAN, on every master
+// of a cluster, scaled to the number of keys.
+func (c *GoredisV9Cache) MemoryUsage() (int64, error) {
+	if c.client == nil {
+		return 0, ErrNoRedis
+	}
+	var total int64
+	usage := func(ctx context.Context, client redisv9.Cmdable) error {
+		var n, sampled, bytes int64
+		err := goredisV9Scan(ctx, client, escapeGlob(c.prefix)+"*", func(keys []string) error {
+			n += int64(len(keys))
+			if rest := memorySample - sampled; int64(len(keys)) > rest {
+				keys = keys[:rest]
+			}
+			if len(keys) == 0 {
+				return nil
+			}
+			cmds, _ := client.Pipelined(ctx, func(pipe redisv9.Pipeliner) error {
+				for _, k := range keys {
+					pipe.MemoryUsage(ctx, k)
+				}
+				return nil
+			})
+			for _, cmd := range cmds {
+				size, err := cmd.(*redisv9.IntCmd).Result()
+				if err == redisv9.Nil {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				sampled++
+				bytes += size
+			}
+			return nil
+		})
+		atomic.AddInt64(&total, memoryEstimate(n, sampled, bytes))
+		return err
+	}
+	if cluster, ok := c.client.(*redisv9.ClusterClient); ok {
+		err := cluster.ForEachMaster(c.ctx, func(ctx context.Context, node *redisv9.Client) error {
+			return usage(ctx, node)
+		})
+		return total, err
+	}
+	err := usage(c.ctx, c.client)
+	return total, err
+}
+
+// RangeTTL calls fn for each entry written by Set with its remaining ttl, 0
+// when it does not expire, until fn returns false. Keys are found with
+// SCAN, on every master of a cluster, and read in pipelined batches. Hash,
+// list, set and sorted set entries are skipped.
+func (c *GoredisV9Cache) RangeTTL(fn func(key string, value interface{}, ttl time.Duration) bool) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	visit := newRangeVisitor(c.prefix, fn)
+	batch := func(ctx context.Context) func(keys []string) error {
+		return func(keys []string) error {
+			pipe := c.client.Pipeline()
+			cmds := make([]*redisv9.Cmd, len(keys))
+			for i, k := range keys {
+				cmds[i] = luaV9RangeCache.Eval(ctx, pipe, []string{k})
+			}
+			pipe.Exec(ctx)
+			for i, cmd := range cmds {
+				result, err := cmd.Result()
+				if err == redisv9.Nil {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if err := visit(keys[i], result); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	match := escapeGlob(c.prefix) + "*"
+	var err error
+	if cluster, ok := c.client.(*redisv9.ClusterClient); ok {
+		err = cluster.ForEachMaster(c.ctx, func(ctx context.Context, node *redisv9.Client) error {
+			return goredisV9Scan(ctx, node, match, batch(ctx))
+		})
+	} else {
+		err = goredisV9Scan(c.ctx, c.client, match, batch(c.ctx))
+	}
+	if err == errStopRange {
+		return nil
+	}
+	return err
+}
+
+// goredisV9Scan scans client for keys matching match and passes every non
+// empty batch to fn.
+func goredisV9Scan(ctx context.Context, client redisv9.Cmdable, match string, fn func(keys []string) error) error {
+	cursor := uint64(0)
+	for {
+		keys, next, err := client.Scan(ctx, cursor, match, scanCount).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil && err != redisv9.Nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Append appends suffix to the value stored at key in one round trip,
+// creating it with the default expiration if it does not exist.
+func (c *GoredisV9Cache) Append(key, suffix string) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	if c.maxValue > 0 && len(suffix) > c.maxValue {
+		return ErrValueTooLarge
+	}
+	return luaV9AppendCache.Run(c.ctx, c.client, []string{c.prefix + key}, suffix, c.expireSec).Err()
+}
+
+// HSet sets field of the redis hash stored at key and refreshes its
+// expiration, see the HSet of GoredisCache for the layout of the hash.
+func (c *GoredisV9Cache) HSet(key, field string, value interface{}) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	data, err := encodeValueMax(value, c.maxValue)
+	if err != nil {
+		return err
+	}
+	n, err := luaV9HSetCache.Run(c.ctx, c.client, []string{c.prefix + key}, field, data, c.expireSec).Int64()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return ErrDataType
+	}
+	return nil
+}
+
+func (c *GoredisV9Cache) HGet(key, field string) (interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	value, err := luaV9HGetCache.Run(c.ctx, c.client, []string{c.prefix + key}, field).Result()
+	if err == redisv9.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := value.(int64); ok {
+		return nil, ErrDataType
+	}
+	return value, nil
+}
+
+func (c *GoredisV9Cache) HGetAll(key string) (map[string]interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	reply, err := luaV9HGetAll.Run(c.ctx, c.client, []string{c.prefix + key}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return hashReply(reply)
+}
+
+// LPush inserts values at the head of the redis list stored at key and
+// refreshes its expiration.
+func (c *GoredisV9Cache) LPush(key string, values ...interface{}) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	args := make([]interface{}, 0, len(values)+1)
+	args = append(args, c.expireSec)
+	for _, v := range values {
+		value, err := encodeValueMax(v, c.maxValue)
+		if err != nil {
+			return err
+		}
+		args = append(args, value)
+	}
+	err := luaV9LPushCache.Run(c.ctx, c.client, []string{c.prefix + key}, args...).Err()
+	if err == redisv9.Nil {
+		return nil
+	}
+	return err
+}
+
+func (c *GoredisV9Cache) RPop(key string) (interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	value, err := c.client.RPop(c.ctx, c.prefix+key).Result()
+	if err == redisv9.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c *GoredisV9Cache) LRange(key string, start, stop int64) ([]interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	data, err := c.client.LRange(c.ctx, c.prefix+key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]interface{}, len(data))
+	for i, v := range data {
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+// SAdd adds members to the redis set stored at key and refreshes its
+// expiration.
+func (c *GoredisV9Cache) SAdd(key string, members ...interface{}) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	args := make([]interface{}, 0, len(members)+1)
+	args = append(args, c.expireSec)
+	for _, m := range members {
+		member, err := encodeValueMax(m, c.maxValue)
+		if err != nil {
+			return err
+		}
+		args = append(args, member)
+	}
+	err := luaV9SAddCache.Run(c.ctx, c.client, []string{c.prefix + key}, args...).Err()
+	if err == redisv9.Nil {
+		return nil
+	}
+	return err
+}
+
+func (c *GoredisV9Cache) SIsMember(key string, member interface{}) (bool, error) {
+	if c.client == nil {
+		return false, ErrNoRedis
+	}
+	return c.client.SIsMember(c.ctx, c.prefix+key, encodeValue(member)).Result()
+}
+
+func (c *GoredisV9Cache) SMembers(key string) ([]interface{}, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	data, err := c.client.SMembers(c.ctx, c.prefix+key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	ret := make([]interface{}, len(data))
+	for i, v := range data {
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+// ZAdd adds members to the redis sorted set stored at key and refreshes its
+// expiration.
+func (c *GoredisV9Cache) ZAdd(key string, members ...Z) error {
+	if c.client == nil {
+		return ErrNoRedis
+	}
+	args, err := zaddArgs([]interface{}{c.expireSec}, members, c.maxValue)
+	if err != nil {
+		return err
+	}
+	err = luaV9ZAddCache.Run(c.ctx, c.client, []string{c.prefix + key}, args...).Err()
+	if err == redisv9.Nil {
+		return nil
+	}
+	return err
+}
+
+func (c *GoredisV9Cache) ZRangeByScore(key string, min, max float64) ([]Z, error) {
+	if c.client == nil {
+		return nil, ErrNoRedis
+	}
+	data, err := c.client.ZRangeByScoreWithScores(c.ctx, c.prefix+key, &redisv9.ZRangeBy{
+		Min: scoreArg(min),
+		Max: scoreArg(max),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Z, len(data))
+	for i, v := range data {
+		ret[i] = Z{Score: v.Score, Member: v.Member}
+	}
+	return ret, nil
+}
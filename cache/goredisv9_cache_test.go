@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+func getGoRedisV9T(t *testing.T) redisv9.UniversalClient {
+	c := redisv9.NewClient(
+		&redisv9.Options{
+			Addr:     redisAddr,
+			Password: redisPass,
+		})
+
+	_, err := c.Ping(context.Background()).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestGoredisV9SetInt(t *testing.T) {
+	c := NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisV9SetString(t *testing.T) {
+	c := NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(10))
+	v := "test"
+	c.Set("test:123", v)
+	data, _ := c.GetString("test:123")
+	if data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisV9SetBytes(t *testing.T) {
+	c := NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(10))
+	v := []byte("test")
+	c.Set("test:123", v)
+	data, _ := c.GetBytes("test:123")
+	if data == nil || !bytes.Equal(v, data) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisV9SetBool(t *testing.T) {
+	c := NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(10))
+	v := true
+	c.Set("test:123", v)
+	data, _ := c.GetBool("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisV9Del(t *testing.T) {
+	c := NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(10))
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestGoredisV9Expire(t *testing.T) {
+	c := NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(2))
+	key := "test:123"
+	c.Set(key, true)
+	time.Sleep(4 * time.Second)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestGoredisV9Hash(t *testing.T) {
+	c := NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(10))
+	key := "test:hash:123"
+	c.Del(key)
+	c.HSet(key, "name", "test")
+	c.HSet(key, "age", 3)
+	all, _ := c.HGetAll(key)
+	if len(all) != 2 || all["age"] != "3" {
+		t.Errorf("%v value error", all)
+		return
+	}
+	c.Set("test:456", 3)
+	if err := c.HSet("test:456", "name", "test"); err != ErrDataType {
+		t.Errorf("%v error", err)
+	}
+	c.Del("test:456")
+}
+
+func TestGoredisV9AddrSetInt(t *testing.T) {
+	c := NewGoredisV9CacheAddr(redisAddr, GoredisV9WithUsernamePassword("", redisPass), GoredisV9WithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisV9Warm(t *testing.T) {
+	c := NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(10))
+	err := c.Warm(context.Background(), map[string]ValueWithTTL{
+		"test:1": {Value: 1},
+		"test:2": {Value: "2", TTL: time.Second},
+	})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ := c.GetInt("test:1")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.GetInt("test:2")
+	if data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisV9RangeTTL(t *testing.T) {
+	c := NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(10), GoredisV9WithKeyPrefix("range:"))
+	c.Set("test:1", 1)
+	c.SetWithTTL("test:2", "2", 0)
+	c.HSet("test:hash", "f", 1)
+	got := map[string]time.Duration{}
+	err := c.RangeTTL(func(key string, value interface{}, ttl time.Duration) bool {
+		got[key+"="+string(value.([]byte))] = ttl
+		return true
+	})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if ttl, ok := got["test:1=1"]; !ok || ttl <= 0 || ttl > 11*time.Second {
+		t.Errorf("%v value error", got)
+		return
+	}
+	if ttl, ok := got["test:2=2"]; !ok || ttl != 0 || len(got) != 2 {
+		t.Errorf("%v value error", got)
+		return
+	}
+}
+
+func TestGoredisV9Ping(t *testing.T) {
+	c := NewGoredisV9Cache(getGoRedisV9T(t))
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestGoredisV9Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := getGoRedisV9T(t)
+	c := NewGoredisV9Cache(client, GoredisV9WithKeyPrefix("svc:"))
+	events := make(chan Event, 1)
+	if err := c.Subscribe(ctx, func(e Event) { events <- e }); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	client.Publish(ctx, "__keyspace@0__:svc:test:1", "expired")
+	select {
+	case e := <-events:
+		if e.Type != EventExpire || e.Key != "test:1" {
+			t.Errorf("%v value error", e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no event error")
+	}
+}
@@ -0,0 +1,155 @@
+// Package groupcache stores the entries of a cache.Cache in a groupcache
+// group.
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	mailgun "github.com/mailgun/groupcache/v2"
+
+	"mcache/cache"
+)
+
+// errGroupcacheMiss is returned by the group getter when there is no loader,
+// peers only forward its text so it is matched by message.
+var errGroupcacheMiss = errors.New("mcache: groupcache miss")
+
+// GroupcacheCache stores encoded values in a groupcache group, so read-mostly
+// fleets fill each other peer to peer and replicate hot keys without a
+// central server. Peers are registered on the groupcache package as usual,
+// e.g. with groupcache.NewHTTPPool. Groupcache entries are immutable until
+// they expire or are removed, so expiration is always absolute.
+type GroupcacheCache struct {
+	cache.ByteGetters
+	expire time.Duration
+	prefix string
+	ctx    context.Context
+	loader cache.LoaderFunc
+	group  *mailgun.Group
+	m      sync.Mutex
+	r      *rand.Rand
+}
+
+type GroupcacheOption func(c *GroupcacheCache)
+
+func GroupcacheWithExpire(expireSecond int) GroupcacheOption {
+	return func(c *GroupcacheCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func GroupcacheWithTTL(ttl time.Duration) GroupcacheOption {
+	return func(c *GroupcacheCache) {
+		c.expire = ttl
+	}
+}
+
+// GroupcacheWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func GroupcacheWithKeyPrefix(prefix string) GroupcacheOption {
+	return func(c *GroupcacheCache) {
+		c.prefix = prefix
+	}
+}
+
+// GroupcacheWithContext sets the context passed to the group,
+// context.Background() by default.
+func GroupcacheWithContext(ctx context.Context) GroupcacheOption {
+	return func(c *GroupcacheCache) {
+		c.ctx = ctx
+	}
+}
+
+// GroupcacheWithLoader fills missing keys on the peer owning them, the
+// loaded value is cached with the default expiration. Without a loader a
+// missing key reads as nil.
+func GroupcacheWithLoader(loader cache.LoaderFunc) GroupcacheOption {
+	return func(c *GroupcacheCache) {
+		c.loader = loader
+	}
+}
+
+// NewGroupcacheCache creates the groupcache group name holding up to
+// cacheBytes. Group names are global to the process and must be unique.
+func NewGroupcacheCache(name string, cacheBytes int64, opts ...GroupcacheOption) *cache.Cache {
+	c := &GroupcacheCache{
+		ctx: context.Background(),
+		r:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = cache.NewByteGetters(c.Get)
+	for _, fn := range opts {
+		fn(c)
+	}
+	c.group = mailgun.NewGroup(name, cacheBytes, mailgun.GetterFunc(c.load))
+	return cache.NewCache(c)
+}
+
+func (c *GroupcacheCache) load(ctx context.Context, key string, dest mailgun.Sink) error {
+	if c.loader == nil {
+		return errGroupcacheMiss
+	}
+	value, err := c.loader(strings.TrimPrefix(key, c.prefix))
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return errGroupcacheMiss
+	}
+	return dest.SetBytes(cache.EncodeBytes(value), c.expireAt(c.expire))
+}
+
+// expireAt returns the groupcache expiration for ttl, the zero time never
+// expires.
+func (c *GroupcacheCache) expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func (c *GroupcacheCache) Set(key string, value interface{}) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c *GroupcacheCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+// SetWithTTL stores value on the peer owning key and in the local hot cache.
+func (c *GroupcacheCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.group.Set(c.ctx, c.prefix+key, cache.EncodeBytes(value), c.expireAt(ttl), true)
+}
+
+func (c *GroupcacheCache) Get(key string) (interface{}, error) {
+	var value []byte
+	err := c.group.Get(c.ctx, c.prefix+key, mailgun.AllocatingByteSliceSink(&value))
+	if err != nil && strings.Contains(err.Error(), errGroupcacheMiss.Error()) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Del removes key from its owner and from the hot cache of every peer.
+func (c *GroupcacheCache) Del(key string) error {
+	return c.group.Remove(c.ctx, c.prefix+key)
+}
+
+// Close removes the group from the groupcache registry, so peers stop
+// serving it and its name can be used again.
+func (c *GroupcacheCache) Close() error {
+	mailgun.DeregisterGroup(c.group.Name())
+	return nil
+}
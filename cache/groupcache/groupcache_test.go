@@ -0,0 +1,89 @@
+package groupcache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestGroupcacheSetInt(t *testing.T) {
+	c := NewGroupcacheCache(t.Name(), 1<<20, GroupcacheWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGroupcacheSetBytes(t *testing.T) {
+	c := NewGroupcacheCache(t.Name(), 1<<20, GroupcacheWithExpire(10))
+	v := []byte("test")
+	c.Set("test:123", v)
+	data, _ := c.GetBytes("test:123")
+	if data == nil || !bytes.Equal(v, data) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGroupcacheDel(t *testing.T) {
+	c := NewGroupcacheCache(t.Name(), 1<<20, GroupcacheWithExpire(10))
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestGroupcacheExpire(t *testing.T) {
+	c := NewGroupcacheCache(t.Name(), 1<<20, GroupcacheWithTTL(200*time.Millisecond))
+	key := "test:123"
+	c.Set(key, true)
+	time.Sleep(300 * time.Millisecond)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestGroupcacheLoader(t *testing.T) {
+	loads := 0
+	c := NewGroupcacheCache(t.Name(), 1<<20, GroupcacheWithExpire(10),
+		GroupcacheWithLoader(func(key string) (interface{}, error) {
+			loads++
+			return key, nil
+		}))
+	for i := 0; i < 3; i++ {
+		data, _ := c.GetString("test:123")
+		if data != "test:123" {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+	if loads != 1 {
+		t.Errorf("%v loads error", loads)
+	}
+}
+
+func TestGroupcacheClose(t *testing.T) {
+	c := NewGroupcacheCache(t.Name(), 1<<20, GroupcacheWithExpire(10))
+	if err := c.Close(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	// a second group of the same name panics unless the first was closed
+	c = NewGroupcacheCache(t.Name(), 1<<20, GroupcacheWithExpire(10))
+	defer c.Close()
+	c.Set("test:123", 1)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
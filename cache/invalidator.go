@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Invalidator broadcasts changed keys between the processes sharing a cache,
+// so each can drop its local copy.
+type Invalidator interface {
+	// Publish tells the other processes that key changed.
+	Publish(key string) error
+	// Subscribe calls fn with every key published by the other processes.
+	Subscribe(fn func(key string))
+}
+
+// newNodeID returns a random id telling the messages of this process apart.
+func newNodeID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// GoredisInvalidator is an Invalidator on a redis pub/sub channel. Messages
+// are "<node id>:<key>", a process ignores the ones it published.
+type GoredisInvalidator struct {
+	id       string
+	channel  string
+	client   redis.UniversalClient
+	m        sync.RWMutex
+	handlers []func(key string)
+}
+
+// NewGoredisInvalidator subscribes to channel until ctx is done. The
+// subscription is restored by the client after a connection loss, keys
+// published meanwhile are not replayed.
+func NewGoredisInvalidator(ctx context.Context, client redis.UniversalClient, channel string) *GoredisInvalidator {
+	inv := &GoredisInvalidator{
+		id:      newNodeID(),
+		channel: channel,
+		client:  client,
+	}
+	pubsub := client.Subscribe(channel)
+	go func() {
+		<-ctx.Done()
+		pubsub.Close()
+	}()
+	go inv.run(pubsub.Channel())
+	return inv
+}
+
+func (inv *GoredisInvalidator) run(ch <-chan *redis.Message) {
+	for msg := range ch {
+		i := strings.IndexByte(msg.Payload, ':')
+		if i < 0 || msg.Payload[:i] == inv.id {
+			continue
+		}
+		key := msg.Payload[i+1:]
+		inv.m.RLock()
+		for _, fn := range inv.handlers {
+			fn(key)
+		}
+		inv.m.RUnlock()
+	}
+}
+
+func (inv *GoredisInvalidator) Publish(key string) error {
+	return inv.client.Publish(inv.channel, inv.id+":"+key).Err()
+}
+
+func (inv *GoredisInvalidator) Subscribe(fn func(key string)) {
+	inv.m.Lock()
+	inv.handlers = append(inv.handlers, fn)
+	inv.m.Unlock()
+}
+
+// InvalidatedCache publishes every key written or deleted through it and
+// drops its copy of the keys published by other processes. It wraps a
+// process local cache so each process sees the writes of the others within
+// the pub/sub latency.
+type InvalidatedCache struct {
+	ICache
+	inv Invalidator
+}
+
+// NewInvalidatedCache returns c wrapped to publish and apply invalidations
+// through inv.
+func NewInvalidatedCache(c ICache, inv Invalidator) *Cache {
+	ic := &InvalidatedCache{
+		ICache: c,
+		inv:    inv,
+	}
+	inv.Subscribe(func(key string) {
+		ic.ICache.Del(key)
+	})
+	return NewCache(ic)
+}
+
+func (c *InvalidatedCache) Set(key string, value interface{}) error {
+	if err := c.ICache.Set(key, value); err != nil {
+		return err
+	}
+	return c.inv.Publish(key)
+}
+
+func (c *InvalidatedCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *InvalidatedCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if err := c.ICache.SetWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+	return c.inv.Publish(key)
+}
+
+// Ping pings the wrapped cache.
+func (c *InvalidatedCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.ICache)
+}
+
+// Close closes the wrapped cache.
+func (c *InvalidatedCache) Close() error {
+	return closeCache(c.ICache)
+}
+
+func (c *InvalidatedCache) Del(key string) error {
+	if err := c.ICache.Del(key); err != nil {
+		return err
+	}
+	return c.inv.Publish(key)
+}
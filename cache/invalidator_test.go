@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memBus connects memInvalidators in process, like a pub/sub channel.
+type memBus struct {
+	m     sync.Mutex
+	nodes []*memInvalidator
+}
+
+type memInvalidator struct {
+	bus      *memBus
+	handlers []func(key string)
+}
+
+func (b *memBus) node() *memInvalidator {
+	inv := &memInvalidator{bus: b}
+	b.m.Lock()
+	b.nodes = append(b.nodes, inv)
+	b.m.Unlock()
+	return inv
+}
+
+func (inv *memInvalidator) Publish(key string) error {
+	inv.bus.m.Lock()
+	defer inv.bus.m.Unlock()
+	for _, n := range inv.bus.nodes {
+		if n == inv {
+			continue
+		}
+		for _, fn := range n.handlers {
+			fn(key)
+		}
+	}
+	return nil
+}
+
+func (inv *memInvalidator) Subscribe(fn func(key string)) {
+	inv.handlers = append(inv.handlers, fn)
+}
+
+func TestInvalidatedCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := &memBus{}
+	c1 := NewInvalidatedCache(NewLocalCache(ctx, LocalWithExpire(10)), bus.node())
+	c2 := NewInvalidatedCache(NewLocalCache(ctx, LocalWithExpire(10)), bus.node())
+	c1.Set("test:123", 1)
+	c2.Set("test:123", 2)
+	data, _ := c1.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+	data, _ = c2.GetInt("test:123")
+	if data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestTieredInvalidator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := &memBus{}
+	l2 := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c1 := NewTieredCache(NewLocalCache(ctx, LocalWithExpire(10)), l2, TieredWithInvalidator(bus.node()))
+	c2 := NewTieredCache(NewLocalCache(ctx, LocalWithExpire(10)), l2, TieredWithInvalidator(bus.node()))
+	c1.Set("test:123", "a")
+	data, _ := c2.GetString("test:123")
+	if data != "a" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c1.Set("test:123", "b")
+	data, _ = c2.GetString("test:123")
+	if data != "b" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c1.Del("test:123")
+	data, _ = c2.GetString("test:123")
+	if data != "" {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestGoredisInvalidator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := getGoRedisT(t)
+	inv1 := NewGoredisInvalidator(ctx, client, "test:invalidate")
+	inv2 := NewGoredisInvalidator(ctx, client, "test:invalidate")
+	keys := make(chan string, 1)
+	inv2.Subscribe(func(key string) {
+		keys <- key
+	})
+	time.Sleep(100 * time.Millisecond)
+	inv1.Publish("test:123")
+	select {
+	case key := <-keys:
+		if key != "test:123" {
+			t.Errorf("%v value error", key)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("invalidation not received")
+	}
+}
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// KeyCache validates and normalizes the keys before passing them to the
+// wrapped cache, so pathological keys, e.g. huge or with newlines, do not
+// reach redis and break its tooling. Invalid keys fail with ErrInvalidKey.
+type KeyCache struct {
+	c         ICache
+	maxLen    int
+	forbidden string
+	hashLong  bool
+}
+
+type KeyOption func(c *KeyCache)
+
+// KeyWithMaxLength rejects the keys longer than n bytes, or hashes them with
+// KeyWithHashLong.
+func KeyWithMaxLength(n int) KeyOption {
+	return func(c *KeyCache) {
+		c.maxLen = n
+	}
+}
+
+// KeyWithForbiddenChars rejects the keys containing any of the bytes of
+// chars, e.g. " \r\n" for the text protocols.
+func KeyWithForbiddenChars(chars string) KeyOption {
+	return func(c *KeyCache) {
+		c.forbidden = chars
+	}
+}
+
+// KeyWithHashLong replaces the keys longer than the KeyWithMaxLength with
+// their start followed by the hex SHA-256 of the whole key, at most the
+// maximum length, instead of rejecting them. A redis hash tag cut from the
+// start no longer selects the cluster slot.
+func KeyWithHashLong() KeyOption {
+	return func(c *KeyCache) {
+		c.hashLong = true
+	}
+}
+
+// NewKeyCache returns c with the keys validated.
+func NewKeyCache(c ICache, opts ...KeyOption) *Cache {
+	kc := &KeyCache{c: c}
+	for _, fn := range opts {
+		fn(kc)
+	}
+	return NewCache(kc)
+}
+
+// key returns the key passed to the wrapped cache for key.
+func (c *KeyCache) key(key string) (string, error) {
+	if c.forbidden != "" && strings.ContainsAny(key, c.forbidden) {
+		return "", ErrInvalidKey
+	}
+	if c.maxLen <= 0 || len(key) <= c.maxLen {
+		return key, nil
+	}
+	if !c.hashLong {
+		return "", ErrInvalidKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	h := hex.EncodeToString(sum[:])
+	if len(h) >= c.maxLen {
+		return h[:c.maxLen], nil
+	}
+	return key[:c.maxLen-len(h)] + h, nil
+}
+
+func (c *KeyCache) Set(key string, value interface{}) error {
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	return c.c.Set(k, value)
+}
+
+func (c *KeyCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	return c.c.SetWithExpire(k, value, expireSec)
+}
+
+func (c *KeyCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	return c.c.SetWithTTL(k, value, ttl)
+}
+
+func (c *KeyCache) Get(key string) (interface{}, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.Get(k)
+}
+
+func (c *KeyCache) GetInt(key string) (*int64, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetInt(k)
+}
+
+func (c *KeyCache) GetUint(key string) (*uint64, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetUint(k)
+}
+
+func (c *KeyCache) GetFloat(key string) (*float64, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetFloat(k)
+}
+
+func (c *KeyCache) GetString(key string) (string, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return "", err
+	}
+	return c.c.GetString(k)
+}
+
+func (c *KeyCache) GetBytes(key string) ([]byte, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetBytes(k)
+}
+
+func (c *KeyCache) GetBool(key string) (*bool, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetBool(k)
+}
+
+func (c *KeyCache) GetTime(key string) (*time.Time, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetTime(k)
+}
+
+func (c *KeyCache) GetDuration(key string) (*time.Duration, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetDuration(k)
+}
+
+func (c *KeyCache) GetStringSlice(key string) ([]string, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetStringSlice(k)
+}
+
+func (c *KeyCache) GetIntSlice(key string) ([]int64, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetIntSlice(k)
+}
+
+// Ping pings the wrapped cache.
+func (c *KeyCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.c)
+}
+
+// Close closes the wrapped cache.
+func (c *KeyCache) Close() error {
+	return closeCache(c.c)
+}
+
+func (c *KeyCache) Del(key string) error {
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	return c.c.Del(k)
+}
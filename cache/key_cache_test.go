@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestKeyCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	local := NewLocalCache(ctx)
+	c := NewKeyCache(local, KeyWithMaxLength(80), KeyWithForbiddenChars(" \r\n"))
+	if err := c.Set("test:1", 1); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := c.Set("test:\n1", 1); err != ErrInvalidKey {
+		t.Errorf("%v error", err)
+		return
+	}
+	long := "test:" + strings.Repeat("a", 100)
+	if err := c.Set(long, 1); err != ErrInvalidKey {
+		t.Errorf("%v error", err)
+		return
+	}
+	if _, err := c.GetInt(long); err != ErrInvalidKey {
+		t.Errorf("%v error", err)
+		return
+	}
+
+	c = NewKeyCache(local, KeyWithMaxLength(80), KeyWithHashLong())
+	if err := c.Set(long, 2); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := c.GetInt(long); data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	// the stored key keeps the start of the key and fits the maximum
+	found := false
+	local.Range(func(key string, value interface{}) bool {
+		if value == 2 {
+			found = len(key) == 80 && strings.HasPrefix(key, "test:aaa")
+		}
+		return true
+	})
+	if !found {
+		t.Errorf("hashed key error")
+	}
+}
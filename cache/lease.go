@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	defaultLeaseRetry = 50 * time.Millisecond
+
+	// getLeaseStr returns {'hit', value} for a cached key, else a lease on
+	// it: {'lease', stale} storing the token ARGV[1] expiring at ARGV[2] +
+	// ARGV[3] milliseconds, or {'held', stale} while the lease of another
+	// reader is valid. The lease is kept in the hash of the key, so it is
+	// removed with it.
+	getLeaseStr string = `
+	local key,token,now,ttl = KEYS[1],ARGV[1],tonumber(ARGV[2]),tonumber(ARGV[3])
+	local v = redis.call('hmget', key, 'data', 'lease', 'leasexp', 'stale')
+	if v[1] ~= false
+	then
+		return {'hit', v[1]}
+	end
+	if (v[2] ~= false) and (tonumber(v[3]) > now)
+	then
+		return {'held', v[4]}
+	end
+	redis.call('hmset', key, 'lease', token, 'leasexp', now + ttl)
+	if redis.call('pttl', key) < ttl
+	then
+		redis.call('pexpire', key, ttl)
+	end
+	return {'lease', v[4]}
+	`
+
+	// setLeaseCheckStr starts the script of SetLease, run before the set
+	// script: it stores nothing unless ARGV[4] is the lease of the key.
+	setLeaseCheckStr string = `
+	if redis.call('hget', KEYS[1], 'lease') ~= ARGV[4]
+	then
+		return 0
+	end
+	redis.call('del', KEYS[1])
+	`
+
+	// invalidateStr deletes the key and its lease, keeping its value as
+	// stale for ARGV[1] milliseconds.
+	invalidateStr string = `
+	local key,ttl = KEYS[1],tonumber(ARGV[1])
+	local v = redis.call('hget', key, 'data')
+	if v == false
+	then
+		v = redis.call('hget', key, 'stale')
+	end
+	redis.call('del', key)
+	if (v ~= false) and (ttl > 0)
+	then
+		redis.call('hset', key, 'stale', v)
+		redis.call('pexpire', key, ttl)
+	end
+	`
+)
+
+var (
+	// ErrLeaseHeld is returned by GetLease on a miss while another reader
+	// holds the lease of the key.
+	ErrLeaseHeld = errors.New("lease held error")
+	// ErrLeaseInvalid is returned by SetLease when the token is not the
+	// lease of the key, e.g. invalidated meanwhile, and the value is not
+	// stored.
+	ErrLeaseInvalid = errors.New("invalid lease error")
+)
+
+// ILease is implemented by caches handing out leases on missing keys, as
+// memcache does: a miss gives the reader a lease token, valid for ttl, and
+// only a SetLease with the token stores the value. The other readers missing
+// the key meanwhile get ErrLeaseHeld, with the stale value kept by Invalidate
+// if any. Invalidate cancels the lease, so a value loaded before it is not
+// stored after it.
+type ILease interface {
+	GetLease(key string, ttl time.Duration) (value interface{}, token string, err error)
+	SetLease(key string, value interface{}, token string) error
+	Invalidate(key string, staleTTL time.Duration) error
+}
+
+// setLeaseStr returns the script of SetLease, set being the script of Set.
+func setLeaseStr(set string) string {
+	return setLeaseCheckStr + set + "return 1\n\t"
+}
+
+// leaseReply returns the result of a getLeaseStr script from its reply.
+func leaseReply(reply interface{}, token string) (interface{}, string, error) {
+	r, ok := reply.([]interface{})
+	if !ok || len(r) != 2 {
+		return nil, "", ErrDataType
+	}
+	status := r[0]
+	if b, ok := status.([]byte); ok {
+		status = string(b)
+	}
+	switch status {
+	case "hit":
+		return r[1], "", nil
+	case "lease":
+		return nil, token, nil
+	}
+	return r[1], "", ErrLeaseHeld
+}
+
+// newLeaseToken returns a random lease token.
+func newLeaseToken() string {
+	return newNodeID() + newNodeID()
+}
+
+// leaseMillis returns the unix milliseconds of t.
+func leaseMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// GetLease returns the cached value of key, or on a miss a lease token
+// valid for ttl to pass to SetLease, or ErrLeaseHeld and the stale value if
+// any while another reader holds the lease. It returns ErrNotSupported if
+// the underlying cache has no leases.
+func (c *Cache) GetLease(key string, ttl time.Duration) (interface{}, string, error) {
+	l, ok := c.cache.(ILease)
+	if !ok {
+		return nil, "", ErrNotSupported
+	}
+	return l.GetLease(key, ttl)
+}
+
+// SetLease stores value for key with the default expiration if token is
+// still its lease, else returns ErrLeaseInvalid. It returns ErrNotSupported
+// if the underlying cache has no leases.
+func (c *Cache) SetLease(key string, value interface{}, token string) error {
+	l, ok := c.cache.(ILease)
+	if !ok {
+		return ErrNotSupported
+	}
+	err := l.SetLease(key, value, token)
+	if err != ErrLeaseInvalid {
+		c.stats.write(1, err)
+	}
+	return err
+}
+
+// Invalidate deletes key and cancels its lease, keeping its value for
+// staleTTL to be served as stale, 0 keeping nothing. It returns
+// ErrNotSupported if the underlying cache has no leases.
+func (c *Cache) Invalidate(key string, staleTTL time.Duration) error {
+	l, ok := c.cache.(ILease)
+	if !ok {
+		return ErrNotSupported
+	}
+	err := l.Invalidate(key, staleTTL)
+	c.stats.del(1, err)
+	return err
+}
+
+type leaseOptions struct {
+	stale bool
+	retry time.Duration
+}
+
+type LeaseOption func(o *leaseOptions)
+
+// LeaseWithStale returns the stale value of a key whose lease is held by
+// another reader instead of waiting for its new value.
+func LeaseWithStale() LeaseOption {
+	return func(o *leaseOptions) {
+		o.stale = true
+	}
+}
+
+// LeaseWithRetryInterval sets how often a reader waiting for the lease
+// holder checks the key, 50ms by default.
+func LeaseWithRetryInterval(interval time.Duration) LeaseOption {
+	return func(o *leaseOptions) {
+		o.retry = interval
+	}
+}
+
+// GetOrSetLease is GetOrSet with leases, coalescing the loads of key across
+// processes sharing the cache: the reader getting the lease, valid for ttl,
+// calls loader and stores its result, the others wait for it until ctx is
+// done, or get the stale value with LeaseWithStale. A value loaded while the
+// key was invalidated is returned but not stored.
+func (c *Cache) GetOrSetLease(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc, opts ...LeaseOption) (interface{}, error) {
+	o := leaseOptions{retry: defaultLeaseRetry}
+	for _, fn := range opts {
+		fn(&o)
+	}
+	for {
+		value, token, err := c.GetLease(key, ttl)
+		switch {
+		case err == ErrLeaseHeld:
+			if o.stale && value != nil {
+				return value, nil
+			}
+		case err != nil:
+			return nil, err
+		case token == "":
+			c.stats.read(false, nil)
+			return value, nil
+		default:
+			c.stats.read(true, nil)
+			value, err := c.stats.load(key, loader)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.SetLease(key, value, token); err != nil && err != ErrLeaseInvalid {
+				return nil, err
+			}
+			return value, nil
+		}
+		timer := time.NewTimer(o.retry)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testLease(t *testing.T, c *Cache) {
+	c.Del("test:lease")
+	value, token, err := c.GetLease("test:lease", time.Second)
+	if value != nil || token == "" || err != nil {
+		t.Errorf("%v %v %v error", value, token, err)
+		return
+	}
+	if _, token2, err := c.GetLease("test:lease", time.Second); token2 != "" || err != ErrLeaseHeld {
+		t.Errorf("%v %v error", token2, err)
+		return
+	}
+	if err := c.SetLease("test:lease", "a", "other"); err != ErrLeaseInvalid {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := c.SetLease("test:lease", "a", token); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := c.GetString("test:lease"); data != "a" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if value, token, err := c.GetLease("test:lease", time.Second); value == nil || token != "" || err != nil {
+		t.Errorf("%v %v %v error", value, token, err)
+		return
+	}
+
+	// invalidated while loading, the stale set is rejected
+	if err := c.Invalidate("test:lease", time.Second); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	_, token, err = c.GetLease("test:lease", time.Second)
+	if token == "" || err != nil {
+		t.Errorf("%v %v error", token, err)
+		return
+	}
+	value, _, err = c.GetLease("test:lease", time.Second)
+	if err != ErrLeaseHeld {
+		t.Errorf("%v error", err)
+		return
+	}
+	if s, ok := value.(string); ok {
+		value = []byte(s)
+	}
+	if b, _ := value.([]byte); string(b) != "a" {
+		t.Errorf("%v value error", value)
+		return
+	}
+	if err := c.Invalidate("test:lease", 0); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := c.SetLease("test:lease", "b", token); err != ErrLeaseInvalid {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := c.GetString("test:lease"); data != "" {
+		t.Errorf("%v value error", data)
+		return
+	}
+
+	// an expired lease is given to the next reader
+	_, token, _ = c.GetLease("test:lease", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if _, token2, err := c.GetLease("test:lease", time.Second); token2 == "" || token2 == token || err != nil {
+		t.Errorf("%v %v error", token2, err)
+		return
+	}
+	c.Del("test:lease")
+}
+
+func TestLocalLease(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	testLease(t, NewLocalCache(ctx, LocalWithEncoding()))
+}
+
+func TestGoredisLease(t *testing.T) {
+	testLease(t, NewGoredisCache(getGoRedisT(t), GoredisWithExpire(10)))
+}
+
+func TestGoredisV9Lease(t *testing.T) {
+	testLease(t, NewGoredisV9Cache(getGoRedisV9T(t), GoredisV9WithExpire(10)))
+}
+
+func TestRedigoLease(t *testing.T) {
+	testLease(t, NewRedigoCache(getRedigoT(t), RedigoWithExpire(10)))
+}
+
+func TestGetOrSetLease(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx)
+	var calls int32
+	loader := func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return "a", nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.GetOrSetLease(ctx, "test:lease", time.Second, loader, LeaseWithRetryInterval(10*time.Millisecond))
+			if value != "a" || err != nil {
+				t.Errorf("%v %v error", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("%v value error", n)
+		return
+	}
+
+	c.Invalidate("test:lease", time.Second)
+	_, token, _ := c.GetLease("test:lease", time.Second)
+	value, err := c.GetOrSetLease(ctx, "test:lease", time.Second, loader, LeaseWithStale())
+	if value != "a" || err != nil {
+		t.Errorf("%v %v error", value, err)
+		return
+	}
+	tctx, tcancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer tcancel()
+	if _, err := c.GetOrSetLease(tctx, "test:lease", time.Second, loader); err != context.DeadlineExceeded {
+		t.Errorf("%v error", err)
+		return
+	}
+	c.SetLease("test:lease", "b", token)
+	if value, _ := c.GetOrSetLease(ctx, "test:lease", time.Second, loader); value != "b" {
+		t.Errorf("%v value error", value)
+	}
+}
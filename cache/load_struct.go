@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by a StructLoaderFunc for the keys missing from
+// the source of truth, the miss is then cached by LoadStruct and returned to
+// the callers until it expires.
+var ErrNotFound = errors.New("not found error")
+
+// notFoundValue is cached by LoadStruct for the keys the loader did not
+// find, it can not be mistaken for a JSON document.
+const notFoundValue = "\x00notfound"
+
+// StructLoaderFunc loads the struct of key, or a pointer to it, from the
+// source of truth, returning ErrNotFound when there is none.
+type StructLoaderFunc func(ctx context.Context, key string) (interface{}, error)
+
+type loadOptions struct {
+	negativeTTL time.Duration
+}
+
+type LoadOption func(o *loadOptions)
+
+// LoadWithNegativeTTL caches the keys not found by the loader for ttl
+// instead of the ttl of the values, 0 not caching them.
+func LoadWithNegativeTTL(ttl time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.negativeTTL = ttl
+	}
+}
+
+// LoadStruct is the cache-aside pattern for a struct: it decodes the value
+// of key into dst, a pointer to a struct, or on a miss calls loader, stores
+// its result as JSON with ttl and decodes it into dst. The fields are mapped
+// by their json tags. Concurrent misses on the same key share one loader
+// call. When loader returns ErrNotFound the miss is cached too, and
+// LoadStruct returns ErrNotFound leaving dst untouched.
+func (c *Cache) LoadStruct(ctx context.Context, key string, dst interface{}, loader StructLoaderFunc, ttl time.Duration, opts ...LoadOption) error {
+	o := loadOptions{negativeTTL: ttl}
+	for _, fn := range opts {
+		fn(&o)
+	}
+	value, err := c.Get(key)
+	ok, err := c.found(value, err)
+	if err != nil {
+		return err
+	}
+	if ok {
+		switch v := value.(type) {
+		case string:
+			return decodeStruct([]byte(v), dst)
+		case []byte:
+			return decodeStruct(v, dst)
+		}
+		return ErrDataType
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := c.flight.Do(key, func() (interface{}, error) {
+		value, err := c.stats.load(key, func(key string) (interface{}, error) {
+			return loader(ctx, key)
+		})
+		if err == ErrNotFound {
+			if o.negativeTTL > 0 {
+				if err := c.SetWithTTL(key, notFoundValue, o.negativeTTL); err != nil {
+					return nil, err
+				}
+			}
+			return []byte(notFoundValue), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.SetWithTTL(key, string(data), ttl); err != nil {
+			return nil, err
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	return decodeStruct(data.([]byte), dst)
+}
+
+// decodeStruct decodes the cached data into dst, returning ErrNotFound for
+// a cached miss.
+func decodeStruct(data []byte, dst interface{}) error {
+	if string(data) == notFoundValue {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, dst)
+}
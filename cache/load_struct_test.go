@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type loadUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestLoadStruct(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx)
+	calls := 0
+	loader := func(ctx context.Context, key string) (interface{}, error) {
+		calls++
+		if key == "user:2" {
+			return nil, ErrNotFound
+		}
+		return &loadUser{ID: 1, Name: "bob"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		var u loadUser
+		if err := c.LoadStruct(ctx, "user:1", &u, loader, time.Minute); err != nil {
+			t.Errorf("%v error", err)
+			return
+		}
+		if u.ID != 1 || u.Name != "bob" {
+			t.Errorf("%v value error", u)
+			return
+		}
+	}
+	if data, _ := c.GetString("user:1"); data != `{"id":1,"name":"bob"}` {
+		t.Errorf("%v value error", data)
+		return
+	}
+	for i := 0; i < 2; i++ {
+		var u loadUser
+		if err := c.LoadStruct(ctx, "user:2", &u, loader, time.Minute); err != ErrNotFound {
+			t.Errorf("%v error", err)
+			return
+		}
+	}
+	if calls != 2 {
+		t.Errorf("%v value error", calls)
+		return
+	}
+
+	var u loadUser
+	if err := c.LoadStruct(ctx, "user:3", &u, loader, time.Minute, LoadWithNegativeTTL(0)); err != nil || u.ID != 1 {
+		t.Errorf("%v %v error", u, err)
+		return
+	}
+	calls = 0
+	loader = func(ctx context.Context, key string) (interface{}, error) {
+		calls++
+		return nil, ErrNotFound
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.LoadStruct(ctx, "user:4", &u, loader, time.Minute, LoadWithNegativeTTL(0)); err != ErrNotFound {
+			t.Errorf("%v error", err)
+			return
+		}
+	}
+	if calls != 2 {
+		t.Errorf("%v value error", calls)
+		return
+	}
+}
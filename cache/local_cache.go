@@ -1,21 +1,100 @@
 package cache
 
 import (
+	"container/heap"
 	"context"
+	"fmt"
 	"math/rand"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-	"unsafe"
 )
 
 const (
 	DefaultCheckSecond = 60
+
+	minCheckInterval = 10 * time.Millisecond
+
+	// localItemOverhead approximates the memory of an entry besides its key
+	// and value, the map slot and the cacheItem.
+	localItemOverhead = 64
+	// localEvictSample is how many entries are compared to pick the one to
+	// evict when the cache is over its size budget.
+	localEvictSample = 5
+	// localSweepBatch is how many expired entries a sweep removes before
+	// releasing the lock for the other goroutines.
+	localSweepBatch = 256
+	// localSweepMinWait is the shortest wait of the sweeper, so entries
+	// expiring close together are removed by the same sweep.
+	localSweepMinWait = time.Millisecond
 )
 
 type cacheItem struct {
-	expireSec  int
-	expireTime time.Time
-	value      interface{}
+	// expireAt is the expiration in unix nanoseconds, 0 for none. Reads
+	// extend it atomically so they only need c.m read locked. First for its
+	// alignment on 32 bit platforms.
+	expireAt int64
+	expire   time.Duration
+	value    interface{}
+	size     int64
+	// ver is the sequence number of the last write of the entry, its
+	// version with the epoch of the cache.
+	ver uint64
+
+	// k, at and index place the entry in the expiry heap of the cache: its
+	// prefixed key, its expiration when pushed, and its index or -1.
+	k     string
+	at    int64
+	index int
+}
+
+// expireTime returns the expiration of the entry, zero for none.
+func (d *cacheItem) expireTime() time.Time {
+	n := atomic.LoadInt64(&d.expireAt)
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// expired reports whether the entry expired at now.
+func (d *cacheItem) expired(now time.Time) bool {
+	n := atomic.LoadInt64(&d.expireAt)
+	return n != 0 && now.UnixNano() > n
+}
+
+// localHash is the value of a hash entry.
+type localHash map[string]interface{}
+
+// localList is the value of a list entry, the head is at index 0.
+type localList []interface{}
+
+// localSet is the value of a set entry, members are keyed by their string
+// form so 1 and "1" are the same member as they are in redis.
+type localSet map[string]interface{}
+
+// localZSet is the value of a sorted set entry, ordered by score and then by
+// the string form of the member.
+type localZSet []Z
+
+func (z localZSet) search(score float64, member string) int {
+	return sort.Search(len(z), func(i int) bool {
+		if z[i].Score != score {
+			return z[i].Score > score
+		}
+		return fmt.Sprint(z[i].Member) >= member
+	})
+}
+
+// localLease is the lease of a missing key of a LocalCache, or the stale
+// value kept by Invalidate. at and staleAt are unix nanoseconds.
+type localLease struct {
+	token   string
+	at      int64
+	stale   interface{}
+	staleAt int64
 }
 
 type cacheKV struct {
@@ -24,20 +103,71 @@ type cacheKV struct {
 }
 
 type LocalCache struct {
-	expireSec int
-	r         *rand.Rand
-	m         sync.Mutex
-	cache     map[string]interface{}
-	expireFn  CacheExpireFunc
+	typedGetters
+	expire   time.Duration
+	absolute bool
+	copy     bool
+	encode   bool
+	missErr  bool
+	prefix   string
+	maxBytes int64
+	used     int64
+	m        sync.RWMutex
+	cache    map[string]*cacheItem
+	leases   map[string]*localLease
+	epoch    string
+	seq      uint64
+	expireFn CacheExpireFunc
+	evictFn  CacheEvictFunc
+	clk      Clock
+	workers  int
+	queue    int
+	policy   NotifyPolicy
+	flush    bool
+	removed  []removal
+	bg       Background
+
+	log             *appendLog
+	compactInterval time.Duration
+	logErrFn        func(err error)
+
+	subs []chan Event
+
+	expiry expiryHeap
+	wake   chan struct{}
+
+	sweeping      bool
+	sweeps        uint64
+	swept         uint64
+	lastSweep     time.Time
+	lastSweepTime time.Duration
 }
 
 type CacheExpireFunc func(key string, value interface{})
 
+// CacheEvictFunc is called with an entry removed from the cache and the
+// reason, EventDel, EventExpire or EventEvict.
+type CacheEvictFunc func(key string, value interface{}, reason EventType)
+
+// removal is an entry removed from a LocalCache, waiting for c.m to be
+// unlocked to be passed to the eviction callback.
+type removal struct {
+	k      string
+	v      interface{}
+	reason EventType
+}
+
 type LocalOption func(c *LocalCache)
 
 func LocalWithExpire(expireSecond int) LocalOption {
 	return func(c *LocalCache) {
-		c.expireSec = expireSecond
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func LocalWithTTL(ttl time.Duration) LocalOption {
+	return func(c *LocalCache) {
+		c.expire = ttl
 	}
 }
 
@@ -47,213 +177,1121 @@ func LocalExpireNotify(fn CacheExpireFunc) LocalOption {
 	}
 }
 
-func NewLocalCache(ctx context.Context, opts ...LocalOption) *Cache {
-	c := &LocalCache{
-		r:     rand.New(rand.NewSource(time.Now().UnixNano())),
-		cache: map[string]interface{}{},
+// LocalWithClock takes the time of the expirations from clk instead of the
+// system clock, e.g. a FakeClock in tests.
+func LocalWithClock(clk Clock) LocalOption {
+	return func(c *LocalCache) {
+		c.clk = clk
+	}
+}
+
+// LocalExpireWorkers runs the LocalExpireNotify callback on workers
+// goroutines instead of the sweeper, with up to queue callbacks waiting for
+// them. policy is what the sweeper does when the queue is full.
+func LocalExpireWorkers(workers, queue int, policy NotifyPolicy) LocalOption {
+	return func(c *LocalCache) {
+		c.workers = workers
+		c.queue = queue
+		c.policy = policy
+	}
+}
+
+// LocalFlushOnClose runs the pending expire callbacks when the sweeper
+// stops, on Close or when the context of the cache is done: the ones queued
+// for the LocalExpireWorkers, then the ones of the entries expired since the
+// last sweep. Without it they are dropped.
+func LocalFlushOnClose() LocalOption {
+	return func(c *LocalCache) {
+		c.flush = true
+	}
+}
+
+// LocalEvictNotify calls fn for each entry removed by Del, DelByPrefix,
+// DelByPattern, Rename, a Tx, expiration, or eviction to stay under the budget
+// of LocalWithMaxBytes. fn runs once the cache is unlocked, on the goroutine
+// that removed the entry.
+func LocalEvictNotify(fn CacheEvictFunc) LocalOption {
+	return func(c *LocalCache) {
+		c.evictFn = fn
+	}
+}
+
+// LocalWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads.
+func LocalWithAbsoluteExpire() LocalOption {
+	return func(c *LocalCache) {
+		c.absolute = true
 	}
+}
+
+// LocalWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func LocalWithKeyPrefix(prefix string) LocalOption {
+	return func(c *LocalCache) {
+		c.prefix = prefix
+	}
+}
+
+// LocalWithMaxBytes bounds the approximate memory of the entries to n bytes,
+// evicting the entries expiring first when it is exceeded. The size of a
+// string or []byte is its length, other values are estimated, use
+// SetWithCost to give the size of structs. 0 is unlimited.
+func LocalWithMaxBytes(n int64) LocalOption {
+	return func(c *LocalCache) {
+		c.maxBytes = n
+	}
+}
+
+// LocalWithEncoding stores the values encoded as the redis backends store
+// them, Get returning a new []byte and the typed getters parsing it, so code
+// gets the same results from a LocalCache and from redis. Values of hash, list,
+// set and sorted set entries are stored as they are.
+func LocalWithEncoding() LocalOption {
+	return func(c *LocalCache) {
+		c.encode = true
+	}
+}
+
+// LocalWithCopy stores a copy of the values written and returns a copy of
+// the values read, so callers changing a slice, map or struct they got or
+// set do not change the cached value. See copyValue for how values are
+// copied. Values of hash, list, set and sorted set entries are not copied.
+func LocalWithCopy() LocalOption {
+	return func(c *LocalCache) {
+		c.copy = true
+	}
+}
+
+// LocalWithMissError makes Get and the typed getters return ErrCacheMiss when
+// the key is not cached, so a nil value can be cached, e.g. to remember that a
+// loader found nothing. GetOrSet and the other loading methods of Cache treat
+// ErrCacheMiss as a miss. The wrapping caches do not, and should wrap caches
+// built without this option.
+func LocalWithMissError() LocalOption {
+	return func(c *LocalCache) {
+		c.missErr = true
+	}
+}
+
+func NewLocalCache(ctx context.Context, opts ...LocalOption) *Cache {
+	c := &LocalCache{}
+	c.init(opts)
+	c.sweeping = true
+	c.bg.run(ctx, c.runExpireCheck)
+	return NewCache(c)
+}
+
+func (c *LocalCache) init(opts []LocalOption) {
+	c.cache = map[string]*cacheItem{}
+	c.leases = map[string]*localLease{}
+	c.epoch = newNodeID()
+	c.wake = make(chan struct{}, 1)
 	for _, fn := range opts {
 		fn(c)
 	}
-	go c.runExpireCheck(ctx)
-	return NewCache(c)
+	c.clk = ClockOf(c)
+	if c.encode {
+		c.typedGetters = ByteGetters{get: c.Get}
+	} else {
+		c.typedGetters = ValueGetters{get: c.Get}
+	}
 }
 
 func (c *LocalCache) Set(key string, value interface{}) error {
-	exp := time.Time{}
-	if c.expireSec != 0 {
-		exp = time.Now().Add(time.Second * time.Duration(c.expireSec+c.r.Intn(int(c.expireSec/10+1))))
+	return c.SetWithTTL(key, value, c.expire)
+}
+
+func (c *LocalCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *LocalCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.setWithSize(key, value, ttl, localSize(value))
+}
+
+// SetWithCost stores value with the default expiration, counting cost bytes
+// against the LocalWithMaxBytes budget instead of the estimated size.
+func (c *LocalCache) SetWithCost(key string, value interface{}, cost int64) error {
+	return c.setWithSize(key, value, c.expire, cost)
+}
+
+func (c *LocalCache) setWithSize(key string, value interface{}, ttl time.Duration, size int64) error {
+	return c.setIf(key, value, ttl, size, nil)
+}
+
+// setIf stores value as setWithSize does if check, called with c.m held,
+// returns no error.
+func (c *LocalCache) setIf(key string, value interface{}, ttl time.Duration, size int64, check func(k string) error) error {
+	k := c.prefix + key
+	size += int64(len(k)) + localItemOverhead
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return ErrOverflow
 	}
-	data := &cacheItem{
-		expireSec:  c.expireSec,
-		expireTime: exp,
-		value:      value,
+	if c.encode {
+		value = EncodeBytes(value)
+	} else if c.copy {
+		var err error
+		if value, err = copyValue(value); err != nil {
+			return err
+		}
 	}
 	c.m.Lock()
-	c.cache[key] = data
+	if check != nil {
+		if err := check(k); err != nil {
+			c.unlock()
+			return err
+		}
+	}
+	data := c.newItem(value, ttl)
+	data.size = size
+	c.put(k, data)
+	c.unlock()
+	return nil
+}
+
+// localSize estimates the memory held by value.
+func localSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int, uint, int64, uint64, float64, time.Duration:
+		return 8
+	case time.Time:
+		return 24
+	case []string:
+		var n int64
+		for _, s := range v {
+			n += 16 + int64(len(s))
+		}
+		return n
+	case []int64:
+		return 8 * int64(len(v))
+	case localHash:
+		var n int64
+		for f, x := range v {
+			n += int64(len(f)) + localSize(x) + 16
+		}
+		return n
+	case localList:
+		var n int64
+		for _, x := range v {
+			n += localSize(x) + 16
+		}
+		return n
+	case localSet:
+		var n int64
+		for m, x := range v {
+			n += int64(len(m)) + localSize(x) + 16
+		}
+		return n
+	case localZSet:
+		var n int64
+		for _, z := range v {
+			n += localSize(z.Member) + 24
+		}
+		return n
+	}
+	return 64
+}
+
+// put stores data at the prefixed key k and evicts entries while the cache
+// is over its budget. Must be called with c.m held.
+func (c *LocalCache) put(k string, data *cacheItem) {
+	if old, ok := c.cache[k]; ok {
+		c.used -= old.size
+		c.untrack(old)
+	}
+	c.seq++
+	data.ver = c.seq
+	c.cache[k] = data
+	c.track(k, data)
+	c.used += data.size
+	c.logPut(k, data)
+	c.emit(EventSet, k, data.value)
+	c.evict(k)
+}
+
+// remove deletes the prefixed key k. Must be called with c.m held.
+func (c *LocalCache) remove(k string) {
+	c.removeAs(k, EventDel)
+}
+
+// removeAs deletes the prefixed key k, reported as an event of typ. Must be
+// called with c.m held.
+func (c *LocalCache) removeAs(k string, typ EventType) {
+	data, ok := c.cache[k]
+	if !ok {
+		return
+	}
+	c.used -= data.size
+	c.untrack(data)
+	delete(c.cache, k)
+	c.logRemove(k)
+	c.emit(typ, k, data.value)
+	if c.evictFn != nil {
+		c.removed = append(c.removed, removal{k: strings.TrimPrefix(k, c.prefix), v: data.value, reason: typ})
+	}
+}
+
+// unlock unlocks c.m, then passes the entries removed meanwhile to the
+// eviction callback.
+func (c *LocalCache) unlock() {
+	removed := c.removed
+	c.removed = nil
 	c.m.Unlock()
+	for _, r := range removed {
+		c.evictFn(r.k, r.v, r.reason)
+	}
+}
+
+// grow adds delta to the size of data, stored at the prefixed key k, after
+// it was changed in place. Must be called with c.m held.
+func (c *LocalCache) grow(k string, data *cacheItem, delta int64) {
+	data.size += delta
+	c.used += delta
+	c.seq++
+	data.ver = c.seq
+	c.logPut(k, data)
+	c.emit(EventSet, k, data.value)
+	if delta > 0 {
+		c.evict(k)
+	}
+}
+
+// evict removes entries while the cache is over its budget, each time the
+// one expiring first among a few sampled entries, so expired and idle
+// entries go first. keep, the entry just written, is not evicted. Must be
+// called with c.m held.
+func (c *LocalCache) evict(keep string) {
+	for c.maxBytes > 0 && c.used > c.maxBytes {
+		var victim string
+		var victimExp time.Time
+		n := 0
+		for k, data := range c.cache {
+			if k == keep {
+				continue
+			}
+			exp := data.expireTime()
+			if victim == "" || (!exp.IsZero() && (victimExp.IsZero() || exp.Before(victimExp))) {
+				victim, victimExp = k, exp
+			}
+			if n++; n >= localEvictSample {
+				break
+			}
+		}
+		if victim == "" {
+			return
+		}
+		c.removeAs(victim, EventEvict)
+	}
+}
+
+// newItem returns an entry of value expiring after ttl. Must be called with
+// c.m held.
+func (c *LocalCache) newItem(value interface{}, ttl time.Duration) *cacheItem {
+	var exp int64
+	if ttl > 0 {
+		exp = c.clk.Now().Add(ttl + c.jitter(ttl)).UnixNano()
+	}
+	return &cacheItem{
+		expireAt: exp,
+		expire:   ttl,
+		value:    value,
+	}
+}
+
+// jitter returns a random extra up to a tenth of ttl, so entries written
+// together do not all expire together.
+func (c *LocalCache) jitter(ttl time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(ttl/10) + 1))
+}
+
+func (c *LocalCache) Get(key string) (interface{}, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	data := c.getItem(key)
+	if data == nil {
+		if c.missErr {
+			return nil, ErrCacheMiss
+		}
+		return nil, nil
+	}
+	// read under the lock, Append and the hash and list writes change the
+	// value in place
+	if c.copy || c.encode {
+		return copyValue(data.value)
+	}
+	return data.value, nil
+}
+
+func (c *LocalCache) missError() bool {
+	return c.missErr
+}
+
+func (c *LocalCache) Clock() Clock {
+	return c.clk
+}
+
+// peekItem returns the unexpired entry of key, leaving its expiration. Must
+// be called with c.m held, read locked at least.
+func (c *LocalCache) peekItem(key string, now time.Time) *cacheItem {
+	data, ok := c.cache[c.prefix+key]
+	if !ok || data.expired(now) {
+		return nil
+	}
+	return data
+}
+
+// getItem returns the unexpired entry of key and extends its expiration
+// unless the cache uses absolute expiration. Must be called with c.m held,
+// read locked at least.
+func (c *LocalCache) getItem(key string) *cacheItem {
+	now := c.clk.Now()
+	data := c.peekItem(key, now)
+	if data == nil {
+		return nil
+	}
+	if data.expire > 0 && !c.absolute {
+		// never moved earlier, the expiry heap expects no entry to expire
+		// before it was pushed to expire
+		if at := now.Add(data.expire + c.jitter(data.expire)).UnixNano(); at > atomic.LoadInt64(&data.expireAt) {
+			atomic.StoreInt64(&data.expireAt, at)
+		}
+	}
+	return data
+}
+
+func (c *LocalCache) Del(key string) error {
+	c.m.Lock()
+	c.remove(c.prefix + key)
+	c.unlock()
 	return nil
 }
 
-func (c *LocalCache) SetWithExpire(key string, value interface{}, expireSec int) error {
-	exp := time.Time{}
-	if expireSec != 0 {
-		exp = time.Now().Add(time.Second * time.Duration(expireSec+c.r.Intn(int(expireSec/10+1))))
+// GetWithVersion returns the value of key and its version.
+func (c *LocalCache) GetWithVersion(key string) (interface{}, string, error) {
+	return c.GetIfChanged(key, "")
+}
+
+// GetIfChanged returns the value of key and its version, or ErrNotModified
+// when version is still its version. Versions change on every write of the
+// key and differ between caches and restarts.
+func (c *LocalCache) GetIfChanged(key, version string) (interface{}, string, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	data := c.getItem(key)
+	if data == nil {
+		if c.missErr {
+			return nil, "", ErrCacheMiss
+		}
+		return nil, "", nil
+	}
+	ver := localVersion(c.epoch, data.ver)
+	if ver == version {
+		return nil, ver, ErrNotModified
 	}
-	data := &cacheItem{
-		expireSec:  expireSec,
-		expireTime: exp,
-		value:      value,
+	if c.copy || c.encode {
+		value, err := copyValue(data.value)
+		return value, ver, err
 	}
+	return data.value, ver, nil
+}
+
+// GetLease returns the value of key, or on a miss a lease on it valid for
+// ttl, or ErrLeaseHeld and the stale value kept by Invalidate while another
+// reader holds the lease.
+func (c *LocalCache) GetLease(key string, ttl time.Duration) (interface{}, string, error) {
+	k := c.prefix + key
 	c.m.Lock()
-	c.cache[key] = data
-	c.m.Unlock()
+	defer c.unlock()
+	if data := c.getItem(key); data != nil {
+		if c.copy || c.encode {
+			value, err := copyValue(data.value)
+			return value, "", err
+		}
+		return data.value, "", nil
+	}
+	now := c.clk.Now().UnixNano()
+	l := c.leases[k]
+	if l == nil {
+		l = &localLease{}
+		c.leases[k] = l
+	}
+	if l.staleAt <= now {
+		l.stale = nil
+	}
+	if l.token != "" && l.at > now {
+		if c.copy || c.encode {
+			stale, err := copyValue(l.stale)
+			if err != nil {
+				return nil, "", err
+			}
+			return stale, "", ErrLeaseHeld
+		}
+		return l.stale, "", ErrLeaseHeld
+	}
+	l.token = newLeaseToken()
+	l.at = now + int64(ttl)
+	return nil, l.token, nil
+}
+
+// SetLease stores value with the default expiration if token is the lease
+// of key, else returns ErrLeaseInvalid.
+func (c *LocalCache) SetLease(key string, value interface{}, token string) error {
+	return c.setIf(key, value, c.expire, localSize(value), func(k string) error {
+		if l := c.leases[k]; l == nil || token == "" || l.token != token {
+			return ErrLeaseInvalid
+		}
+		delete(c.leases, k)
+		return nil
+	})
+}
+
+// Invalidate deletes key and its lease, keeping its value for staleTTL to
+// be returned by GetLease while the key is loaded again.
+func (c *LocalCache) Invalidate(key string, staleTTL time.Duration) error {
+	k := c.prefix + key
+	c.m.Lock()
+	defer c.unlock()
+	now := c.clk.Now()
+	var stale interface{}
+	if data, ok := c.cache[k]; ok && !data.expired(now) {
+		stale = data.value
+	} else if l := c.leases[k]; l != nil && l.staleAt > now.UnixNano() {
+		stale = l.stale
+	}
+	c.remove(k)
+	delete(c.leases, k)
+	if stale != nil && staleTTL > 0 {
+		c.leases[k] = &localLease{stale: stale, staleAt: now.Add(staleTTL).UnixNano()}
+	}
 	return nil
 }
 
-func (c *LocalCache) Get(key string) (interface{}, error) {
+// Rename moves the entry of oldKey to newKey, keeping its remaining ttl: the
+// move is not a read extending it.
+func (c *LocalCache) Rename(oldKey, newKey string) error {
+	c.m.Lock()
+	defer c.unlock()
+	data := c.peekItem(oldKey, c.clk.Now())
+	if data == nil {
+		return nil
+	}
+	c.remove(c.prefix + oldKey)
+	data.size += int64(len(newKey) - len(oldKey))
+	c.put(c.prefix+newKey, data)
+	return nil
+}
+
+func (c *LocalCache) DelByPrefix(prefix string) error {
+	c.m.Lock()
+	for k := range c.cache {
+		if strings.HasPrefix(k, c.prefix+prefix) {
+			c.remove(k)
+		}
+	}
+	c.unlock()
+	return nil
+}
+
+// Clear deletes the entries under the key prefix.
+func (c *LocalCache) Clear() error {
+	return c.DelByPrefix("")
+}
+
+func (c *LocalCache) DelByPattern(pattern string) error {
+	c.m.Lock()
+	for k := range c.cache {
+		if strings.HasPrefix(k, c.prefix) && globMatch(pattern, k[len(c.prefix):]) {
+			c.remove(k)
+		}
+	}
+	c.unlock()
+	return nil
+}
+
+// Append appends suffix to the string value stored at key, creating it with
+// the default expiration if it does not exist.
+func (c *LocalCache) Append(key, suffix string) error {
+	c.m.Lock()
+	defer c.unlock()
+	data := c.getItem(key)
+	if data == nil {
+		data = c.newItem(suffix, c.expire)
+		data.size = int64(len(c.prefix+key)+len(suffix)) + localItemOverhead
+		c.put(c.prefix+key, data)
+		return nil
+	}
+	switch v := data.value.(type) {
+	case string:
+		data.value = v + suffix
+	case []byte:
+		ret := make([]byte, 0, len(v)+len(suffix))
+		ret = append(ret, v...)
+		data.value = append(ret, suffix...)
+	default:
+		return ErrDataType
+	}
+	c.grow(c.prefix+key, data, int64(len(suffix)))
+	return nil
+}
+
+// HSet sets field of the hash stored at key, creating the hash with the
+// default expiration if it does not exist.
+func (c *LocalCache) HSet(key, field string, value interface{}) error {
 	c.m.Lock()
-	defer c.m.Unlock()
-	value, ok := c.cache[key]
+	defer c.unlock()
+	data := c.getItem(key)
+	if data == nil {
+		data = c.newItem(localHash{}, c.expire)
+		data.size = int64(len(c.prefix+key)) + localItemOverhead
+		c.put(c.prefix+key, data)
+	}
+	h, ok := data.value.(localHash)
 	if !ok {
+		return ErrDataType
+	}
+	delta := localSize(value)
+	if old, ok := h[field]; ok {
+		delta -= localSize(old)
+	} else {
+		delta += int64(len(field)) + 16
+	}
+	h[field] = value
+	c.grow(c.prefix+key, data, delta)
+	return nil
+}
+
+func (c *LocalCache) HGet(key, field string) (interface{}, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	data := c.getItem(key)
+	if data == nil {
 		return nil, nil
 	}
-	data, ok := value.(*cacheItem)
+	h, ok := data.value.(localHash)
 	if !ok {
 		return nil, ErrDataType
 	}
-	if data.expireSec != 0 {
-		data.expireTime = time.Now().Add(time.Duration(data.expireSec)*time.Second + time.Duration(c.r.Intn(int(data.expireSec/10+1))))
+	return h[field], nil
+}
+
+func (c *LocalCache) HGetAll(key string) (map[string]interface{}, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	data := c.getItem(key)
+	if data == nil {
+		return nil, nil
 	}
-	return data.value, nil
+	h, ok := data.value.(localHash)
+	if !ok {
+		return nil, ErrDataType
+	}
+	ret := make(map[string]interface{}, len(h))
+	for k, v := range h {
+		ret[k] = v
+	}
+	return ret, nil
 }
 
-func (c *LocalCache) GetInt(key string) (*int64, error) {
-	value, err := c.Get(key)
-	if value == nil {
-		return nil, err
+// LPush inserts values at the head of the list stored at key, creating the
+// list with the default expiration if it does not exist.
+func (c *LocalCache) LPush(key string, values ...interface{}) error {
+	c.m.Lock()
+	defer c.unlock()
+	data := c.getItem(key)
+	if data == nil {
+		data = c.newItem(localList{}, c.expire)
+		data.size = int64(len(c.prefix+key)) + localItemOverhead
+		c.put(c.prefix+key, data)
 	}
-	var ret int64
-	switch v := value.(type) {
-	case int:
-		ret = int64(v)
-	case int8:
-		ret = int64(v)
-	case int16:
-		ret = int64(v)
-	case int32:
-		ret = int64(v)
-	case int64:
-		ret = int64(v)
-	case uint:
-		ret = int64(v)
-	case uint8:
-		ret = int64(v)
-	case uint16:
-		ret = int64(v)
-	case uint32:
-		ret = int64(v)
-	default:
+	l, ok := data.value.(localList)
+	if !ok {
+		return ErrDataType
+	}
+	ret := make(localList, 0, len(values)+len(l))
+	for i := len(values) - 1; i >= 0; i-- {
+		ret = append(ret, values[i])
+	}
+	data.value = append(ret, l...)
+	c.grow(c.prefix+key, data, localSize(localList(values)))
+	return nil
+}
+
+func (c *LocalCache) RPop(key string) (interface{}, error) {
+	c.m.Lock()
+	defer c.unlock()
+	data := c.getItem(key)
+	if data == nil {
+		return nil, nil
+	}
+	l, ok := data.value.(localList)
+	if !ok {
 		return nil, ErrDataType
 	}
-	return &ret, nil
+	if len(l) == 0 {
+		return nil, nil
+	}
+	value := l[len(l)-1]
+	l[len(l)-1] = nil
+	data.value = l[:len(l)-1]
+	c.grow(c.prefix+key, data, -localSize(localList{value}))
+	if len(l) == 1 {
+		c.remove(c.prefix + key)
+	}
+	return value, nil
 }
 
-func (c *LocalCache) GetFloat(key string) (*float64, error) {
-	value, err := c.Get(key)
-	if value == nil {
-		return nil, err
+func (c *LocalCache) LRange(key string, start, stop int64) ([]interface{}, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	data := c.getItem(key)
+	if data == nil {
+		return nil, nil
 	}
-	var ret float64
-	switch v := value.(type) {
-	case float32:
-		ret = float64(v)
-	case float64:
-		ret = float64(v)
-	default:
+	l, ok := data.value.(localList)
+	if !ok {
 		return nil, ErrDataType
 	}
-	return &ret, nil
+	n := int64(len(l))
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return []interface{}{}, nil
+	}
+	ret := make([]interface{}, stop-start+1)
+	copy(ret, l[start:stop+1])
+	return ret, nil
 }
 
-func (c *LocalCache) GetString(key string) (string, error) {
-	value, err := c.Get(key)
-	if value == nil {
-		return "", err
+// SAdd adds members to the set stored at key, creating the set with the
+// default expiration if it does not exist.
+func (c *LocalCache) SAdd(key string, members ...interface{}) error {
+	c.m.Lock()
+	defer c.unlock()
+	data := c.getItem(key)
+	if data == nil {
+		data = c.newItem(localSet{}, c.expire)
+		data.size = int64(len(c.prefix+key)) + localItemOverhead
+		c.put(c.prefix+key, data)
 	}
-	var ret string
-	switch v := value.(type) {
-	case string:
-		ret = v
-	case []byte:
-		ret = *(*string)(unsafe.Pointer(&v))
-	default:
-		return "", ErrDataType
+	st, ok := data.value.(localSet)
+	if !ok {
+		return ErrDataType
+	}
+	added := localSet{}
+	for _, m := range members {
+		k := fmt.Sprint(m)
+		if _, ok := st[k]; !ok {
+			added[k] = m
+		}
+		st[k] = m
+	}
+	c.grow(c.prefix+key, data, localSize(added))
+	return nil
+}
+
+func (c *LocalCache) SIsMember(key string, member interface{}) (bool, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	data := c.getItem(key)
+	if data == nil {
+		return false, nil
+	}
+	st, ok := data.value.(localSet)
+	if !ok {
+		return false, ErrDataType
+	}
+	_, ok = st[fmt.Sprint(member)]
+	return ok, nil
+}
+
+func (c *LocalCache) SMembers(key string) ([]interface{}, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	data := c.getItem(key)
+	if data == nil {
+		return nil, nil
+	}
+	st, ok := data.value.(localSet)
+	if !ok {
+		return nil, ErrDataType
+	}
+	ret := make([]interface{}, 0, len(st))
+	for _, v := range st {
+		ret = append(ret, v)
 	}
 	return ret, nil
 }
 
-func (c *LocalCache) GetBytes(key string) ([]byte, error) {
-	value, err := c.Get(key)
-	if value == nil {
-		return nil, err
+// ZAdd adds members to the sorted set stored at key, creating the set with
+// the default expiration if it does not exist.
+func (c *LocalCache) ZAdd(key string, members ...Z) error {
+	c.m.Lock()
+	defer c.unlock()
+	data := c.getItem(key)
+	if data == nil {
+		data = c.newItem(localZSet{}, c.expire)
+		data.size = int64(len(c.prefix+key)) + localItemOverhead
+		c.put(c.prefix+key, data)
 	}
-	var ret []byte
-	switch v := value.(type) {
-	case string:
-		ret = []byte(v)
-	case []byte:
-		ret = v
-	default:
+	z, ok := data.value.(localZSet)
+	if !ok {
+		return ErrDataType
+	}
+	oldSize := localSize(z)
+	for _, m := range members {
+		member := fmt.Sprint(m.Member)
+		for i := range z {
+			if fmt.Sprint(z[i].Member) == member {
+				z = append(z[:i], z[i+1:]...)
+				break
+			}
+		}
+		i := z.search(m.Score, member)
+		z = append(z, Z{})
+		copy(z[i+1:], z[i:])
+		z[i] = m
+	}
+	data.value = z
+	c.grow(c.prefix+key, data, localSize(z)-oldSize)
+	return nil
+}
+
+func (c *LocalCache) ZRangeByScore(key string, min, max float64) ([]Z, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	data := c.getItem(key)
+	if data == nil {
+		return nil, nil
+	}
+	z, ok := data.value.(localZSet)
+	if !ok {
 		return nil, ErrDataType
 	}
+	start := sort.Search(len(z), func(i int) bool { return z[i].Score >= min })
+	stop := sort.Search(len(z), func(i int) bool { return z[i].Score > max })
+	ret := []Z{}
+	if start < stop {
+		ret = make([]Z, stop-start)
+		copy(ret, z[start:stop])
+	}
 	return ret, nil
 }
 
-func (c *LocalCache) GetBool(key string) (*bool, error) {
-	value, err := c.Get(key)
-	if value == nil {
-		return nil, err
+// Range calls fn for each unexpired entry until fn returns false. The entries
+// are copied under the lock first so fn runs on a consistent snapshot and may
+// safely call back into the cache.
+func (c *LocalCache) Range(fn func(key string, value interface{}) bool) {
+	now := c.clk.Now()
+	c.m.RLock()
+	snapshot := make([]*cacheKV, 0, len(c.cache))
+	for k, data := range c.cache {
+		if data.expired(now) {
+			continue
+		}
+		snapshot = append(snapshot, &cacheKV{k: strings.TrimPrefix(k, c.prefix), v: &cacheItem{value: data.value}})
 	}
-	ret := false
-	switch v := value.(type) {
-	case float32, float64, int, int64:
-		if v == 1 {
-			ret = true
+	c.m.RUnlock()
+	for _, x := range snapshot {
+		if !fn(x.k, x.v.value) {
+			return
 		}
-	case string:
-		if v == "true" || v == "1" || v == "t" || v == "T" {
-			ret = true
+	}
+}
+
+// Tx applies the writes queued by fn in a single critical section, so no
+// reader sees some of them without the others.
+func (c *LocalCache) Tx(fn func(tx TxCache) error) error {
+	log := &txLog{}
+	if err := fn(log); err != nil {
+		return err
+	}
+	for i, op := range log.ops {
+		if !op.del && c.maxBytes > 0 && localSize(op.value)+int64(len(c.prefix+op.key))+localItemOverhead > c.maxBytes {
+			return ErrOverflow
 		}
-	case bool:
-		ret = v
-	default:
-		return nil, ErrDataType
+		if !op.del && c.encode {
+			log.ops[i].value = EncodeBytes(op.value)
+		} else if !op.del && c.copy {
+			value, err := copyValue(op.value)
+			if err != nil {
+				return err
+			}
+			log.ops[i].value = value
+		}
+	}
+	c.m.Lock()
+	defer c.unlock()
+	for _, op := range log.ops {
+		k := c.prefix + op.key
+		if op.del {
+			c.remove(k)
+			continue
+		}
+		ttl := op.ttl
+		if op.def {
+			ttl = c.expire
+		}
+		data := c.newItem(op.value, ttl)
+		data.size = localSize(op.value) + int64(len(k)) + localItemOverhead
+		c.put(k, data)
 	}
-	return &ret, nil
+	return nil
 }
 
-func (c *LocalCache) Del(key string) error {
+// RangeTTL is Range passing the remaining ttl of each entry, 0 when it does
+// not expire.
+func (c *LocalCache) RangeTTL(fn func(key string, value interface{}, ttl time.Duration) bool) error {
+	now := c.clk.Now()
+	return c.ForEach(func(key string, value interface{}, expireAt time.Time) bool {
+		var ttl time.Duration
+		if !expireAt.IsZero() {
+			ttl = expireAt.Sub(now)
+		}
+		return fn(key, value, ttl)
+	})
+}
+
+// ForEach calls fn for each unexpired entry with its expiration, zero when
+// it does not expire, until fn returns false. Like Range the entries are
+// copied under the lock first, fn runs on a consistent snapshot without
+// holding it.
+func (c *LocalCache) ForEach(fn func(key string, value interface{}, expireAt time.Time) bool) error {
+	now := c.clk.Now()
+	c.m.RLock()
+	type entry struct {
+		key      string
+		value    interface{}
+		expireAt time.Time
+	}
+	snapshot := make([]entry, 0, len(c.cache))
+	for k, data := range c.cache {
+		if data.expired(now) {
+			continue
+		}
+		snapshot = append(snapshot, entry{key: strings.TrimPrefix(k, c.prefix), value: data.value, expireAt: data.expireTime()})
+	}
+	c.m.RUnlock()
+	for _, x := range snapshot {
+		if !fn(x.key, x.value, x.expireAt) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Info returns the number of entries, their estimated memory and the
+// statistics of the expiration sweeps.
+func (c *LocalCache) Info() CacheInfo {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	return CacheInfo{
+		Entries:       len(c.cache),
+		Bytes:         c.used,
+		Sweeps:        c.sweeps,
+		Swept:         c.swept,
+		LastSweep:     c.lastSweep,
+		LastSweepTime: c.lastSweepTime,
+	}
+}
+
+// MemoryUsage returns the estimated bytes of the entries, counted against
+// LocalWithMaxBytes, including the expired entries not swept yet.
+func (c *LocalCache) MemoryUsage() (int64, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	return c.used, nil
+}
+
+// Count returns the number of unexpired entries.
+func (c *LocalCache) Count() (int, error) {
+	now := c.clk.Now()
+	c.m.RLock()
+	defer c.m.RUnlock()
+	n := 0
+	for _, data := range c.cache {
+		if !data.expired(now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Subscribe calls fn with the writes, deletes, expirations and evictions of
+// keys until ctx is done. Up to eventBuffer events wait for fn, the next ones
+// are dropped. Hash, list, set and sorted set events have no Value, being
+// changed in place.
+func (c *LocalCache) Subscribe(ctx context.Context, fn EventFunc) error {
+	ch := make(chan Event, eventBuffer)
 	c.m.Lock()
-	delete(c.cache, key)
-	c.m.Unlock()
+	c.subs = append(c.subs, ch)
+	c.unlock()
+	go func() {
+		for {
+			select {
+			case e := <-ch:
+				fn(e)
+			case <-ctx.Done():
+				c.m.Lock()
+				for i, sub := range c.subs {
+					if sub == ch {
+						c.subs = append(c.subs[:i:i], c.subs[i+1:]...)
+						break
+					}
+				}
+				c.unlock()
+				return
+			}
+		}
+	}()
 	return nil
 }
 
-func (c *LocalCache) runExpireCheck(ctx context.Context) {
-	exp := c.expireSec
-	if exp > 0 {
-		exp /= 2
-	} else {
-		exp = DefaultCheckSecond
+// emit queues an event of the prefixed key k for the subscribers. Must be
+// called with c.m held.
+func (c *LocalCache) emit(typ EventType, k string, value interface{}) {
+	if len(c.subs) == 0 {
+		return
 	}
-	timer := time.NewTimer(time.Duration(exp) * time.Second)
-	tmpDel := []*cacheKV{}
+	switch value.(type) {
+	case localHash, localList, localSet, localZSet:
+		value = nil
+	}
+	e := Event{Type: typ, Key: strings.TrimPrefix(k, c.prefix), Value: value}
+	for _, ch := range c.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Ping returns ErrSweeperStopped once the context of the cache is done, the
+// expired entries are then no longer removed.
+func (c *LocalCache) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	if !c.sweeping {
+		return ErrSweeperStopped
+	}
+	return nil
+}
+
+// Close stops the sweeper and the append log, and waits for them to return,
+// the pending expire callbacks running first with LocalFlushOnClose. The
+// entries can still be read and written, they no longer expire in the
+// background.
+func (c *LocalCache) Close() error {
+	c.bg.Stop()
+	return nil
+}
+
+func (c *LocalCache) runExpireCheck(ctx context.Context) {
+	notify, drain := expireNotifier(ctx, c.expireFn, c.workers, c.queue, c.policy)
+	timer := c.clk.NewTimer(DefaultCheckSecond * time.Second)
 	for {
+		c.m.RLock()
+		next := c.nextExpiry()
+		c.m.RUnlock()
+		wait := DefaultCheckSecond * time.Second
+		if next != 0 {
+			wait = time.Unix(0, next).Sub(c.clk.Now())
+			if wait < localSweepMinWait {
+				wait = localSweepMinWait
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C():
+			default:
+			}
+		}
+		timer.Reset(wait)
 		select {
-		case <-timer.C:
-			c.m.Lock()
-			for k, v := range c.cache {
-				data, ok := v.(*cacheItem)
-				if !ok {
-					delete(c.cache, k)
-					continue
-				}
-				if !data.expireTime.IsZero() && time.Now().After(data.expireTime) {
-					delete(c.cache, k)
-					tmpDel = append(tmpDel, &cacheKV{k: k, v: data})
+		case <-timer.C():
+			for _, x := range c.sweep() {
+				if notify != nil {
+					notify(x.k, x.v.value)
 				}
 			}
-			c.m.Unlock()
-			for _, x := range tmpDel {
-				if c.expireFn != nil {
+		case <-c.wake:
+		case <-ctx.Done():
+			timer.Stop()
+			drain(c.flush)
+			if c.flush && c.expireFn != nil {
+				for _, x := range c.sweep() {
 					c.expireFn(x.k, x.v.value)
 				}
 			}
-			tmpDel = tmpDel[0:0]
-			timer = time.NewTimer(time.Duration(exp) * time.Second)
-		case <-ctx.Done():
+			c.m.Lock()
+			c.sweeping = false
+			c.unlock()
 			return
 		}
 	}
 }
+
+// sweep removes the expired entries from the top of the expiry heap, pushing
+// back the ones extended by reads. The lock is released every
+// localSweepBatch entries. The expired leases are dropped too. It returns
+// the removed entries.
+func (c *LocalCache) sweep() []*cacheKV {
+	var removed []*cacheKV
+	start := time.Now()
+	for more := true; more; {
+		c.m.Lock()
+		now := c.clk.Now().UnixNano()
+		more = false
+		for n := 0; len(c.expiry) > 0 && c.expiry[0].at <= now; n++ {
+			if n == localSweepBatch {
+				more = true
+				break
+			}
+			data := c.expiry[0]
+			if at := atomic.LoadInt64(&data.expireAt); at > now {
+				data.at = at
+				heap.Fix(&c.expiry, 0)
+				continue
+			}
+			c.removeAs(data.k, EventExpire)
+			removed = append(removed, &cacheKV{k: strings.TrimPrefix(data.k, c.prefix), v: data})
+		}
+		c.unlock()
+	}
+	c.m.Lock()
+	now := c.clk.Now().UnixNano()
+	for k, l := range c.leases {
+		if l.at <= now && l.staleAt <= now {
+			delete(c.leases, k)
+		}
+	}
+	c.sweeps++
+	c.swept += uint64(len(removed))
+	c.lastSweep = start
+	c.lastSweepTime = time.Since(start)
+	c.unlock()
+	return removed
+}
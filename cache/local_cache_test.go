@@ -3,6 +3,14 @@ package cache
 import (
 	"bytes"
 	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -117,7 +125,8 @@ func TestLocalDel(t *testing.T) {
 func TestLocalExpire(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	c := NewLocalCache(ctx, LocalWithExpire(10))
+	clk := NewFakeClock(time.Now())
+	c := NewLocalCache(ctx, LocalWithExpire(10), LocalWithClock(clk))
 	v := true
 	key := "test:123"
 	c.Set(key, v)
@@ -126,7 +135,7 @@ func TestLocalExpire(t *testing.T) {
 		t.Errorf("%v value error", data)
 		return
 	}
-	time.Sleep(20 * time.Second)
+	clk.Advance(20 * time.Second)
 	data, err := c.GetBool(key)
 	if data != nil || err != nil {
 		t.Errorf("%v value error:%v", data, err)
@@ -137,7 +146,8 @@ func TestLocalExpire(t *testing.T) {
 func TestLocalExtend(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	c := NewLocalCache(ctx, LocalWithExpire(10))
+	clk := NewFakeClock(time.Now())
+	c := NewLocalCache(ctx, LocalWithExpire(10), LocalWithClock(clk))
 	v := true
 	key := "test:123"
 	c.Set(key, v)
@@ -146,17 +156,15 @@ func TestLocalExtend(t *testing.T) {
 		t.Errorf("%v value error", data)
 		return
 	}
-	go func() {
-		for i := 0; i < 2; i++ {
-			time.Sleep(7 * time.Second)
-			data, _ := c.GetBool(key)
-			if data == nil || *data != v {
-				t.Errorf("%v value error", data)
-				return
-			}
+	for i := 0; i < 2; i++ {
+		clk.Advance(7 * time.Second)
+		data, _ := c.GetBool(key)
+		if data == nil || *data != v {
+			t.Errorf("%v value error", data)
+			return
 		}
-	}()
-	time.Sleep(15 * time.Second)
+	}
+	clk.Advance(time.Second)
 	data, err := c.GetBool(key)
 	if data == nil || *data != v {
 		t.Errorf("%v value error:%v", data, err)
@@ -167,7 +175,8 @@ func TestLocalExtend(t *testing.T) {
 func TestLocalSetBoolNoExpire(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	c := NewLocalCache(ctx)
+	clk := NewFakeClock(time.Now())
+	c := NewLocalCache(ctx, LocalWithClock(clk))
 	key := "test:123"
 	v := true
 	c.Set(key, v)
@@ -176,7 +185,7 @@ func TestLocalSetBoolNoExpire(t *testing.T) {
 		t.Errorf("%v value error", data)
 		return
 	}
-	time.Sleep(300 * time.Second)
+	clk.Advance(300 * time.Second)
 	data, _ = c.GetBool(key)
 	if data == nil || *data != v {
 		t.Errorf("%v value error", data)
@@ -193,7 +202,8 @@ func TestLocalSetBoolNoExpire(t *testing.T) {
 func TestLocalSetExpire(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	c := NewLocalCache(ctx, LocalWithExpire(5))
+	clk := NewFakeClock(time.Now())
+	c := NewLocalCache(ctx, LocalWithExpire(5), LocalWithClock(clk))
 	v := true
 	key := "test:123"
 	c.SetWithExpire(key, v, 30)
@@ -202,16 +212,1155 @@ func TestLocalSetExpire(t *testing.T) {
 		t.Errorf("%v value error", data)
 		return
 	}
-	time.Sleep(15 * time.Second)
+	clk.Advance(15 * time.Second)
 	data, _ = c.GetBool(key)
 	if data == nil || *data != v {
 		t.Errorf("%v value error", data)
 		return
 	}
-	time.Sleep(45 * time.Second)
+	clk.Advance(45 * time.Second)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestLocalAbsoluteExpire(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := NewFakeClock(time.Now())
+	c := NewLocalCache(ctx, LocalWithExpire(2), LocalWithAbsoluteExpire(), LocalWithClock(clk))
+	v := true
+	key := "test:123"
+	c.Set(key, v)
+	for i := 0; i < 8; i++ {
+		c.GetBool(key)
+		clk.Advance(500 * time.Millisecond)
+	}
+	clk.Advance(time.Second)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestLocalRange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	for i := 0; i < 10; i++ {
+		c.Set(fmt.Sprintf("test:%d", i), i)
+	}
+	seen := map[string]interface{}{}
+	err := c.Range(func(key string, value interface{}) bool {
+		c.Del(key)
+		seen[key] = value
+		return true
+	})
+	if err != nil || len(seen) != 10 {
+		t.Errorf("%v value error:%v", seen, err)
+		return
+	}
+	if seen["test:3"] != 3 {
+		t.Errorf("%v value error", seen["test:3"])
+		return
+	}
+	count := 0
+	c.Range(func(key string, value interface{}) bool {
+		count++
+		return true
+	})
+	if count != 0 {
+		t.Errorf("%v value error", count)
+		return
+	}
+}
+
+func TestLocalSetTTL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithTTL(time.Hour))
+	v := true
+	key := "test:123"
+	c.SetWithTTL(key, v, 200*time.Millisecond)
+	data, _ := c.GetBool(key)
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+	time.Sleep(300 * time.Millisecond)
 	data, err := c.GetBool(key)
 	if data != nil || err != nil {
 		t.Errorf("%v value error:%v", data, err)
 		return
 	}
 }
+
+func TestLocalKeyPrefix(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithKeyPrefix("svcA:"))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.Range(func(key string, value interface{}) bool {
+		if key != "test:123" {
+			t.Errorf("%v key error", key)
+		}
+		return true
+	})
+}
+
+func TestLocalSetTime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	v := time.Now()
+	c.Set("test:123", v)
+	data, _ := c.GetTime("test:123")
+	if data == nil || !data.Equal(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.Set("test:123", v.Format(time.RFC3339Nano))
+	data, _ = c.GetTime("test:123")
+	if data == nil || !data.Equal(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestLocalSetDuration(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	v := 1500 * time.Millisecond
+	c.Set("test:123", v)
+	data, _ := c.GetDuration("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.Set("test:123", "1500000000")
+	data, _ = c.GetDuration("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestLocalSetUint(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	v := uint64(math.MaxUint64)
+	c.Set("test:123", v)
+	data, _ := c.GetUint("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+	ret, err := c.GetInt("test:123")
+	if ret != nil || err != ErrOverflow {
+		t.Errorf("%v value error:%v", ret, err)
+		return
+	}
+	c.Set("test:123", -1)
+	data, err = c.GetUint("test:123")
+	if data != nil || err != ErrOverflow {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestLocalSetSlice(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	c.Set("test:123", []string{"a", "b"})
+	strs, _ := c.GetStringSlice("test:123")
+	if len(strs) != 2 || strs[1] != "b" {
+		t.Errorf("%v value error", strs)
+		return
+	}
+	c.Set("test:123", []int{1, 2})
+	ints, _ := c.GetIntSlice("test:123")
+	if len(ints) != 2 || ints[1] != 2 {
+		t.Errorf("%v value error", ints)
+		return
+	}
+	c.Set("test:123", "[3,4]")
+	ints, _ = c.GetIntSlice("test:123")
+	if len(ints) != 2 || ints[1] != 4 {
+		t.Errorf("%v value error", ints)
+		return
+	}
+}
+
+func TestLocalHash(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	key := "test:123"
+	c.HSet(key, "name", "test")
+	c.HSet(key, "age", 3)
+	data, _ := c.HGet(key, "age")
+	if data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	all, _ := c.HGetAll(key)
+	if len(all) != 2 || all["name"] != "test" {
+		t.Errorf("%v value error", all)
+		return
+	}
+	c.Set("test:456", 3)
+	if err := c.HSet("test:456", "name", "test"); err != ErrDataType {
+		t.Errorf("%v error", err)
+		return
+	}
+}
+
+func TestLocalList(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	key := "test:123"
+	c.LPush(key, 1, 2)
+	c.LPush(key, 3)
+	data, _ := c.LRange(key, 0, -1)
+	if len(data) != 3 || data[0] != 3 || data[1] != 2 || data[2] != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.LRange(key, -2, 10)
+	if len(data) != 2 || data[0] != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	for _, v := range []int{1, 2, 3} {
+		value, _ := c.RPop(key)
+		if value != v {
+			t.Errorf("%v value error", value)
+			return
+		}
+	}
+	value, err := c.RPop(key)
+	if value != nil || err != nil {
+		t.Errorf("%v value error:%v", value, err)
+		return
+	}
+}
+
+func TestLocalSetMembers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	key := "test:123"
+	c.SAdd(key, 1, 2, 2)
+	ok, _ := c.SIsMember(key, "2")
+	if !ok {
+		t.Errorf("%v value error", ok)
+		return
+	}
+	ok, _ = c.SIsMember(key, 3)
+	if ok {
+		t.Errorf("%v value error", ok)
+		return
+	}
+	data, _ := c.SMembers(key)
+	if len(data) != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestLocalZSet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	key := "test:123"
+	c.ZAdd(key, Z{Score: 3, Member: "c"}, Z{Score: 1, Member: "a"}, Z{Score: 2, Member: "b"})
+	c.ZAdd(key, Z{Score: 5, Member: "a"})
+	data, _ := c.ZRangeByScore(key, 2, math.Inf(1))
+	if len(data) != 3 || data[0].Member != "b" || data[2].Member != "a" || data[2].Score != 5 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.ZRangeByScore(key, 0, 1)
+	if len(data) != 0 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestLocalAppend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	key := "test:123"
+	c.Append(key, "a")
+	c.Append(key, "b")
+	data, _ := c.GetString(key)
+	if data != "ab" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.Set(key, 3)
+	if err := c.Append(key, "c"); err != ErrDataType {
+		t.Errorf("%v error", err)
+		return
+	}
+}
+
+func TestLocalDelByPattern(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithKeyPrefix("svcA:"))
+	c.Set("user:42:name", "test")
+	c.Set("user:42:age", 3)
+	c.Set("user:43:name", "test")
+	c.DelByPattern("user:42:*")
+	data, _ := c.Get("user:42:age")
+	if data != nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.Get("user:43:name")
+	if data == nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.DelByPrefix("user:")
+	data, _ = c.Get("user:43:name")
+	if data != nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestLocalRename(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	c.Set("test:new", 3)
+	c.Rename("test:new", "test:123")
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	value, _ := c.Get("test:new")
+	if value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+	if err := c.Rename("test:new", "test:123"); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+}
+
+func TestLocalRenameKeepsTTL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := NewFakeClock(time.Now())
+	c := NewLocalCache(ctx, LocalWithClock(clk))
+	c.SetWithTTL("test:new", 3, 10*time.Second)
+	clk.Advance(8 * time.Second)
+	c.Rename("test:new", "test:123")
+	clk.Advance(4 * time.Second)
+	if value, _ := c.Get("test:123"); value != nil {
+		t.Errorf("%v value error", value)
+	}
+}
+
+func TestLocalMaxBytes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10), LocalWithMaxBytes(10*1024))
+	value := string(make([]byte, 1024))
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprintf("test:%d", i), value)
+	}
+	n := 0
+	c.Range(func(key string, value interface{}) bool {
+		n++
+		return true
+	})
+	if n == 0 || n > 10 {
+		t.Errorf("%v entries error", n)
+		return
+	}
+	data, _ := c.GetString("test:99")
+	if data != value {
+		t.Errorf("last value error")
+		return
+	}
+	if err := c.Set("test:big", string(make([]byte, 20*1024))); err != ErrOverflow {
+		t.Errorf("%v error", err)
+		return
+	}
+}
+
+func TestLocalMaxBytesCost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10), LocalWithMaxBytes(1024))
+	type user struct{ name string }
+	c.SetWithCost("test:1", &user{name: "a"}, 600)
+	c.SetWithCost("test:2", &user{name: "b"}, 600)
+	data, _ := c.Get("test:1")
+	if data != nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.Get("test:2")
+	if data == nil {
+		t.Errorf("value error")
+		return
+	}
+}
+
+func TestLocalMaxBytesCollections(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewLocalCache(ctx, LocalWithMaxBytes(1<<20)).cache.(*LocalCache)
+	l.LPush("test:list", "a", "b")
+	l.RPop("test:list")
+	l.RPop("test:list")
+	l.SAdd("test:set", "a", "a")
+	l.HSet("test:hash", "f", "v")
+	l.Append("test:str", "ab")
+	l.Del("test:set")
+	l.Del("test:hash")
+	l.Del("test:str")
+	if l.used != 0 {
+		t.Errorf("%v used error", l.used)
+		return
+	}
+}
+
+func TestLocalSaveLoad(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	c.Set("test:123", 3)
+	c.SetWithTTL("test:expired", 1, time.Millisecond)
+	c.SetWithTTL("test:forever", "a", 0)
+	c.HSet("test:hash", "f", 1)
+	c.ZAdd("test:zset", Z{Score: 1, Member: "m"})
+	time.Sleep(5 * time.Millisecond)
+	path := filepath.Join(t.TempDir(), "local.snap")
+	if err := c.SaveFile(path); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	restored := NewLocalCache(ctx, LocalWithExpire(10))
+	if err := restored.LoadFile(path); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ := restored.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	s, _ := restored.GetString("test:forever")
+	if s != "a" {
+		t.Errorf("%v value error", s)
+		return
+	}
+	value, _ := restored.Get("test:expired")
+	if value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+	field, _ := restored.HGet("test:hash", "f")
+	if field != 1 {
+		t.Errorf("%v value error", field)
+		return
+	}
+	zs, _ := restored.ZRangeByScore("test:zset", 0, 2)
+	if len(zs) != 1 || zs[0].Member != "m" {
+		t.Errorf("%v value error", zs)
+		return
+	}
+	if err := restored.LoadFile(path + ".missing"); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+}
+
+func TestLocalAppendLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "local.log")
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := NewLocalCacheWithLog(ctx, path, LocalWithExpire(10))
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	c.Set("test:123", 3)
+	c.Set("test:del", 1)
+	c.Del("test:del")
+	c.LPush("test:list", "a", "b")
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	c, err = NewLocalCacheWithLog(ctx, path, LocalWithExpire(10), LocalWithCompactInterval(10*time.Millisecond))
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	value, _ := c.Get("test:del")
+	if value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+	list, _ := c.LRange("test:list", 0, -1)
+	if len(list) != 2 || list[0] != "b" {
+		t.Errorf("%v value error", list)
+		return
+	}
+	c.Set("test:1", 1)
+	time.Sleep(50 * time.Millisecond)
+	restored := NewLocalCache(ctx)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	defer f.Close()
+	// a compacted log is a snapshot
+	if err := restored.Load(f); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ = restored.GetInt("test:1")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestLocalTx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	c.Set("test:del", 1)
+	err := c.Tx(func(tx TxCache) error {
+		tx.Set("test:1", 1)
+		tx.SetWithTTL("test:2", 2, time.Second)
+		tx.Del("test:del")
+		return nil
+	})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ := c.GetInt("test:2")
+	if data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	value, _ := c.Get("test:del")
+	if value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+	errAbort := errors.New("abort error")
+	err = c.Tx(func(tx TxCache) error {
+		tx.Set("test:3", 3)
+		return errAbort
+	})
+	if err != errAbort {
+		t.Errorf("%v error", err)
+		return
+	}
+	value, _ = c.Get("test:3")
+	if value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+}
+
+func TestLocalTxOverflow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithMaxBytes(200))
+	err := c.Tx(func(tx TxCache) error {
+		tx.Set("test:1", 1)
+		tx.Set("test:2", string(make([]byte, 300)))
+		return nil
+	})
+	if err != ErrOverflow {
+		t.Errorf("%v error", err)
+		return
+	}
+	value, _ := c.Get("test:1")
+	if value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+}
+
+func TestLocalPing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l1ctx, l1cancel := context.WithCancel(ctx)
+	defer l1cancel()
+	c := NewTieredCache(NewLocalCache(l1ctx), NewCache(&SyncMapCache{}))
+	if err := c.Ping(ctx); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	l1cancel()
+	time.Sleep(10 * time.Millisecond)
+	if err := c.Ping(ctx); err != ErrSweeperStopped {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestLocalClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewTieredCache(NewLocalCache(ctx), NewCache(&SyncMapCache{}))
+	if err := c.Close(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := c.Ping(ctx); err != ErrSweeperStopped {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+
+	for _, opts := range [][]LocalOption{
+		{LocalFlushOnClose()},
+		{LocalFlushOnClose(), LocalExpireWorkers(1, 10, NotifyQueue)},
+	} {
+		var m sync.Mutex
+		var expired []string
+		opts = append(opts, LocalWithTTL(time.Millisecond), LocalExpireNotify(func(key string, value interface{}) {
+			time.Sleep(5 * time.Millisecond)
+			m.Lock()
+			expired = append(expired, key)
+			m.Unlock()
+		}))
+		c := NewLocalCache(ctx, opts...)
+		for i := 0; i < 3; i++ {
+			c.Set(fmt.Sprint("test:", i), i)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if err := c.Close(); err != nil {
+			t.Errorf("%v error", err)
+			return
+		}
+		m.Lock()
+		n := len(expired)
+		m.Unlock()
+		if n != 3 {
+			t.Errorf("%v value error", n)
+			return
+		}
+	}
+}
+
+func TestLocalSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithTTL(10*time.Millisecond), LocalWithMaxBytes(100))
+	events := make(chan Event, 10)
+	c.Subscribe(ctx, func(e Event) {
+		events <- e
+	})
+	c.Set("test:1", "abc")
+	c.Del("test:1")
+	c.Set("test:2", "abc")
+	c.Set("test:3", "abc")
+	want := []Event{
+		{Type: EventSet, Key: "test:1", Value: "abc"},
+		{Type: EventDel, Key: "test:1", Value: "abc"},
+		{Type: EventSet, Key: "test:2", Value: "abc"},
+		{Type: EventSet, Key: "test:3", Value: "abc"},
+		{Type: EventEvict, Key: "test:2", Value: "abc"},
+		{Type: EventExpire, Key: "test:3", Value: "abc"},
+	}
+	for _, w := range want {
+		select {
+		case e := <-events:
+			if e != w {
+				t.Errorf("%v value error", e)
+				return
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%v missing", w)
+			return
+		}
+	}
+}
+
+func BenchmarkLocalGetSet(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprint("test:", i), i)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := fmt.Sprint("test:", i%100)
+			if i%10 == 0 {
+				c.Set(key, i)
+			} else {
+				c.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func TestLocalSweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx)
+	for i := 0; i < 10000; i++ {
+		c.SetWithTTL(fmt.Sprint("test:", i), i, 10*time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		c.Set(fmt.Sprint("keep:", i), i)
+	}
+	time.Sleep(500 * time.Millisecond)
+	info, _ := c.Info()
+	if info.Entries != 10 || info.Swept != 10000 {
+		t.Errorf("%+v value error", info)
+	}
+}
+
+func TestLocalExpireHeap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	expired := make(chan string, 3)
+	c := NewLocalCache(ctx, LocalExpireNotify(func(key string, value interface{}) {
+		expired <- key
+	}))
+	start := time.Now()
+	c.SetWithTTL("test:2", 2, 200*time.Millisecond)
+	c.SetWithTTL("test:1", 1, 50*time.Millisecond)
+	c.Set("test:3", 3)
+	for _, want := range []string{"test:1", "test:2"} {
+		select {
+		case key := <-expired:
+			if key != want {
+				t.Errorf("%v value error", key)
+				return
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%v not expired", want)
+			return
+		}
+	}
+	if d := time.Since(start); d > 300*time.Millisecond {
+		t.Errorf("%v expiration late", d)
+	}
+	if data, _ := c.GetInt("test:3"); data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+	}
+}
+
+func TestLocalExpireWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	expired := make(chan string, 10)
+	slow := func(key string, value interface{}) {
+		if key == "test:1" {
+			<-release
+		}
+		expired <- key
+	}
+	c := NewLocalCache(ctx, LocalExpireNotify(slow), LocalExpireWorkers(2, 10, NotifyQueue))
+	c.SetWithTTL("test:1", 1, 20*time.Millisecond)
+	c.SetWithTTL("test:2", 2, 20*time.Millisecond)
+	c.SetWithTTL("test:3", 3, 100*time.Millisecond)
+	for _, want := range []string{"test:2", "test:3"} {
+		select {
+		case key := <-expired:
+			if key != want {
+				t.Errorf("%v value error", key)
+				return
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%v not expired", want)
+			return
+		}
+	}
+	close(release)
+	if key := <-expired; key != "test:1" {
+		t.Errorf("%v value error", key)
+	}
+
+	// a single busy worker and no queue drops the other callbacks
+	release = make(chan struct{})
+	expired = make(chan string, 10)
+	c = NewLocalCache(ctx, LocalExpireNotify(slow), LocalExpireWorkers(1, 0, NotifyDrop))
+	c.SetWithTTL("test:1", 1, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	c.SetWithTTL("test:2", 2, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	if key := <-expired; key != "test:1" {
+		t.Errorf("%v value error", key)
+	}
+	select {
+	case key := <-expired:
+		t.Errorf("%v not dropped", key)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+type copyTestValue struct {
+	Names []string
+}
+
+func init() {
+	gob.Register(copyTestValue{})
+}
+
+func TestLocalCopy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10), LocalWithCopy())
+	b := []byte("abc")
+	c.Set("test:bytes", b)
+	b[0] = 'x'
+	data, _ := c.GetBytes("test:bytes")
+	if string(data) != "abc" {
+		t.Errorf("%s value error", data)
+		return
+	}
+	data[0] = 'y'
+	data, _ = c.GetBytes("test:bytes")
+	if string(data) != "abc" {
+		t.Errorf("%s value error", data)
+		return
+	}
+	v := copyTestValue{Names: []string{"a"}}
+	c.Set("test:struct", v)
+	v.Names[0] = "b"
+	value, err := c.Get("test:struct")
+	got, ok := value.(copyTestValue)
+	if err != nil || !ok || got.Names[0] != "a" {
+		t.Errorf("%v %v value error", value, err)
+		return
+	}
+	got.Names[0] = "c"
+	value, _ = c.Get("test:struct")
+	if value.(copyTestValue).Names[0] != "a" {
+		t.Errorf("%v value error", value)
+	}
+}
+
+func TestLocalEncoding(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10), LocalWithEncoding())
+	c.Set("test:int", 3)
+	value, _ := c.Get("test:int")
+	if b, ok := value.([]byte); !ok || string(b) != "3" {
+		t.Errorf("%v value error", value)
+		return
+	}
+	s, _ := c.GetString("test:int")
+	i, _ := c.GetInt("test:int")
+	if s != "3" || i == nil || *i != 3 {
+		t.Errorf("%v %v value error", s, i)
+		return
+	}
+	c.Set("test:bool", true)
+	ok, _ := c.GetBool("test:bool")
+	if ok == nil || !*ok {
+		t.Errorf("%v value error", ok)
+		return
+	}
+	now := time.Now()
+	c.Set("test:time", now)
+	tm, _ := c.GetTime("test:time")
+	if tm == nil || !tm.Equal(now) {
+		t.Errorf("%v value error", tm)
+		return
+	}
+	c.Set("test:slice", []string{"a", "b"})
+	slice, _ := c.GetStringSlice("test:slice")
+	if len(slice) != 2 || slice[1] != "b" {
+		t.Errorf("%v value error", slice)
+	}
+}
+
+func TestLocalMissError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10), LocalWithMissError())
+	if _, err := c.Get("test:miss"); err != ErrCacheMiss {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if _, err := c.GetInt("test:miss"); err != ErrCacheMiss {
+		t.Errorf("%v value error", err)
+		return
+	}
+	c.Set("test:nil", nil)
+	value, err := c.Get("test:nil")
+	if value != nil || err != nil {
+		t.Errorf("%v %v value error", value, err)
+		return
+	}
+	loads := 0
+	loader := func(key string) (interface{}, error) {
+		loads++
+		return nil, nil
+	}
+	for i := 0; i < 2; i++ {
+		value, err = c.GetOrSet("test:load", loader)
+		if value != nil || err != nil {
+			t.Errorf("%v %v value error", value, err)
+			return
+		}
+	}
+	if loads != 1 {
+		t.Errorf("%v value error", loads)
+		return
+	}
+	values, err := c.MGet("test:miss", "test:nil")
+	if err != nil || len(values) != 2 {
+		t.Errorf("%v %v value error", values, err)
+		return
+	}
+	stats := c.Stats()
+	if stats.Errors != 0 || stats.Hits == 0 || stats.Misses == 0 {
+		t.Errorf("%+v value error", stats)
+	}
+}
+
+func TestLocalGetBool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	local := NewLocalCache(ctx, LocalWithExpire(10))
+	encoded := NewLocalCache(ctx, LocalWithExpire(10), LocalWithEncoding())
+	values := map[interface{}]bool{
+		true: true, false: false, 1: true, 0: false, int64(2): true, uint8(0): false,
+		1.0: true, float32(0): false, "1": true, "0": false, "t": true, "F": false,
+		"true": true, "FALSE": false, "1.5": true, "0.0": false,
+	}
+	for v, want := range values {
+		for _, c := range []*Cache{local, encoded} {
+			c.Set("test:bool", v)
+			data, err := c.GetBool("test:bool")
+			if err != nil || data == nil || *data != want {
+				t.Errorf("%v %v %v value error", v, data, err)
+				return
+			}
+		}
+	}
+	for _, c := range []*Cache{local, encoded} {
+		c.Set("test:bool", "abc")
+		if _, err := c.GetBool("test:bool"); err != ErrDataType {
+			t.Errorf("%v value error", err)
+			return
+		}
+	}
+}
+
+func TestLocalEvictNotify(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var c *Cache
+	removed := make(chan string, 10)
+	c = NewLocalCache(ctx, LocalWithMaxBytes(1000), LocalEvictNotify(func(key string, value interface{}, reason EventType) {
+		c.Get(key) // the cache is unlocked
+		removed <- fmt.Sprint(reason, " ", key, " ", value)
+	}))
+	c.Set("test:del", "a")
+	c.Del("test:del")
+	c.SetWithTTL("test:expire", "b", 10*time.Millisecond)
+	for _, want := range []string{"del test:del a", "expire test:expire b", "evict test:evict c"} {
+		if want == "evict test:evict c" {
+			c.Set("test:evict", "c")
+			c.Set("test:big", strings.Repeat("d", 900))
+		}
+		select {
+		case r := <-removed:
+			if r != want {
+				t.Errorf("%v value error", r)
+				return
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%v not removed", want)
+			return
+		}
+	}
+}
+
+func TestLocalCount(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := NewFakeClock(time.Now())
+	c := NewLocalCache(ctx, LocalWithClock(clk))
+	c.Set("test:1", 1)
+	c.Set("test:2", 2)
+	c.SetWithTTL("test:3", 3, time.Second)
+	if n, err := c.Count(); n != 3 || err != nil {
+		t.Errorf("%v value error:%v", n, err)
+		return
+	}
+	clk.Advance(2 * time.Second)
+	if n, err := c.Count(); n != 2 || err != nil {
+		t.Errorf("%v value error:%v", n, err)
+	}
+}
+
+func TestLocalMemoryUsage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx)
+	c.Set("test:1", strings.Repeat("a", 1000))
+	if n, err := c.MemoryUsage(); n < 1000 || err != nil {
+		t.Errorf("%v value error:%v", n, err)
+		return
+	}
+	c.Del("test:1")
+	if n, err := c.MemoryUsage(); n != 0 || err != nil {
+		t.Errorf("%v value error:%v", n, err)
+	}
+}
+
+func TestLocalForEach(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := NewFakeClock(time.Now())
+	c := NewLocalCache(ctx, LocalWithClock(clk), LocalWithAbsoluteExpire())
+	start := clk.Now()
+	c.Set("test:1", 1)
+	c.SetWithTTL("test:2", 2, time.Minute)
+	c.SetWithTTL("test:3", 3, time.Second)
+	clk.Advance(2 * time.Second)
+	got := map[string]time.Time{}
+	err := c.ForEach(func(key string, value interface{}, expireAt time.Time) bool {
+		// the lock is not held, fn may write to the cache
+		c.Set("test:4", 4)
+		got[key] = expireAt
+		return true
+	})
+	if err != nil || len(got) != 2 {
+		t.Errorf("%v value error:%v", got, err)
+		return
+	}
+	if exp, ok := got["test:1"]; !ok || !exp.IsZero() {
+		t.Errorf("%v value error", exp)
+		return
+	}
+	if exp := got["test:2"]; exp.Before(start.Add(time.Minute)) || exp.After(start.Add(66*time.Second)) {
+		t.Errorf("%v value error", exp)
+	}
+}
+
+func TestLocalBinaryKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithKeyPrefix("\xfe:"))
+	key := string([]byte{0xff, 0x00, '*', 0x80})
+	c.Set(key, 1)
+	c.Set("\xff\x01", 2)
+	if data, _ := c.GetInt(key); data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.DelByPattern(escapeGlob(key[:3]) + "*")
+	if data, _ := c.GetInt(key); data != nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if data, _ := c.GetInt("\xff\x01"); data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+	}
+}
+
+func TestLocalSetTimePointer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	now := time.Now().In(time.FixedZone("X", 3600))
+	d := time.Minute
+	for _, c := range []*Cache{NewLocalCache(ctx), NewLocalCache(ctx, LocalWithEncoding())} {
+		c.Set("test:1", now)
+		c.Set("test:2", &now)
+		c.Set("test:3", &d)
+		for _, key := range []string{"test:1", "test:2"} {
+			if data, err := c.GetTime(key); data == nil || !data.Equal(now) {
+				t.Errorf("%v value error:%v", data, err)
+				return
+			}
+		}
+		if data, err := c.GetDuration("test:3"); data == nil || *data != d {
+			t.Errorf("%v value error:%v", data, err)
+			return
+		}
+	}
+	// the encoding of an instant does not depend on its location
+	if string(EncodeBytes(now)) != string(EncodeBytes(now.UTC())) {
+		t.Errorf("%s value error", EncodeBytes(now))
+	}
+}
+
+func TestLocalClear(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithKeyPrefix("svcA:"))
+	c.Set("test:123", 1)
+	c.Set("test:456", 2)
+	if err := c.Clear(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if n, _ := c.Count(); n != 0 {
+		t.Errorf("%v value error", n)
+	}
+}
+
+func TestLocalGetWhileAppend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithCopy())
+	c.Set("test:123", []byte("a"))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			c.Append("test:123", "a")
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		if _, err := c.GetBytes("test:123"); err != nil {
+			t.Errorf("%v error", err)
+			break
+		}
+	}
+	wg.Wait()
+}
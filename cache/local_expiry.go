@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"container/heap"
+	"sync/atomic"
+)
+
+// expiryHeap is a min-heap of the LocalCache entries that expire, ordered by
+// the expiration they had when pushed. Reads extending an entry do not move
+// it, the sweeper pushes it back down when it finds it extended.
+type expiryHeap []*cacheItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at < h[j].at }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	data := x.(*cacheItem)
+	data.index = len(*h)
+	*h = append(*h, data)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	data := old[n-1]
+	old[n-1] = nil
+	data.index = -1
+	*h = old[:n-1]
+	return data
+}
+
+// track adds data, stored at the prefixed key k, to the expiry heap if it
+// expires, waking the sweeper when it expires first. Must be called with c.m
+// held.
+func (c *LocalCache) track(k string, data *cacheItem) {
+	data.k = k
+	data.index = -1
+	data.at = atomic.LoadInt64(&data.expireAt)
+	if data.at == 0 {
+		return
+	}
+	heap.Push(&c.expiry, data)
+	if data.index == 0 {
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// untrack removes data from the expiry heap. Must be called with c.m held.
+func (c *LocalCache) untrack(data *cacheItem) {
+	if data.index >= 0 && data.index < len(c.expiry) && c.expiry[data.index] == data {
+		heap.Remove(&c.expiry, data.index)
+	}
+}
+
+// nextExpiry returns when the first entry of the expiry heap expires, 0 when
+// none expires. Must be called with c.m held.
+func (c *LocalCache) nextExpiry() int64 {
+	if len(c.expiry) == 0 {
+		return 0
+	}
+	return c.expiry[0].at
+}
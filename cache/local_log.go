@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// logFlushInterval is how often the append log is written to disk, the
+	// writes of at most that long are lost on a crash.
+	logFlushInterval = time.Second
+	// defaultCompactInterval is how often the append log is rewritten to the
+	// live entries.
+	defaultCompactInterval = time.Hour
+)
+
+// appendLog is the file a LocalCache records its writes to, a snapshot of
+// the entries followed by the entries written and deleted since, in a single
+// gob stream.
+type appendLog struct {
+	path string
+	f    *os.File
+	w    *bufio.Writer
+	enc  *gob.Encoder
+}
+
+// LocalWithCompactInterval sets how often the append log of a cache created
+// by NewLocalCacheWithLog is rewritten to its live entries, every hour by
+// default.
+func LocalWithCompactInterval(d time.Duration) LocalOption {
+	return func(c *LocalCache) {
+		c.compactInterval = d
+	}
+}
+
+// LocalWithLogErrorFunc reports the failures to write the append log, e.g.
+// a value of a type not registered with gob.Register, whose write is then
+// missing from the log.
+func LocalWithLogErrorFunc(fn func(err error)) LocalOption {
+	return func(c *LocalCache) {
+		c.logErrFn = fn
+	}
+}
+
+// NewLocalCacheWithLog returns a LocalCache recording every write and delete
+// to the append log at path, replayed when the cache is created again, so
+// its entries survive restarts. The log is buffered and written every
+// second, and compacted at creation and periodically. Changes to hashes,
+// lists, sets and sorted sets log the whole entry, reads extending a sliding
+// expiration are not logged. The log is closed when ctx is done or on Close.
+func NewLocalCacheWithLog(ctx context.Context, path string, opts ...LocalOption) (*Cache, error) {
+	c := &LocalCache{
+		compactInterval: defaultCompactInterval,
+	}
+	c.init(opts)
+	f, err := os.Open(path)
+	if err == nil {
+		err = c.load(bufio.NewReader(f), true)
+		f.Close()
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	c.m.Lock()
+	err = c.compact(path)
+	c.unlock()
+	if err != nil {
+		return nil, err
+	}
+	c.m.Lock()
+	c.sweeping = true
+	c.unlock()
+	c.bg.run(ctx, c.runExpireCheck, c.runLog)
+	return NewCache(c), nil
+}
+
+// compact rewrites the log at path to the live entries. Must be called with
+// c.m held.
+func (c *LocalCache) compact(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	log := &appendLog{path: path, f: f, w: bufio.NewWriter(f)}
+	log.enc = gob.NewEncoder(log.w)
+	err = writeSnapshot(log.enc, c.snapshot())
+	if err == nil {
+		err = log.w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if c.log != nil {
+		c.log.f.Close()
+	}
+	c.log = log
+	return nil
+}
+
+// logPut records data stored at the prefixed key k. Must be called with c.m
+// held.
+func (c *LocalCache) logPut(k string, data *cacheItem) {
+	if c.log == nil {
+		return
+	}
+	item := c.snapshotOf(k, data)
+	c.logError(c.log.enc.Encode(&item))
+}
+
+// logRemove records the delete of the prefixed key k. Must be called with
+// c.m held.
+func (c *LocalCache) logRemove(k string) {
+	if c.log == nil {
+		return
+	}
+	item := snapshotItem{Key: strings.TrimPrefix(k, c.prefix), Del: true}
+	c.logError(c.log.enc.Encode(&item))
+}
+
+func (c *LocalCache) logError(err error) {
+	if err != nil && c.logErrFn != nil {
+		go c.logErrFn(err)
+	}
+}
+
+// runLog writes the log to disk every second and compacts it every compact
+// interval until ctx is done.
+func (c *LocalCache) runLog(ctx context.Context) {
+	flush := time.NewTicker(logFlushInterval)
+	defer flush.Stop()
+	var compact <-chan time.Time
+	if c.compactInterval > 0 {
+		t := time.NewTicker(c.compactInterval)
+		defer t.Stop()
+		compact = t.C
+	}
+	for {
+		select {
+		case <-flush.C:
+			c.m.Lock()
+			c.logError(c.log.w.Flush())
+			c.unlock()
+		case <-compact:
+			c.m.Lock()
+			c.logError(c.compact(c.log.path))
+			c.unlock()
+		case <-ctx.Done():
+			c.m.Lock()
+			c.logError(c.log.w.Flush())
+			c.logError(c.log.f.Close())
+			c.log = nil
+			c.unlock()
+			return
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	redigo "github.com/gomodule/redigo/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultLockRetry = 50 * time.Millisecond
+
+	unlockStr string = `
+	if redis.call('get', KEYS[1]) == ARGV[1]
+	then
+		return redis.call('del', KEYS[1])
+	end
+	return 0
+	`
+
+	refreshLockStr string = `
+	if redis.call('get', KEYS[1]) == ARGV[1]
+	then
+		return redis.call('pexpire', KEYS[1], ARGV[2])
+	end
+	return 0
+	`
+)
+
+var (
+	ErrNotObtained    = errors.New("lock not obtained error")
+	ErrLockNotHeld    = errors.New("lock not held error")
+	ErrInvalidLockTTL = errors.New("invalid lock ttl error")
+)
+
+var (
+	luaUnlock        = redis.NewScript(unlockStr)
+	luaRefreshLock   = redis.NewScript(refreshLockStr)
+	luaV9Unlock      = redisv9.NewScript(unlockStr)
+	luaV9RefreshLock = redisv9.NewScript(refreshLockStr)
+	redigoUnlock     = redigo.NewScript(1, unlockStr)
+	redigoRefresh    = redigo.NewScript(1, refreshLockStr)
+)
+
+// lockClient runs the lock commands on one of the redis clients. release and
+// refresh report whether key still held token.
+type lockClient interface {
+	obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	release(ctx context.Context, key, token string) (bool, error)
+	refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+}
+
+type goredisLockClient struct {
+	client redis.UniversalClient
+}
+
+func (c goredisLockClient) obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(key, token, ttl).Result()
+}
+
+func (c goredisLockClient) release(ctx context.Context, key, token string) (bool, error) {
+	n, err := luaUnlock.Run(c.client, []string{key}, token).Int64()
+	return n == 1, err
+}
+
+func (c goredisLockClient) refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	n, err := luaRefreshLock.Run(c.client, []string{key}, token, ttl.Milliseconds()).Int64()
+	return n == 1, err
+}
+
+type goredisV9LockClient struct {
+	client redisv9.UniversalClient
+}
+
+func (c goredisV9LockClient) obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, token, ttl).Result()
+}
+
+func (c goredisV9LockClient) release(ctx context.Context, key, token string) (bool, error) {
+	n, err := luaV9Unlock.Run(ctx, c.client, []string{key}, token).Int64()
+	return n == 1, err
+}
+
+func (c goredisV9LockClient) refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	n, err := luaV9RefreshLock.Run(ctx, c.client, []string{key}, token, ttl.Milliseconds()).Int64()
+	return n == 1, err
+}
+
+type redigoLockClient struct {
+	getConn GetRedisConn
+}
+
+func (c redigoLockClient) obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	conn := c.getConn()
+	defer conn.Close()
+	_, err := redigo.String(conn.Do("SET", key, token, "NX", "PX", ttl.Milliseconds()))
+	if err == redigo.ErrNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c redigoLockClient) release(ctx context.Context, key, token string) (bool, error) {
+	conn := c.getConn()
+	defer conn.Close()
+	n, err := redigo.Int64(redigoUnlock.Do(conn, key, token))
+	return n == 1, err
+}
+
+func (c redigoLockClient) refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	conn := c.getConn()
+	defer conn.Close()
+	n, err := redigo.Int64(redigoRefresh.Do(conn, key, token, ttl.Milliseconds()))
+	return n == 1, err
+}
+
+// Locker obtains locks held across processes as redis keys set with SET NX
+// and a random token, so only the holder can release or extend its lock.
+// A lock expires after its ttl unless refreshed, a crashed holder does not
+// block the others for longer.
+type Locker struct {
+	client    lockClient
+	prefix    string
+	retry     time.Duration
+	autoRenew bool
+}
+
+type LockOption func(l *Locker)
+
+// LockWithKeyPrefix namespaces every lock key with prefix, e.g. "lock:".
+func LockWithKeyPrefix(prefix string) LockOption {
+	return func(l *Locker) {
+		l.prefix = prefix
+	}
+}
+
+// LockWithRetryInterval sets how often ObtainWait retries, 50ms by default.
+func LockWithRetryInterval(interval time.Duration) LockOption {
+	return func(l *Locker) {
+		l.retry = interval
+	}
+}
+
+// LockWithAutoRenew refreshes every obtained lock at a third of its ttl
+// until it is released, so a lock outlives a slow critical section. A failed
+// refresh is retried at the retry interval until the lock would have
+// expired.
+func LockWithAutoRenew() LockOption {
+	return func(l *Locker) {
+		l.autoRenew = true
+	}
+}
+
+func newLocker(client lockClient, opts []LockOption) *Locker {
+	l := &Locker{
+		client: client,
+		retry:  defaultLockRetry,
+	}
+	for _, fn := range opts {
+		fn(l)
+	}
+	return l
+}
+
+func NewGoredisLocker(client redis.UniversalClient, opts ...LockOption) *Locker {
+	return newLocker(goredisLockClient{client: client}, opts)
+}
+
+func NewGoredisV9Locker(client redisv9.UniversalClient, opts ...LockOption) *Locker {
+	return newLocker(goredisV9LockClient{client: client}, opts)
+}
+
+func NewRedigoLocker(getConn GetRedisConn, opts ...LockOption) *Locker {
+	return newLocker(redigoLockClient{getConn: getConn}, opts)
+}
+
+// Obtain takes the lock named key for ttl, returning ErrNotObtained when it
+// is held by someone else. ttl is at least 1ms, the precision of redis.
+func (l *Locker) Obtain(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	if ttl < time.Millisecond {
+		return nil, ErrInvalidLockTTL
+	}
+	token := newNodeID() + newNodeID()
+	start := time.Now()
+	ok, err := l.client.obtain(ctx, l.prefix+key, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotObtained
+	}
+	lock := &Lock{
+		l:     l,
+		key:   l.prefix + key,
+		token: token,
+		ttl:   ttl,
+		done:  make(chan struct{}),
+	}
+	if l.autoRenew {
+		go lock.renew(start)
+	}
+	return lock, nil
+}
+
+// ObtainWait retries Obtain until the lock is obtained or ctx is done.
+func (l *Locker) ObtainWait(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	for {
+		lock, err := l.Obtain(ctx, key, ttl)
+		if err != ErrNotObtained {
+			return lock, err
+		}
+		timer := time.NewTimer(l.retry)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Lock is a lock obtained by a Locker.
+type Lock struct {
+	l     *Locker
+	key   string
+	token string
+	ttl   time.Duration
+	once  sync.Once
+	done  chan struct{}
+}
+
+// Done is closed when the lock is released or, with LockWithAutoRenew, when
+// the lock is lost or could not be refreshed before its ttl passed.
+func (lock *Lock) Done() <-chan struct{} {
+	return lock.done
+}
+
+func (lock *Lock) stop() {
+	lock.once.Do(func() {
+		close(lock.done)
+	})
+}
+
+// renew refreshes the lock, obtained at start, until it is released. The
+// lock is given up once a refresh finds it lost, or when the refreshes kept
+// failing until its ttl passed.
+func (lock *Lock) renew(start time.Time) {
+	interval := lock.ttl / 3
+	deadline := start.Add(lock.ttl)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			now := time.Now()
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			err := lock.Refresh(ctx, lock.ttl)
+			cancel()
+			switch {
+			case err == nil:
+				deadline = now.Add(lock.ttl)
+				timer.Reset(interval)
+			case err == ErrLockNotHeld || !time.Now().Before(deadline):
+				lock.stop()
+				return
+			default:
+				wait := lock.l.retry
+				if left := time.Until(deadline); left < wait {
+					wait = left
+				}
+				timer.Reset(wait)
+			}
+		case <-lock.done:
+			return
+		}
+	}
+}
+
+// Refresh extends the lock to expire ttl from now, returning ErrLockNotHeld
+// when it already expired.
+func (lock *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	ok, err := lock.l.client.refresh(ctx, lock.key, lock.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Release unlocks the lock, returning ErrLockNotHeld when it already
+// expired.
+func (lock *Lock) Release(ctx context.Context) error {
+	lock.stop()
+	ok, err := lock.l.client.release(ctx, lock.key, lock.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// lockSuffix names the lock taken by GetOrSetLocked while loading a key.
+const lockSuffix = ":lock"
+
+// GetOrSetLocked is GetOrSet coalescing the loads of key across processes:
+// the loader runs under a lock of l held for at most lockTTL, the processes
+// missing the lock wait for the value to be stored, or for the lock to be
+// released, until ctx is done.
+func (c *Cache) GetOrSetLocked(ctx context.Context, key string, l *Locker, lockTTL time.Duration, loader LoaderFunc) (interface{}, error) {
+	value, err := c.Get(key)
+	if ok, err := c.found(value, err); ok || err != nil {
+		return value, err
+	}
+	return c.flight.Do(key, func() (interface{}, error) {
+		for {
+			lock, err := l.Obtain(ctx, key+lockSuffix, lockTTL)
+			if err == nil {
+				defer lock.Release(context.Background())
+				return c.loadLocked(key, loader)
+			}
+			if err != ErrNotObtained {
+				return nil, err
+			}
+			timer := time.NewTimer(l.retry)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+			value, err := c.cache.Get(key)
+			if ok, err := c.found(value, err); ok || err != nil {
+				return value, err
+			}
+		}
+	})
+}
+
+// loadLocked loads and stores key unless the holder of the previous lock
+// stored it meanwhile.
+func (c *Cache) loadLocked(key string, loader LoaderFunc) (interface{}, error) {
+	value, err := c.cache.Get(key)
+	if ok, err := c.found(value, err); ok || err != nil {
+		return value, err
+	}
+	value, err = c.stats.load(key, loader)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Set(key, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testLocker(t *testing.T, l *Locker) {
+	ctx := context.Background()
+	lock, err := l.Obtain(ctx, "test:lock", time.Second)
+	if err != nil {
+		t.Errorf("%v obtain error", err)
+		return
+	}
+	if _, err := l.Obtain(ctx, "test:lock", time.Second); err != ErrNotObtained {
+		t.Errorf("%v obtain held error", err)
+		return
+	}
+	if err := lock.Refresh(ctx, time.Second); err != nil {
+		t.Errorf("%v refresh error", err)
+		return
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Errorf("%v release error", err)
+		return
+	}
+	if err := lock.Release(ctx); err != ErrLockNotHeld {
+		t.Errorf("%v release released error", err)
+		return
+	}
+	lock, err = l.Obtain(ctx, "test:lock", time.Second)
+	if err != nil {
+		t.Errorf("%v obtain released error", err)
+		return
+	}
+	lock.Release(ctx)
+}
+
+func TestGoredisLocker(t *testing.T) {
+	testLocker(t, NewGoredisLocker(getGoRedisT(t)))
+}
+
+func TestGoredisV9Locker(t *testing.T) {
+	testLocker(t, NewGoredisV9Locker(getGoRedisV9T(t)))
+}
+
+func TestRedigoLocker(t *testing.T) {
+	testLocker(t, NewRedigoLocker(getRedigoT(t)))
+}
+
+func TestLockExpire(t *testing.T) {
+	ctx := context.Background()
+	l := NewGoredisLocker(getGoRedisT(t))
+	lock, err := l.Obtain(ctx, "test:lock", 100*time.Millisecond)
+	if err != nil {
+		t.Errorf("%v obtain error", err)
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := lock.Refresh(ctx, time.Second); err != ErrLockNotHeld {
+		t.Errorf("%v refresh expired error", err)
+		return
+	}
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	lock, err = l.ObtainWait(wctx, "test:lock", time.Second)
+	if err != nil {
+		t.Errorf("%v obtain wait error", err)
+		return
+	}
+	lock.Release(ctx)
+}
+
+func TestLockAutoRenew(t *testing.T) {
+	ctx := context.Background()
+	l := NewGoredisLocker(getGoRedisT(t), LockWithAutoRenew())
+	lock, err := l.Obtain(ctx, "test:lock", 150*time.Millisecond)
+	if err != nil {
+		t.Errorf("%v obtain error", err)
+		return
+	}
+	time.Sleep(400 * time.Millisecond)
+	if _, err := l.Obtain(ctx, "test:lock", time.Second); err != ErrNotObtained {
+		t.Errorf("%v obtain renewed error", err)
+		return
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Errorf("%v release error", err)
+		return
+	}
+	select {
+	case <-lock.Done():
+	default:
+		t.Errorf("done not closed error")
+	}
+}
+
+func TestGetOrSetLocked(t *testing.T) {
+	client := getGoRedisT(t)
+	l := NewGoredisLocker(client, LockWithRetryInterval(10*time.Millisecond))
+	NewGoredisCache(client).Del("test:123")
+	var calls int32
+	loader := func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		return 3, nil
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		// a cache per process, sharing the redis keys
+		c := NewGoredisCache(client, GoredisWithExpire(10))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := c.GetOrSetLocked(context.Background(), "test:123", l, time.Second, loader)
+			if err != nil || data == nil {
+				t.Errorf("%v value error:%v", data, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("%v loader calls", calls)
+		return
+	}
+}
+
+// flakyLockClient holds every lock and fails the refreshes while fail is
+// above 0, counting them down.
+type flakyLockClient struct {
+	fail int32
+}
+
+func (c *flakyLockClient) obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (c *flakyLockClient) release(ctx context.Context, key, token string) (bool, error) {
+	return true, nil
+}
+
+func (c *flakyLockClient) refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	if atomic.AddInt32(&c.fail, -1) >= 0 {
+		return false, errors.New("network error")
+	}
+	return true, nil
+}
+
+func TestObtainInvalidTTL(t *testing.T) {
+	l := newLocker(&flakyLockClient{}, nil)
+	if _, err := l.Obtain(context.Background(), "test:lock", time.Millisecond/2); err != ErrInvalidLockTTL {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestRenewRetry(t *testing.T) {
+	ctx := context.Background()
+	client := &flakyLockClient{fail: 3}
+	l := newLocker(client, []LockOption{LockWithAutoRenew(), LockWithRetryInterval(5 * time.Millisecond)})
+	lock, err := l.Obtain(ctx, "test:lock", 90*time.Millisecond)
+	if err != nil {
+		t.Errorf("%v obtain error", err)
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case <-lock.Done():
+		t.Errorf("done closed error")
+		return
+	default:
+	}
+	lock.Release(ctx)
+
+	atomic.StoreInt32(&client.fail, 1000)
+	lock, _ = l.Obtain(ctx, "test:lock", 90*time.Millisecond)
+	start := time.Now()
+	select {
+	case <-lock.Done():
+		if d := time.Since(start); d < 60*time.Millisecond {
+			t.Errorf("%v value error", d)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("done not closed error")
+	}
+}
@@ -0,0 +1,145 @@
+// Package memcache stores the entries of a cache.Cache in memcached.
+package memcache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	gomemcache "github.com/bradfitz/gomemcache/memcache"
+
+	"mcache/cache"
+)
+
+// memcacheMaxRelative is the largest expiration memcached treats as
+// relative seconds, larger values are unix timestamps.
+const memcacheMaxRelative = 30 * 24 * 3600
+
+type MemcacheCache struct {
+	cache.ByteGetters
+	expireSec int
+	absolute  bool
+	prefix    string
+	client    *gomemcache.Client
+	m         sync.Mutex
+	r         *rand.Rand
+}
+
+type MemcacheOption func(c *MemcacheCache)
+
+func MemcacheWithExpire(expireSecond int) MemcacheOption {
+	return func(c *MemcacheCache) {
+		c.expireSec = expireSecond
+	}
+}
+
+// MemcacheWithTTL sets the default expiration, rounded up to whole seconds.
+func MemcacheWithTTL(ttl time.Duration) MemcacheOption {
+	return func(c *MemcacheCache) {
+		c.expireSec = cache.TTLSeconds(ttl)
+	}
+}
+
+// MemcacheWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads.
+func MemcacheWithAbsoluteExpire() MemcacheOption {
+	return func(c *MemcacheCache) {
+		c.absolute = true
+	}
+}
+
+// MemcacheWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func MemcacheWithKeyPrefix(prefix string) MemcacheOption {
+	return func(c *MemcacheCache) {
+		c.prefix = prefix
+	}
+}
+
+func NewMemcacheCache(client *gomemcache.Client, opts ...MemcacheOption) *cache.Cache {
+	c := &MemcacheCache{
+		client: client,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = cache.NewByteGetters(c.Get)
+	for _, fn := range opts {
+		fn(c)
+	}
+	return cache.NewCache(c)
+}
+
+// memcacheExpiration converts expireSec to a memcached expiration, using an
+// absolute unix time when it is too long to be relative.
+func memcacheExpiration(expireSec int) int32 {
+	if expireSec > memcacheMaxRelative {
+		return int32(time.Now().Unix() + int64(expireSec))
+	}
+	return int32(expireSec)
+}
+
+func (c *MemcacheCache) Set(key string, value interface{}) error {
+	exp := c.expireSec
+	if exp != 0 {
+		c.m.Lock()
+		exp += c.r.Intn(int(exp/10 + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithExpire(key, value, exp)
+}
+
+// SetWithExpire stores value and keeps expireSec in the item flags, so Get
+// can slide the expiration like the redis backends do.
+func (c *MemcacheCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	return c.client.Set(&gomemcache.Item{
+		Key:        c.prefix + key,
+		Value:      cache.EncodeBytes(value),
+		Flags:      uint32(expireSec),
+		Expiration: memcacheExpiration(expireSec),
+	})
+}
+
+// SetWithTTL stores value with the given ttl, rounded up to whole seconds.
+func (c *MemcacheCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.SetWithExpire(key, value, cache.TTLSeconds(ttl))
+}
+
+func (c *MemcacheCache) Get(key string) (interface{}, error) {
+	if c.client == nil {
+		return nil, cache.ErrNoClient
+	}
+	item, err := c.client.Get(c.prefix + key)
+	if err == gomemcache.ErrCacheMiss {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if item.Flags != 0 && !c.absolute {
+		err = c.client.Touch(item.Key, memcacheExpiration(int(item.Flags)))
+		if err != nil && err != gomemcache.ErrCacheMiss {
+			return nil, err
+		}
+	}
+	return item.Value, nil
+}
+
+func (c *MemcacheCache) Del(key string) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	err := c.client.Delete(c.prefix + key)
+	if err == gomemcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
+
+// Close closes the idle connections of the memcache client.
+func (c *MemcacheCache) Close() error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	return c.client.Close()
+}
@@ -0,0 +1,102 @@
+package memcache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	gomemcache "github.com/bradfitz/gomemcache/memcache"
+
+	"mcache/cache"
+)
+
+var memcacheAddr string = "192.168.3.105:11211"
+
+func getMemcacheT(t *testing.T) *gomemcache.Client {
+	c := gomemcache.New(memcacheAddr)
+	if err := c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestMemcacheSetInt(t *testing.T) {
+	c := NewMemcacheCache(getMemcacheT(t), MemcacheWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestMemcacheSetFloat(t *testing.T) {
+	c := NewMemcacheCache(getMemcacheT(t), MemcacheWithExpire(10))
+	v := 3.5
+	c.Set("test:123", v)
+	data, _ := c.GetFloat("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestMemcacheSetBytes(t *testing.T) {
+	c := NewMemcacheCache(getMemcacheT(t), MemcacheWithExpire(10))
+	v := []byte("test")
+	c.Set("test:123", v)
+	data, _ := c.GetBytes("test:123")
+	if data == nil || !bytes.Equal(v, data) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestMemcacheSetBool(t *testing.T) {
+	c := NewMemcacheCache(getMemcacheT(t), MemcacheWithExpire(10))
+	v := true
+	c.Set("test:123", v)
+	data, _ := c.GetBool("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestMemcacheDel(t *testing.T) {
+	c := NewMemcacheCache(getMemcacheT(t), MemcacheWithExpire(10))
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestMemcacheExpire(t *testing.T) {
+	c := NewMemcacheCache(getMemcacheT(t), MemcacheWithExpire(2))
+	key := "test:123"
+	c.Set(key, true)
+	time.Sleep(4 * time.Second)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestMemcacheClose(t *testing.T) {
+	c := NewMemcacheCache(getMemcacheT(t), MemcacheWithExpire(10))
+	c.Set("test:123", 1)
+	if err := c.Close(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := NewMemcacheCache(nil).Close(); err != cache.ErrNoClient {
+		t.Errorf("%v error", err)
+		return
+	}
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// MemoFunc is a function memoized by Memoize, returning a value encodable as
+// JSON, or ErrNotFound.
+type MemoFunc func(ctx context.Context, args ...interface{}) (interface{}, error)
+
+// MemoKeyFunc returns the part of the cache key derived from the arguments
+// of a memoized call.
+type MemoKeyFunc func(args ...interface{}) (string, error)
+
+// Memo is a memoized function, its results are cached by the arguments of
+// the calls with LoadStruct: concurrent calls with the same arguments share
+// one call of the function, and its ErrNotFound results are cached too.
+type Memo struct {
+	c     *Cache
+	name  string
+	fn    MemoFunc
+	ttl   time.Duration
+	keyFn MemoKeyFunc
+	opts  []LoadOption
+}
+
+type MemoOption func(m *Memo)
+
+// MemoWithKeyFunc derives the keys from the arguments with fn instead of
+// their JSON encoding, e.g. to hash long arguments.
+func MemoWithKeyFunc(fn MemoKeyFunc) MemoOption {
+	return func(m *Memo) {
+		m.keyFn = fn
+	}
+}
+
+// MemoWithNegativeTTL caches the ErrNotFound results for ttl instead of the
+// ttl of the values, 0 not caching them.
+func MemoWithNegativeTTL(ttl time.Duration) MemoOption {
+	return func(m *Memo) {
+		m.opts = append(m.opts, LoadWithNegativeTTL(ttl))
+	}
+}
+
+// memoKey returns the JSON array of args.
+func memoKey(args ...interface{}) (string, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Memoize returns fn memoized in the cache for ttl, under the keys name
+// followed by ":" and the JSON array of the arguments, e.g. `user:[42]`.
+// name must be unique among the functions sharing the cache.
+func (c *Cache) Memoize(name string, fn MemoFunc, ttl time.Duration, opts ...MemoOption) *Memo {
+	m := &Memo{
+		c:     c,
+		name:  name,
+		fn:    fn,
+		ttl:   ttl,
+		keyFn: memoKey,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Key returns the cache key of the result of the call with args.
+func (m *Memo) Key(args ...interface{}) (string, error) {
+	key, err := m.keyFn(args...)
+	if err != nil {
+		return "", err
+	}
+	return m.name + ":" + key, nil
+}
+
+// Call decodes into dst, a pointer to a value of the type returned by the
+// function, the cached result of the call with args, calling the function
+// on a miss. It returns ErrNotFound, leaving dst untouched, when the
+// function did.
+func (m *Memo) Call(ctx context.Context, dst interface{}, args ...interface{}) error {
+	key, err := m.Key(args...)
+	if err != nil {
+		return err
+	}
+	return m.c.LoadStruct(ctx, key, dst, func(ctx context.Context, key string) (interface{}, error) {
+		return m.fn(ctx, args...)
+	}, m.ttl, m.opts...)
+}
+
+// Forget deletes the cached result of the call with args, the next call
+// runs the function again.
+func (m *Memo) Forget(args ...interface{}) error {
+	key, err := m.Key(args...)
+	if err != nil {
+		return err
+	}
+	return m.c.Del(key)
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx)
+	var calls int32
+	errDown := errors.New("down")
+	m := c.Memoize("user", func(ctx context.Context, args ...interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		switch args[0].(int) {
+		case 2:
+			return nil, ErrNotFound
+		case 3:
+			return nil, errDown
+		}
+		return &loadUser{ID: args[0].(int), Name: args[1].(string)}, nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var u loadUser
+			if err := m.Call(ctx, &u, 1, "bob"); err != nil || u.ID != 1 || u.Name != "bob" {
+				t.Errorf("%v %v value error", u, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("%v value error", calls)
+		return
+	}
+	if data, _ := c.GetString(`user:[1,"bob"]`); data != `{"id":1,"name":"bob"}` {
+		t.Errorf("%v value error", data)
+		return
+	}
+
+	var u loadUser
+	for i := 0; i < 2; i++ {
+		if err := m.Call(ctx, &u, 2, "alice"); err != ErrNotFound {
+			t.Errorf("%v error", err)
+			return
+		}
+		if err := m.Call(ctx, &u, 3, "carol"); err != errDown {
+			t.Errorf("%v error", err)
+			return
+		}
+	}
+	if calls != 4 {
+		t.Errorf("%v value error", calls)
+		return
+	}
+
+	if err := m.Forget(1, "bob"); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := m.Call(ctx, &u, 1, "bob"); err != nil || calls != 5 {
+		t.Errorf("%v %v value error", calls, err)
+		return
+	}
+	if err := m.Call(ctx, &u, func() {}); err == nil {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestMemoizeOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx)
+	calls := 0
+	m := c.Memoize("count", func(ctx context.Context, args ...interface{}) (interface{}, error) {
+		calls++
+		if len(args) == 0 {
+			return nil, ErrNotFound
+		}
+		return len(args), nil
+	}, time.Minute, MemoWithNegativeTTL(0), MemoWithKeyFunc(func(args ...interface{}) (string, error) {
+		return "n", nil
+	}))
+	if key, _ := m.Key(1, 2); key != "count:n" {
+		t.Errorf("%v value error", key)
+		return
+	}
+	var n int
+	for i := 0; i < 2; i++ {
+		if err := m.Call(ctx, &n); err != ErrNotFound {
+			t.Errorf("%v error", err)
+			return
+		}
+	}
+	if calls != 2 {
+		t.Errorf("%v value error", calls)
+		return
+	}
+	if err := m.Call(ctx, &n, "a", "b"); err != nil || n != 2 {
+		t.Errorf("%v %v value error", n, err)
+	}
+}
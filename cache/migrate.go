@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// migrateProgressEvery is how many copied entries separate two progress
+// reports.
+const migrateProgressEvery = 100
+
+type migrator struct {
+	rate     int
+	progress func(copied int)
+}
+
+type MigrateOption func(m *migrator)
+
+// MigrateWithRate copies at most perSecond entries per second, so the
+// migration does not starve the traffic of either cache. 0 is unlimited.
+func MigrateWithRate(perSecond int) MigrateOption {
+	return func(m *migrator) {
+		m.rate = perSecond
+	}
+}
+
+// MigrateWithProgress calls fn with the number of entries copied so far
+// every 100 entries and once the migration ends.
+func MigrateWithProgress(fn func(copied int)) MigrateOption {
+	return func(m *migrator) {
+		m.progress = fn
+	}
+}
+
+// Migrate copies every entry of src to dst with its remaining ttl, e.g. to
+// move from a RedigoCache to a GoredisCache or from a LocalCache to redis.
+// src must implement IRangeTTL, a *Cache returns ErrNotSupported when its
+// backend does not. Entries are streamed, not loaded at once. Values read
+// from redis are copied as the []byte they are stored as, and the hash,
+// list, set and sorted set entries of a LocalCache are skipped. Migrate
+// stops at the first error or when ctx is done and returns the number of
+// entries copied.
+func Migrate(ctx context.Context, src, dst ICache, opts ...MigrateOption) (int, error) {
+	m := &migrator{}
+	for _, fn := range opts {
+		fn(m)
+	}
+	r, ok := src.(IRangeTTL)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	var interval time.Duration
+	if m.rate > 0 {
+		interval = time.Second / time.Duration(m.rate)
+	}
+	next := time.Now()
+	copied := 0
+	var err error
+	rerr := r.RangeTTL(func(key string, value interface{}, ttl time.Duration) bool {
+		switch value.(type) {
+		case localHash, localList, localSet, localZSet:
+			return true
+		}
+		if interval > 0 {
+			if wait := time.Until(next); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+				}
+			}
+			next = next.Add(interval)
+			if now := time.Now(); next.Before(now) {
+				// do not burst to catch up after a slow write
+				next = now
+			}
+		}
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+		if err = dst.SetWithTTL(key, value, ttl); err != nil {
+			return false
+		}
+		copied++
+		if m.progress != nil && copied%migrateProgressEvery == 0 {
+			m.progress(copied)
+		}
+		return true
+	})
+	if err == nil {
+		err = rerr
+	}
+	if m.progress != nil {
+		m.progress(copied)
+	}
+	return copied, err
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := NewLocalCache(ctx, LocalWithExpire(10))
+	for i := 0; i < 150; i++ {
+		src.Set("test:"+strconv.Itoa(i), i)
+	}
+	src.SetWithTTL("test:forever", "a", 0)
+	src.HSet("test:hash", "f", 1)
+	dst := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	var progress []int
+	n, err := Migrate(ctx, src, dst, MigrateWithProgress(func(copied int) {
+		progress = append(progress, copied)
+	}))
+	if err != nil || n != 151 {
+		t.Errorf("%v %v error", n, err)
+		return
+	}
+	if len(progress) != 2 || progress[0] != 100 || progress[1] != 151 {
+		t.Errorf("%v progress error", progress)
+		return
+	}
+	data, _ := dst.GetInt("test:149")
+	if data == nil || *data != 149 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	s, _ := dst.GetString("test:forever")
+	if s != "a" {
+		t.Errorf("%v value error", s)
+		return
+	}
+}
+
+func TestMigrateRate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := NewSyncMapCache(ctx, SyncMapWithExpire(10))
+	for i := 0; i < 5; i++ {
+		src.Set("test:"+strconv.Itoa(i), i)
+	}
+	dst := NewLocalCache(ctx, LocalWithExpire(10))
+	start := time.Now()
+	n, err := Migrate(ctx, src, dst, MigrateWithRate(100))
+	if err != nil || n != 5 {
+		t.Errorf("%v %v error", n, err)
+		return
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("%v rate error", d)
+		return
+	}
+}
+
+func TestMigrateNotSupported(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src, err := NewFileCache(ctx, t.TempDir())
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if _, err := Migrate(ctx, src, NewLocalCache(ctx), MigrateWithRate(100)); err != ErrNotSupported {
+		t.Errorf("%v error", err)
+		return
+	}
+}
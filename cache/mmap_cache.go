@@ -0,0 +1,342 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// mmapMagic starts the header of an mmap cache file.
+	mmapMagic = "MCACHE01"
+	// mmapHeaderLen is the size of the file header, magic, slot count and
+	// slot size, padded so the slots stay 8 byte aligned.
+	mmapHeaderLen = 64
+	// mmapSlotHeaderLen is the size of a slot header, expireAt, ttl, key
+	// hash, used flag, key length and value length.
+	mmapSlotHeaderLen = 40
+	// mmapProbe is how many slots from the hashed one a key may be stored in.
+	mmapProbe = 8
+)
+
+var errMmapLayout = errors.New("mmap cache file layout error")
+
+// MmapCache stores encoded values in a fixed size hash table in a memory
+// mapped file, so processes on the same host share one cache without a
+// server. The table has slots slots of slotSize bytes, a key is stored in one
+// of the mmapProbe slots after its hash and overwrites the entry expiring
+// first when they are all used. Writers take an exclusive flock on the file
+// and readers a shared one. The mapping lives until Close.
+type MmapCache struct {
+	ByteGetters
+	expire   time.Duration
+	absolute bool
+	prefix   string
+	file     *os.File
+	data     []byte
+	slots    int
+	slotSize int
+	lock     sync.RWMutex
+	m        sync.Mutex
+	r        *rand.Rand
+}
+
+type MmapOption func(c *MmapCache)
+
+func MmapWithExpire(expireSecond int) MmapOption {
+	return func(c *MmapCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func MmapWithTTL(ttl time.Duration) MmapOption {
+	return func(c *MmapCache) {
+		c.expire = ttl
+	}
+}
+
+// MmapWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads. Reads then never write to the
+// mapping.
+func MmapWithAbsoluteExpire() MmapOption {
+	return func(c *MmapCache) {
+		c.absolute = true
+	}
+}
+
+// MmapWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func MmapWithKeyPrefix(prefix string) MmapOption {
+	return func(c *MmapCache) {
+		c.prefix = prefix
+	}
+}
+
+// NewMmapCache maps the cache file at path, creating it with slots slots of
+// slotSize bytes when it is missing or empty. An existing file must have been
+// created with the same layout. A slot holds the key, the encoded value and
+// a 40 byte header.
+func NewMmapCache(path string, slots, slotSize int, opts ...MmapOption) (*Cache, error) {
+	slotSize = (slotSize + 7) &^ 7
+	if slots <= 0 || slotSize <= mmapSlotHeaderLen {
+		return nil, errMmapLayout
+	}
+	c := &MmapCache{
+		slots:    slots,
+		slotSize: slotSize,
+		r:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = ByteGetters{get: c.Get}
+	for _, fn := range opts {
+		fn(c)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.init(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return NewCache(c), nil
+}
+
+// init writes the header of a new file or checks the one of an existing
+// file, then maps it.
+func (c *MmapCache) init(f *os.File) error {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	size := int64(mmapHeaderLen + c.slots*c.slotSize)
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header := make([]byte, mmapHeaderLen)
+	if info.Size() == 0 {
+		copy(header, mmapMagic)
+		binary.LittleEndian.PutUint64(header[8:], uint64(c.slots))
+		binary.LittleEndian.PutUint64(header[16:], uint64(c.slotSize))
+		if err := f.Truncate(size); err != nil {
+			return err
+		}
+		if _, err := f.WriteAt(header, 0); err != nil {
+			return err
+		}
+	} else {
+		if _, err := f.ReadAt(header, 0); err != nil {
+			return err
+		}
+		if string(header[:8]) != mmapMagic || info.Size() != size ||
+			binary.LittleEndian.Uint64(header[8:]) != uint64(c.slots) ||
+			binary.LittleEndian.Uint64(header[16:]) != uint64(c.slotSize) {
+			return errMmapLayout
+		}
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	c.file = f
+	c.data = data
+	return nil
+}
+
+// rlock takes the in process read lock and a shared flock, wlock the write
+// lock and an exclusive flock. The flock only excludes other processes.
+func (c *MmapCache) rlock() {
+	c.lock.RLock()
+	syscall.Flock(int(c.file.Fd()), syscall.LOCK_SH)
+}
+
+func (c *MmapCache) runlock() {
+	syscall.Flock(int(c.file.Fd()), syscall.LOCK_UN)
+	c.lock.RUnlock()
+}
+
+func (c *MmapCache) wlock() {
+	c.lock.Lock()
+	syscall.Flock(int(c.file.Fd()), syscall.LOCK_EX)
+}
+
+func (c *MmapCache) wunlock() {
+	syscall.Flock(int(c.file.Fd()), syscall.LOCK_UN)
+	c.lock.Unlock()
+}
+
+// slot returns the i-th slot of the table.
+func (c *MmapCache) slot(i int) []byte {
+	off := mmapHeaderLen + i*c.slotSize
+	return c.data[off : off+c.slotSize]
+}
+
+// expireAt points at the expiration of slot s, updated atomically by
+// readers sliding the expiration under the shared lock.
+func mmapExpireAt(s []byte) *int64 {
+	return (*int64)(unsafe.Pointer(&s[0]))
+}
+
+func mmapHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// lookup returns the slot holding the unexpired entry of key, nil if there
+// is none. Must be called with a lock held.
+func (c *MmapCache) lookup(key string, hash uint64, now int64) []byte {
+	for i := 0; i < mmapProbe; i++ {
+		s := c.slot(int((hash + uint64(i)) % uint64(c.slots)))
+		if binary.LittleEndian.Uint64(s[24:]) == 0 || binary.LittleEndian.Uint64(s[16:]) != hash {
+			continue
+		}
+		keyLen := int(binary.LittleEndian.Uint32(s[32:]))
+		if !bytes.Equal(s[mmapSlotHeaderLen:mmapSlotHeaderLen+keyLen], []byte(key)) {
+			continue
+		}
+		if exp := atomic.LoadInt64(mmapExpireAt(s)); exp != 0 && now > exp {
+			// a newer entry of key may be in a later slot
+			continue
+		}
+		return s
+	}
+	return nil
+}
+
+func (c *MmapCache) Set(key string, value interface{}) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c *MmapCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+// SetWithTTL stores value in the slot already holding key, else in a free or
+// expired slot, else over the entry expiring first. ErrOverflow is returned
+// when the key and value do not fit in a slot.
+func (c *MmapCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	key = c.prefix + key
+	data := EncodeBytes(value)
+	if mmapSlotHeaderLen+len(key)+len(data) > c.slotSize {
+		return ErrOverflow
+	}
+	hash := mmapHash(key)
+	now := time.Now().UnixNano()
+	c.wlock()
+	defer c.wunlock()
+	if c.data == nil {
+		return ErrNoClient
+	}
+	var target []byte
+	var targetExp int64
+	for i := 0; i < mmapProbe; i++ {
+		s := c.slot(int((hash + uint64(i)) % uint64(c.slots)))
+		exp := atomic.LoadInt64(mmapExpireAt(s))
+		if binary.LittleEndian.Uint64(s[24:]) == 0 || (exp != 0 && now > exp) {
+			if target == nil || targetExp != -1 {
+				target, targetExp = s, -1
+			}
+			continue
+		}
+		keyLen := int(binary.LittleEndian.Uint32(s[32:]))
+		if binary.LittleEndian.Uint64(s[16:]) == hash &&
+			bytes.Equal(s[mmapSlotHeaderLen:mmapSlotHeaderLen+keyLen], []byte(key)) {
+			target = s
+			break
+		}
+		if targetExp == -1 {
+			continue
+		}
+		// entries without expiration are overwritten last
+		if exp == 0 {
+			exp = 1<<63 - 1
+		}
+		if target == nil || exp < targetExp {
+			target, targetExp = s, exp
+		}
+	}
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = now + int64(ttl)
+	}
+	atomic.StoreInt64(mmapExpireAt(target), expireAt)
+	binary.LittleEndian.PutUint64(target[8:], uint64(ttl))
+	binary.LittleEndian.PutUint64(target[16:], hash)
+	binary.LittleEndian.PutUint64(target[24:], 1)
+	binary.LittleEndian.PutUint32(target[32:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(target[36:], uint32(len(data)))
+	copy(target[mmapSlotHeaderLen:], key)
+	copy(target[mmapSlotHeaderLen+len(key):], data)
+	return nil
+}
+
+// Get returns a copy of the value of key, extending its expiration unless
+// the cache uses absolute expiration.
+func (c *MmapCache) Get(key string) (interface{}, error) {
+	key = c.prefix + key
+	now := time.Now()
+	c.rlock()
+	defer c.runlock()
+	if c.data == nil {
+		return nil, ErrNoClient
+	}
+	s := c.lookup(key, mmapHash(key), now.UnixNano())
+	if s == nil {
+		return nil, nil
+	}
+	ttl := time.Duration(binary.LittleEndian.Uint64(s[8:]))
+	if ttl > 0 && !c.absolute {
+		atomic.StoreInt64(mmapExpireAt(s), now.Add(ttl).UnixNano())
+	}
+	keyLen := int(binary.LittleEndian.Uint32(s[32:]))
+	valueLen := int(binary.LittleEndian.Uint32(s[36:]))
+	start := mmapSlotHeaderLen + keyLen
+	return append([]byte{}, s[start:start+valueLen]...), nil
+}
+
+func (c *MmapCache) Del(key string) error {
+	key = c.prefix + key
+	c.wlock()
+	defer c.wunlock()
+	if c.data == nil {
+		return ErrNoClient
+	}
+	if s := c.lookup(key, mmapHash(key), time.Now().UnixNano()); s != nil {
+		binary.LittleEndian.PutUint64(s[24:], 0)
+	}
+	return nil
+}
+
+// Close unmaps the cache file and closes it, the file and its entries stay
+// for the other processes. The cache returns ErrNoClient once closed.
+func (c *MmapCache) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.data == nil {
+		return ErrNoClient
+	}
+	err := syscall.Munmap(c.data)
+	c.data = nil
+	if cerr := c.file.Close(); err == nil {
+		err = cerr
+	}
+	c.file = nil
+	return err
+}
@@ -0,0 +1,160 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMmapSetInt(t *testing.T) {
+	c, err := NewMmapCache(filepath.Join(t.TempDir(), "cache.mmap"), 1024, 128, MmapWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestMmapSetBytes(t *testing.T) {
+	c, err := NewMmapCache(filepath.Join(t.TempDir(), "cache.mmap"), 1024, 128, MmapWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := []byte("test")
+	c.Set("test:123", v)
+	c.Set("test:123", []byte("tes"))
+	data, _ := c.GetBytes("test:123")
+	if !bytes.Equal([]byte("tes"), data) {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if err := c.Set("test:123", bytes.Repeat(v, 100)); err != ErrOverflow {
+		t.Errorf("%v overflow error", err)
+		return
+	}
+}
+
+func TestMmapDel(t *testing.T) {
+	c, err := NewMmapCache(filepath.Join(t.TempDir(), "cache.mmap"), 1024, 128, MmapWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestMmapExpire(t *testing.T) {
+	c, err := NewMmapCache(filepath.Join(t.TempDir(), "cache.mmap"), 1024, 128, MmapWithTTL(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test:123"
+	c.Set(key, true)
+	time.Sleep(300 * time.Millisecond)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestMmapExtend(t *testing.T) {
+	c, err := NewMmapCache(filepath.Join(t.TempDir(), "cache.mmap"), 1024, 128, MmapWithTTL(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test:123"
+	c.Set(key, true)
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		data, _ := c.GetBool(key)
+		if data == nil || !*data {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestMmapShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.mmap")
+	c1, err := NewMmapCache(path, 1024, 128, MmapWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := NewMmapCache(path, 1024, 128, MmapWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.Set("test:123", "test")
+	data, _ := c2.GetString("test:123")
+	if data != "test" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if _, err := NewMmapCache(path, 512, 128); err == nil {
+		t.Errorf("layout error not reported")
+		return
+	}
+}
+
+func TestMmapFull(t *testing.T) {
+	c, err := NewMmapCache(filepath.Join(t.TempDir(), "cache.mmap"), 16, 128, MmapWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprintf("test:%d", i), i)
+	}
+	data, _ := c.GetInt("test:99")
+	if data == nil || *data != 99 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestMmapClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.mmap")
+	c, err := NewMmapCache(path, 1024, 128, MmapWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("test:123", "test")
+	if err := c.Close(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if _, err := c.GetString("test:123"); err != ErrNoClient {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := c.Close(); err != ErrNoClient {
+		t.Errorf("%v error", err)
+		return
+	}
+	c, err = NewMmapCache(path, 1024, 128, MmapWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	data, _ := c.GetString("test:123")
+	if data != "test" {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
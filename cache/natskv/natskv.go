@@ -0,0 +1,147 @@
+// Package natskv stores the entries of a cache.Cache in a NATS JetStream key
+// value bucket.
+package natskv
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"mcache/cache"
+)
+
+// NatsKVCache stores encoded values in a NATS JetStream key value bucket. The
+// bucket ttl set on creation bounds every entry, shorter per key expirations
+// are kept in an entry header and checked on read. Keys are limited to the
+// NATS key alphabet, letters, digits and "-/_=.".
+type NatsKVCache struct {
+	cache.ByteGetters
+	expire   time.Duration
+	absolute bool
+	prefix   string
+	client   nats.KeyValue
+	conn     *nats.Conn
+	m        sync.Mutex
+	r        *rand.Rand
+}
+
+type NatsKVOption func(c *NatsKVCache)
+
+func NatsKVWithExpire(expireSecond int) NatsKVOption {
+	return func(c *NatsKVCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func NatsKVWithTTL(ttl time.Duration) NatsKVOption {
+	return func(c *NatsKVCache) {
+		c.expire = ttl
+	}
+}
+
+// NatsKVWithAbsoluteExpire disables sliding expiration, entries expire at
+// a fixed time after Set regardless of reads.
+func NatsKVWithAbsoluteExpire() NatsKVOption {
+	return func(c *NatsKVCache) {
+		c.absolute = true
+	}
+}
+
+// NatsKVWithKeyPrefix namespaces every key with prefix, e.g. "svcA.".
+func NatsKVWithKeyPrefix(prefix string) NatsKVOption {
+	return func(c *NatsKVCache) {
+		c.prefix = prefix
+	}
+}
+
+// NatsKVWithConn hands nc, the connection the bucket was opened on, to the
+// cache, which drains it on Close.
+func NatsKVWithConn(nc *nats.Conn) NatsKVOption {
+	return func(c *NatsKVCache) {
+		c.conn = nc
+	}
+}
+
+func NewNatsKVCache(client nats.KeyValue, opts ...NatsKVOption) *cache.Cache {
+	c := &NatsKVCache{
+		client: client,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = cache.NewByteGetters(c.Get)
+	for _, fn := range opts {
+		fn(c)
+	}
+	return cache.NewCache(c)
+}
+
+func (c *NatsKVCache) Set(key string, value interface{}) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c *NatsKVCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *NatsKVCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	_, err := c.client.Put(c.prefix+key, cache.EncodeEntry(cache.EncodeBytes(value), ttl))
+	return err
+}
+
+// Get returns the value of key, rewriting the entry to extend its
+// expiration unless the cache uses absolute expiration.
+func (c *NatsKVCache) Get(key string) (interface{}, error) {
+	if c.client == nil {
+		return nil, cache.ErrNoClient
+	}
+	kve, err := c.client.Get(c.prefix + key)
+	if err == nats.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	value, ttl, ok := cache.DecodeEntry(kve.Value())
+	if !ok {
+		c.client.Purge(c.prefix + key)
+		return nil, nil
+	}
+	if ttl > 0 && !c.absolute {
+		if _, err := c.client.Put(c.prefix+key, cache.EncodeEntry(value, ttl)); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// Del purges key, dropping its history instead of only adding a delete
+// marker.
+func (c *NatsKVCache) Del(key string) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	err := c.client.Purge(c.prefix + key)
+	if err == nats.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// Close drains the connection given with NatsKVWithConn, the bucket itself
+// holds nothing to release.
+func (c *NatsKVCache) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Drain()
+}
@@ -0,0 +1,132 @@
+package natskv
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var natsURL string = "nats://192.168.3.105:4222"
+
+func getNatsKVT(t *testing.T) nats.KeyValue {
+	nc, err := nats.Connect(natsURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "mcache", TTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kv
+}
+
+func TestNatsKVSetInt(t *testing.T) {
+	c := NewNatsKVCache(getNatsKVT(t), NatsKVWithExpire(10))
+	v := 3
+	c.Set("test.123", v)
+	data, _ := c.GetInt("test.123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestNatsKVSetBytes(t *testing.T) {
+	c := NewNatsKVCache(getNatsKVT(t), NatsKVWithExpire(10))
+	v := []byte("test")
+	c.Set("test.123", v)
+	data, _ := c.GetBytes("test.123")
+	if data == nil || !bytes.Equal(v, data) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestNatsKVSetBool(t *testing.T) {
+	c := NewNatsKVCache(getNatsKVT(t), NatsKVWithExpire(10))
+	v := true
+	c.Set("test.123", v)
+	data, _ := c.GetBool("test.123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestNatsKVDel(t *testing.T) {
+	c := NewNatsKVCache(getNatsKVT(t), NatsKVWithExpire(10))
+	key := "test.123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestNatsKVExpire(t *testing.T) {
+	c := NewNatsKVCache(getNatsKVT(t), NatsKVWithTTL(200*time.Millisecond))
+	key := "test.123"
+	c.Set(key, true)
+	data, _ := c.GetBool(key)
+	if data == nil || !*data {
+		t.Errorf("%v value error", data)
+		return
+	}
+	time.Sleep(300 * time.Millisecond)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestNatsKVExtend(t *testing.T) {
+	c := NewNatsKVCache(getNatsKVT(t), NatsKVWithTTL(200*time.Millisecond))
+	key := "test.123"
+	c.Set(key, true)
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		data, _ := c.GetBool(key)
+		if data == nil || !*data {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestNatsKVClose(t *testing.T) {
+	nc, err := nats.Connect(natsURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "mcache", TTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewNatsKVCache(kv, NatsKVWithConn(nc), NatsKVWithExpire(10))
+	c.Set("test.123", 1)
+	if err := c.Close(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	for i := 0; i < 100 && !nc.IsClosed(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !nc.IsClosed() {
+		t.Errorf("connection not closed")
+		return
+	}
+}
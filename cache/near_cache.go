@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	nearGetCacheStr string = `
+	local key,slide = KEYS[1],ARGV[1]
+	local value = redis.call('hmget', key, 'data', 'exp', 'ver', 'pexp')
+	if value[1] == false
+	then
+		return false
+	end
+	if (slide ~= '0') and (value[4] ~= false) and (tonumber(value[4]) ~= 0)
+	then
+		redis.call('pexpire', key, value[4])
+	elseif (slide ~= '0') and (tonumber(value[2]) ~= 0)
+	then
+		redis.call('expire', key, value[2])
+	end
+	return {value[1], value[3] or '0'}
+	`
+
+	nearVersionCacheStr string = `
+	local key,slide = KEYS[1],ARGV[1]
+	local value = redis.call('hmget', key, 'data', 'exp', 'ver', 'pexp')
+	if value[1] == false
+	then
+		return false
+	end
+	if (slide ~= '0') and (value[4] ~= false) and (tonumber(value[4]) ~= 0)
+	then
+		redis.call('pexpire', key, value[4])
+	elseif (slide ~= '0') and (tonumber(value[2]) ~= 0)
+	then
+		redis.call('expire', key, value[2])
+	end
+	return value[3] or '0'
+	`
+)
+
+var (
+	luaNearGetCache     = redis.NewScript(nearGetCacheStr)
+	luaNearVersionCache = redis.NewScript(nearVersionCacheStr)
+)
+
+// nearItem is the local copy of a redis entry. checked is first so it stays
+// 64 bit aligned for the atomic operations on 32 bit platforms.
+type nearItem struct {
+	checked int64 // unix nanoseconds of the last version check
+	version string
+	data    string
+}
+
+// NearCache serves redis entries from local copies validated against the
+// version counter every redis write bumps. A version check reads a few bytes
+// instead of the value, and copies checked less than the check interval ago
+// are served without asking redis at all, so a copy is never staler than the
+// interval. Entries are the same as GoredisCache's, the two can share keys.
+type NearCache struct {
+	ByteGetters
+	redis *GoredisCache
+	local ICache
+	check time.Duration
+}
+
+// NewGoredisNearCache returns a cache on client keeping copies in local, an
+// in-process cache storing values as they are, e.g. LocalCache or
+// SyncMapCache. The expiration of local bounds the memory used by the
+// copies. check is the longest a copy is served without a version check, 0
+// checks on every read. opts configure the redis entries as for
+// NewGoredisCache.
+func NewGoredisNearCache(client redis.UniversalClient, local ICache, check time.Duration, opts ...GoredisOption) *Cache {
+	r := &GoredisCache{
+		client: client,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, fn := range opts {
+		fn(r)
+	}
+	c := &NearCache{
+		redis: r,
+		local: local,
+		check: check,
+	}
+	c.ByteGetters = ByteGetters{get: c.Get}
+	return NewCache(c)
+}
+
+// Set stores value in redis and drops the local copy, the next read fetches
+// the new version.
+func (c *NearCache) Set(key string, value interface{}) error {
+	if err := c.redis.Set(key, value); err != nil {
+		return err
+	}
+	return c.local.Del(key)
+}
+
+func (c *NearCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	if err := c.redis.SetWithExpire(key, value, expireSec); err != nil {
+		return err
+	}
+	return c.local.Del(key)
+}
+
+func (c *NearCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.SetWithExpire(key, value, TTLSeconds(ttl))
+}
+
+// Get serves the local copy of key when it was checked within the check
+// interval or its version still matches redis, else fetches the entry from
+// redis and keeps a copy. Both paths extend the redis expiration unless the
+// cache uses absolute expiration.
+func (c *NearCache) Get(key string) (interface{}, error) {
+	if c.redis.client == nil {
+		return nil, ErrNoRedis
+	}
+	rkey := c.redis.prefix + key
+	slide := slideArg(c.redis.absolute)
+	now := time.Now().UnixNano()
+	v, _ := c.local.Get(key)
+	if item, ok := v.(*nearItem); ok {
+		if now-atomic.LoadInt64(&item.checked) < int64(c.check) {
+			return []byte(item.data), nil
+		}
+		ver, err := luaNearVersionCache.Run(c.redis.client, []string{rkey}, slide).Result()
+		if err == redis.Nil || (ver == nil && err == nil) {
+			c.local.Del(key)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ver == item.version {
+			atomic.StoreInt64(&item.checked, now)
+			return []byte(item.data), nil
+		}
+	}
+	value, err := luaNearGetCache.Run(c.redis.client, []string{rkey}, slide).Result()
+	if err == redis.Nil || (value == nil && err == nil) {
+		c.local.Del(key)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pair, ok := value.([]interface{})
+	if !ok || len(pair) != 2 {
+		return nil, ErrDataType
+	}
+	data, ok := pair[0].(string)
+	if !ok {
+		return nil, ErrDataType
+	}
+	ver, ok := pair[1].(string)
+	if !ok {
+		return nil, ErrDataType
+	}
+	c.local.Set(key, &nearItem{
+		checked: now,
+		version: ver,
+		data:    data,
+	})
+	return []byte(data), nil
+}
+
+// Ping pings redis and the local cache.
+func (c *NearCache) Ping(ctx context.Context) error {
+	if err := c.redis.Ping(ctx); err != nil {
+		return err
+	}
+	return ping(ctx, c.local)
+}
+
+// Close closes the redis client and the local cache.
+func (c *NearCache) Close() error {
+	err := c.redis.Close()
+	if err2 := closeCache(c.local); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Del removes key from redis and the local copy. Copies held by other
+// processes are dropped on their next version check.
+func (c *NearCache) Del(key string) error {
+	if err := c.redis.Del(key); err != nil {
+		return err
+	}
+	return c.local.Del(key)
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNearSetGet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := getGoRedisT(t)
+	c1 := NewGoredisNearCache(client, NewLocalCache(ctx, LocalWithExpire(10)), 0, GoredisWithExpire(10))
+	c2 := NewGoredisNearCache(client, NewLocalCache(ctx, LocalWithExpire(10)), 0, GoredisWithExpire(10))
+	c1.Set("test:123", 1)
+	data, _ := c2.GetInt("test:123")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c1.Set("test:123", 2)
+	data, _ = c2.GetInt("test:123")
+	if data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c1.Del("test:123")
+	data, _ = c2.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+}
+
+func TestNearGoredisWrite(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := getGoRedisT(t)
+	c := NewGoredisNearCache(client, NewLocalCache(ctx, LocalWithExpire(10)), 0, GoredisWithExpire(10))
+	r := NewGoredisCache(client, GoredisWithExpire(10))
+	r.Set("test:123", "a")
+	data, _ := c.GetString("test:123")
+	if data != "a" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	r.Append("test:123", "b")
+	data, _ = c.GetString("test:123")
+	if data != "ab" {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestNearCheckInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := getGoRedisT(t)
+	c1 := NewGoredisNearCache(client, NewLocalCache(ctx, LocalWithExpire(10)), 0, GoredisWithExpire(10))
+	c2 := NewGoredisNearCache(client, NewLocalCache(ctx, LocalWithExpire(10)), time.Second, GoredisWithExpire(10))
+	c1.Set("test:123", "a")
+	data, _ := c2.GetString("test:123")
+	if data != "a" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c1.Set("test:123", "b")
+	data, _ = c2.GetString("test:123")
+	if data != "a" {
+		t.Errorf("%v stale value error", data)
+		return
+	}
+	time.Sleep(1100 * time.Millisecond)
+	data, _ = c2.GetString("test:123")
+	if data != "b" {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
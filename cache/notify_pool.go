@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// NotifyPolicy is what an expire callback pool does with a callback when its
+// queue is full.
+type NotifyPolicy int
+
+const (
+	NotifyQueue NotifyPolicy = iota // wait for room in the queue
+	NotifyDrop                      // drop the callback
+)
+
+// notifyPool runs the expire callbacks on a fixed number of goroutines, so
+// a slow callback does not hold up the sweeper.
+type notifyPool struct {
+	ctx    context.Context
+	fn     CacheExpireFunc
+	queue  chan notifyItem
+	policy NotifyPolicy
+	wg     sync.WaitGroup
+}
+
+type notifyItem struct {
+	k string
+	v interface{}
+}
+
+// newNotifyPool starts workers goroutines calling fn with the entries
+// queued by notify, until ctx is done. queue entries wait for a worker.
+func newNotifyPool(ctx context.Context, fn CacheExpireFunc, workers, queue int, policy NotifyPolicy) *notifyPool {
+	p := &notifyPool{
+		ctx:    ctx,
+		fn:     fn,
+		queue:  make(chan notifyItem, queue),
+		policy: policy,
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.run()
+	}
+	return p
+}
+
+func (p *notifyPool) run() {
+	defer p.wg.Done()
+	for {
+		select {
+		case x := <-p.queue:
+			p.fn(x.k, x.v)
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// notify queues the callback of key and value. With NotifyDrop it is
+// dropped when the queue is full, with NotifyQueue it waits for room until
+// the context of the pool is done.
+func (p *notifyPool) notify(key string, value interface{}) {
+	x := notifyItem{k: key, v: value}
+	if p.policy == NotifyDrop {
+		select {
+		case p.queue <- x:
+		default:
+		}
+		return
+	}
+	select {
+	case p.queue <- x:
+	case <-p.ctx.Done():
+	}
+}
+
+// wait waits for the workers to return once the context of the pool is
+// done, then with flush runs the callbacks left in the queue.
+func (p *notifyPool) wait(flush bool) {
+	p.wg.Wait()
+	for flush {
+		select {
+		case x := <-p.queue:
+			p.fn(x.k, x.v)
+		default:
+			return
+		}
+	}
+}
+
+// expireNotifier returns the function the sweeper calls for the expired
+// entries: fn itself with no workers, else the notify of a pool running fn.
+// wait is called once ctx is done, waiting for the callbacks running and
+// with flush running the queued ones.
+func expireNotifier(ctx context.Context, fn CacheExpireFunc, workers, queue int, policy NotifyPolicy) (notify CacheExpireFunc, wait func(flush bool)) {
+	if fn == nil || workers <= 0 {
+		return fn, func(bool) {}
+	}
+	p := newNotifyPool(ctx, fn, workers, queue, policy)
+	return p.notify, p.wait
+}
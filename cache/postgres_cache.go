@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	DefaultPostgresTable = "mcache"
+)
+
+// PostgresCache stores encoded values in an UNLOGGED Postgres table, a shared
+// cache for teams that can't operate Redis. Unlogged tables skip the WAL and
+// are truncated after a crash, which is fine for a cache. db must be opened
+// with a postgres driver, e.g. github.com/lib/pq. Expired rows are ignored on
+// read and removed by a periodic cleanup. Keys are stored as TEXT, they must
+// be valid UTF-8 without NUL bytes.
+type PostgresCache struct {
+	ByteGetters
+	expire   time.Duration
+	absolute bool
+	prefix   string
+	table    string
+	db       *sql.DB
+	m        sync.Mutex
+	r        *rand.Rand
+	bg       Background
+}
+
+type PostgresOption func(c *PostgresCache)
+
+func PostgresWithExpire(expireSecond int) PostgresOption {
+	return func(c *PostgresCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func PostgresWithTTL(ttl time.Duration) PostgresOption {
+	return func(c *PostgresCache) {
+		c.expire = ttl
+	}
+}
+
+// PostgresWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads.
+func PostgresWithAbsoluteExpire() PostgresOption {
+	return func(c *PostgresCache) {
+		c.absolute = true
+	}
+}
+
+// PostgresWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func PostgresWithKeyPrefix(prefix string) PostgresOption {
+	return func(c *PostgresCache) {
+		c.prefix = prefix
+	}
+}
+
+// PostgresWithTable sets the table holding the entries, DefaultPostgresTable
+// by default.
+func PostgresWithTable(table string) PostgresOption {
+	return func(c *PostgresCache) {
+		c.table = table
+	}
+}
+
+// NewPostgresCache returns a cache stored in db, creating its table when
+// missing. The cleanup runs until ctx is done.
+func NewPostgresCache(ctx context.Context, db *sql.DB, opts ...PostgresOption) (*Cache, error) {
+	c := &PostgresCache{
+		table: DefaultPostgresTable,
+		db:    db,
+		r:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = ByteGetters{get: c.Get}
+	for _, fn := range opts {
+		fn(c)
+	}
+	if db == nil {
+		return nil, ErrNoClient
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE UNLOGGED TABLE IF NOT EXISTS %q (
+		key TEXT PRIMARY KEY,
+		value BYTEA NOT NULL,
+		expire_at BIGINT NOT NULL,
+		ttl BIGINT NOT NULL
+	)`, c.table))
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (expire_at)`,
+		c.table+"_expire_at", c.table))
+	if err != nil {
+		return nil, err
+	}
+	c.bg.Sweep(ctx, c.expire, c.deleteExpired)
+	return NewCache(c), nil
+}
+
+func (c *PostgresCache) Set(key string, value interface{}) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c *PostgresCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *PostgresCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	_, err := c.db.Exec(fmt.Sprintf(`INSERT INTO %q (key, value, expire_at, ttl) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE
+		SET value = EXCLUDED.value, expire_at = EXCLUDED.expire_at, ttl = EXCLUDED.ttl`, c.table),
+		c.prefix+key, EncodeBytes(value), sqlExpireAt(ttl), int64(ttl))
+	return err
+}
+
+// Get returns the value of key. Unless the cache uses absolute expiration,
+// the lookup and the expiration extension are a single UPDATE ... RETURNING.
+func (c *PostgresCache) Get(key string) (interface{}, error) {
+	var value []byte
+	var err error
+	now := time.Now().UnixNano()
+	if c.absolute {
+		err = c.db.QueryRow(fmt.Sprintf(`SELECT value FROM %q
+			WHERE key = $1 AND (expire_at = 0 OR expire_at > $2)`, c.table),
+			c.prefix+key, now).Scan(&value)
+	} else {
+		err = c.db.QueryRow(fmt.Sprintf(`UPDATE %q
+			SET expire_at = CASE WHEN ttl > 0 THEN $2 + ttl ELSE 0 END
+			WHERE key = $1 AND (expire_at = 0 OR expire_at > $2)
+			RETURNING value`, c.table),
+			c.prefix+key, now).Scan(&value)
+	}
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, nil
+}
+
+func (c *PostgresCache) Del(key string) error {
+	_, err := c.db.Exec(fmt.Sprintf(`DELETE FROM %q WHERE key = $1`, c.table), c.prefix+key)
+	return err
+}
+
+// Clear deletes the rows of the keys starting with the key prefix.
+func (c *PostgresCache) Clear() error {
+	_, err := c.db.Exec(fmt.Sprintf(`DELETE FROM %q WHERE substr(key, 1, $1) = $2`, c.table),
+		utf8.RuneCountInString(c.prefix), c.prefix)
+	return err
+}
+
+// Close stops the periodic cleanup and waits for a running one. The
+// connection pool is left open for the caller.
+func (c *PostgresCache) Close() error {
+	c.bg.Stop()
+	return nil
+}
+
+// deleteExpired deletes the expired rows.
+func (c *PostgresCache) deleteExpired(ctx context.Context) {
+	c.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %q
+		WHERE expire_at != 0 AND expire_at <= $1`, c.table), time.Now().UnixNano())
+}
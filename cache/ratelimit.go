@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket holds up to burst tokens, refilled at rate tokens per second.
+type tokenBucket struct {
+	m      sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns how long to wait for it to be refilled.
+// It takes nothing and returns false when that is longer than max.
+func (b *tokenBucket) reserve(now time.Time, max time.Duration) (time.Duration, bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	if b.rate <= 0 {
+		return 0, false
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if wait > max {
+		return 0, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// RateLimitedCache limits the operations of the wrapped cache, e.g. a redis
+// shared with other services, to a rate with a token bucket. The operations
+// over the rate fail with ErrRateLimited, or wait for a token with
+// RateLimitWithWait.
+type RateLimitedCache struct {
+	c      ICache
+	bucket *tokenBucket
+	wait   time.Duration
+}
+
+type RateLimitOption func(c *RateLimitedCache)
+
+// RateLimitWithWait makes the operations over the rate wait up to max for a
+// token before failing with ErrRateLimited.
+func RateLimitWithWait(max time.Duration) RateLimitOption {
+	return func(c *RateLimitedCache) {
+		c.wait = max
+	}
+}
+
+// NewRateLimitedCache returns c allowing perSecond operations per second on
+// average, and bursts of up to burst operations.
+func NewRateLimitedCache(c ICache, perSecond float64, burst int, opts ...RateLimitOption) *Cache {
+	rc := &RateLimitedCache{
+		c: c,
+		bucket: &tokenBucket{
+			rate:   perSecond,
+			burst:  float64(burst),
+			tokens: float64(burst),
+			last:   time.Now(),
+		},
+	}
+	for _, fn := range opts {
+		fn(rc)
+	}
+	return NewCache(rc)
+}
+
+// take waits for a token, or returns ErrRateLimited.
+func (c *RateLimitedCache) take() error {
+	wait, ok := c.bucket.reserve(time.Now(), c.wait)
+	if !ok {
+		return ErrRateLimited
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+func (c *RateLimitedCache) Set(key string, value interface{}) error {
+	if err := c.take(); err != nil {
+		return err
+	}
+	return c.c.Set(key, value)
+}
+
+func (c *RateLimitedCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	if err := c.take(); err != nil {
+		return err
+	}
+	return c.c.SetWithExpire(key, value, expireSec)
+}
+
+func (c *RateLimitedCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if err := c.take(); err != nil {
+		return err
+	}
+	return c.c.SetWithTTL(key, value, ttl)
+}
+
+func (c *RateLimitedCache) Get(key string) (interface{}, error) {
+	if err := c.take(); err != nil {
+		return nil, err
+	}
+	return c.c.Get(key)
+}
+
+func (c *RateLimitedCache) GetInt(key string) (*int64, error) {
+	if err := c.take(); err != nil {
+		return nil, err
+	}
+	return c.c.GetInt(key)
+}
+
+func (c *RateLimitedCache) GetUint(key string) (*uint64, error) {
+	if err := c.take(); err != nil {
+		return nil, err
+	}
+	return c.c.GetUint(key)
+}
+
+func (c *RateLimitedCache) GetFloat(key string) (*float64, error) {
+	if err := c.take(); err != nil {
+		return nil, err
+	}
+	return c.c.GetFloat(key)
+}
+
+func (c *RateLimitedCache) GetString(key string) (string, error) {
+	if err := c.take(); err != nil {
+		return "", err
+	}
+	return c.c.GetString(key)
+}
+
+func (c *RateLimitedCache) GetBytes(key string) ([]byte, error) {
+	if err := c.take(); err != nil {
+		return nil, err
+	}
+	return c.c.GetBytes(key)
+}
+
+func (c *RateLimitedCache) GetBool(key string) (*bool, error) {
+	if err := c.take(); err != nil {
+		return nil, err
+	}
+	return c.c.GetBool(key)
+}
+
+func (c *RateLimitedCache) GetTime(key string) (*time.Time, error) {
+	if err := c.take(); err != nil {
+		return nil, err
+	}
+	return c.c.GetTime(key)
+}
+
+func (c *RateLimitedCache) GetDuration(key string) (*time.Duration, error) {
+	if err := c.take(); err != nil {
+		return nil, err
+	}
+	return c.c.GetDuration(key)
+}
+
+func (c *RateLimitedCache) GetStringSlice(key string) ([]string, error) {
+	if err := c.take(); err != nil {
+		return nil, err
+	}
+	return c.c.GetStringSlice(key)
+}
+
+func (c *RateLimitedCache) GetIntSlice(key string) ([]int64, error) {
+	if err := c.take(); err != nil {
+		return nil, err
+	}
+	return c.c.GetIntSlice(key)
+}
+
+// Ping pings the wrapped cache, regardless of the rate.
+func (c *RateLimitedCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.c)
+}
+
+// Close closes the wrapped cache.
+func (c *RateLimitedCache) Close() error {
+	return closeCache(c.c)
+}
+
+func (c *RateLimitedCache) Del(key string) error {
+	if err := c.take(); err != nil {
+		return err
+	}
+	return c.c.Del(key)
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewRateLimitedCache(NewLocalCache(ctx, LocalWithExpire(10)), 10, 2)
+	if err := c.Set("test:123", 1); err != nil {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if data, err := c.GetInt("test:123"); err != nil || data == nil || *data != 1 {
+		t.Errorf("%v %v value error", data, err)
+		return
+	}
+	if _, err := c.Get("test:123"); err != ErrRateLimited {
+		t.Errorf("%v value error", err)
+		return
+	}
+	time.Sleep(110 * time.Millisecond)
+	if _, err := c.Get("test:123"); err != nil {
+		t.Errorf("%v value error", err)
+	}
+}
+
+func TestRateLimitedCacheWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewRateLimitedCache(NewLocalCache(ctx, LocalWithExpire(10)), 20, 1, RateLimitWithWait(time.Second))
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := c.Set("test:123", i); err != nil {
+			t.Errorf("%v value error", err)
+			return
+		}
+	}
+	if d := time.Since(start); d < 190*time.Millisecond || d > time.Second {
+		t.Errorf("%v wait error", d)
+	}
+}
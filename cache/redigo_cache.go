@@ -1,8 +1,13 @@
 package cache
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"math/rand"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -10,16 +15,51 @@ import (
 )
 
 var (
-	redigoGetCache = redigo.NewScript(1, getCacheStr)
 	redigoSetCache = redigo.NewScript(1, setCacheStr)
+
+	redigoRangeCache = redigo.NewScript(1, rangeCacheStr)
+
+	redigoAppendCache = redigo.NewScript(1, appendCacheStr)
+	redigoRenameCache = redigo.NewScript(2, renameCacheStr)
+	redigoHSetCache   = redigo.NewScript(1, hsetCacheStr)
+	redigoHGetCache   = redigo.NewScript(1, hgetCacheStr)
+	redigoHGetAll     = redigo.NewScript(1, hgetallCacheStr)
+	redigoLPushCache  = redigo.NewScript(1, lpushCacheStr)
+	redigoSAddCache   = redigo.NewScript(1, saddCacheStr)
+	redigoZAddCache   = redigo.NewScript(1, zaddCacheStr)
+
+	redigoGetLease   = redigo.NewScript(1, getLeaseStr)
+	redigoSetLease   = redigo.NewScript(1, setLeaseStr(setCacheStr))
+	redigoInvalidate = redigo.NewScript(1, invalidateStr)
+
+	redigoGetVersion = redigo.NewScript(1, getVersionStr)
 )
 
 type GetRedisConn func() redigo.Conn
 
 type RedigoCache struct {
 	expireSec int
+	ttl       time.Duration
+	ms        bool
+	absolute  bool
+	hooks     ScriptHooks
+	prefix    string
+	dialOpts  []redigo.DialOption
+	username  string
+	password  string
+	maxValue  int
+	expireCtx context.Context
+	expireFn  CacheExpireFunc
+	timeout   time.Duration
+	retries   int
+	backoff   time.Duration
 	getConn   GetRedisConn
+	pool      *redigo.Pool
 	rnd       *rand.Rand
+	scripts   redigoScripts
+	getScript *redigo.Script
+	setScript *redigo.Script
+	setLease  *redigo.Script
 }
 
 type RedigoOption func(c *RedigoCache)
@@ -27,39 +67,491 @@ type RedigoOption func(c *RedigoCache)
 func RedigoWithExpire(expireSecond int) RedigoOption {
 	return func(c *RedigoCache) {
 		c.expireSec = expireSecond
+		c.ttl = time.Duration(expireSecond) * time.Second
+	}
+}
+
+// RedigoExpireNotify calls fn with the keys of the cache expiring in
+// redis, read from the keyspace notifications like Subscribe, until ctx is
+// done. The value is nil. The server must have notify-keyspace-events
+// including "Kx".
+func RedigoExpireNotify(ctx context.Context, fn CacheExpireFunc) RedigoOption {
+	return func(c *RedigoCache) {
+		c.expireCtx = ctx
+		c.expireFn = fn
+	}
+}
+
+// RedigoWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads. Get then runs a script only
+// reading the entry, without the EXPIRE write of every read.
+func RedigoWithAbsoluteExpire() RedigoOption {
+	return func(c *RedigoCache) {
+		c.absolute = true
+	}
+}
+
+// RedigoWithTTL sets the default expiration, rounded up to whole seconds.
+func RedigoWithTTL(ttl time.Duration) RedigoOption {
+	return func(c *RedigoCache) {
+		c.expireSec = TTLSeconds(ttl)
+		c.ttl = ttl
+	}
+}
+
+// RedigoWithMillisecondTTL expires the entries written by Set, SetWithTTL,
+// Warm, MSet and Tx with PEXPIRE, keeping the milliseconds of their ttl and of
+// the default expiration instead of rounding them up to seconds. Hashes,
+// lists, sets and sorted sets still expire in whole seconds.
+func RedigoWithMillisecondTTL() RedigoOption {
+	return func(c *RedigoCache) {
+		c.ms = true
+	}
+}
+
+// RedigoWithMaxValueSize rejects with ErrValueTooLarge the values, and the
+// members of lists, sets and sorted sets, longer than n bytes once encoded,
+// and the Append suffixes longer than n bytes, without sending them to redis.
+// 0 is unlimited.
+func RedigoWithMaxValueSize(n int) RedigoOption {
+	return func(c *RedigoCache) {
+		c.maxValue = n
+	}
+}
+
+// RedigoWithScriptHooks runs hooks inside the scripts of Get and Set, and of
+// MGet, MSet, Warm and Tx using them.
+func RedigoWithScriptHooks(hooks ScriptHooks) RedigoOption {
+	return func(c *RedigoCache) {
+		c.hooks = hooks
+		c.setScript = redigo.NewScript(1, hooks.script(setCacheStr))
+		c.setLease = redigo.NewScript(1, setLeaseStr(hooks.script(setCacheStr)))
+	}
+}
+
+// RedigoWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func RedigoWithKeyPrefix(prefix string) RedigoOption {
+	return func(c *RedigoCache) {
+		c.prefix = prefix
+	}
+}
+
+// RedigoWithDialOptions sets the options used to dial redis by the
+// constructors that create their own connections, e.g.
+// NewRedigoCacheSentinel.
+func RedigoWithDialOptions(opts ...redigo.DialOption) RedigoOption {
+	return func(c *RedigoCache) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	}
+}
+
+// RedigoWithTLSConfig connects over TLS with cfg. It applies to the
+// constructors that create their own connections, e.g. NewRedigoCacheAddr.
+func RedigoWithTLSConfig(cfg *tls.Config) RedigoOption {
+	return func(c *RedigoCache) {
+		c.dialOpts = append(c.dialOpts, redigo.DialUseTLS(true), redigo.DialTLSConfig(cfg))
+	}
+}
+
+// RedigoWithUsernamePassword authenticates as the ACL user username, or with
+// the legacy requirepass password when username is empty. It applies to the
+// constructors that create their own connections, e.g. NewRedigoCacheAddr.
+func RedigoWithUsernamePassword(username, password string) RedigoOption {
+	return func(c *RedigoCache) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// RedigoWithTimeout bounds each command to d, so a slow redis fails the
+// operations quickly rather than hanging the callers. The connections must
+// implement redigo.ConnWithTimeout, as the ones of a redigo.Pool do.
+func RedigoWithTimeout(d time.Duration) RedigoOption {
+	return func(c *RedigoCache) {
+		c.timeout = d
+	}
+}
+
+// RedigoWithRetry retries a command failing on a network error or a timeout
+// up to n times on a new connection, waiting backoff before each retry. A
+// retried write may be applied twice.
+func RedigoWithRetry(n int, backoff time.Duration) RedigoOption {
+	return func(c *RedigoCache) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// retryConn runs the commands of a connection with the timeout and retry
+// options of a RedigoCache, moving to a new connection after a failure.
+type retryConn struct {
+	redigo.Conn
+	getConn GetRedisConn
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+}
+
+func (c *retryConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	for i := 0; ; i++ {
+		reply, err := c.do(cmd, args...)
+		if _, ok := err.(redigo.Error); err == nil || ok || i >= c.retries {
+			return reply, err
+		}
+		time.Sleep(c.backoff)
+		conn := c.getConn()
+		if conn == nil {
+			return nil, ErrNoRedis
+		}
+		c.Conn.Close()
+		c.Conn = conn
+	}
+}
+
+func (c *retryConn) do(cmd string, args ...interface{}) (interface{}, error) {
+	if c.timeout > 0 {
+		return redigo.DoWithTimeout(c.Conn, c.timeout, cmd, args...)
+	}
+	return c.Conn.Do(cmd, args...)
+}
+
+// dial connects to the redis server at addr with the dial, TLS and auth
+// options of c.
+func (r *RedigoCache) dial(addr string) (redigo.Conn, error) {
+	c, err := redigo.Dial("tcp", addr, r.dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if r.username != "" {
+		_, err = c.Do("AUTH", r.username, r.password)
+	} else if r.password != "" {
+		_, err = c.Do("AUTH", r.password)
 	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewRedigoCacheAddr returns a cache on a connection pool to the redis
+// server at addr, e.g. a managed service needing RedigoWithTLSConfig and
+// RedigoWithUsernamePassword.
+func NewRedigoCacheAddr(addr string, opts ...RedigoOption) *Cache {
+	return newRedigoPoolCache(func(c *RedigoCache) *redigo.Pool {
+		return &redigo.Pool{
+			MaxIdle:     3,
+			IdleTimeout: 240 * time.Second,
+			Dial: func() (redigo.Conn, error) {
+				return c.dial(addr)
+			},
+		}
+	}, opts)
+}
+
+// newRedigoPoolCache returns a cache on the connections of the pool built by
+// newPool from the cache configured by opts, closing it on Close.
+func newRedigoPoolCache(newPool func(c *RedigoCache) *redigo.Pool, opts []RedigoOption) *Cache {
+	c := newRedigoCache(opts)
+	c.pool = newPool(c)
+	c.setConn(c.pool.Get)
+	return c.start()
 }
 
 func NewRedigoCache(getConn GetRedisConn, opts ...RedigoOption) *Cache {
+	c := newRedigoCache(opts)
+	c.setConn(getConn)
+	return c.start()
+}
+
+// newRedigoCache returns a cache configured by opts, without connections
+// yet.
+func newRedigoCache(opts []RedigoOption) *RedigoCache {
 	c := &RedigoCache{
-		getConn: getConn,
-		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		rnd:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		setScript: redigoSetCache,
+		setLease:  redigoSetLease,
 	}
 	for _, fn := range opts {
 		fn(c)
 	}
-	return NewCache(c)
+	c.getScript = redigo.NewScript(1, c.hooks.script(getCacheScript(c.absolute)))
+	return c
+}
+
+// setConn gets the connections from getConn, retrying the commands as
+// configured.
+func (r *RedigoCache) setConn(getConn GetRedisConn) {
+	r.getConn = getConn
+	if r.timeout > 0 || r.retries > 0 {
+		r.getConn = func() redigo.Conn {
+			conn := getConn()
+			if conn == nil {
+				return nil
+			}
+			return &retryConn{Conn: conn, getConn: getConn, timeout: r.timeout, retries: r.retries, backoff: r.backoff}
+		}
+	}
+}
+
+// start starts the expiration notifications if configured and returns the
+// cache.
+func (r *RedigoCache) start() *Cache {
+	if r.expireFn != nil {
+		notifyExpire(r.expireCtx, r, r.expireFn)
+	}
+	return NewCache(r)
 }
 
 func (r *RedigoCache) Set(key string, value interface{}) error {
+	exp, unit := r.defaultExpireArgs()
+	return r.set(key, value, exp, unit)
+}
+
+// defaultExpire returns the default expiration with up to 10% of jitter, so
+// keys written together do not expire together.
+func (r *RedigoCache) defaultExpire() int {
+	exp := r.expireSec
+	if exp > 0 {
+		exp += r.rnd.Intn(int(exp/10 + 1))
+	}
+	return exp
+}
+
+// expireArgs returns the expiration and unit arguments of setCacheStr for
+// ttl, in milliseconds with RedigoWithMillisecondTTL, else in seconds.
+func (r *RedigoCache) expireArgs(ttl time.Duration) (int64, string) {
+	if r.ms {
+		return ttlMillis(ttl), "ms"
+	}
+	return int64(TTLSeconds(ttl)), "s"
+}
+
+// defaultExpireArgs returns the expireArgs of the default expiration, with
+// jitter.
+func (r *RedigoCache) defaultExpireArgs() (int64, string) {
+	if !r.ms {
+		return int64(r.defaultExpire()), "s"
+	}
+	exp := ttlMillis(r.ttl)
+	if exp > 0 {
+		exp += r.rnd.Int63n(exp/10 + 1)
+	}
+	return exp, "ms"
+}
+
+// Warm stores entries in a single pipeline on one connection.
+func (r *RedigoCache) Warm(ctx context.Context, entries map[string]ValueWithTTL) error {
+	keys := make([]string, 0, len(entries))
+	values := make([]interface{}, 0, len(entries))
+	for key, v := range entries {
+		value, err := encodeValueMax(v.Value, r.maxValue)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
 	c := r.getConn()
 	if c == nil {
 		return ErrNoRedis
 	}
-	exp := r.expireSec
-	if exp > 0 {
-		exp += r.rnd.Intn(int(exp/10 + 1))
+	defer c.Close()
+	return r.withScript(c, r.setScript, func() error {
+		return redigoPipeline(c, len(keys), func(i int) error {
+			v := entries[keys[i]]
+			exp, unit := r.expireArgs(v.TTL)
+			if v.TTL <= 0 {
+				exp, unit = r.defaultExpireArgs()
+			}
+			return r.setScript.SendHash(c, r.prefix+keys[i], values[i], exp, unit)
+		}, nil)
+	})
+}
+
+// redigoScripts records the scripts loaded on the server of a RedigoCache,
+// so the pipelines send EVALSHA without loading the scripts each time.
+type redigoScripts struct {
+	m      sync.Mutex
+	loaded map[*redigo.Script]bool
+}
+
+// load loads script on the server of c unless it is known to be loaded.
+// reload forgets every script loaded, the server lost them.
+func (s *redigoScripts) load(c redigo.Conn, script *redigo.Script, reload bool) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if reload || s.loaded == nil {
+		s.loaded = map[*redigo.Script]bool{}
 	}
-	_, err := redigoSetCache.Do(c, key, value, exp)
-	return err
+	if s.loaded[script] {
+		return nil
+	}
+	if err := script.Load(c); err != nil {
+		return err
+	}
+	s.loaded[script] = true
+	return nil
+}
+
+// redigoNoScript reports whether err is the reply to EVALSHA of a script
+// the server does not have.
+func redigoNoScript(err error) bool {
+	e, ok := err.(redigo.Error)
+	return ok && strings.HasPrefix(string(e), "NOSCRIPT")
+}
+
+// withScript runs fn sending script with EVALSHA on c, loading it first
+// unless it was already loaded. When the server lost it, e.g. after a
+// failover or a SCRIPT FLUSH, fn fails with NOSCRIPT and is run again once
+// the script is loaded again, so fn must be safe to repeat. The single
+// commands use Script.Do, which falls back to EVAL by itself.
+func (r *RedigoCache) withScript(c redigo.Conn, script *redigo.Script, fn func() error) error {
+	if err := r.scripts.load(c, script, false); err != nil {
+		return err
+	}
+	err := fn()
+	if !redigoNoScript(err) {
+		return err
+	}
+	if err := r.scripts.load(c, script, true); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// redigoPipelineSize is how many commands a pipeline sends before reading
+// their replies, bounding the replies buffered on both ends.
+const redigoPipelineSize = 500
+
+// redigoPipeline sends n commands with send on c in batches, flushing each
+// batch and passing the reply of every command to recv when it is not nil.
+// It returns the first error of send, recv or the replies, nil replies are
+// not errors.
+func redigoPipeline(c redigo.Conn, n int, send func(i int) error, recv func(i int, reply interface{}) error) error {
+	var first error
+	for start := 0; start < n; start += redigoPipelineSize {
+		end := start + redigoPipelineSize
+		if end > n {
+			end = n
+		}
+		for i := start; i < end; i++ {
+			if err := send(i); err != nil {
+				return err
+			}
+		}
+		if err := c.Flush(); err != nil {
+			return err
+		}
+		for i := start; i < end; i++ {
+			reply, err := c.Receive()
+			if err == redigo.ErrNil {
+				err = nil
+			}
+			if err == nil && recv != nil {
+				err = recv(i, reply)
+			}
+			if err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// MGet reads keys with pipelined scripts on one connection, extending their
+// expiration like Get.
+func (r *RedigoCache) MGet(keys ...string) ([]interface{}, error) {
+	c := r.getConn()
+	if c == nil {
+		return nil, ErrNoRedis
+	}
+	defer c.Close()
+	ret := make([]interface{}, len(keys))
+	slide := slideArg(r.absolute)
+	err := r.withScript(c, r.getScript, func() error {
+		return redigoPipeline(c, len(keys), func(i int) error {
+			return r.getScript.SendHash(c, r.prefix+keys[i], slide)
+		}, func(i int, reply interface{}) error {
+			if reply == nil {
+				return nil
+			}
+			data, ok := reply.([]byte)
+			if !ok {
+				return ErrDataType
+			}
+			ret[i] = data
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// MSet stores entries with the default expiration with pipelined scripts on
+// one connection.
+func (r *RedigoCache) MSet(entries map[string]interface{}) error {
+	keys := make([]string, 0, len(entries))
+	values := make([]interface{}, 0, len(entries))
+	for key, v := range entries {
+		value, err := encodeValueMax(v, r.maxValue)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	return r.withScript(c, r.setScript, func() error {
+		return redigoPipeline(c, len(keys), func(i int) error {
+			exp, unit := r.defaultExpireArgs()
+			return r.setScript.SendHash(c, r.prefix+keys[i], values[i], exp, unit)
+		}, nil)
+	})
+}
+
+// MDel removes keys with pipelined DELs on one connection, which unlike a
+// single multi key DEL also works through a cluster proxy.
+func (r *RedigoCache) MDel(keys ...string) error {
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	return redigoPipeline(c, len(keys), func(i int) error {
+		return c.Send("DEL", r.prefix+keys[i])
+	}, nil)
 }
 
 func (r *RedigoCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return r.set(key, value, int64(expireSec), "s")
+}
+
+// SetWithTTL stores value with the given ttl, rounded up to whole seconds
+// unless RedigoWithMillisecondTTL is set.
+func (r *RedigoCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	exp, unit := r.expireArgs(ttl)
+	return r.set(key, value, exp, unit)
+}
+
+// set stores value expiring after exp in unit, the expireArgs.
+func (r *RedigoCache) set(key string, value interface{}, exp int64, unit string) error {
+	data, err := encodeValueMax(value, r.maxValue)
+	if err != nil {
+		return err
+	}
 	c := r.getConn()
 	if c == nil {
 		return ErrNoRedis
 	}
-	_, err := redigoSetCache.Do(c, key, value, expireSec)
+	defer c.Close()
+	_, err = r.setScript.Do(c, r.prefix+key, data, exp, unit)
 	return err
 }
 
@@ -68,7 +560,8 @@ func (r *RedigoCache) Get(key string) (interface{}, error) {
 	if c == nil {
 		return nil, ErrNoRedis
 	}
-	value, err := redigoGetCache.Do(c, key, r.expireSec)
+	defer c.Close()
+	value, err := r.getScript.Do(c, r.prefix+key, slideArg(r.absolute))
 	if err == redigo.ErrNil || (value == nil && err == nil) {
 		return nil, nil
 	}
@@ -82,6 +575,82 @@ func (r *RedigoCache) Get(key string) (interface{}, error) {
 	return tmp, err
 }
 
+// GetWithVersion returns the value of key and its version, the SHA-1 of
+// the value.
+func (r *RedigoCache) GetWithVersion(key string) (interface{}, string, error) {
+	return r.GetIfChanged(key, "")
+}
+
+// GetIfChanged returns the value of key and its version, or ErrNotModified
+// without reading the value when version is still the SHA-1 of the value.
+func (r *RedigoCache) GetIfChanged(key, version string) (interface{}, string, error) {
+	c := r.getConn()
+	if c == nil {
+		return nil, "", ErrNoRedis
+	}
+	defer c.Close()
+	reply, err := redigoGetVersion.Do(c, r.prefix+key, slideArg(r.absolute), version)
+	if err == redigo.ErrNil || (reply == nil && err == nil) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return versionReply(reply)
+}
+
+// GetLease returns the value of key, or on a miss a lease on it valid for
+// ttl, or ErrLeaseHeld and the stale value kept by Invalidate while another
+// reader holds the lease. A hit does not extend a sliding expiration.
+func (r *RedigoCache) GetLease(key string, ttl time.Duration) (interface{}, string, error) {
+	c := r.getConn()
+	if c == nil {
+		return nil, "", ErrNoRedis
+	}
+	defer c.Close()
+	token := newLeaseToken()
+	reply, err := redigoGetLease.Do(c, r.prefix+key, token, leaseMillis(time.Now()), ttlMillis(ttl))
+	if err != nil {
+		return nil, "", err
+	}
+	return leaseReply(reply, token)
+}
+
+// SetLease stores value with the default expiration if token is the lease
+// of key, else returns ErrLeaseInvalid.
+func (r *RedigoCache) SetLease(key string, value interface{}, token string) error {
+	data, err := encodeValueMax(value, r.maxValue)
+	if err != nil {
+		return err
+	}
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	exp, unit := r.defaultExpireArgs()
+	n, err := redigo.Int64(r.setLease.Do(c, r.prefix+key, data, exp, unit, token))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseInvalid
+	}
+	return nil
+}
+
+// Invalidate deletes key and its lease, keeping its value for staleTTL to
+// be returned by GetLease while the key is loaded again.
+func (r *RedigoCache) Invalidate(key string, staleTTL time.Duration) error {
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	_, err := redigoInvalidate.Do(c, r.prefix+key, ttlMillis(staleTTL))
+	return err
+}
+
 func (r *RedigoCache) GetInt(key string) (*int64, error) {
 	value, err := r.Get(key)
 	if value == nil {
@@ -91,6 +660,15 @@ func (r *RedigoCache) GetInt(key string) (*int64, error) {
 	return &data, err
 }
 
+func (r *RedigoCache) GetUint(key string) (*uint64, error) {
+	value, err := r.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseUint(string(value.([]byte)), 10, 64)
+	return &data, err
+}
+
 func (r *RedigoCache) GetFloat(key string) (*float64, error) {
 	value, err := r.Get(key)
 	if value == nil {
@@ -123,18 +701,556 @@ func (r *RedigoCache) GetBool(key string) (*bool, error) {
 	if value == nil {
 		return nil, err
 	}
-	data, err := strconv.ParseBool(string(value.([]byte)))
+	data, err := parseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (r *RedigoCache) GetTime(key string) (*time.Time, error) {
+	value, err := r.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := time.Parse(time.RFC3339Nano, string(value.([]byte)))
 	return &data, err
 }
 
+func (r *RedigoCache) GetDuration(key string) (*time.Duration, error) {
+	value, err := r.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	data, err := strconv.ParseInt(string(value.([]byte)), 10, 64)
+	ret := time.Duration(data)
+	return &ret, err
+}
+
+func (r *RedigoCache) GetStringSlice(key string) ([]string, error) {
+	value, err := r.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	var data []string
+	err = json.Unmarshal(value.([]byte), &data)
+	return data, err
+}
+
+func (r *RedigoCache) GetIntSlice(key string) ([]int64, error) {
+	value, err := r.Get(key)
+	if value == nil {
+		return nil, err
+	}
+	var data []int64
+	err = json.Unmarshal(value.([]byte), &data)
+	return data, err
+}
+
+// Subscribe calls fn with the changes of the keys of the cache, read from
+// the redis keyspace notifications, until ctx is done. The server must have
+// notify-keyspace-events set to "KA" or similar. Events have no Value. The
+// subscription ends when its connection fails.
+func (r *RedigoCache) Subscribe(ctx context.Context, fn EventFunc) error {
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	psc := redigo.PubSubConn{Conn: c}
+	if err := psc.PSubscribe(keyspacePattern(r.prefix)); err != nil {
+		c.Close()
+		return err
+	}
+	if err, ok := psc.Receive().(error); ok {
+		c.Close()
+		return err
+	}
+	// m orders the unsubscribe with the close of the connection.
+	var m sync.Mutex
+	closed := false
+	go func() {
+		<-ctx.Done()
+		m.Lock()
+		if !closed {
+			psc.PUnsubscribe()
+		}
+		m.Unlock()
+	}()
+	go func() {
+		defer func() {
+			m.Lock()
+			closed = true
+			c.Close()
+			m.Unlock()
+		}()
+		for {
+			switch msg := psc.Receive().(type) {
+			case redigo.Message:
+				if e, ok := keyspaceEvent(msg.Channel, string(msg.Data), r.prefix); ok {
+					fn(e)
+				}
+			case redigo.Subscription:
+				if msg.Count == 0 {
+					return
+				}
+			case error:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Close closes the connection pool of the caches built by
+// NewRedigoCacheAddr and NewRedigoCacheSentinel. The connections of the
+// GetRedisConn of NewRedigoCache belong to the caller.
+func (r *RedigoCache) Close() error {
+	if r.pool == nil {
+		return nil
+	}
+	return r.pool.Close()
+}
+
+// Ping sends PING on a connection from getConn.
+func (r *RedigoCache) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	_, err := c.Do("PING")
+	return err
+}
+
 func (r *RedigoCache) Del(key string) error {
 	c := r.getConn()
 	if c == nil {
 		return ErrNoRedis
 	}
-	_, err := c.Do("DEL", key)
+	defer c.Close()
+	_, err := c.Do("DEL", r.prefix+key)
 	if err == redigo.ErrNil {
 		return nil
 	}
 	return err
 }
+
+// Rename moves oldKey to newKey with RENAME, which keeps both the value and
+// the remaining ttl, then fixes up the version and exp fields of the entry
+// as renameFixupStr does.
+func (r *RedigoCache) Rename(oldKey, newKey string) error {
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	_, err := redigoRenameCache.Do(c, r.prefix+oldKey, r.prefix+newKey)
+	return err
+}
+
+// Clear deletes the keys under the key prefix, every key of the database
+// without one.
+func (r *RedigoCache) Clear() error {
+	return r.DelByPattern("*")
+}
+
+func (r *RedigoCache) DelByPrefix(prefix string) error {
+	return r.DelByPattern(escapeGlob(prefix) + "*")
+}
+
+// DelByPattern deletes the keys matching pattern with SCAN and batched DEL,
+// so redis is not blocked like KEYS would.
+func (r *RedigoCache) DelByPattern(pattern string) error {
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	return redigoScan(c, escapeGlob(r.prefix)+pattern, func(keys []interface{}) error {
+		_, err := c.Do("DEL", keys...)
+		return err
+	})
+}
+
+// Count returns the number of keys of the cache, with DBSIZE without a key
+// prefix and else by counting the keys with the prefix with SCAN, which may
+// return a key twice while redis resizes. The keys of every type are
+// counted, e.g. the ":xfetch" keys of GetOrSetWithTTL.
+func (r *RedigoCache) Count() (int, error) {
+	c := r.getConn()
+	if c == nil {
+		return 0, ErrNoRedis
+	}
+	defer c.Close()
+	if r.prefix == "" {
+		return redigo.Int(c.Do("DBSIZE"))
+	}
+	n := 0
+	err := redigoScan(c, escapeGlob(r.prefix)+"*", func(keys []interface{}) error {
+		n += len(keys)
+		return nil
+	})
+	return n, err
+}
+
+// MemoryUsage estimates the bytes used by the keys of the cache from the
+// MEMORY USAGE of the first memorySample keys found by SCAN, scaled to the
+// number of keys.
+func (r *RedigoCache) MemoryUsage() (int64, error) {
+	c := r.getConn()
+	if c == nil {
+		return 0, ErrNoRedis
+	}
+	defer c.Close()
+	var n, sampled, bytes int64
+	err := redigoScan(c, escapeGlob(r.prefix)+"*", func(keys []interface{}) error {
+		n += int64(len(keys))
+		if rest := memorySample - sampled; int64(len(keys)) > rest {
+			keys = keys[:rest]
+		}
+		return redigoPipeline(c, len(keys), func(i int) error {
+			return c.Send("MEMORY", "USAGE", keys[i])
+		}, func(i int, reply interface{}) error {
+			if reply == nil {
+				return nil
+			}
+			size, err := redigo.Int64(reply, nil)
+			if err != nil {
+				return err
+			}
+			sampled++
+			bytes += size
+			return nil
+		})
+	})
+	return memoryEstimate(n, sampled, bytes), err
+}
+
+// RangeTTL calls fn for each entry written by Set with its remaining ttl, 0
+// when it does not expire, until fn returns false. Keys are found with SCAN
+// and read in pipelined batches. Hash, list, set and sorted set entries are
+// skipped.
+func (r *RedigoCache) RangeTTL(fn func(key string, value interface{}, ttl time.Duration) bool) error {
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	visit := newRangeVisitor(r.prefix, fn)
+	err := redigoScan(c, escapeGlob(r.prefix)+"*", func(keys []interface{}) error {
+		return r.withScript(c, redigoRangeCache, func() error {
+			return redigoPipeline(c, len(keys), func(i int) error {
+				return redigoRangeCache.SendHash(c, keys[i])
+			}, func(i int, reply interface{}) error {
+				if reply == nil {
+					return nil
+				}
+				key, err := redigo.String(keys[i], nil)
+				if err != nil {
+					return err
+				}
+				return visit(key, reply)
+			})
+		})
+	})
+	if err == errStopRange {
+		return nil
+	}
+	return err
+}
+
+// redigoScan scans c for keys matching match and passes every non empty
+// batch to fn.
+func redigoScan(c redigo.Conn, match string, fn func(keys []interface{}) error) error {
+	cursor := int64(0)
+	for {
+		values, err := redigo.Values(c.Do("SCAN", cursor, "MATCH", match, "COUNT", scanCount))
+		if err != nil {
+			return err
+		}
+		if len(values) != 2 {
+			return ErrDataType
+		}
+		cursor, err = redigo.Int64(values[0], nil)
+		if err != nil {
+			return err
+		}
+		keys, err := redigo.Values(values[1], nil)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil && err != redigo.ErrNil {
+				return err
+			}
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// Append appends suffix to the value stored at key in one round trip,
+// creating it with the default expiration if it does not exist.
+func (r *RedigoCache) Append(key, suffix string) error {
+	if r.maxValue > 0 && len(suffix) > r.maxValue {
+		return ErrValueTooLarge
+	}
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	_, err := redigoAppendCache.Do(c, r.prefix+key, suffix, r.expireSec)
+	return err
+}
+
+// HSet sets field of the redis hash stored at key and refreshes its
+// expiration, see the HSet of GoredisCache for the layout of the hash.
+func (r *RedigoCache) HSet(key, field string, value interface{}) error {
+	data, err := encodeValueMax(value, r.maxValue)
+	if err != nil {
+		return err
+	}
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	n, err := redigo.Int64(redigoHSetCache.Do(c, r.prefix+key, field, data, r.expireSec))
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return ErrDataType
+	}
+	return nil
+}
+
+func (r *RedigoCache) HGet(key, field string) (interface{}, error) {
+	c := r.getConn()
+	if c == nil {
+		return nil, ErrNoRedis
+	}
+	defer c.Close()
+	value, err := redigoHGetCache.Do(c, r.prefix+key, field)
+	if err == redigo.ErrNil || (value == nil && err == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := value.(int64); ok {
+		return nil, ErrDataType
+	}
+	return value, nil
+}
+
+func (r *RedigoCache) HGetAll(key string) (map[string]interface{}, error) {
+	c := r.getConn()
+	if c == nil {
+		return nil, ErrNoRedis
+	}
+	defer c.Close()
+	reply, err := redigoHGetAll.Do(c, r.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	return hashReply(reply)
+}
+
+// LPush inserts values at the head of the redis list stored at key and
+// refreshes its expiration.
+func (r *RedigoCache) LPush(key string, values ...interface{}) error {
+	args := make([]interface{}, 0, len(values)+2)
+	args = append(args, r.prefix+key, r.expireSec)
+	for _, v := range values {
+		value, err := encodeValueMax(v, r.maxValue)
+		if err != nil {
+			return err
+		}
+		args = append(args, value)
+	}
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	_, err := redigoLPushCache.Do(c, args...)
+	return err
+}
+
+func (r *RedigoCache) RPop(key string) (interface{}, error) {
+	c := r.getConn()
+	if c == nil {
+		return nil, ErrNoRedis
+	}
+	defer c.Close()
+	value, err := c.Do("RPOP", r.prefix+key)
+	if err == redigo.ErrNil || (value == nil && err == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (r *RedigoCache) LRange(key string, start, stop int64) ([]interface{}, error) {
+	c := r.getConn()
+	if c == nil {
+		return nil, ErrNoRedis
+	}
+	defer c.Close()
+	return redigo.Values(c.Do("LRANGE", r.prefix+key, start, stop))
+}
+
+// SAdd adds members to the redis set stored at key and refreshes its
+// expiration.
+func (r *RedigoCache) SAdd(key string, members ...interface{}) error {
+	args := make([]interface{}, 0, len(members)+2)
+	args = append(args, r.prefix+key, r.expireSec)
+	for _, m := range members {
+		member, err := encodeValueMax(m, r.maxValue)
+		if err != nil {
+			return err
+		}
+		args = append(args, member)
+	}
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	_, err := redigoSAddCache.Do(c, args...)
+	return err
+}
+
+func (r *RedigoCache) SIsMember(key string, member interface{}) (bool, error) {
+	c := r.getConn()
+	if c == nil {
+		return false, ErrNoRedis
+	}
+	defer c.Close()
+	return redigo.Bool(c.Do("SISMEMBER", r.prefix+key, encodeValue(member)))
+}
+
+func (r *RedigoCache) SMembers(key string) ([]interface{}, error) {
+	c := r.getConn()
+	if c == nil {
+		return nil, ErrNoRedis
+	}
+	defer c.Close()
+	values, err := redigo.Values(c.Do("SMEMBERS", r.prefix+key))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values, nil
+}
+
+// ZAdd adds members to the redis sorted set stored at key and refreshes its
+// expiration.
+func (r *RedigoCache) ZAdd(key string, members ...Z) error {
+	args, err := zaddArgs([]interface{}{r.prefix + key, r.expireSec}, members, r.maxValue)
+	if err != nil {
+		return err
+	}
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	_, err = redigoZAddCache.Do(c, args...)
+	return err
+}
+
+func (r *RedigoCache) ZRangeByScore(key string, min, max float64) ([]Z, error) {
+	c := r.getConn()
+	if c == nil {
+		return nil, ErrNoRedis
+	}
+	defer c.Close()
+	values, err := redigo.Values(c.Do("ZRANGEBYSCORE", r.prefix+key, scoreArg(min), scoreArg(max), "WITHSCORES"))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Z, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		score, err := redigo.Float64(values[i+1], nil)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, Z{Score: score, Member: values[i]})
+	}
+	return ret, nil
+}
+
+// Tx sends the writes queued by fn between MULTI and EXEC on one
+// connection. A command failing at run time, e.g. on a key of another type,
+// does not undo the others.
+func (r *RedigoCache) Tx(fn func(tx TxCache) error) error {
+	log := &txLog{}
+	if err := fn(log); err != nil {
+		return err
+	}
+	for i, op := range log.ops {
+		if op.del {
+			continue
+		}
+		value, err := encodeValueMax(op.value, r.maxValue)
+		if err != nil {
+			return err
+		}
+		log.ops[i].value = value
+	}
+	c := r.getConn()
+	if c == nil {
+		return ErrNoRedis
+	}
+	defer c.Close()
+	// EVALSHA of an unknown script only fails at EXEC, after the other
+	// commands ran, which the retry runs again
+	return r.withScript(c, r.setScript, func() error {
+		return r.execTx(c, log)
+	})
+}
+
+// execTx runs the ops of log in MULTI and EXEC on c.
+func (r *RedigoCache) execTx(c redigo.Conn, log *txLog) error {
+	if err := c.Send("MULTI"); err != nil {
+		return err
+	}
+	for _, op := range log.ops {
+		var err error
+		switch {
+		case op.del:
+			err = c.Send("DEL", r.prefix+op.key)
+		case op.def:
+			exp, unit := r.defaultExpireArgs()
+			err = r.setScript.SendHash(c, r.prefix+op.key, op.value, exp, unit)
+		default:
+			exp, unit := r.expireArgs(op.ttl)
+			err = r.setScript.SendHash(c, r.prefix+op.key, op.value, exp, unit)
+		}
+		if err != nil {
+			c.Do("DISCARD")
+			return err
+		}
+	}
+	replies, err := redigo.Values(c.Do("EXEC"))
+	if err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		if err, ok := reply.(redigo.Error); ok {
+			return err
+		}
+	}
+	return nil
+}
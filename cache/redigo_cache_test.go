@@ -3,7 +3,10 @@ package cache
 import (
 	"bytes"
 	"context"
+	"math"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -222,3 +225,535 @@ func TestRedigoSetExpire(t *testing.T) {
 		return
 	}
 }
+
+func TestRedigoSetTime(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	v := time.Now()
+	c.Set("test:123", v)
+	data, _ := c.GetTime("test:123")
+	if data == nil || !data.Equal(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRedigoSetDuration(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	v := 1500 * time.Millisecond
+	c.Set("test:123", v)
+	data, _ := c.GetDuration("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRedigoSetUint(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	v := uint64(math.MaxUint64)
+	c.Set("test:123", v)
+	data, _ := c.GetUint("test:123")
+	if data == nil || *data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRedigoSetSlice(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	c.Set("test:123", []string{"a", "b"})
+	strs, _ := c.GetStringSlice("test:123")
+	if len(strs) != 2 || strs[1] != "b" {
+		t.Errorf("%v value error", strs)
+		return
+	}
+	c.Set("test:123", []int64{1, 2})
+	ints, _ := c.GetIntSlice("test:123")
+	if len(ints) != 2 || ints[1] != 2 {
+		t.Errorf("%v value error", ints)
+		return
+	}
+}
+
+func TestRedigoHash(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	key := "test:hash:123"
+	c.Del(key)
+	c.HSet(key, "name", "test")
+	c.HSet(key, "age", 3)
+	data, _ := c.HGet(key, "age")
+	if data == nil || string(data.([]byte)) != "3" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.HSet(key, "data", "x")
+	all, _ := c.HGetAll(key)
+	if len(all) != 3 || all["data"] == nil {
+		t.Errorf("%v value error", all)
+		return
+	}
+	if value, _ := c.Get(key); value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+	c.Set("test:456", 3)
+	if err := c.HSet("test:456", "name", "test"); err != ErrDataType {
+		t.Errorf("%v error", err)
+		return
+	}
+	if _, err := c.HGetAll("test:456"); err != ErrDataType {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := c.GetInt("test:456"); data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+	}
+	c.Del("test:456")
+}
+
+func TestRedigoList(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	key := "test:list:123"
+	c.Del(key)
+	c.LPush(key, 1, 2)
+	c.LPush(key, 3)
+	data, _ := c.LRange(key, 0, -1)
+	if len(data) != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	value, _ := c.RPop(key)
+	if value == nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+}
+
+func TestRedigoSetMembers(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	key := "test:set:123"
+	c.Del(key)
+	c.SAdd(key, 1, 2, 2)
+	ok, _ := c.SIsMember(key, 2)
+	if !ok {
+		t.Errorf("%v value error", ok)
+		return
+	}
+	data, _ := c.SMembers(key)
+	if len(data) != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRedigoZSet(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	key := "test:zset:123"
+	c.Del(key)
+	c.ZAdd(key, Z{Score: 3, Member: "c"}, Z{Score: 1, Member: "a"}, Z{Score: 2, Member: "b"})
+	c.ZAdd(key, Z{Score: 5, Member: "a"})
+	data, _ := c.ZRangeByScore(key, 2, math.Inf(1))
+	if len(data) != 3 || data[2].Score != 5 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRedigoAppend(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	key := "test:123"
+	c.Del(key)
+	c.Append(key, "a")
+	c.Append(key, "b")
+	data, _ := c.GetString(key)
+	if data != "ab" {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRedigoDelByPattern(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	c.Set("test:user:42:name", "test")
+	c.Set("test:user:43:name", "test")
+	c.DelByPattern("test:user:42:*")
+	data, _ := c.Get("test:user:42:name")
+	if data != nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.DelByPrefix("test:user:")
+	data, _ = c.Get("test:user:43:name")
+	if data != nil {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRedigoRename(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	c.Set("test:new", 3)
+	c.Rename("test:new", "test:123")
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRedigoAddrSetInt(t *testing.T) {
+	c := NewRedigoCacheAddr(redisAddr, RedigoWithUsernamePassword("", redisPass), RedigoWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRedigoWarm(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	err := c.Warm(context.Background(), map[string]ValueWithTTL{
+		"test:1": {Value: 1},
+		"test:2": {Value: "2", TTL: time.Second},
+	})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ := c.GetInt("test:1")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.GetInt("test:2")
+	if data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRedigoRangeTTL(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10), RedigoWithKeyPrefix("range:"))
+	c.Set("test:1", 1)
+	c.SetWithTTL("test:2", "2", 0)
+	c.HSet("test:hash", "f", 1)
+	got := map[string]time.Duration{}
+	err := c.RangeTTL(func(key string, value interface{}, ttl time.Duration) bool {
+		got[key+"="+string(value.([]byte))] = ttl
+		return true
+	})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if ttl, ok := got["test:1=1"]; !ok || ttl <= 0 || ttl > 11*time.Second {
+		t.Errorf("%v value error", got)
+		return
+	}
+	if ttl, ok := got["test:2=2"]; !ok || ttl != 0 || len(got) != 2 {
+		t.Errorf("%v value error", got)
+		return
+	}
+}
+
+func TestRedigoMulti(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	err := c.MSet(map[string]interface{}{"test:1": 1, "test:2": "2"})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	values, err := c.MGet("test:1", "test:missing", "test:2")
+	if err != nil || len(values) != 3 {
+		t.Errorf("%v %v error", values, err)
+		return
+	}
+	if string(values[0].([]byte)) != "1" || values[1] != nil || string(values[2].([]byte)) != "2" {
+		t.Errorf("%v value error", values)
+		return
+	}
+	if err := c.MDel("test:1", "test:2"); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	values, _ = c.MGet("test:1", "test:2")
+	if values[0] != nil || values[1] != nil {
+		t.Errorf("%v value error", values)
+		return
+	}
+}
+
+func TestRedigoTx(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10))
+	c.Set("test:del", 1)
+	err := c.Tx(func(tx TxCache) error {
+		tx.Set("test:1", 1)
+		tx.SetWithTTL("test:2", 2, time.Second)
+		tx.Del("test:del")
+		return nil
+	})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ := c.GetInt("test:2")
+	if data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	value, _ := c.Get("test:del")
+	if value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+}
+
+func TestRedigoPing(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t))
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestRedigoSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	getConn := getRedigoT(t)
+	c := NewRedigoCache(getConn, RedigoWithKeyPrefix("svc:"))
+	events := make(chan Event, 1)
+	if err := c.Subscribe(ctx, func(e Event) { events <- e }); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	conn := getConn()
+	conn.Do("PUBLISH", "__keyspace@0__:svc:test:1", "hset")
+	conn.Close()
+	select {
+	case e := <-events:
+		if e.Type != EventSet || e.Key != "test:1" {
+			t.Errorf("%v value error", e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no event error")
+	}
+}
+
+func TestRedigoTimeoutRetry(t *testing.T) {
+	addr, conns := stallingServer(t)
+	c := NewRedigoCacheAddr(addr, RedigoWithTimeout(50*time.Millisecond), RedigoWithRetry(2, 10*time.Millisecond))
+	start := time.Now()
+	if _, err := c.Get("test:123"); err == nil {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("%v timeout error", d)
+	}
+	if n := atomic.LoadInt32(conns); n != 3 {
+		t.Errorf("%v value error", n)
+	}
+}
+
+func TestRedigoMaxValueSize(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10), RedigoWithMaxValueSize(4))
+	key := "test:max"
+	if err := c.Set(key, "abcd"); err != nil {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if err := c.MSet(map[string]interface{}{"test:max2": "a", key: "abcde"}); err != ErrValueTooLarge {
+		t.Errorf("%v value error", err)
+		return
+	}
+	if data, _ := c.GetString(key); data != "abcd" {
+		t.Errorf("%v value error", data)
+	}
+}
+
+func TestRedigoMillisecondTTL(t *testing.T) {
+	getConn := getRedigoT(t)
+	c := NewRedigoCache(getConn, RedigoWithTTL(1500*time.Millisecond), RedigoWithMillisecondTTL())
+	key := "test:ms"
+	if err := c.Set(key, "a"); err != nil {
+		t.Errorf("%v value error", err)
+		return
+	}
+	conn := getConn()
+	defer conn.Close()
+	ttl, err := redigo.Int64(conn.Do("PTTL", key))
+	if err != nil || ttl <= 1000 || ttl > 1650 {
+		t.Errorf("%v %v value error", ttl, err)
+	}
+}
+
+func TestRedigoExpireNotify(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	getConn := getRedigoT(t)
+	keys := make(chan string, 10)
+	NewRedigoCache(getConn, RedigoWithKeyPrefix("svc:"), RedigoExpireNotify(ctx, func(key string, value interface{}) {
+		keys <- key
+	}))
+	conn := getConn()
+	defer conn.Close()
+	// the subscription starts in the background, publish until it is up
+	for i := 0; i < 20; i++ {
+		conn.Do("PUBLISH", "__keyspace@0__:svc:test:1", "hset")
+		conn.Do("PUBLISH", "__keyspace@0__:svc:test:1", "expired")
+		select {
+		case key := <-keys:
+			if key != "test:1" {
+				t.Errorf("%v value error", key)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	t.Errorf("no expire error")
+}
+
+func TestRedigoCount(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithKeyPrefix("count:"))
+	c.DelByPrefix("")
+	c.Set("test:1", 1)
+	c.Set("test:2", 2)
+	if n, err := c.Count(); n != 2 || err != nil {
+		t.Errorf("%v value error:%v", n, err)
+	}
+}
+
+func TestRedigoMemoryUsage(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithKeyPrefix("memory:"))
+	c.DelByPrefix("")
+	for i := 0; i < 200; i++ {
+		c.Set("test:"+strconv.Itoa(i), strings.Repeat("a", 1000))
+	}
+	if n, err := c.MemoryUsage(); n < 200*1000 || err != nil {
+		t.Errorf("%v value error:%v", n, err)
+	}
+}
+
+func TestRedigoScriptFlush(t *testing.T) {
+	getConn := getRedigoT(t)
+	c := NewRedigoCache(getConn, RedigoWithExpire(10))
+	if err := c.MSet(map[string]interface{}{"test:1": 1, "test:2": 2}); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	// a failover or SCRIPT FLUSH drops the scripts loaded
+	conn := getConn()
+	conn.Do("SCRIPT", "FLUSH")
+	conn.Close()
+	if err := c.MSet(map[string]interface{}{"test:1": 3, "test:2": 4}); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	conn = getConn()
+	conn.Do("SCRIPT", "FLUSH")
+	conn.Close()
+	values, err := c.MGet("test:1", "test:2")
+	if err != nil || len(values) != 2 {
+		t.Errorf("%v value error:%v", values, err)
+		return
+	}
+	if data, _ := c.GetInt("test:2"); data == nil || *data != 4 {
+		t.Errorf("%v value error", data)
+	}
+}
+
+func TestRedigoScriptHooks(t *testing.T) {
+	getConn := getRedigoT(t)
+	c := NewRedigoCache(getConn, RedigoWithExpire(10), RedigoWithScriptHooks(ScriptHooks{
+		AfterSet: `redis.call('hset', key, 'by', 'test')`,
+		AfterGet: `if value ~= false then value = value .. '!' end`,
+	}))
+	key := "test:hooks"
+	if err := c.MSet(map[string]interface{}{key: "abc"}); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := c.GetString(key); data != "abc!" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	conn := getConn()
+	defer conn.Close()
+	if by, _ := redigo.String(conn.Do("HGET", key, "by")); by != "test" {
+		t.Errorf("%v value error", by)
+	}
+}
+
+func TestRedigoBinaryKeys(t *testing.T) {
+	c := NewRedigoCache(getRedigoT(t), RedigoWithExpire(10), RedigoWithKeyPrefix("\xfe:"))
+	key := string([]byte{0xff, 0x00, '*', 0x80})
+	c.MSet(map[string]interface{}{key: 1, "\xff\x01": 2})
+	if data, _ := c.GetInt(key); data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.Del(key)
+	values, err := c.MGet(key, "\xff\x01")
+	if err != nil || values[0] != nil || values[1] == nil {
+		t.Errorf("%v value error:%v", values, err)
+	}
+}
+
+func TestRedigoMGetTyped(t *testing.T) {
+	testMGetTyped(t, NewRedigoCache(getRedigoT(t), RedigoWithExpire(10)))
+}
+
+func TestRedigoAbsoluteExpire(t *testing.T) {
+	getConn := getRedigoT(t)
+	c := NewRedigoCache(getConn, RedigoWithExpire(10), RedigoWithAbsoluteExpire())
+	key := "test:absolute"
+	c.Set(key, "abc")
+	conn := getConn()
+	defer conn.Close()
+	conn.Do("EXPIRE", key, 100)
+	if data, _ := c.GetString(key); data != "abc" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if ttl, _ := redigo.Int(conn.Do("TTL", key)); ttl <= 10 {
+		t.Errorf("%v value error", ttl)
+	}
+	c.Del(key)
+}
+
+func TestRedigoAddrReleasesConns(t *testing.T) {
+	getRedigoT(t)
+	c := NewRedigoCacheAddr(redisAddr, RedigoWithUsernamePassword("", redisPass), RedigoWithExpire(10))
+	defer c.Close()
+	for i := 0; i < 10; i++ {
+		c.Set("test:123", i)
+		c.Get("test:123")
+		c.Del("test:123")
+	}
+	pool := c.cache.(*RedigoCache).pool
+	if n := pool.ActiveCount() - pool.IdleCount(); n != 0 {
+		t.Errorf("%v value error", n)
+	}
+}
+
+func TestRedigoRenameFixup(t *testing.T) {
+	getConn := getRedigoT(t)
+	c := NewRedigoCache(getConn, RedigoWithExpire(10))
+	c.Set("test:123", 1)
+	c.Set("test:123", 2)
+	c.Set("test:new", 3)
+	conn := getConn()
+	defer conn.Close()
+	old, _ := redigo.Int(conn.Do("HGET", "test:123", "ver"))
+	c.Rename("test:new", "test:123")
+	if ver, _ := redigo.Int(conn.Do("HGET", "test:123", "ver")); ver <= old {
+		t.Errorf("%v value error", ver)
+	}
+	c.Del("test:123")
+}
+
+func TestRedigoClear(t *testing.T) {
+	getConn := getRedigoT(t)
+	testClear(t, NewRedigoCache(getConn, RedigoWithKeyPrefix("svcA:")), NewRedigoCache(getConn, RedigoWithKeyPrefix("svcB:")))
+}
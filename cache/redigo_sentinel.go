@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// redigoRoleCheckIdle is how long a pooled connection may idle before it is
+// checked to still be the master when borrowed.
+const redigoRoleCheckIdle = time.Second
+
+// redigoSentinel resolves the master address from a list of sentinels.
+type redigoSentinel struct {
+	masterName string
+	dial       func(addr string) (redigo.Conn, error)
+	m          sync.Mutex
+	addrs      []string
+}
+
+// masterAddr asks the sentinels in turn for the master address. The first
+// sentinel that answers is moved to the front so it is asked first next time.
+func (s *redigoSentinel) masterAddr() (string, error) {
+	s.m.Lock()
+	addrs := append([]string{}, s.addrs...)
+	s.m.Unlock()
+	var lastErr error = ErrNoMaster
+	for i, addr := range addrs {
+		conn, err := redigo.Dial("tcp", addr, redigo.DialConnectTimeout(time.Second),
+			redigo.DialReadTimeout(time.Second), redigo.DialWriteTimeout(time.Second))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		master, err := redigo.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", s.masterName))
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(master) != 2 {
+			continue
+		}
+		if i > 0 {
+			s.m.Lock()
+			s.addrs = append([]string{addr}, append(addrs[:i:i], addrs[i+1:]...)...)
+			s.m.Unlock()
+		}
+		return master[0] + ":" + master[1], nil
+	}
+	return "", lastErr
+}
+
+func (s *redigoSentinel) dialMaster() (redigo.Conn, error) {
+	addr, err := s.masterAddr()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := redigoCheckMaster(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// redigoCheckMaster returns ErrNoMaster when conn is not connected to a
+// master, e.g. after a failover demoted it.
+func redigoCheckMaster(conn redigo.Conn) error {
+	role, err := redigo.Values(conn.Do("ROLE"))
+	if err != nil {
+		return err
+	}
+	if len(role) == 0 {
+		return ErrNoMaster
+	}
+	if name, _ := redigo.String(role[0], nil); name != "master" {
+		return ErrNoMaster
+	}
+	return nil
+}
+
+// NewRedigoCacheSentinel returns a cache on the master monitored by the
+// sentinels at sentinelAddrs as masterName. Connections are pooled, each new
+// connection asks the sentinels for the current master and connections that
+// idled are checked to still be the master before use, so the cache follows
+// failovers.
+func NewRedigoCacheSentinel(masterName string, sentinelAddrs []string, opts ...RedigoOption) *Cache {
+	return newRedigoPoolCache(func(c *RedigoCache) *redigo.Pool {
+		s := &redigoSentinel{
+			masterName: masterName,
+			dial:       c.dial,
+			addrs:      append([]string{}, sentinelAddrs...),
+		}
+		return &redigo.Pool{
+			MaxIdle:     3,
+			IdleTimeout: 240 * time.Second,
+			Dial:        s.dialMaster,
+			TestOnBorrow: func(conn redigo.Conn, t time.Time) error {
+				if time.Since(t) < redigoRoleCheckIdle {
+					return nil
+				}
+				return redigoCheckMaster(conn)
+			},
+		}
+	}, opts)
+}
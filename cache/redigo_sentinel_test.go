@@ -0,0 +1,24 @@
+package cache
+
+import (
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+var (
+	sentinelAddrs      = []string{"192.168.3.105:26379"}
+	sentinelMasterName = "mymaster"
+)
+
+func TestRedigoSentinelSetInt(t *testing.T) {
+	c := NewRedigoCacheSentinel(sentinelMasterName, sentinelAddrs, RedigoWithExpire(10),
+		RedigoWithDialOptions(redigo.DialPassword(redisPass)))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
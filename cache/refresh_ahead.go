@@ -0,0 +1,342 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRefreshFactor = 0.8
+
+// refreshLoader is a loader registered for the keys starting with prefix.
+type refreshLoader struct {
+	prefix string
+	loader LoaderFunc
+}
+
+// refreshEntry tracks a loaded key until its next refresh. hot is set by the
+// reads since the last load.
+type refreshEntry struct {
+	timer *time.Timer
+	hot   bool
+}
+
+// RefreshAheadCache loads missing keys with the loader registered for them
+// and reloads them in the background before they expire, at a factor of the
+// ttl, as long as they were read since the last load. Hot keys then never
+// miss, cold ones expire as usual.
+type RefreshAheadCache struct {
+	c       ICache
+	ctx     context.Context
+	ttl     time.Duration
+	factor  float64
+	loaders []refreshLoader
+	errFn   func(key string, err error)
+	flight  flightGroup
+	m       sync.Mutex
+	entries map[string]*refreshEntry
+}
+
+type RefreshOption func(c *RefreshAheadCache)
+
+// RefreshWithLoader loads the keys starting with prefix with loader. The
+// longest matching prefix wins, a full key registers a single key.
+func RefreshWithLoader(prefix string, loader LoaderFunc) RefreshOption {
+	return func(c *RefreshAheadCache) {
+		c.loaders = append(c.loaders, refreshLoader{prefix: prefix, loader: loader})
+	}
+}
+
+// RefreshWithFactor sets the part of the ttl after which a hot key is
+// reloaded, 0.8 by default.
+func RefreshWithFactor(factor float64) RefreshOption {
+	return func(c *RefreshAheadCache) {
+		c.factor = factor
+	}
+}
+
+// RefreshWithErrorFunc reports the background reloads that failed. The key
+// keeps its value until it expires, then a failing load fails the read:
+// StaleCache with StaleWithStaleIfError serves the expired value instead.
+func RefreshWithErrorFunc(fn func(key string, err error)) RefreshOption {
+	return func(c *RefreshAheadCache) {
+		c.errFn = fn
+	}
+}
+
+// NewRefreshAheadCache returns c storing loaded keys with ttl. Background
+// reloads stop when ctx is done.
+func NewRefreshAheadCache(ctx context.Context, c ICache, ttl time.Duration, opts ...RefreshOption) *Cache {
+	rc := &RefreshAheadCache{
+		c:       c,
+		ctx:     ctx,
+		ttl:     ttl,
+		factor:  defaultRefreshFactor,
+		entries: make(map[string]*refreshEntry),
+	}
+	for _, fn := range opts {
+		fn(rc)
+	}
+	go func() {
+		<-ctx.Done()
+		rc.m.Lock()
+		for key, e := range rc.entries {
+			e.timer.Stop()
+			delete(rc.entries, key)
+		}
+		rc.m.Unlock()
+	}()
+	return NewCache(rc)
+}
+
+// loaderFor returns the loader of key, nil when none is registered.
+func (c *RefreshAheadCache) loaderFor(key string) LoaderFunc {
+	return findLoader(c.loaders, key)
+}
+
+// findLoader returns the loader with the longest prefix of key, nil when
+// none matches.
+func findLoader(loaders []refreshLoader, key string) LoaderFunc {
+	var ret refreshLoader
+	for _, l := range loaders {
+		if strings.HasPrefix(key, l.prefix) && (ret.loader == nil || len(l.prefix) > len(ret.prefix)) {
+			ret = l
+		}
+	}
+	return ret.loader
+}
+
+// load calls loader, stores its result and schedules the next refresh.
+// Concurrent loads of key share a single loader call.
+func (c *RefreshAheadCache) load(key string, loader LoaderFunc) error {
+	_, err := c.flight.Do(key, func() (interface{}, error) {
+		value, err := loader(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.c.SetWithTTL(key, value, c.ttl); err != nil {
+			return nil, err
+		}
+		c.schedule(key)
+		return nil, nil
+	})
+	return err
+}
+
+// schedule replaces the pending refresh of key with one at factor of the
+// ttl.
+func (c *RefreshAheadCache) schedule(key string) {
+	if c.ttl <= 0 || c.ctx.Err() != nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.timer.Stop()
+	}
+	e := &refreshEntry{}
+	e.timer = time.AfterFunc(time.Duration(float64(c.ttl)*c.factor), func() {
+		c.refresh(key, e)
+	})
+	c.entries[key] = e
+}
+
+// refresh reloads key when it was read since it was loaded, else stops
+// tracking it.
+func (c *RefreshAheadCache) refresh(key string, e *refreshEntry) {
+	c.m.Lock()
+	if c.entries[key] != e {
+		c.m.Unlock()
+		return
+	}
+	hot := e.hot
+	if !hot {
+		delete(c.entries, key)
+	}
+	c.m.Unlock()
+	if !hot || c.ctx.Err() != nil {
+		return
+	}
+	loader := c.loaderFor(key)
+	if loader == nil {
+		return
+	}
+	if err := c.load(key, loader); err != nil {
+		c.m.Lock()
+		if c.entries[key] == e {
+			delete(c.entries, key)
+		}
+		c.m.Unlock()
+		if c.errFn != nil {
+			c.errFn(key, err)
+		}
+	}
+}
+
+// touch marks key as read.
+func (c *RefreshAheadCache) touch(key string) {
+	c.m.Lock()
+	if e, ok := c.entries[key]; ok {
+		e.hot = true
+	}
+	c.m.Unlock()
+}
+
+// get calls get on the cache, loading key and calling it again on a miss.
+func (c *RefreshAheadCache) get(key string, get func() (interface{}, error)) (interface{}, error) {
+	value, err := get()
+	if err != nil {
+		return nil, err
+	}
+	if !tieredMiss(value) {
+		c.touch(key)
+		return value, nil
+	}
+	loader := c.loaderFor(key)
+	if loader == nil {
+		return nil, nil
+	}
+	if err := c.load(key, loader); err != nil {
+		return nil, err
+	}
+	value, err = get()
+	if err != nil || tieredMiss(value) {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set stores value with the ttl of the cache, a key with a loader is then
+// refreshed like a loaded one.
+func (c *RefreshAheadCache) Set(key string, value interface{}) error {
+	return c.SetWithTTL(key, value, c.ttl)
+}
+
+func (c *RefreshAheadCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+// SetWithTTL stores value with ttl. Only keys stored with the ttl of the
+// cache are refreshed.
+func (c *RefreshAheadCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if err := c.c.SetWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+	if ttl == c.ttl && c.loaderFor(key) != nil {
+		c.schedule(key)
+	} else {
+		c.cancel(key)
+	}
+	return nil
+}
+
+// cancel drops the pending refresh of key.
+func (c *RefreshAheadCache) cancel(key string) {
+	c.m.Lock()
+	if e, ok := c.entries[key]; ok {
+		e.timer.Stop()
+		delete(c.entries, key)
+	}
+	c.m.Unlock()
+}
+
+func (c *RefreshAheadCache) Get(key string) (interface{}, error) {
+	return c.get(key, func() (interface{}, error) { return c.c.Get(key) })
+}
+
+func (c *RefreshAheadCache) GetInt(key string) (*int64, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetInt(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*int64), nil
+}
+
+func (c *RefreshAheadCache) GetUint(key string) (*uint64, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetUint(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*uint64), nil
+}
+
+func (c *RefreshAheadCache) GetFloat(key string) (*float64, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetFloat(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*float64), nil
+}
+
+func (c *RefreshAheadCache) GetString(key string) (string, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetString(key) })
+	if value == nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (c *RefreshAheadCache) GetBytes(key string) ([]byte, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetBytes(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
+
+func (c *RefreshAheadCache) GetBool(key string) (*bool, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetBool(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*bool), nil
+}
+
+func (c *RefreshAheadCache) GetTime(key string) (*time.Time, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetTime(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Time), nil
+}
+
+func (c *RefreshAheadCache) GetDuration(key string) (*time.Duration, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetDuration(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Duration), nil
+}
+
+func (c *RefreshAheadCache) GetStringSlice(key string) ([]string, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetStringSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]string), nil
+}
+
+func (c *RefreshAheadCache) GetIntSlice(key string) ([]int64, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetIntSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]int64), nil
+}
+
+// Ping pings the wrapped cache.
+func (c *RefreshAheadCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.c)
+}
+
+// Close closes the wrapped cache.
+func (c *RefreshAheadCache) Close() error {
+	return closeCache(c.c)
+}
+
+// Del removes key and its pending refresh.
+func (c *RefreshAheadCache) Del(key string) error {
+	c.cancel(key)
+	return c.c.Del(key)
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshAheadLoad(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var n int64
+	c := NewRefreshAheadCache(ctx, NewLocalCache(ctx), time.Second,
+		RefreshWithLoader("test:", func(key string) (interface{}, error) {
+			return atomic.AddInt64(&n, 1), nil
+		}))
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = c.GetInt("other:123")
+	if data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+}
+
+func TestRefreshAheadHotKey(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var n int64
+	c := NewRefreshAheadCache(ctx, NewLocalCache(ctx, LocalWithAbsoluteExpire()), 200*time.Millisecond,
+		RefreshWithLoader("test:", func(key string) (interface{}, error) {
+			return atomic.AddInt64(&n, 1), nil
+		}))
+	c.GetInt("test:123")
+	for i := 0; i < 10; i++ {
+		time.Sleep(50 * time.Millisecond)
+		data, _ := c.GetInt("test:123")
+		if data == nil {
+			t.Errorf("hot key miss error")
+			return
+		}
+	}
+	if atomic.LoadInt64(&n) < 3 {
+		t.Errorf("%v refresh count error", n)
+		return
+	}
+}
+
+func TestRefreshAheadColdKey(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var n int64
+	c := NewRefreshAheadCache(ctx, NewLocalCache(ctx), 100*time.Millisecond,
+		RefreshWithLoader("test:", func(key string) (interface{}, error) {
+			return atomic.AddInt64(&n, 1), nil
+		}))
+	c.GetInt("test:123")
+	time.Sleep(300 * time.Millisecond)
+	if v := atomic.LoadInt64(&n); v != 1 {
+		t.Errorf("%v refresh count error", v)
+		return
+	}
+}
+
+func TestRefreshAheadError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errLoad := errors.New("load error")
+	var n int64
+	errs := make(chan error, 1)
+	c := NewRefreshAheadCache(ctx, NewLocalCache(ctx), 100*time.Millisecond,
+		RefreshWithLoader("test:", func(key string) (interface{}, error) {
+			if atomic.AddInt64(&n, 1) > 1 {
+				return nil, errLoad
+			}
+			return 1, nil
+		}),
+		RefreshWithErrorFunc(func(key string, err error) {
+			errs <- err
+		}))
+	c.GetInt("test:123")
+	c.GetInt("test:123")
+	select {
+	case err := <-errs:
+		if err != errLoad {
+			t.Errorf("%v error", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no refresh error")
+	}
+}
@@ -0,0 +1,355 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRepairInterval = time.Second
+	defaultRepairAttempts = 3
+	repairQueueSize       = 1024
+)
+
+var ErrQuorum = errors.New("write quorum error")
+
+// replicaKey is a key on one replica of a ReplicatedCache.
+type replicaKey struct {
+	replica int
+	key     string
+}
+
+// repairTask retries a write that failed on a replica.
+type repairTask struct {
+	rk       replicaKey
+	seq      uint64
+	attempts int
+	due      time.Time
+	write    func(ICache) error
+}
+
+// ReplicatedCache writes to several caches, e.g. redis clusters in different
+// zones, and reads from the first one that has the key. A write succeeds
+// once a quorum of replicas stored it. The replicas that failed are repaired
+// in the background by retrying the write, unless the key is written again
+// meanwhile.
+type ReplicatedCache struct {
+	replicas []ICache
+	quorum   int
+	interval time.Duration
+	attempts int
+	errFn    func(key string, err error)
+	m        sync.Mutex
+	seq      uint64
+	pending  map[replicaKey]uint64
+	repairs  chan *repairTask
+}
+
+type ReplicatedOption func(c *ReplicatedCache)
+
+// ReplicatedWithQuorum sets how many replicas must store a write for it to
+// succeed, a majority by default.
+func ReplicatedWithQuorum(n int) ReplicatedOption {
+	return func(c *ReplicatedCache) {
+		c.quorum = n
+	}
+}
+
+// ReplicatedWithRepair sets how often and how many times a failed write is
+// retried on its replica, every second and 3 times by default. 0 attempts
+// disables the repair.
+func ReplicatedWithRepair(interval time.Duration, attempts int) ReplicatedOption {
+	return func(c *ReplicatedCache) {
+		c.interval = interval
+		c.attempts = attempts
+	}
+}
+
+// ReplicatedWithErrorFunc reports the writes whose repair gave up, and the
+// repairs dropped because the queue was full.
+func ReplicatedWithErrorFunc(fn func(key string, err error)) ReplicatedOption {
+	return func(c *ReplicatedCache) {
+		c.errFn = fn
+	}
+}
+
+// NewReplicatedCache returns a cache replicated over replicas. Repairs run
+// until ctx is done.
+func NewReplicatedCache(ctx context.Context, replicas []ICache, opts ...ReplicatedOption) *Cache {
+	c := &ReplicatedCache{
+		replicas: replicas,
+		quorum:   len(replicas)/2 + 1,
+		interval: defaultRepairInterval,
+		attempts: defaultRepairAttempts,
+		pending:  make(map[replicaKey]uint64),
+		repairs:  make(chan *repairTask, repairQueueSize),
+	}
+	for _, fn := range opts {
+		fn(c)
+	}
+	if c.quorum > len(replicas) {
+		c.quorum = len(replicas)
+	}
+	go c.runRepair(ctx)
+	return NewCache(c)
+}
+
+// write calls fn on every replica concurrently and returns once quorum
+// replicas succeeded, or ErrQuorum once too many failed.
+func (c *ReplicatedCache) write(key string, fn func(ICache) error) error {
+	c.m.Lock()
+	c.seq++
+	seq := c.seq
+	c.m.Unlock()
+	results := make(chan error, len(c.replicas))
+	for i, r := range c.replicas {
+		go func(i int, r ICache) {
+			err := fn(r)
+			c.settle(&repairTask{rk: replicaKey{replica: i, key: key}, seq: seq, write: fn}, err)
+			results <- err
+		}(i, r)
+	}
+	ok, failed := 0, 0
+	for range c.replicas {
+		if err := <-results; err != nil {
+			failed++
+		} else {
+			ok++
+		}
+		if ok >= c.quorum {
+			return nil
+		}
+		if failed > len(c.replicas)-c.quorum {
+			return ErrQuorum
+		}
+	}
+	return ErrQuorum
+}
+
+// settle records the outcome of a write on a replica, queueing its repair
+// when it failed.
+func (c *ReplicatedCache) settle(task *repairTask, err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if seq, ok := c.pending[task.rk]; ok && seq > task.seq {
+		// a newer write of the key is already being repaired
+		return
+	}
+	if err == nil {
+		delete(c.pending, task.rk)
+		return
+	}
+	if c.attempts <= 0 {
+		return
+	}
+	c.pending[task.rk] = task.seq
+	task.due = time.Now().Add(c.interval)
+	select {
+	case c.repairs <- task:
+	default:
+		delete(c.pending, task.rk)
+		if c.errFn != nil {
+			go c.errFn(task.rk.key, err)
+		}
+	}
+}
+
+func (c *ReplicatedCache) runRepair(ctx context.Context) {
+	for {
+		select {
+		case task := <-c.repairs:
+			timer := time.NewTimer(time.Until(task.due))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			c.repair(task)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// repair retries task unless the key was written again since.
+func (c *ReplicatedCache) repair(task *repairTask) {
+	c.m.Lock()
+	current := c.pending[task.rk] == task.seq
+	c.m.Unlock()
+	if !current {
+		return
+	}
+	err := task.write(c.replicas[task.rk.replica])
+	task.attempts++
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.pending[task.rk] != task.seq {
+		return
+	}
+	if err == nil {
+		delete(c.pending, task.rk)
+		return
+	}
+	if task.attempts < c.attempts {
+		task.due = time.Now().Add(c.interval)
+		select {
+		case c.repairs <- task:
+			return
+		default:
+		}
+	}
+	delete(c.pending, task.rk)
+	if c.errFn != nil {
+		go c.errFn(task.rk.key, err)
+	}
+}
+
+func (c *ReplicatedCache) Set(key string, value interface{}) error {
+	return c.write(key, func(r ICache) error { return r.Set(key, value) })
+}
+
+func (c *ReplicatedCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *ReplicatedCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.write(key, func(r ICache) error { return r.SetWithTTL(key, value, ttl) })
+}
+
+// get calls get on each replica until one has key. Failing replicas are
+// skipped, the error of the last one is returned when none has key.
+func (c *ReplicatedCache) get(get func(ICache) (interface{}, error)) (interface{}, error) {
+	var lastErr error
+	for _, r := range c.replicas {
+		value, err := get(r)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if !tieredMiss(value) {
+			return value, nil
+		}
+	}
+	return nil, lastErr
+}
+
+func (c *ReplicatedCache) Get(key string) (interface{}, error) {
+	return c.get(func(r ICache) (interface{}, error) { return r.Get(key) })
+}
+
+func (c *ReplicatedCache) GetInt(key string) (*int64, error) {
+	value, err := c.get(func(r ICache) (interface{}, error) { return r.GetInt(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*int64), nil
+}
+
+func (c *ReplicatedCache) GetUint(key string) (*uint64, error) {
+	value, err := c.get(func(r ICache) (interface{}, error) { return r.GetUint(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*uint64), nil
+}
+
+func (c *ReplicatedCache) GetFloat(key string) (*float64, error) {
+	value, err := c.get(func(r ICache) (interface{}, error) { return r.GetFloat(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*float64), nil
+}
+
+func (c *ReplicatedCache) GetString(key string) (string, error) {
+	value, err := c.get(func(r ICache) (interface{}, error) { return r.GetString(key) })
+	if value == nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (c *ReplicatedCache) GetBytes(key string) ([]byte, error) {
+	value, err := c.get(func(r ICache) (interface{}, error) { return r.GetBytes(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
+
+func (c *ReplicatedCache) GetBool(key string) (*bool, error) {
+	value, err := c.get(func(r ICache) (interface{}, error) { return r.GetBool(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*bool), nil
+}
+
+func (c *ReplicatedCache) GetTime(key string) (*time.Time, error) {
+	value, err := c.get(func(r ICache) (interface{}, error) { return r.GetTime(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Time), nil
+}
+
+func (c *ReplicatedCache) GetDuration(key string) (*time.Duration, error) {
+	value, err := c.get(func(r ICache) (interface{}, error) { return r.GetDuration(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Duration), nil
+}
+
+func (c *ReplicatedCache) GetStringSlice(key string) ([]string, error) {
+	value, err := c.get(func(r ICache) (interface{}, error) { return r.GetStringSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]string), nil
+}
+
+func (c *ReplicatedCache) GetIntSlice(key string) ([]int64, error) {
+	value, err := c.get(func(r ICache) (interface{}, error) { return r.GetIntSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]int64), nil
+}
+
+// Ping pings every replica and fails when fewer than the quorum answer, the
+// writes failing then.
+func (c *ReplicatedCache) Ping(ctx context.Context) error {
+	var firstErr error
+	failed := 0
+	for _, r := range c.replicas {
+		if err := ping(ctx, r); err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if failed > len(c.replicas)-c.quorum {
+		return firstErr
+	}
+	return nil
+}
+
+// Close closes every replica, returning the first error.
+func (c *ReplicatedCache) Close() error {
+	var firstErr error
+	for _, r := range c.replicas {
+		if err := closeCache(r); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (c *ReplicatedCache) Del(key string) error {
+	return c.write(key, func(r ICache) error { return r.Del(key) })
+}
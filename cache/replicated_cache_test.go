@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errReplicaDown = errors.New("replica down error")
+
+// flakyCache fails every write while down is set.
+type flakyCache struct {
+	ICache
+	down int32
+}
+
+func (c *flakyCache) Set(key string, value interface{}) error {
+	if atomic.LoadInt32(&c.down) == 1 {
+		return errReplicaDown
+	}
+	return c.ICache.Set(key, value)
+}
+
+func (c *flakyCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if atomic.LoadInt32(&c.down) == 1 {
+		return errReplicaDown
+	}
+	return c.ICache.SetWithTTL(key, value, ttl)
+}
+
+func (c *flakyCache) Del(key string) error {
+	if atomic.LoadInt32(&c.down) == 1 {
+		return errReplicaDown
+	}
+	return c.ICache.Del(key)
+}
+
+func TestReplicatedSet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r1 := NewLocalCache(ctx, LocalWithExpire(10))
+	r2 := NewLocalCache(ctx, LocalWithExpire(10))
+	c := NewReplicatedCache(ctx, []ICache{r1, r2})
+	c.Set("test:123", 3)
+	for _, r := range []*Cache{r1, r2} {
+		data, _ := r.GetInt("test:123")
+		if data == nil || *data != 3 {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+	c.Del("test:123")
+	data, _ := c.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+}
+
+func TestReplicatedQuorum(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r1 := &flakyCache{ICache: NewLocalCache(ctx, LocalWithExpire(10)), down: 1}
+	r2 := NewLocalCache(ctx, LocalWithExpire(10))
+	r3 := NewLocalCache(ctx, LocalWithExpire(10))
+	c := NewReplicatedCache(ctx, []ICache{r1, r2, r3}, ReplicatedWithRepair(time.Hour, 1))
+	if err := c.Set("test:123", 3); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c = NewReplicatedCache(ctx, []ICache{r1, r2, r3}, ReplicatedWithQuorum(3), ReplicatedWithRepair(time.Hour, 1))
+	if err := c.Set("test:123", 3); err != ErrQuorum {
+		t.Errorf("%v error", err)
+		return
+	}
+}
+
+func TestReplicatedRepair(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r1 := &flakyCache{ICache: NewLocalCache(ctx, LocalWithExpire(10)), down: 1}
+	r2 := NewLocalCache(ctx, LocalWithExpire(10))
+	c := NewReplicatedCache(ctx, []ICache{r1, r2}, ReplicatedWithQuorum(1), ReplicatedWithRepair(50*time.Millisecond, 3))
+	c.Set("test:123", 3)
+	c.Set("test:1", 1)
+	time.Sleep(10 * time.Millisecond)
+	atomic.StoreInt32(&r1.down, 0)
+	// a newer write lands before the repair, which must not overwrite it
+	r1.Set("test:1", 2)
+	time.Sleep(150 * time.Millisecond)
+	data, _ := r1.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v repaired value error", data)
+		return
+	}
+	data, _ = r1.GetInt("test:1")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestReplicatedRepairGiveUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r1 := &flakyCache{ICache: NewLocalCache(ctx, LocalWithExpire(10)), down: 1}
+	r2 := NewLocalCache(ctx, LocalWithExpire(10))
+	errs := make(chan error, 1)
+	c := NewReplicatedCache(ctx, []ICache{r1, r2}, ReplicatedWithQuorum(1), ReplicatedWithRepair(10*time.Millisecond, 2),
+		ReplicatedWithErrorFunc(func(key string, err error) {
+			errs <- err
+		}))
+	c.Set("test:123", 3)
+	select {
+	case err := <-errs:
+		if err != errReplicaDown {
+			t.Errorf("%v error", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no repair error")
+	}
+}
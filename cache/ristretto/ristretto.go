@@ -0,0 +1,133 @@
+// Package ristretto stores the entries of a cache.Cache in a Ristretto
+// cache.
+package ristretto
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	dgraph "github.com/dgraph-io/ristretto"
+
+	"mcache/cache"
+)
+
+// DefaultRistrettoCost is the cost of entries stored without SetWithCost.
+const DefaultRistrettoCost = 1
+
+type ristrettoItem struct {
+	value interface{}
+	ttl   time.Duration
+	cost  int64
+}
+
+// RistrettoCache stores values in a Ristretto cache, which admits and evicts
+// entries by cost. Ristretto applies writes asynchronously and may drop them
+// under contention, so a Get right after Set can miss. Reads do not extend
+// the expiration, storing the entry again on each read could overwrite a
+// concurrent Set with the value read, or be dropped.
+type RistrettoCache struct {
+	cache.ValueGetters
+	expire time.Duration
+	prefix string
+	client *dgraph.Cache
+	m      sync.Mutex
+	r      *rand.Rand
+}
+
+type RistrettoOption func(c *RistrettoCache)
+
+func RistrettoWithExpire(expireSecond int) RistrettoOption {
+	return func(c *RistrettoCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func RistrettoWithTTL(ttl time.Duration) RistrettoOption {
+	return func(c *RistrettoCache) {
+		c.expire = ttl
+	}
+}
+
+// RistrettoWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func RistrettoWithKeyPrefix(prefix string) RistrettoOption {
+	return func(c *RistrettoCache) {
+		c.prefix = prefix
+	}
+}
+
+func NewRistrettoCache(client *dgraph.Cache, opts ...RistrettoOption) *cache.Cache {
+	c := &RistrettoCache{
+		client: client,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ValueGetters = cache.NewValueGetters(c.Get)
+	for _, fn := range opts {
+		fn(c)
+	}
+	return cache.NewCache(c)
+}
+
+func (c *RistrettoCache) Set(key string, value interface{}) error {
+	return c.SetWithCost(key, value, DefaultRistrettoCost)
+}
+
+func (c *RistrettoCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *RistrettoCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.set(key, &ristrettoItem{value: value, ttl: ttl, cost: DefaultRistrettoCost})
+}
+
+// SetWithCost stores value with the default expiration, charging cost
+// against the cache's MaxCost.
+func (c *RistrettoCache) SetWithCost(key string, value interface{}, cost int64) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.set(key, &ristrettoItem{value: value, ttl: ttl, cost: cost})
+}
+
+func (c *RistrettoCache) set(key string, item *ristrettoItem) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	c.client.SetWithTTL(c.prefix+key, item, item.cost, item.ttl)
+	return nil
+}
+
+func (c *RistrettoCache) Get(key string) (interface{}, error) {
+	if c.client == nil {
+		return nil, cache.ErrNoClient
+	}
+	value, ok := c.client.Get(c.prefix + key)
+	if !ok {
+		return nil, nil
+	}
+	item, ok := value.(*ristrettoItem)
+	if !ok {
+		return nil, cache.ErrDataType
+	}
+	return item.value, nil
+}
+
+// Close closes the ristretto client, stopping its goroutines.
+func (c *RistrettoCache) Close() error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	c.client.Close()
+	return nil
+}
+
+func (c *RistrettoCache) Del(key string) error {
+	if c.client == nil {
+		return cache.ErrNoClient
+	}
+	c.client.Del(c.prefix + key)
+	return nil
+}
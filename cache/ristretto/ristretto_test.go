@@ -0,0 +1,89 @@
+package ristretto
+
+import (
+	"testing"
+	"time"
+
+	dgraph "github.com/dgraph-io/ristretto"
+)
+
+func getRistrettoT(t *testing.T) *dgraph.Cache {
+	c, err := dgraph.NewCache(&dgraph.Config{
+		NumCounters: 1e4,
+		MaxCost:     1 << 20,
+		BufferItems: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRistrettoSetInt(t *testing.T) {
+	client := getRistrettoT(t)
+	c := NewRistrettoCache(client, RistrettoWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	client.Wait()
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestRistrettoSetCost(t *testing.T) {
+	client := getRistrettoT(t)
+	c := NewRistrettoCache(client, RistrettoWithExpire(10))
+	v := "test"
+	c.SetWithCost("test:123", v, 100)
+	client.Wait()
+	data, _ := c.GetString("test:123")
+	if data != v {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if err := c.SetWithCost("test:456", v, 1<<21); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	client.Wait()
+	value, _ := c.Get("test:456")
+	if value != nil {
+		t.Errorf("%v value error", value)
+		return
+	}
+}
+
+func TestRistrettoDel(t *testing.T) {
+	client := getRistrettoT(t)
+	c := NewRistrettoCache(client, RistrettoWithExpire(10))
+	key := "test:123"
+	c.Set(key, true)
+	client.Wait()
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestRistrettoExpire(t *testing.T) {
+	client := getRistrettoT(t)
+	c := NewRistrettoCache(client, RistrettoWithTTL(200*time.Millisecond))
+	key := "test:123"
+	c.Set(key, true)
+	client.Wait()
+	data, _ := c.GetBool(key)
+	if data == nil || !*data {
+		t.Errorf("%v value error", data)
+		return
+	}
+	time.Sleep(300 * time.Millisecond)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
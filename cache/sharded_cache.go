@@ -0,0 +1,334 @@
+package cache
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// defaultVirtualNodes is how many points a shard has on the hash ring by
+// default, enough for an even spread over a few shards.
+const defaultVirtualNodes = 160
+
+// ringHash places s on the hash ring, FNV-1a followed by the murmur3
+// finalizer, as FNV alone clusters similar names such as addresses.
+func ringHash(s string) uint32 {
+	f := fnv.New32a()
+	f.Write([]byte(s))
+	h := f.Sum32()
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// ringPoint is a virtual node of a shard on the hash ring.
+type ringPoint struct {
+	hash  uint32
+	shard int
+}
+
+// ShardedCache spreads keys over independent caches, e.g. redis servers
+// without cluster mode, with a consistent hash ring, so adding or removing a
+// shard only moves the keys of its neighbours. Keys with a hash tag like
+// "{user1000}.a" are hashed on the tag as in Redis Cluster, so they live on
+// the same shards. With replicas each key is written to several successive
+// shards of the ring and read from the first one that has it.
+type ShardedCache struct {
+	names        []string
+	shards       []ICache
+	ring         []ringPoint
+	virtualNodes int
+	replicas     int
+	replicaReads bool
+}
+
+type ShardedOption func(c *ShardedCache)
+
+// ShardedWithVirtualNodes sets how many points each shard has on the ring,
+// 160 by default. More points spread the keys more evenly.
+func ShardedWithVirtualNodes(n int) ShardedOption {
+	return func(c *ShardedCache) {
+		c.virtualNodes = n
+	}
+}
+
+// ShardedWithReplicas writes each key to n shards, so it is still served
+// when one of them is down, 1 by default.
+func ShardedWithReplicas(n int) ShardedOption {
+	return func(c *ShardedCache) {
+		c.replicas = n
+	}
+}
+
+// ShardedWithReplicaReads reads a key from a random one of its replicas
+// first instead of its primary shard, spreading the reads of hot keys.
+func ShardedWithReplicaReads() ShardedOption {
+	return func(c *ShardedCache) {
+		c.replicaReads = true
+	}
+}
+
+// NewShardedCache returns a cache spreading keys over shards, keyed by a
+// stable name such as the server address. Only the names place the shards
+// on the ring, so the same names map the same keys in every process.
+func NewShardedCache(shards map[string]ICache, opts ...ShardedOption) *Cache {
+	c := &ShardedCache{
+		virtualNodes: defaultVirtualNodes,
+		replicas:     1,
+	}
+	for _, fn := range opts {
+		fn(c)
+	}
+	for name := range shards {
+		c.names = append(c.names, name)
+	}
+	sort.Strings(c.names)
+	for i, name := range c.names {
+		c.shards = append(c.shards, shards[name])
+		for v := 0; v < c.virtualNodes; v++ {
+			c.ring = append(c.ring, ringPoint{
+				hash:  ringHash(name + "#" + strconv.Itoa(v)),
+				shard: i,
+			})
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool {
+		return c.ring[i].hash < c.ring[j].hash
+	})
+	if c.replicas < 1 {
+		c.replicas = 1
+	}
+	if c.replicas > len(c.shards) {
+		c.replicas = len(c.shards)
+	}
+	return NewCache(c)
+}
+
+// point returns the index of the first ring point at or after the hash of
+// key. The ring must not be empty.
+func (c *ShardedCache) point(key string) int {
+	h := ringHash(hashTag(key))
+	i := sort.Search(len(c.ring), func(i int) bool {
+		return c.ring[i].hash >= h
+	})
+	return i % len(c.ring)
+}
+
+// owners returns the shards of key, its primary shard first, then the next
+// distinct shards clockwise on the ring.
+func (c *ShardedCache) owners(key string) []ICache {
+	if len(c.ring) == 0 {
+		return nil
+	}
+	i := c.point(key)
+	ret := make([]ICache, 0, c.replicas)
+	seen := make(map[int]bool, c.replicas)
+	for n := 0; n < len(c.ring) && len(ret) < c.replicas; n++ {
+		p := c.ring[(i+n)%len(c.ring)]
+		if !seen[p.shard] {
+			seen[p.shard] = true
+			ret = append(ret, c.shards[p.shard])
+		}
+	}
+	return ret
+}
+
+// Shard returns the name of the primary shard of key.
+func (c *ShardedCache) Shard(key string) string {
+	if len(c.ring) == 0 {
+		return ""
+	}
+	return c.names[c.ring[c.point(key)].shard]
+}
+
+// write calls fn on every shard of key, returning the first error.
+func (c *ShardedCache) write(key string, fn func(ICache) error) error {
+	owners := c.owners(key)
+	if len(owners) == 0 {
+		return ErrNoClient
+	}
+	var ret error
+	for _, s := range owners {
+		if err := fn(s); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+func (c *ShardedCache) Set(key string, value interface{}) error {
+	return c.write(key, func(s ICache) error { return s.Set(key, value) })
+}
+
+func (c *ShardedCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *ShardedCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.write(key, func(s ICache) error { return s.SetWithTTL(key, value, ttl) })
+}
+
+// get calls get on the shards of key until one has it. Failing shards are
+// skipped, the error of the last one is returned when none has key.
+func (c *ShardedCache) get(key string, get func(ICache) (interface{}, error)) (interface{}, error) {
+	owners := c.owners(key)
+	if len(owners) == 0 {
+		return nil, ErrNoClient
+	}
+	first := 0
+	if c.replicaReads && len(owners) > 1 {
+		first = rand.Intn(len(owners))
+	}
+	var lastErr error
+	for n := range owners {
+		value, err := get(owners[(first+n)%len(owners)])
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if !tieredMiss(value) {
+			return value, nil
+		}
+	}
+	return nil, lastErr
+}
+
+func (c *ShardedCache) Get(key string) (interface{}, error) {
+	return c.get(key, func(s ICache) (interface{}, error) { return s.Get(key) })
+}
+
+func (c *ShardedCache) GetInt(key string) (*int64, error) {
+	value, err := c.get(key, func(s ICache) (interface{}, error) { return s.GetInt(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*int64), nil
+}
+
+func (c *ShardedCache) GetUint(key string) (*uint64, error) {
+	value, err := c.get(key, func(s ICache) (interface{}, error) { return s.GetUint(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*uint64), nil
+}
+
+func (c *ShardedCache) GetFloat(key string) (*float64, error) {
+	value, err := c.get(key, func(s ICache) (interface{}, error) { return s.GetFloat(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*float64), nil
+}
+
+func (c *ShardedCache) GetString(key string) (string, error) {
+	value, err := c.get(key, func(s ICache) (interface{}, error) { return s.GetString(key) })
+	if value == nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (c *ShardedCache) GetBytes(key string) ([]byte, error) {
+	value, err := c.get(key, func(s ICache) (interface{}, error) { return s.GetBytes(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
+
+func (c *ShardedCache) GetBool(key string) (*bool, error) {
+	value, err := c.get(key, func(s ICache) (interface{}, error) { return s.GetBool(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*bool), nil
+}
+
+func (c *ShardedCache) GetTime(key string) (*time.Time, error) {
+	value, err := c.get(key, func(s ICache) (interface{}, error) { return s.GetTime(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Time), nil
+}
+
+func (c *ShardedCache) GetDuration(key string) (*time.Duration, error) {
+	value, err := c.get(key, func(s ICache) (interface{}, error) { return s.GetDuration(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Duration), nil
+}
+
+func (c *ShardedCache) GetStringSlice(key string) ([]string, error) {
+	value, err := c.get(key, func(s ICache) (interface{}, error) { return s.GetStringSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]string), nil
+}
+
+func (c *ShardedCache) GetIntSlice(key string) ([]int64, error) {
+	value, err := c.get(key, func(s ICache) (interface{}, error) { return s.GetIntSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]int64), nil
+}
+
+// Ping pings every shard, the keys of a shard down are not served.
+func (c *ShardedCache) Ping(ctx context.Context) error {
+	for _, shard := range c.shards {
+		if err := ping(ctx, shard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every shard, returning the first error.
+func (c *ShardedCache) Close() error {
+	var firstErr error
+	for _, shard := range c.shards {
+		if err := closeCache(shard); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (c *ShardedCache) Del(key string) error {
+	return c.write(key, func(s ICache) error { return s.Del(key) })
+}
+
+// DelByPrefix deletes the keys starting with prefix from every shard.
+func (c *ShardedCache) DelByPrefix(prefix string) error {
+	return c.each(func(d IDelPattern) error { return d.DelByPrefix(prefix) })
+}
+
+// DelByPattern deletes the keys matching pattern from every shard.
+func (c *ShardedCache) DelByPattern(pattern string) error {
+	return c.each(func(d IDelPattern) error { return d.DelByPattern(pattern) })
+}
+
+// each calls fn on every shard, returning ErrNotSupported when a shard can
+// not delete by pattern.
+func (c *ShardedCache) each(fn func(IDelPattern) error) error {
+	for _, s := range c.shards {
+		d, ok := s.(IDelPattern)
+		if !ok {
+			return ErrNotSupported
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func newShards(ctx context.Context, n int) map[string]ICache {
+	shards := make(map[string]ICache, n)
+	for i := 0; i < n; i++ {
+		shards["10.0.0."+strconv.Itoa(i)+":6379"] = NewLocalCache(ctx, LocalWithExpire(10))
+	}
+	return shards
+}
+
+func TestShardedSet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	shards := newShards(ctx, 3)
+	c := NewShardedCache(shards)
+	sc := c.cache.(*ShardedCache)
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		key := "test:" + strconv.Itoa(i)
+		c.Set(key, i)
+		name := sc.Shard(key)
+		counts[name]++
+		data, _ := shards[name].GetInt(key)
+		if data == nil || *data != int64(i) {
+			t.Errorf("%v value error", data)
+			return
+		}
+		data, _ = c.GetInt(key)
+		if data == nil || *data != int64(i) {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+	for name, n := range counts {
+		if n < 50 {
+			t.Errorf("%v %v spread error", name, n)
+		}
+	}
+	if sc.Shard("{user1000}.a") != sc.Shard("{user1000}.b") {
+		t.Errorf("hash tag error")
+	}
+}
+
+func TestShardedRebalance(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	shards := newShards(ctx, 4)
+	before := NewShardedCache(shards).cache.(*ShardedCache)
+	delete(shards, "10.0.0.3:6379")
+	after := NewShardedCache(shards).cache.(*ShardedCache)
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := "test:" + strconv.Itoa(i)
+		if b := before.Shard(key); b != "10.0.0.3:6379" && b != after.Shard(key) {
+			moved++
+		}
+	}
+	if moved != 0 {
+		t.Errorf("%v moved error", moved)
+	}
+}
+
+func TestShardedReplicas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	shards := newShards(ctx, 3)
+	c := NewShardedCache(shards, ShardedWithReplicas(2), ShardedWithReplicaReads())
+	c.Set("test:123", 3)
+	n := 0
+	for _, s := range shards {
+		if data, _ := s.GetInt("test:123"); data != nil {
+			n++
+		}
+	}
+	if n != 2 {
+		t.Errorf("%v replicas error", n)
+		return
+	}
+	// the key survives the loss of its primary
+	shards[c.cache.(*ShardedCache).Shard("test:123")].Del("test:123")
+	for i := 0; i < 10; i++ {
+		data, _ := c.GetInt("test:123")
+		if data == nil || *data != 3 {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+	c.Del("test:123")
+	data, _ := c.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v value error", *data)
+	}
+}
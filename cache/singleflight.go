@@ -0,0 +1,42 @@
+package cache
+
+import "sync"
+
+// flightCall is an in-flight or completed load for a single key.
+type flightCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// flightGroup de-duplicates concurrent loads of the same key so that only
+// one loader runs and every caller waiting on that key shares its result.
+type flightGroup struct {
+	mtx   sync.Mutex
+	calls map[string]*flightCall
+}
+
+func (g *flightGroup) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mtx.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mtx.Unlock()
+		call.wg.Wait()
+		return call.value, call.err
+	}
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mtx.Unlock()
+
+	defer func() {
+		g.mtx.Lock()
+		delete(g.calls, key)
+		g.mtx.Unlock()
+		call.wg.Done()
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// snapshotVersion is the version of the snapshot format written by Save.
+const snapshotVersion = 1
+
+var ErrSnapshot = errors.New("snapshot format error")
+
+func init() {
+	// the value types LocalCache itself stores, builtin types are known to gob
+	gob.Register(time.Time{})
+	gob.Register(time.Duration(0))
+	gob.Register(localHash{})
+	gob.Register(localList{})
+	gob.Register(localSet{})
+	gob.Register(localZSet{})
+}
+
+// snapshotHeader starts a snapshot.
+type snapshotHeader struct {
+	Version int
+}
+
+// snapshotItem is an entry of a snapshot. ExpireTime is in unix nanoseconds,
+// 0 for entries that do not expire, and Cost the size of the value counted
+// against LocalWithMaxBytes. Del marks a deleted key in an append log.
+type snapshotItem struct {
+	Key        string
+	Value      interface{}
+	Expire     time.Duration
+	ExpireTime int64
+	Cost       int64
+	Del        bool
+}
+
+// snapshotOf returns the snapshot item of data stored at the prefixed key
+// k.
+func (c *LocalCache) snapshotOf(k string, data *cacheItem) snapshotItem {
+	item := snapshotItem{
+		Key:    strings.TrimPrefix(k, c.prefix),
+		Value:  data.value,
+		Expire: data.expire,
+		Cost:   data.size - int64(len(k)) - localItemOverhead,
+	}
+	item.ExpireTime = atomic.LoadInt64(&data.expireAt)
+	return item
+}
+
+// snapshot returns the unexpired entries. Must be called with c.m held.
+func (c *LocalCache) snapshot() []snapshotItem {
+	now := c.clk.Now()
+	items := make([]snapshotItem, 0, len(c.cache))
+	for k, data := range c.cache {
+		if data.expired(now) {
+			continue
+		}
+		items = append(items, c.snapshotOf(k, data))
+	}
+	return items
+}
+
+// writeSnapshot encodes the header and items to enc.
+func writeSnapshot(enc *gob.Encoder, items []snapshotItem) error {
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion}); err != nil {
+		return err
+	}
+	for i := range items {
+		if err := enc.Encode(&items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes the unexpired entries with their expiration to w, in a gob
+// stream read by Load. Keys are written without the cache prefix. Values of
+// types other than the builtin ones, time.Time and time.Duration must be
+// registered with gob.Register.
+func (c *LocalCache) Save(w io.Writer) error {
+	c.m.RLock()
+	items := c.snapshot()
+	c.m.RUnlock()
+	return writeSnapshot(gob.NewEncoder(w), items)
+}
+
+// Load adds the entries written by Save to the cache, keeping their
+// expiration and skipping the ones expired since. Entries already in the
+// cache are replaced by the loaded ones of the same key. The deletes of an
+// append log are applied too.
+func (c *LocalCache) Load(r io.Reader) error {
+	return c.load(r, false)
+}
+
+// load reads a snapshot or, with replay, an append log, where a later
+// expired or oversized entry also drops the earlier ones of its key.
+func (c *LocalCache) load(r io.Reader, replay bool) error {
+	dec := gob.NewDecoder(r)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return err
+	}
+	if header.Version != snapshotVersion {
+		return ErrSnapshot
+	}
+	for {
+		var item snapshotItem
+		if err := dec.Decode(&item); err != nil {
+			// a crash may cut the last record of a log
+			if err == io.EOF || (replay && err == io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return err
+		}
+		k := c.prefix + item.Key
+		data := &cacheItem{
+			expireAt: item.ExpireTime,
+			expire:   item.Expire,
+			value:    item.Value,
+			size:     item.Cost + int64(len(k)) + localItemOverhead,
+		}
+		skip := data.expired(c.clk.Now()) ||
+			(c.maxBytes > 0 && data.size > c.maxBytes)
+		c.m.Lock()
+		if item.Del || (skip && replay) {
+			c.remove(k)
+		} else if !skip {
+			c.put(k, data)
+		}
+		c.m.Unlock()
+	}
+}
+
+// SaveFile saves the cache to path, through a temporary file renamed over
+// path so a crash never leaves a partial snapshot.
+func (c *Cache) SaveFile(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = c.Save(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// LoadFile loads the snapshot saved to path, a missing file loads nothing.
+func (c *Cache) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.Load(bufio.NewReader(f))
+}
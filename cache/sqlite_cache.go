@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	DefaultSQLiteTable = "mcache"
+)
+
+// SQLiteCache stores encoded values in a single SQLite table, for embedded
+// and edge deployments without a cache server. db must be opened with a
+// sqlite driver, e.g. github.com/mattn/go-sqlite3. Expired rows are ignored
+// on read and removed by a background sweeper like LocalCache's.
+type SQLiteCache struct {
+	ByteGetters
+	expire   time.Duration
+	absolute bool
+	vacuum   bool
+	prefix   string
+	table    string
+	db       *sql.DB
+	m        sync.Mutex
+	r        *rand.Rand
+	bg       Background
+}
+
+type SQLiteOption func(c *SQLiteCache)
+
+func SQLiteWithExpire(expireSecond int) SQLiteOption {
+	return func(c *SQLiteCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func SQLiteWithTTL(ttl time.Duration) SQLiteOption {
+	return func(c *SQLiteCache) {
+		c.expire = ttl
+	}
+}
+
+// SQLiteWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads.
+func SQLiteWithAbsoluteExpire() SQLiteOption {
+	return func(c *SQLiteCache) {
+		c.absolute = true
+	}
+}
+
+// SQLiteWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func SQLiteWithKeyPrefix(prefix string) SQLiteOption {
+	return func(c *SQLiteCache) {
+		c.prefix = prefix
+	}
+}
+
+// SQLiteWithTable sets the table holding the entries, DefaultSQLiteTable by
+// default.
+func SQLiteWithTable(table string) SQLiteOption {
+	return func(c *SQLiteCache) {
+		c.table = table
+	}
+}
+
+// SQLiteWithVacuum makes the sweeper VACUUM the database after it removed
+// expired rows, returning the freed pages to the file system.
+func SQLiteWithVacuum() SQLiteOption {
+	return func(c *SQLiteCache) {
+		c.vacuum = true
+	}
+}
+
+// NewSQLiteCache returns a cache stored in db, creating its table when
+// missing. The sweeper runs until ctx is done.
+func NewSQLiteCache(ctx context.Context, db *sql.DB, opts ...SQLiteOption) (*Cache, error) {
+	c := &SQLiteCache{
+		table: DefaultSQLiteTable,
+		db:    db,
+		r:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.ByteGetters = ByteGetters{get: c.Get}
+	for _, fn := range opts {
+		fn(c)
+	}
+	if db == nil {
+		return nil, ErrNoClient
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+		key TEXT PRIMARY KEY,
+		value BLOB NOT NULL,
+		expire_at INTEGER NOT NULL,
+		ttl INTEGER NOT NULL
+	)`, c.table))
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (expire_at)`,
+		c.table+"_expire_at", c.table))
+	if err != nil {
+		return nil, err
+	}
+	c.bg.Sweep(ctx, c.expire, c.deleteExpired)
+	return NewCache(c), nil
+}
+
+func (c *SQLiteCache) Set(key string, value interface{}) error {
+	ttl := c.expire
+	if ttl > 0 {
+		c.m.Lock()
+		ttl += time.Duration(c.r.Int63n(int64(ttl/10) + 1))
+		c.m.Unlock()
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c *SQLiteCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *SQLiteCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	_, err := c.db.Exec(fmt.Sprintf(`INSERT OR REPLACE INTO %q (key, value, expire_at, ttl)
+		VALUES (?, ?, ?, ?)`, c.table), c.prefix+key, EncodeBytes(value), sqlExpireAt(ttl), int64(ttl))
+	return err
+}
+
+// sqlExpireAt returns the unix nanosecond expiration for ttl, 0 never
+// expires.
+func sqlExpireAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
+
+// Get returns the value of key, extending its expiration unless the cache
+// uses absolute expiration.
+func (c *SQLiteCache) Get(key string) (interface{}, error) {
+	var value []byte
+	var ttl int64
+	err := c.db.QueryRow(fmt.Sprintf(`SELECT value, ttl FROM %q
+		WHERE key = ? AND (expire_at = 0 OR expire_at > ?)`, c.table),
+		c.prefix+key, time.Now().UnixNano()).Scan(&value, &ttl)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 && !c.absolute {
+		_, err = c.db.Exec(fmt.Sprintf(`UPDATE %q SET expire_at = ? WHERE key = ?`, c.table),
+			sqlExpireAt(time.Duration(ttl)), c.prefix+key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, nil
+}
+
+func (c *SQLiteCache) Del(key string) error {
+	_, err := c.db.Exec(fmt.Sprintf(`DELETE FROM %q WHERE key = ?`, c.table), c.prefix+key)
+	return err
+}
+
+// Clear deletes the rows of the keys under the key prefix.
+func (c *SQLiteCache) Clear() error {
+	_, err := c.db.Exec(fmt.Sprintf(`DELETE FROM %q WHERE substr(key, 1, ?) = ?`, c.table),
+		utf8.RuneCountInString(c.prefix), c.prefix)
+	return err
+}
+
+// Close stops the expiration sweeper, waiting for a running sweep and
+// vacuum. The caller still owns db and closes it.
+func (c *SQLiteCache) Close() error {
+	c.bg.Stop()
+	return nil
+}
+
+// deleteExpired deletes the expired rows, then vacuums the database if
+// enabled and rows were deleted.
+func (c *SQLiteCache) deleteExpired(ctx context.Context) {
+	res, err := c.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %q
+		WHERE expire_at != 0 AND expire_at <= ?`, c.table), time.Now().UnixNano())
+	if err == nil && c.vacuum {
+		if n, _ := res.RowsAffected(); n > 0 {
+			c.db.ExecContext(ctx, `VACUUM`)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func getSQLiteT(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteSetInt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewSQLiteCache(ctx, getSQLiteT(t), SQLiteWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestSQLiteSetTime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewSQLiteCache(ctx, getSQLiteT(t), SQLiteWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := time.Now()
+	c.Set("test:123", v)
+	data, _ := c.GetTime("test:123")
+	if data == nil || !data.Equal(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestSQLiteDel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewSQLiteCache(ctx, getSQLiteT(t), SQLiteWithExpire(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestSQLiteExpire(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := getSQLiteT(t)
+	c, err := NewSQLiteCache(ctx, db, SQLiteWithTTL(200*time.Millisecond), SQLiteWithVacuum())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test:123"
+	c.Set(key, true)
+	time.Sleep(500 * time.Millisecond)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+	count := 0
+	db.QueryRow("SELECT COUNT(*) FROM " + DefaultSQLiteTable).Scan(&count)
+	if count != 0 {
+		t.Errorf("%v entries not swept", count)
+		return
+	}
+}
+
+func TestSQLiteExtend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewSQLiteCache(ctx, getSQLiteT(t), SQLiteWithTTL(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test:123"
+	c.Set(key, true)
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		data, _ := c.GetBool(key)
+		if data == nil || !*data {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestSQLiteClear(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := getSQLiteT(t)
+	a, err := NewSQLiteCache(ctx, db, SQLiteWithKeyPrefix("svcA:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSQLiteCache(ctx, db, SQLiteWithKeyPrefix("svcB:"))
+	testClear(t, a, b)
+}
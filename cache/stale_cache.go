@@ -0,0 +1,333 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// softSuffix names the key holding the soft expiration of a key stored by
+// a StaleCache.
+const softSuffix = ":soft"
+
+// StaleCache gives entries a soft and a hard ttl. Past the soft ttl Get still
+// returns the stale value at once and reloads it in the background with the
+// loader registered for the key, past the hard ttl the entry is a miss,
+// loaded before Get returns. The soft expiration is stored under key +
+// ":soft" so processes sharing the wrapped cache agree on it. The wrapped
+// cache should use absolute expiration, else reads push back the hard ttl.
+type StaleCache struct {
+	c       ICache
+	ctx     context.Context
+	soft    time.Duration
+	hard    time.Duration
+	window  time.Duration
+	loaders []refreshLoader
+	errFn   func(key string, err error)
+	flight  flightGroup
+	m       sync.Mutex
+	loading map[string]bool
+}
+
+type StaleOption func(c *StaleCache)
+
+// StaleWithLoader loads the keys starting with prefix with loader. The
+// longest matching prefix wins, a full key registers a single key.
+func StaleWithLoader(prefix string, loader LoaderFunc) StaleOption {
+	return func(c *StaleCache) {
+		c.loaders = append(c.loaders, refreshLoader{prefix: prefix, loader: loader})
+	}
+}
+
+// StaleWithErrorFunc reports the background reloads that failed, the stale
+// value is served until the hard ttl, and the errors hidden by
+// StaleWithStaleIfError.
+func StaleWithErrorFunc(fn func(key string, err error)) StaleOption {
+	return func(c *StaleCache) {
+		c.errFn = fn
+	}
+}
+
+// StaleWithStaleIfError keeps entries for window past their hard ttl. A Get
+// past the hard ttl loads the key before returning, as for a miss, but
+// returns the expired value when the loader fails, or the wrapped cache
+// fails to store or return the new value, instead of the error. The error is
+// reported to the StaleWithErrorFunc function. A wrapped cache failing to
+// return the expired value in the first place still fails the Get.
+func StaleWithStaleIfError(window time.Duration) StaleOption {
+	return func(c *StaleCache) {
+		c.window = window
+	}
+}
+
+// NewStaleCache returns c serving entries stale between the soft and the
+// hard ttl. Background reloads stop when ctx is done.
+func NewStaleCache(ctx context.Context, c ICache, soft, hard time.Duration, opts ...StaleOption) *Cache {
+	sc := &StaleCache{
+		c:       c,
+		ctx:     ctx,
+		soft:    soft,
+		hard:    hard,
+		loading: make(map[string]bool),
+	}
+	for _, fn := range opts {
+		fn(sc)
+	}
+	return NewCache(sc)
+}
+
+// store writes value expiring after hard, fresh for soft, a soft ttl <= 0
+// is never stale and stored as 0. With a stale if error window the entry is
+// kept for the window past hard and the hard expiration is stored after the
+// soft one, "<soft>:<hard>".
+func (c *StaleCache) store(key string, value interface{}, soft, hard time.Duration) error {
+	now := time.Now()
+	ttl := hard
+	meta := "0"
+	if soft > 0 {
+		meta = strconv.FormatInt(now.Add(soft).UnixNano(), 10)
+	}
+	if c.window > 0 && hard > 0 {
+		ttl += c.window
+		meta += ":" + strconv.FormatInt(now.Add(hard).UnixNano(), 10)
+	}
+	if err := c.c.SetWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+	return c.c.SetWithTTL(key+softSuffix, meta, ttl)
+}
+
+// load calls loader and stores its result. Concurrent loads of key share a
+// single loader call.
+func (c *StaleCache) load(key string, loader LoaderFunc) error {
+	_, err := c.flight.Do(key, func() (interface{}, error) {
+		value, err := loader(key)
+		if err != nil {
+			return nil, err
+		}
+		return nil, c.store(key, value, c.soft, c.hard)
+	})
+	return err
+}
+
+// expiration returns the soft and hard expiration of key in unix
+// nanoseconds, softAt is 0 when the entry is never stale and hardAt 0 when
+// it expires at its hard ttl. ok is
+// false for keys without them, e.g. stored by another client, which are
+// never stale.
+func (c *StaleCache) expiration(key string) (softAt, hardAt int64, ok bool) {
+	meta, err := c.c.GetString(key + softSuffix)
+	if err != nil || meta == "" {
+		return 0, 0, false
+	}
+	hard := ""
+	if i := strings.IndexByte(meta, ':'); i >= 0 {
+		meta, hard = meta[:i], meta[i+1:]
+	}
+	softAt, err = strconv.ParseInt(meta, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if hard != "" {
+		if hardAt, err = strconv.ParseInt(hard, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return softAt, hardAt, true
+}
+
+// revalidate reloads key in the background unless it is being reloaded.
+func (c *StaleCache) revalidate(key string, loader LoaderFunc) {
+	if c.ctx.Err() != nil {
+		return
+	}
+	c.m.Lock()
+	if c.loading[key] {
+		c.m.Unlock()
+		return
+	}
+	c.loading[key] = true
+	c.m.Unlock()
+	go func() {
+		err := c.load(key, loader)
+		c.m.Lock()
+		delete(c.loading, key)
+		c.m.Unlock()
+		if err != nil && c.errFn != nil {
+			c.errFn(key, err)
+		}
+	}()
+}
+
+// get calls get on the cache, triggering a reload when the value is stale
+// and loading key and calling it again on a miss.
+func (c *StaleCache) get(key string, get func() (interface{}, error)) (interface{}, error) {
+	value, err := get()
+	if err != nil {
+		return nil, err
+	}
+	loader := findLoader(c.loaders, key)
+	if !tieredMiss(value) {
+		if loader == nil {
+			return value, nil
+		}
+		softAt, hardAt, ok := c.expiration(key)
+		now := time.Now().UnixNano()
+		stale := softAt != 0 && now > softAt
+		expired := hardAt != 0 && now > hardAt
+		if !ok || !stale && !expired {
+			return value, nil
+		}
+		if !expired {
+			c.revalidate(key, loader)
+			return value, nil
+		}
+		// past the hard ttl, kept for the stale if error window
+		fresh, err := c.reload(key, loader, get)
+		if err != nil {
+			if c.errFn != nil {
+				c.errFn(key, err)
+			}
+			return value, nil
+		}
+		return fresh, nil
+	}
+	if loader == nil {
+		return nil, nil
+	}
+	return c.reload(key, loader, get)
+}
+
+// reload loads key and calls get again.
+func (c *StaleCache) reload(key string, loader LoaderFunc, get func() (interface{}, error)) (interface{}, error) {
+	if err := c.load(key, loader); err != nil {
+		return nil, err
+	}
+	value, err := get()
+	if err != nil || tieredMiss(value) {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set stores value with the soft and hard ttl of the cache.
+func (c *StaleCache) Set(key string, value interface{}) error {
+	return c.store(key, value, c.soft, c.hard)
+}
+
+func (c *StaleCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+// SetWithTTL stores value with ttl as the hard ttl and a soft ttl scaled
+// like the cache's. A ttl <= 0 never expires and is never stale.
+func (c *StaleCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	soft := ttl
+	if ttl > 0 && c.hard > 0 {
+		soft = time.Duration(float64(ttl) * float64(c.soft) / float64(c.hard))
+	}
+	return c.store(key, value, soft, ttl)
+}
+
+func (c *StaleCache) Get(key string) (interface{}, error) {
+	return c.get(key, func() (interface{}, error) { return c.c.Get(key) })
+}
+
+func (c *StaleCache) GetInt(key string) (*int64, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetInt(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*int64), nil
+}
+
+func (c *StaleCache) GetUint(key string) (*uint64, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetUint(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*uint64), nil
+}
+
+func (c *StaleCache) GetFloat(key string) (*float64, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetFloat(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*float64), nil
+}
+
+func (c *StaleCache) GetString(key string) (string, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetString(key) })
+	if value == nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (c *StaleCache) GetBytes(key string) ([]byte, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetBytes(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
+
+func (c *StaleCache) GetBool(key string) (*bool, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetBool(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*bool), nil
+}
+
+func (c *StaleCache) GetTime(key string) (*time.Time, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetTime(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Time), nil
+}
+
+func (c *StaleCache) GetDuration(key string) (*time.Duration, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetDuration(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Duration), nil
+}
+
+func (c *StaleCache) GetStringSlice(key string) ([]string, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetStringSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]string), nil
+}
+
+func (c *StaleCache) GetIntSlice(key string) ([]int64, error) {
+	value, err := c.get(key, func() (interface{}, error) { return c.c.GetIntSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]int64), nil
+}
+
+// Ping pings the wrapped cache.
+func (c *StaleCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.c)
+}
+
+// Close closes the wrapped cache.
+func (c *StaleCache) Close() error {
+	return closeCache(c.c)
+}
+
+func (c *StaleCache) Del(key string) error {
+	if err := c.c.Del(key); err != nil {
+		return err
+	}
+	return c.c.Del(key + softSuffix)
+}
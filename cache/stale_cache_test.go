@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStaleServeStale(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var n int64
+	c := NewStaleCache(ctx, NewLocalCache(ctx, LocalWithAbsoluteExpire()), 100*time.Millisecond, time.Second,
+		StaleWithLoader("test:", func(key string) (interface{}, error) {
+			time.Sleep(50 * time.Millisecond)
+			return atomic.AddInt64(&n, 1), nil
+		}))
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	time.Sleep(150 * time.Millisecond)
+	start := time.Now()
+	data, _ = c.GetInt("test:123")
+	if data == nil || *data != 1 || time.Since(start) > 40*time.Millisecond {
+		t.Errorf("%v stale value error", data)
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+	data, _ = c.GetInt("test:123")
+	if data == nil || *data != 2 {
+		t.Errorf("%v revalidated value error", data)
+		return
+	}
+}
+
+func TestStaleHardExpire(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var n int64
+	c := NewStaleCache(ctx, NewLocalCache(ctx, LocalWithAbsoluteExpire()), 50*time.Millisecond, 100*time.Millisecond,
+		StaleWithLoader("test:", func(key string) (interface{}, error) {
+			return atomic.AddInt64(&n, 1), nil
+		}))
+	c.GetInt("test:123")
+	time.Sleep(150 * time.Millisecond)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestStaleNoTTL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var n int64
+	c := NewStaleCache(ctx, NewLocalCache(ctx, LocalWithAbsoluteExpire()), 50*time.Millisecond, 100*time.Millisecond,
+		StaleWithLoader("test:", func(key string) (interface{}, error) {
+			return atomic.AddInt64(&n, 1), nil
+		}))
+	c.SetWithTTL("test:123", 0, 0)
+	time.Sleep(150 * time.Millisecond)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 0 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt64(&n) != 0 {
+		t.Errorf("%v loader calls", n)
+		return
+	}
+}
+
+func TestStaleError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errLoad := errors.New("load error")
+	var n int64
+	errs := make(chan error, 1)
+	c := NewStaleCache(ctx, NewLocalCache(ctx, LocalWithAbsoluteExpire()), 10*time.Millisecond, time.Second,
+		StaleWithLoader("test:", func(key string) (interface{}, error) {
+			if atomic.AddInt64(&n, 1) > 1 {
+				return nil, errLoad
+			}
+			return 1, nil
+		}),
+		StaleWithErrorFunc(func(key string, err error) {
+			errs <- err
+		}))
+	c.GetInt("test:123")
+	time.Sleep(20 * time.Millisecond)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	select {
+	case err := <-errs:
+		if err != errLoad {
+			t.Errorf("%v error", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no revalidate error")
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errLoad := errors.New("load error")
+	var n int64
+	var reported int64
+	c := NewStaleCache(ctx, NewLocalCache(ctx, LocalWithAbsoluteExpire()), 50*time.Millisecond, 100*time.Millisecond,
+		StaleWithStaleIfError(200*time.Millisecond),
+		StaleWithLoader("test:", func(key string) (interface{}, error) {
+			if atomic.AddInt64(&n, 1) > 1 {
+				return nil, errLoad
+			}
+			return 1, nil
+		}),
+		StaleWithErrorFunc(func(key string, err error) {
+			atomic.AddInt64(&reported, 1)
+		}))
+	c.GetInt("test:123")
+	time.Sleep(150 * time.Millisecond)
+	data, err := c.GetInt("test:123")
+	if data == nil || *data != 1 || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+	if atomic.LoadInt64(&reported) == 0 {
+		t.Errorf("error not reported")
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+	data, err = c.GetInt("test:123")
+	if data != nil || err != errLoad {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
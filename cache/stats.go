@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats counts the operations made through a Cache since it was created.
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	Sets   uint64
+	Dels   uint64
+	// Errors counts the reads, writes and deletes that failed.
+	Errors uint64
+	// Loads counts the loader calls of the GetOrSet methods, LoadErrors
+	// the ones that failed.
+	Loads       uint64
+	LoadErrors  uint64
+	LoadTime    time.Duration // total time spent in loaders
+	MaxLoadTime time.Duration
+}
+
+// HitRatio returns the part of the reads that hit, 0 without reads.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// AvgLoadTime returns the mean duration of a loader call.
+func (s Stats) AvgLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+// cacheStats holds the counters of a Cache, updated atomically. It only has
+// 64 bit fields so they stay aligned for the atomic operations on 32 bit
+// platforms as long as it is 64 bit aligned.
+type cacheStats struct {
+	hits        uint64
+	misses      uint64
+	sets        uint64
+	dels        uint64
+	errors      uint64
+	loads       uint64
+	loadErrors  uint64
+	loadTime    int64
+	maxLoadTime int64
+}
+
+// read counts a read, a miss when miss is set.
+func (s *cacheStats) read(miss bool, err error) {
+	switch {
+	case err != nil && err != ErrCacheMiss:
+		atomic.AddUint64(&s.errors, 1)
+	case miss || err == ErrCacheMiss:
+		atomic.AddUint64(&s.misses, 1)
+	default:
+		atomic.AddUint64(&s.hits, 1)
+	}
+}
+
+// write counts n writes, failed when err is set.
+func (s *cacheStats) write(n int, err error) {
+	if err != nil {
+		atomic.AddUint64(&s.errors, 1)
+		return
+	}
+	atomic.AddUint64(&s.sets, uint64(n))
+}
+
+// del counts n deletes, failed when err is set.
+func (s *cacheStats) del(n int, err error) {
+	if err != nil {
+		atomic.AddUint64(&s.errors, 1)
+		return
+	}
+	atomic.AddUint64(&s.dels, uint64(n))
+}
+
+// load calls loader and counts the call and its duration.
+func (s *cacheStats) load(key string, loader LoaderFunc) (interface{}, error) {
+	start := time.Now()
+	value, err := loader(key)
+	d := int64(time.Since(start))
+	atomic.AddUint64(&s.loads, 1)
+	if err != nil {
+		atomic.AddUint64(&s.loadErrors, 1)
+	}
+	atomic.AddInt64(&s.loadTime, d)
+	for {
+		max := atomic.LoadInt64(&s.maxLoadTime)
+		if d <= max || atomic.CompareAndSwapInt64(&s.maxLoadTime, max, d) {
+			break
+		}
+	}
+	return value, err
+}
+
+// Stats returns a snapshot of the counters of the cache. Operations made on
+// the underlying cache directly are not counted.
+func (c *Cache) Stats() Stats {
+	s := &c.stats
+	return Stats{
+		Hits:        atomic.LoadUint64(&s.hits),
+		Misses:      atomic.LoadUint64(&s.misses),
+		Sets:        atomic.LoadUint64(&s.sets),
+		Dels:        atomic.LoadUint64(&s.dels),
+		Errors:      atomic.LoadUint64(&s.errors),
+		Loads:       atomic.LoadUint64(&s.loads),
+		LoadErrors:  atomic.LoadUint64(&s.loadErrors),
+		LoadTime:    time.Duration(atomic.LoadInt64(&s.loadTime)),
+		MaxLoadTime: time.Duration(atomic.LoadInt64(&s.maxLoadTime)),
+	}
+}
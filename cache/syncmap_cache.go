@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// syncMapItem is a SyncMapCache entry. expireAt is first so it stays 64 bit
+// aligned for the atomic operations on 32 bit platforms.
+type syncMapItem struct {
+	expireAt int64 // unix nanoseconds, 0 never expires
+	expire   time.Duration
+	value    interface{}
+}
+
+// SyncMapCache is a LocalCache for read-heavy workloads. Entries live in a
+// sync.Map and the sliding expiration is an atomic store, so Get never takes
+// a lock. Writes are more expensive than LocalCache's, and only the ICache,
+// IRange and IRangeTTL methods are provided.
+type SyncMapCache struct {
+	ValueGetters
+	expire   time.Duration
+	absolute bool
+	prefix   string
+	cache    sync.Map
+	expireFn CacheExpireFunc
+	workers  int
+	queue    int
+	policy   NotifyPolicy
+	bg       Background
+}
+
+type SyncMapOption func(c *SyncMapCache)
+
+func SyncMapWithExpire(expireSecond int) SyncMapOption {
+	return func(c *SyncMapCache) {
+		c.expire = time.Duration(expireSecond) * time.Second
+	}
+}
+
+func SyncMapWithTTL(ttl time.Duration) SyncMapOption {
+	return func(c *SyncMapCache) {
+		c.expire = ttl
+	}
+}
+
+func SyncMapExpireNotify(fn CacheExpireFunc) SyncMapOption {
+	return func(c *SyncMapCache) {
+		c.expireFn = fn
+	}
+}
+
+// SyncMapExpireWorkers runs the SyncMapExpireNotify callback on workers
+// goroutines instead of the sweeper, with up to queue callbacks waiting for
+// them. policy is what the sweeper does when the queue is full.
+func SyncMapExpireWorkers(workers, queue int, policy NotifyPolicy) SyncMapOption {
+	return func(c *SyncMapCache) {
+		c.workers = workers
+		c.queue = queue
+		c.policy = policy
+	}
+}
+
+// SyncMapWithAbsoluteExpire disables sliding expiration, entries expire at a
+// fixed time after Set regardless of reads.
+func SyncMapWithAbsoluteExpire() SyncMapOption {
+	return func(c *SyncMapCache) {
+		c.absolute = true
+	}
+}
+
+// SyncMapWithKeyPrefix namespaces every key with prefix, e.g. "svcA:".
+func SyncMapWithKeyPrefix(prefix string) SyncMapOption {
+	return func(c *SyncMapCache) {
+		c.prefix = prefix
+	}
+}
+
+func NewSyncMapCache(ctx context.Context, opts ...SyncMapOption) *Cache {
+	c := &SyncMapCache{}
+	c.ValueGetters = ValueGetters{get: c.Get}
+	for _, fn := range opts {
+		fn(c)
+	}
+	c.bg.run(ctx, c.runExpireCheck)
+	return NewCache(c)
+}
+
+// syncMapExpireAt returns the expiration of an entry with ttl read or
+// written at now. The jitter is taken from the clock instead of a shared
+// rand.Rand so no lock is needed.
+func syncMapExpireAt(now time.Time, ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	n := now.UnixNano()
+	return n + int64(ttl) + n%(int64(ttl/10)+1)
+}
+
+func (c *SyncMapCache) Set(key string, value interface{}) error {
+	return c.SetWithTTL(key, value, c.expire)
+}
+
+func (c *SyncMapCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *SyncMapCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	c.cache.Store(c.prefix+key, &syncMapItem{
+		expireAt: syncMapExpireAt(time.Now(), ttl),
+		expire:   ttl,
+		value:    value,
+	})
+	return nil
+}
+
+// Get returns the value of key, extending its expiration unless the cache
+// uses absolute expiration.
+func (c *SyncMapCache) Get(key string) (interface{}, error) {
+	v, ok := c.cache.Load(c.prefix + key)
+	if !ok {
+		return nil, nil
+	}
+	data := v.(*syncMapItem)
+	now := time.Now()
+	exp := atomic.LoadInt64(&data.expireAt)
+	if exp != 0 && now.UnixNano() > exp {
+		return nil, nil
+	}
+	if data.expire > 0 && !c.absolute {
+		atomic.StoreInt64(&data.expireAt, syncMapExpireAt(now, data.expire))
+	}
+	return data.value, nil
+}
+
+func (c *SyncMapCache) Del(key string) error {
+	c.cache.Delete(c.prefix + key)
+	return nil
+}
+
+// Clear deletes the entries whose keys start with the key prefix.
+func (c *SyncMapCache) Clear() error {
+	c.cache.Range(func(k, v interface{}) bool {
+		if strings.HasPrefix(k.(string), c.prefix) {
+			c.cache.Delete(k)
+		}
+		return true
+	})
+	return nil
+}
+
+// Range calls fn for each unexpired entry until fn returns false. fn may
+// safely call back into the cache.
+func (c *SyncMapCache) Range(fn func(key string, value interface{}) bool) {
+	now := time.Now().UnixNano()
+	c.cache.Range(func(k, v interface{}) bool {
+		key := k.(string)
+		if !strings.HasPrefix(key, c.prefix) {
+			return true
+		}
+		data := v.(*syncMapItem)
+		if exp := atomic.LoadInt64(&data.expireAt); exp != 0 && now > exp {
+			return true
+		}
+		return fn(key[len(c.prefix):], data.value)
+	})
+}
+
+// RangeTTL is Range passing the remaining ttl of each entry, 0 when it does
+// not expire.
+func (c *SyncMapCache) RangeTTL(fn func(key string, value interface{}, ttl time.Duration) bool) error {
+	now := time.Now().UnixNano()
+	c.cache.Range(func(k, v interface{}) bool {
+		key := k.(string)
+		if !strings.HasPrefix(key, c.prefix) {
+			return true
+		}
+		data := v.(*syncMapItem)
+		exp := atomic.LoadInt64(&data.expireAt)
+		if exp != 0 && now > exp {
+			return true
+		}
+		var ttl time.Duration
+		if exp != 0 {
+			ttl = time.Duration(exp - now)
+		}
+		return fn(key[len(c.prefix):], data.value, ttl)
+	})
+	return nil
+}
+
+// Close stops the expiration sweeper and waits for it to return.
+func (c *SyncMapCache) Close() error {
+	c.bg.Stop()
+	return nil
+}
+
+func (c *SyncMapCache) runExpireCheck(ctx context.Context) {
+	notify, wait := expireNotifier(ctx, c.expireFn, c.workers, c.queue, c.policy)
+	defer wait(false)
+	sweepEvery(ctx, c.expire, func() { c.sweep(notify) })
+}
+
+// sweep deletes the expired entries, passing them to notify if not nil.
+func (c *SyncMapCache) sweep(notify CacheExpireFunc) {
+	now := time.Now().UnixNano()
+	c.cache.Range(func(k, v interface{}) bool {
+		data := v.(*syncMapItem)
+		exp := atomic.LoadInt64(&data.expireAt)
+		if exp == 0 || now <= exp {
+			return true
+		}
+		// skip the key when it was stored again since the sweep
+		// started, a Set racing the Delete may still be lost
+		if cur, ok := c.cache.Load(k); !ok || cur != v {
+			return true
+		}
+		c.cache.Delete(k)
+		if notify != nil {
+			notify(strings.TrimPrefix(k.(string), c.prefix), data.value)
+		}
+		return true
+	})
+}
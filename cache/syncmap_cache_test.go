@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSyncMapSetInt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewSyncMapCache(ctx, SyncMapWithExpire(10))
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestSyncMapDel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewSyncMapCache(ctx, SyncMapWithExpire(10))
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestSyncMapExpire(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	expired := make(chan string, 1)
+	c := NewSyncMapCache(ctx, SyncMapWithTTL(200*time.Millisecond),
+		SyncMapExpireNotify(func(key string, value interface{}) {
+			expired <- key
+		}))
+	key := "test:123"
+	c.Set(key, true)
+	time.Sleep(300 * time.Millisecond)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+	select {
+	case k := <-expired:
+		if k != key {
+			t.Errorf("%v value error", k)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expire notify not called")
+	}
+}
+
+func TestSyncMapExtend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewSyncMapCache(ctx, SyncMapWithTTL(200*time.Millisecond))
+	key := "test:123"
+	c.Set(key, true)
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		data, _ := c.GetBool(key)
+		if data == nil || !*data {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestSyncMapConcurrentGet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewSyncMapCache(ctx, SyncMapWithExpire(10))
+	for i := 0; i < 10; i++ {
+		c.Set(fmt.Sprintf("test:%d", i), i)
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				data, _ := c.GetInt(fmt.Sprintf("test:%d", j%10))
+				if data == nil || *data != int64(j%10) {
+					t.Errorf("%v value error", data)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkSyncMapGet(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewSyncMapCache(ctx, SyncMapWithExpire(10))
+	c.Set("test:123", 3)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Get("test:123")
+		}
+	})
+}
+
+func BenchmarkLocalGet(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	c.Set("test:123", 3)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Get("test:123")
+		}
+	})
+}
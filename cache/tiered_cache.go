@@ -0,0 +1,356 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// tieredVersionSuffix names the key holding the version of a key written by
+// a TieredCache with read repair.
+const tieredVersionSuffix = ":ver"
+
+// TieredCache checks a fast L1 cache, usually a LocalCache, before a shared
+// L2 cache, usually a redis backend. L2 hits are copied into L1 and writes go
+// through to both, L2 first. Typed getters ask each tier with the same
+// getter and store the decoded value in L1, so a string read from redis is
+// parsed once.
+type TieredCache struct {
+	l1     ICache
+	l2     ICache
+	l1TTL  time.Duration
+	inv    Invalidator
+	repair bool
+	clk    Clock
+}
+
+type TieredOption func(c *TieredCache)
+
+// TieredWithL1TTL caps how long entries stay in L1, bounding how stale L1 can
+// be against writes made to L2 by other processes. By default L1 entries use
+// the expiration of the L1 cache, or the ttl given to SetWithTTL.
+func TieredWithL1TTL(ttl time.Duration) TieredOption {
+	return func(c *TieredCache) {
+		c.l1TTL = ttl
+	}
+}
+
+// TieredWithInvalidator publishes the keys written or deleted through the
+// cache on inv and drops the L1 copy of the keys published by other
+// processes, so L1 is not stale longer than the pub/sub latency.
+func TieredWithInvalidator(inv Invalidator) TieredOption {
+	return func(c *TieredCache) {
+		c.inv = inv
+	}
+}
+
+// TieredWithReadRepair versions every write with its time, stored under key
+// + ":ver" in both tiers, and compares the versions of the tiers on L1 hits.
+// An L1 copy older than L2, or whose key L2 lost or deleted, is replaced or
+// dropped, an L2 entry older than L1, e.g. written late or restored from an
+// old snapshot, is overwritten with the L1 value with the L2 expiration. The
+// tiers then converge without invalidation traffic, at the cost of reading
+// the L2 version on every hit. Writes by clients without versions are taken
+// as the newest.
+func TieredWithReadRepair() TieredOption {
+	return func(c *TieredCache) {
+		c.repair = true
+	}
+}
+
+// TieredWithClock takes the time of the read repair versions, and of the
+// stampede protection of GetOrSetWithTTL, from clk. By default it is the
+// clock of l1, e.g. the LocalWithClock of a LocalCache.
+func TieredWithClock(clk Clock) TieredOption {
+	return func(c *TieredCache) {
+		c.clk = clk
+	}
+}
+
+// NewTieredCache returns a cache reading l1 then l2. Either may be a *Cache.
+func NewTieredCache(l1, l2 ICache, opts ...TieredOption) *Cache {
+	c := &TieredCache{
+		l1: l1,
+		l2: l2,
+	}
+	for _, fn := range opts {
+		fn(c)
+	}
+	if c.clk == nil {
+		c.clk = ClockOf(l1)
+	}
+	if c.inv != nil {
+		c.inv.Subscribe(func(key string) {
+			c.l1.Del(key)
+		})
+	}
+	return NewCache(c)
+}
+
+func (c *TieredCache) Clock() Clock {
+	return c.clk
+}
+
+// publish announces a change of key when the cache has an invalidator.
+func (c *TieredCache) publish(key string) error {
+	if c.inv == nil {
+		return nil
+	}
+	return c.inv.Publish(key)
+}
+
+// setL1 stores value in L1 for at most ttl, 0 uses the L1 default.
+func (c *TieredCache) setL1(key string, value interface{}, ttl time.Duration) error {
+	if c.l1TTL > 0 && (ttl <= 0 || ttl > c.l1TTL) {
+		ttl = c.l1TTL
+	}
+	if ttl > 0 {
+		return c.l1.SetWithTTL(key, value, ttl)
+	}
+	return c.l1.Set(key, value)
+}
+
+// write stores value in L2 with set2 then in L1 with ttl, versioned with the
+// current time when the cache repairs reads.
+func (c *TieredCache) write(key string, value interface{}, ttl time.Duration, set2 func(key string, value interface{}) error) error {
+	if err := set2(key, value); err != nil {
+		return err
+	}
+	ver := ""
+	if c.repair {
+		ver = strconv.FormatInt(c.clk.Now().UnixNano(), 10)
+		if err := set2(key+tieredVersionSuffix, ver); err != nil {
+			return err
+		}
+	}
+	if err := c.publish(key); err != nil {
+		return err
+	}
+	return c.fill(key, value, ver, ttl)
+}
+
+// fill stores value in L1 with its version, if any.
+func (c *TieredCache) fill(key string, value interface{}, ver string, ttl time.Duration) error {
+	if err := c.setL1(key, value, ttl); err != nil {
+		return err
+	}
+	if !c.repair {
+		return nil
+	}
+	if ver == "" {
+		return c.l1.Del(key + tieredVersionSuffix)
+	}
+	return c.setL1(key+tieredVersionSuffix, ver, ttl)
+}
+
+func (c *TieredCache) Set(key string, value interface{}) error {
+	return c.write(key, value, 0, c.l2.Set)
+}
+
+func (c *TieredCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *TieredCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.write(key, value, ttl, func(key string, value interface{}) error {
+		return c.l2.SetWithTTL(key, value, ttl)
+	})
+}
+
+// tieredMiss reports whether a getter result is a miss, nil, a nil pointer
+// or slice, or an empty string.
+func tieredMiss(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return v.IsNil()
+	case reflect.String:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// get calls get on L1, then on L2 when L1 misses or fails, storing an L2 hit
+// in L1. Pointer results are stored dereferenced. A miss returns nil.
+func (c *TieredCache) get(key string, get func(ICache) (interface{}, error)) (interface{}, error) {
+	value, err := get(c.l1)
+	if err == nil && !tieredMiss(value) {
+		if c.repair {
+			return c.reconcile(key, value, get), nil
+		}
+		return value, nil
+	}
+	value, err = get(c.l2)
+	if err != nil || tieredMiss(value) {
+		return nil, err
+	}
+	ver := ""
+	if c.repair {
+		ver, _ = c.l2.GetString(key + tieredVersionSuffix)
+	}
+	c.fill(key, tieredDeref(value), ver, 0)
+	return value, nil
+}
+
+// tieredDeref returns the value a pointer getter result points to.
+func tieredDeref(value interface{}) interface{} {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr {
+		return v.Elem().Interface()
+	}
+	return value
+}
+
+// reconcile compares the versions of key in both tiers after an L1 hit of
+// value, returning the value read repair settled on, nil when L2 lost or
+// deleted key. An unreachable L2 serves value.
+func (c *TieredCache) reconcile(key string, value interface{}, get func(ICache) (interface{}, error)) interface{} {
+	v2, err := c.l2.GetString(key + tieredVersionSuffix)
+	if err != nil {
+		return value
+	}
+	v1, _ := c.l1.GetString(key + tieredVersionSuffix)
+	if v1 == v2 && v1 != "" {
+		return value
+	}
+	n1, _ := strconv.ParseInt(v1, 10, 64)
+	n2, _ := strconv.ParseInt(v2, 10, 64)
+	if n2 > 0 && n1 > n2 {
+		// L2 holds an older write
+		if c.l2.Set(key, tieredDeref(value)) == nil {
+			c.l2.Set(key+tieredVersionSuffix, v1)
+		}
+		return value
+	}
+	// L2 is newer, lost or deleted key, or was written without a version
+	fresh, err := get(c.l2)
+	if err != nil {
+		return value
+	}
+	if tieredMiss(fresh) {
+		c.l1.Del(key)
+		c.l1.Del(key + tieredVersionSuffix)
+		return nil
+	}
+	c.fill(key, tieredDeref(fresh), v2, 0)
+	return fresh
+}
+
+func (c *TieredCache) Get(key string) (interface{}, error) {
+	return c.get(key, func(t ICache) (interface{}, error) { return t.Get(key) })
+}
+
+func (c *TieredCache) GetInt(key string) (*int64, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetInt(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*int64), nil
+}
+
+func (c *TieredCache) GetUint(key string) (*uint64, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetUint(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*uint64), nil
+}
+
+func (c *TieredCache) GetFloat(key string) (*float64, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetFloat(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*float64), nil
+}
+
+func (c *TieredCache) GetString(key string) (string, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetString(key) })
+	if value == nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (c *TieredCache) GetBytes(key string) ([]byte, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetBytes(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
+
+func (c *TieredCache) GetBool(key string) (*bool, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetBool(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*bool), nil
+}
+
+func (c *TieredCache) GetTime(key string) (*time.Time, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetTime(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Time), nil
+}
+
+func (c *TieredCache) GetDuration(key string) (*time.Duration, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetDuration(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.(*time.Duration), nil
+}
+
+func (c *TieredCache) GetStringSlice(key string) ([]string, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetStringSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]string), nil
+}
+
+func (c *TieredCache) GetIntSlice(key string) ([]int64, error) {
+	value, err := c.get(key, func(t ICache) (interface{}, error) { return t.GetIntSlice(key) })
+	if value == nil {
+		return nil, err
+	}
+	return value.([]int64), nil
+}
+
+// Ping pings both levels.
+func (c *TieredCache) Ping(ctx context.Context) error {
+	if err := ping(ctx, c.l1); err != nil {
+		return err
+	}
+	return ping(ctx, c.l2)
+}
+
+// Close closes both levels, returning the first error.
+func (c *TieredCache) Close() error {
+	err := closeCache(c.l1)
+	if err2 := closeCache(c.l2); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Del removes key from L2 then from L1.
+func (c *TieredCache) Del(key string) error {
+	if err := c.l2.Del(key); err != nil {
+		return err
+	}
+	if err := c.publish(key); err != nil {
+		return err
+	}
+	if c.repair {
+		c.l2.Del(key + tieredVersionSuffix)
+		c.l1.Del(key + tieredVersionSuffix)
+	}
+	return c.l1.Del(key)
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTieredSetInt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l1 := NewLocalCache(ctx, LocalWithExpire(10))
+	l2 := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c := NewTieredCache(l1, l2)
+	v := 3
+	c.Set("test:123", v)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = l2.GetInt("test:123")
+	if data == nil || *data != int64(v) {
+		t.Errorf("%v l2 value error", data)
+		return
+	}
+}
+
+func TestTieredFillL1(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l1 := NewLocalCache(ctx, LocalWithExpire(10))
+	l2 := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c := NewTieredCache(l1, l2)
+	l2.Set("test:123", 3)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	value, _ := l1.Get("test:123")
+	if value != int64(3) {
+		t.Errorf("%v l1 value error", value)
+		return
+	}
+	l2.Del("test:123")
+	data, _ = c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestTieredDel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l1 := NewLocalCache(ctx, LocalWithExpire(10))
+	l2 := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c := NewTieredCache(l1, l2)
+	key := "test:123"
+	c.Set(key, true)
+	c.Del(key)
+	data, err := c.GetBool(key)
+	if data != nil || err != nil {
+		t.Errorf("%v value error:%v", data, err)
+		return
+	}
+}
+
+func TestTieredL1TTL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l1 := NewLocalCache(ctx, LocalWithExpire(10))
+	l2 := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c := NewTieredCache(l1, l2, TieredWithL1TTL(200*time.Millisecond))
+	c.Set("test:123", "a")
+	l2.Set("test:123", "b")
+	data, _ := c.GetString("test:123")
+	if data != "a" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	time.Sleep(300 * time.Millisecond)
+	data, _ = c.GetString("test:123")
+	if data != "b" {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestTieredReadRepair(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l1 := NewLocalCache(ctx, LocalWithExpire(10))
+	l2 := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c := NewTieredCache(l1, l2, TieredWithReadRepair())
+	c.Set("test:123", 3)
+	// another process writes a newer version to L2
+	other := NewTieredCache(NewLocalCache(ctx, LocalWithExpire(10)), l2, TieredWithReadRepair())
+	other.Set("test:123", 4)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 4 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	value, _ := l1.Get("test:123")
+	if value != int64(4) {
+		t.Errorf("%v l1 value error", value)
+		return
+	}
+	// L2 gets an older write back
+	ver, _ := l2.GetString("test:123" + tieredVersionSuffix)
+	c.Set("test:123", 5)
+	l2.Set("test:123", 4)
+	l2.Set("test:123"+tieredVersionSuffix, ver)
+	data, _ = c.GetInt("test:123")
+	if data == nil || *data != 5 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = l2.GetInt("test:123")
+	if data == nil || *data != 5 {
+		t.Errorf("%v l2 value error", data)
+		return
+	}
+	// L2 loses the key
+	l2.Del("test:123")
+	l2.Del("test:123" + tieredVersionSuffix)
+	data, _ = c.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+	value, _ = l1.Get("test:123")
+	if value != nil {
+		t.Errorf("%v l1 value error", value)
+		return
+	}
+}
@@ -0,0 +1,255 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Span is the part of a tracing span used by TracedCache. The otel package
+// of mcache adapts the OpenTelemetry spans.
+type Span interface {
+	SetAttribute(key, value string)
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans, as a child of the span in ctx if any. The otel
+// package of mcache adapts an OpenTelemetry trace.Tracer.
+type Tracer interface {
+	Start(ctx context.Context, name string) Span
+}
+
+// TracedCache starts a span for each Get, Set and Del of the wrapped cache,
+// named "cache." + the method, with the attributes cache.backend,
+// cache.key_prefix and cache.result: hit, miss, ok or error. GetCtx, SetCtx,
+// SetWithTTLCtx and DelCtx start their span in the context of the call, the
+// other methods in the context given to NewTracedCache.
+type TracedCache struct {
+	c       ICache
+	ctx     context.Context
+	tracer  Tracer
+	backend string
+	sep     string
+}
+
+type TracedOption func(c *TracedCache)
+
+// TracedWithBackend names the wrapped cache in the cache.backend attribute,
+// its type by default.
+func TracedWithBackend(name string) TracedOption {
+	return func(c *TracedCache) {
+		c.backend = name
+	}
+}
+
+// TracedWithPrefixSep sets the separator ending the key prefix reported in
+// the cache.key_prefix attribute, ":" by default. Keys without it are
+// reported whole.
+func TracedWithPrefixSep(sep string) TracedOption {
+	return func(c *TracedCache) {
+		c.sep = sep
+	}
+}
+
+// NewTracedCache returns c tracing its operations with tracer. The spans of
+// the methods without a context are children of the span in ctx, usually a
+// long lived one: pass the context of each request to the Ctx methods of the
+// Cache instead.
+func NewTracedCache(ctx context.Context, c ICache, tracer Tracer, opts ...TracedOption) *Cache {
+	tc := &TracedCache{
+		c:       c,
+		ctx:     ctx,
+		tracer:  tracer,
+		backend: fmt.Sprintf("%T", c),
+		sep:     ":",
+	}
+	for _, fn := range opts {
+		fn(tc)
+	}
+	return NewCache(tc)
+}
+
+func (c *TracedCache) start(op, key string) Span {
+	return c.startCtx(c.ctx, op, key)
+}
+
+func (c *TracedCache) startCtx(ctx context.Context, op, key string) Span {
+	span := c.tracer.Start(ctx, "cache."+op)
+	span.SetAttribute("cache.backend", c.backend)
+	prefix := key
+	if i := strings.Index(key, c.sep); i >= 0 && c.sep != "" {
+		prefix = key[:i]
+	}
+	span.SetAttribute("cache.key_prefix", prefix)
+	return span
+}
+
+// end records the result of a read, a miss when miss is set, and ends span.
+func (c *TracedCache) end(span Span, miss bool, err error) {
+	switch {
+	case err != nil:
+		span.SetAttribute("cache.result", "error")
+		span.RecordError(err)
+	case miss:
+		span.SetAttribute("cache.result", "miss")
+	default:
+		span.SetAttribute("cache.result", "hit")
+	}
+	span.End()
+}
+
+// endWrite records the result of a write or delete and ends span.
+func (c *TracedCache) endWrite(span Span, err error) {
+	if err != nil {
+		span.SetAttribute("cache.result", "error")
+		span.RecordError(err)
+	} else {
+		span.SetAttribute("cache.result", "ok")
+	}
+	span.End()
+}
+
+func (c *TracedCache) Set(key string, value interface{}) error {
+	span := c.start("Set", key)
+	err := c.c.Set(key, value)
+	c.endWrite(span, err)
+	return err
+}
+
+func (c *TracedCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	span := c.start("SetWithExpire", key)
+	err := c.c.SetWithExpire(key, value, expireSec)
+	c.endWrite(span, err)
+	return err
+}
+
+func (c *TracedCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	span := c.start("SetWithTTL", key)
+	err := c.c.SetWithTTL(key, value, ttl)
+	c.endWrite(span, err)
+	return err
+}
+
+func (c *TracedCache) Get(key string) (interface{}, error) {
+	span := c.start("Get", key)
+	value, err := c.c.Get(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+func (c *TracedCache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	span := c.startCtx(ctx, "Get", key)
+	value, err := c.c.Get(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+func (c *TracedCache) SetCtx(ctx context.Context, key string, value interface{}) error {
+	span := c.startCtx(ctx, "Set", key)
+	err := c.c.Set(key, value)
+	c.endWrite(span, err)
+	return err
+}
+
+func (c *TracedCache) SetWithTTLCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	span := c.startCtx(ctx, "SetWithTTL", key)
+	err := c.c.SetWithTTL(key, value, ttl)
+	c.endWrite(span, err)
+	return err
+}
+
+func (c *TracedCache) DelCtx(ctx context.Context, key string) error {
+	span := c.startCtx(ctx, "Del", key)
+	err := c.c.Del(key)
+	c.endWrite(span, err)
+	return err
+}
+
+func (c *TracedCache) GetInt(key string) (*int64, error) {
+	span := c.start("GetInt", key)
+	value, err := c.c.GetInt(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+func (c *TracedCache) GetUint(key string) (*uint64, error) {
+	span := c.start("GetUint", key)
+	value, err := c.c.GetUint(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+func (c *TracedCache) GetFloat(key string) (*float64, error) {
+	span := c.start("GetFloat", key)
+	value, err := c.c.GetFloat(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+func (c *TracedCache) GetString(key string) (string, error) {
+	span := c.start("GetString", key)
+	value, err := c.c.GetString(key)
+	c.end(span, value == "", err)
+	return value, err
+}
+
+func (c *TracedCache) GetBytes(key string) ([]byte, error) {
+	span := c.start("GetBytes", key)
+	value, err := c.c.GetBytes(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+func (c *TracedCache) GetBool(key string) (*bool, error) {
+	span := c.start("GetBool", key)
+	value, err := c.c.GetBool(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+func (c *TracedCache) GetTime(key string) (*time.Time, error) {
+	span := c.start("GetTime", key)
+	value, err := c.c.GetTime(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+func (c *TracedCache) GetDuration(key string) (*time.Duration, error) {
+	span := c.start("GetDuration", key)
+	value, err := c.c.GetDuration(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+func (c *TracedCache) GetStringSlice(key string) ([]string, error) {
+	span := c.start("GetStringSlice", key)
+	value, err := c.c.GetStringSlice(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+func (c *TracedCache) GetIntSlice(key string) ([]int64, error) {
+	span := c.start("GetIntSlice", key)
+	value, err := c.c.GetIntSlice(key)
+	c.end(span, value == nil, err)
+	return value, err
+}
+
+// Ping pings the wrapped cache.
+func (c *TracedCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.c)
+}
+
+// Close closes the wrapped cache.
+func (c *TracedCache) Close() error {
+	return closeCache(c.c)
+}
+
+func (c *TracedCache) Del(key string) error {
+	span := c.start("Del", key)
+	err := c.c.Del(key)
+	c.endWrite(span, err)
+	return err
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type testSpan struct {
+	name   string
+	attrs  map[string]string
+	err    error
+	ended  bool
+	parent interface{}
+}
+
+// parentKey keys the name of the parent span in the contexts of the tests.
+type parentKey struct{}
+
+func (s *testSpan) SetAttribute(key, value string) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)          { s.err = err }
+func (s *testSpan) End()                           { s.ended = true }
+
+type testTracer struct {
+	m     sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) Span {
+	t.m.Lock()
+	defer t.m.Unlock()
+	span := &testSpan{name: name, attrs: map[string]string{}, parent: ctx.Value(parentKey{})}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func TestTracedCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracer := &testTracer{}
+	c := NewTracedCache(ctx, NewLocalCache(ctx, LocalWithExpire(10)), tracer, TracedWithBackend("local"))
+	c.Set("test:1", 1)
+	c.GetInt("test:1")
+	c.GetInt("test:missing")
+	c.Del("test")
+	want := []struct{ name, prefix, result string }{
+		{"cache.Set", "test", "ok"},
+		{"cache.GetInt", "test", "hit"},
+		{"cache.GetInt", "test", "miss"},
+		{"cache.Del", "test", "ok"},
+	}
+	if len(tracer.spans) != len(want) {
+		t.Errorf("%v value error", len(tracer.spans))
+		return
+	}
+	for i, w := range want {
+		s := tracer.spans[i]
+		if s.name != w.name || s.attrs["cache.key_prefix"] != w.prefix || s.attrs["cache.result"] != w.result ||
+			s.attrs["cache.backend"] != "local" || !s.ended {
+			t.Errorf("%v %v value error", s.name, s.attrs)
+		}
+	}
+}
+
+func TestTracedCacheCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracer := &testTracer{}
+	c := NewTracedCache(context.WithValue(ctx, parentKey{}, "init"), NewLocalCache(ctx), tracer)
+	reqCtx := context.WithValue(ctx, parentKey{}, "request")
+	c.SetCtx(reqCtx, "test:1", 1)
+	c.GetCtx(reqCtx, "test:1")
+	c.Get("test:1")
+	c.DelCtx(reqCtx, "test:1")
+	want := []struct{ name, parent string }{
+		{"cache.Set", "request"},
+		{"cache.Get", "request"},
+		{"cache.Get", "init"},
+		{"cache.Del", "request"},
+	}
+	if len(tracer.spans) != len(want) {
+		t.Errorf("%v value error", len(tracer.spans))
+		return
+	}
+	for i, w := range want {
+		if s := tracer.spans[i]; s.name != w.name || s.parent != w.parent {
+			t.Errorf("%v %v value error", s.name, s.parent)
+		}
+	}
+	if hits := c.Stats().Hits; hits != 2 {
+		t.Errorf("%v value error", hits)
+	}
+}
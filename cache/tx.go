@@ -0,0 +1,30 @@
+package cache
+
+import "time"
+
+// txOp is a write queued in a txLog, a delete when del is set.
+type txOp struct {
+	key   string
+	value interface{}
+	ttl   time.Duration
+	def   bool // use the default expiration
+	del   bool
+}
+
+// txLog records the writes of a transaction for caches applying them
+// themselves.
+type txLog struct {
+	ops []txOp
+}
+
+func (l *txLog) Set(key string, value interface{}) {
+	l.ops = append(l.ops, txOp{key: key, value: value, def: true})
+}
+
+func (l *txLog) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	l.ops = append(l.ops, txOp{key: key, value: value, ttl: ttl})
+}
+
+func (l *txLog) Del(key string) {
+	l.ops = append(l.ops, txOp{key: key, del: true})
+}
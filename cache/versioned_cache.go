@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// VersionedCache stores the keys of a namespace under the current version of
+// the namespace, "<namespace>:<version>:<key>". The version is an entry of
+// the wrapped cache, so bumping it moves every process sharing e.g. redis to
+// new keys in O(1). The entries of older versions are never read again and
+// expire on their own.
+type VersionedCache struct {
+	c         ICache
+	namespace string
+	refresh   time.Duration
+	m         sync.Mutex
+	version   string
+	readAt    time.Time
+}
+
+type VersionedOption func(c *VersionedCache)
+
+// VersionedWithRefresh reuses the version read from the cache for d before
+// reading it again, saving a read per operation. Other processes then see a
+// bump within d. By default the version is read by every operation.
+func VersionedWithRefresh(d time.Duration) VersionedOption {
+	return func(c *VersionedCache) {
+		c.refresh = d
+	}
+}
+
+// NewVersionedCache returns c with the keys versioned in namespace.
+func NewVersionedCache(c ICache, namespace string, opts ...VersionedOption) *Cache {
+	vc := &VersionedCache{
+		c:         c,
+		namespace: namespace,
+	}
+	for _, fn := range opts {
+		fn(vc)
+	}
+	return NewCache(vc)
+}
+
+// versionKey is the key of the version of the namespace.
+func (c *VersionedCache) versionKey() string {
+	return c.namespace + ":version"
+}
+
+// key returns the key storing key at the current version, creating the
+// version when it is missing, e.g. evicted.
+func (c *VersionedCache) key(key string) (string, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.version == "" || time.Since(c.readAt) >= c.refresh {
+		version, err := c.c.GetString(c.versionKey())
+		if err != nil {
+			return "", err
+		}
+		if version == "" {
+			version = strconv.FormatInt(time.Now().UnixNano(), 36)
+			if err := c.c.SetWithTTL(c.versionKey(), version, 0); err != nil {
+				return "", err
+			}
+		}
+		c.version, c.readAt = version, time.Now()
+	}
+	return c.namespace + ":" + c.version + ":" + key, nil
+}
+
+// BumpVersion moves the namespace to a new version, invalidating all its
+// entries.
+func (c *VersionedCache) BumpVersion() error {
+	version := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := c.c.SetWithTTL(c.versionKey(), version, 0); err != nil {
+		return err
+	}
+	c.m.Lock()
+	c.version, c.readAt = version, time.Now()
+	c.m.Unlock()
+	return nil
+}
+
+func (c *VersionedCache) Set(key string, value interface{}) error {
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	return c.c.Set(k, value)
+}
+
+func (c *VersionedCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	return c.c.SetWithExpire(k, value, expireSec)
+}
+
+func (c *VersionedCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	return c.c.SetWithTTL(k, value, ttl)
+}
+
+func (c *VersionedCache) Get(key string) (interface{}, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.Get(k)
+}
+
+func (c *VersionedCache) GetInt(key string) (*int64, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetInt(k)
+}
+
+func (c *VersionedCache) GetUint(key string) (*uint64, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetUint(k)
+}
+
+func (c *VersionedCache) GetFloat(key string) (*float64, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetFloat(k)
+}
+
+func (c *VersionedCache) GetString(key string) (string, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return "", err
+	}
+	return c.c.GetString(k)
+}
+
+func (c *VersionedCache) GetBytes(key string) ([]byte, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetBytes(k)
+}
+
+func (c *VersionedCache) GetBool(key string) (*bool, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetBool(k)
+}
+
+func (c *VersionedCache) GetTime(key string) (*time.Time, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetTime(k)
+}
+
+func (c *VersionedCache) GetDuration(key string) (*time.Duration, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetDuration(k)
+}
+
+func (c *VersionedCache) GetStringSlice(key string) ([]string, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetStringSlice(k)
+}
+
+func (c *VersionedCache) GetIntSlice(key string) ([]int64, error) {
+	k, err := c.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.c.GetIntSlice(k)
+}
+
+// Ping pings the wrapped cache.
+func (c *VersionedCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.c)
+}
+
+// Close closes the wrapped cache.
+func (c *VersionedCache) Close() error {
+	return closeCache(c.c)
+}
+
+func (c *VersionedCache) Del(key string) error {
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	return c.c.Del(k)
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVersionedBump(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c := NewVersionedCache(l, "users")
+	c.Set("test:123", 1)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if err := c.BumpVersion(); err != nil {
+		t.Errorf("%v bump error", err)
+		return
+	}
+	data, _ = c.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+	c.Set("test:123", 2)
+	data, _ = c.GetInt("test:123")
+	if data == nil || *data != 2 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestVersionedShared(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewLocalCache(ctx, LocalWithExpire(10))
+	c1 := NewVersionedCache(l, "users")
+	c2 := NewVersionedCache(l, "users", VersionedWithRefresh(100*time.Millisecond))
+	other := NewVersionedCache(l, "orders")
+	c1.Set("test:123", 1)
+	other.Set("test:123", 1)
+	data, _ := c2.GetInt("test:123")
+	if data == nil || *data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c1.BumpVersion()
+	data, _ = c2.GetInt("test:123")
+	if data == nil {
+		t.Errorf("refresh value error")
+		return
+	}
+	time.Sleep(150 * time.Millisecond)
+	data, _ = c2.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+	data, _ = other.GetInt("test:123")
+	if data == nil || *data != 1 {
+		t.Errorf("%v other namespace value error", data)
+		return
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// warmBatchSize is how many entries a warmup writes per pipeline.
+const warmBatchSize = 500
+
+// ValueWithTTL is a value to store with its ttl, 0 uses the default
+// expiration of the cache.
+type ValueWithTTL struct {
+	Value interface{}
+	TTL   time.Duration
+}
+
+// WarmEntry is an entry of a streamed warmup.
+type WarmEntry struct {
+	Key string
+	ValueWithTTL
+}
+
+// warmBatches splits entries into maps of at most warmBatchSize entries.
+func warmBatches(entries map[string]ValueWithTTL) []map[string]ValueWithTTL {
+	if len(entries) <= warmBatchSize {
+		return []map[string]ValueWithTTL{entries}
+	}
+	var batches []map[string]ValueWithTTL
+	batch := make(map[string]ValueWithTTL, warmBatchSize)
+	for key, v := range entries {
+		batch[key] = v
+		if len(batch) == warmBatchSize {
+			batches = append(batches, batch)
+			batch = make(map[string]ValueWithTTL, warmBatchSize)
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// Warm stores entries, e.g. to prefill the cache at startup or after a
+// purge. Caches implementing IWarm write them in pipelined batches, others
+// one by one. It stops at the first error or when ctx is done, leaving the
+// entries written so far.
+func (c *Cache) Warm(ctx context.Context, entries map[string]ValueWithTTL) error {
+	if w, ok := c.cache.(IWarm); ok {
+		for _, batch := range warmBatches(entries) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := w.Warm(ctx, batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for key, v := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		if v.TTL > 0 {
+			err = c.cache.SetWithTTL(key, v.Value, v.TTL)
+		} else {
+			err = c.cache.Set(key, v.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WarmStream stores the entries received from entries until it is closed,
+// in batches as Warm does, so a large data set need not be held in memory.
+func (c *Cache) WarmStream(ctx context.Context, entries <-chan WarmEntry) error {
+	batch := make(map[string]ValueWithTTL, warmBatchSize)
+	for {
+		select {
+		case e, ok := <-entries:
+			if !ok {
+				return c.Warm(ctx, batch)
+			}
+			batch[e.Key] = e.ValueWithTTL
+			if len(batch) < warmBatchSize {
+				continue
+			}
+			if err := c.Warm(ctx, batch); err != nil {
+				return err
+			}
+			batch = make(map[string]ValueWithTTL, warmBatchSize)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
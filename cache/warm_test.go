@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWarm(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	err := c.Warm(ctx, map[string]ValueWithTTL{
+		"test:1": {Value: 1},
+		"test:2": {Value: 2, TTL: time.Second},
+	})
+	if err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	for i := int64(1); i <= 2; i++ {
+		data, _ := c.GetInt("test:" + strconv.FormatInt(i, 10))
+		if data == nil || *data != i {
+			t.Errorf("%v value error", data)
+			return
+		}
+	}
+}
+
+func TestWarmStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	entries := make(chan WarmEntry)
+	go func() {
+		for i := 0; i < warmBatchSize+10; i++ {
+			entries <- WarmEntry{Key: "test:" + strconv.Itoa(i), ValueWithTTL: ValueWithTTL{Value: i}}
+		}
+		close(entries)
+	}()
+	if err := c.WarmStream(ctx, entries); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	data, _ := c.GetInt("test:" + strconv.Itoa(warmBatchSize+9))
+	if data == nil || *data != warmBatchSize+9 {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestWarmCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewLocalCache(ctx, LocalWithExpire(10))
+	cancel()
+	if err := c.Warm(ctx, map[string]ValueWithTTL{"test:1": {Value: 1}}); err != context.Canceled {
+		t.Errorf("%v error", err)
+		return
+	}
+}
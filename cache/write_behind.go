@@ -0,0 +1,520 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWriteBehindInterval = 100 * time.Millisecond
+	defaultWriteBehindQueue    = 10000
+	defaultWriteBehindBatch    = 100
+	defaultWriteBehindDrain    = 5 * time.Second
+)
+
+var ErrQueueFull = errors.New("write queue full error")
+
+// ErrFlushTimeout is reported for the queued writes of a WriteBehindCache
+// still not written when the deadline of its last flush passed.
+var ErrFlushTimeout = errors.New("write flush timeout error")
+
+// FlushError is returned by the Close of a WriteBehindCache with the queued
+// writes its last flush did not persist, and their error: the one of the
+// failed write, or ErrFlushTimeout.
+type FlushError map[string]error
+
+func (e FlushError) Error() string {
+	return fmt.Sprintf("%d queued writes not flushed error", len(e))
+}
+
+// writeOp is a pending write of a WriteBehindCache. data is value encoded
+// when it was queued, for reads before the flush.
+type writeOp struct {
+	del    bool
+	value  interface{}
+	data   []byte
+	ttl    time.Duration
+	hasTTL bool
+}
+
+// WriteErrorFunc is called with the key and error of a failed flushed write.
+type WriteErrorFunc func(key string, err error)
+
+// WriteBehindCache queues Set and Del and writes them to the wrapped cache in
+// the background, so writes return without waiting for e.g. redis. Queued
+// writes of the same key are coalesced, only the last one is written, and
+// reads see queued writes before they are flushed. Writes still queued when
+// the process dies are lost, Close flushes them before returning.
+type WriteBehindCache struct {
+	c        ICache
+	interval time.Duration
+	maxQueue int
+	batch    int
+	drain    time.Duration
+	errFn    WriteErrorFunc
+	m        sync.Mutex
+	pending  map[string]*writeOp
+	writing  map[string]chan struct{}
+	flush    chan struct{}
+	stopped  bool
+	failed   FlushError
+	bg       Background
+}
+
+type WriteBehindOption func(c *WriteBehindCache)
+
+// WriteBehindWithFlushInterval sets how often queued writes are flushed,
+// 100ms by default.
+func WriteBehindWithFlushInterval(interval time.Duration) WriteBehindOption {
+	return func(c *WriteBehindCache) {
+		c.interval = interval
+	}
+}
+
+// WriteBehindWithMaxQueue caps the number of queued keys, 10000 by default.
+// Writes of new keys return ErrQueueFull while the queue is full.
+func WriteBehindWithMaxQueue(n int) WriteBehindOption {
+	return func(c *WriteBehindCache) {
+		c.maxQueue = n
+	}
+}
+
+// WriteBehindWithBatchSize flushes before the interval once n keys are
+// queued, 100 by default. A flush writes at most n keys per round trip.
+func WriteBehindWithBatchSize(n int) WriteBehindOption {
+	return func(c *WriteBehindCache) {
+		c.batch = n
+	}
+}
+
+// WriteBehindWithDrainTimeout bounds the last flush, when ctx is done or on
+// Close, to d, 5s by default. The writes not done by then are reported with
+// ErrFlushTimeout, a write already sent may still complete, the later
+// writes of its key waiting for it.
+func WriteBehindWithDrainTimeout(d time.Duration) WriteBehindOption {
+	return func(c *WriteBehindCache) {
+		c.drain = d
+	}
+}
+
+// WriteBehindWithErrorFunc reports the writes failing when flushed, they are
+// not retried, and the ones the last flush did not write.
+func WriteBehindWithErrorFunc(fn WriteErrorFunc) WriteBehindOption {
+	return func(c *WriteBehindCache) {
+		c.errFn = fn
+	}
+}
+
+// NewWriteBehindCache returns c with writes flushed in the background until
+// ctx is done or Close is called, when the queued writes are flushed a last
+// time. Later writes are written to c directly.
+func NewWriteBehindCache(ctx context.Context, c ICache, opts ...WriteBehindOption) *Cache {
+	wc := &WriteBehindCache{
+		c:        c,
+		interval: defaultWriteBehindInterval,
+		maxQueue: defaultWriteBehindQueue,
+		batch:    defaultWriteBehindBatch,
+		drain:    defaultWriteBehindDrain,
+		pending:  make(map[string]*writeOp),
+		writing:  make(map[string]chan struct{}),
+		flush:    make(chan struct{}, 1),
+	}
+	for _, fn := range opts {
+		fn(wc)
+	}
+	wc.bg.run(ctx, wc.run)
+	return NewCache(wc)
+}
+
+// enqueue queues op for key, replacing a queued write of key. Once stopped
+// op is written directly, after the flushed write of key in progress if any,
+// so the older value does not land last.
+func (c *WriteBehindCache) enqueue(key string, op *writeOp) error {
+	c.m.Lock()
+	if c.stopped {
+		delete(c.pending, key)
+		done := c.writing[key]
+		c.m.Unlock()
+		if done != nil {
+			<-done
+		}
+		return c.write(key, op)
+	}
+	_, ok := c.pending[key]
+	if !ok && len(c.pending) >= c.maxQueue {
+		c.m.Unlock()
+		c.signal()
+		return ErrQueueFull
+	}
+	c.pending[key] = op
+	n := len(c.pending)
+	c.m.Unlock()
+	if n >= c.batch {
+		c.signal()
+	}
+	return nil
+}
+
+// signal wakes the worker without blocking when a flush is already due.
+func (c *WriteBehindCache) signal() {
+	select {
+	case c.flush <- struct{}{}:
+	default:
+	}
+}
+
+func (c *WriteBehindCache) run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.flushPending()
+		case <-c.flush:
+			c.flushPending()
+		case <-ctx.Done():
+			c.drainPending()
+			return
+		}
+	}
+}
+
+// flushPending writes the queued writes. A write stays queued, and visible to
+// reads, until it is written, unless it is replaced meanwhile.
+func (c *WriteBehindCache) flushPending() {
+	c.flushOps(func(key string, err error) {
+		if c.errFn != nil {
+			c.errFn(key, err)
+		}
+	})
+}
+
+// drainPending flushes the queued writes a last time, for at most the drain
+// timeout, and keeps the writes not done for Close. The next writes are not
+// queued.
+func (c *WriteBehindCache) drainPending() {
+	c.m.Lock()
+	c.stopped = true
+	c.m.Unlock()
+	var m sync.Mutex
+	failed := FlushError{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.flushOps(func(key string, err error) {
+			m.Lock()
+			failed[key] = err
+			m.Unlock()
+		})
+	}()
+	timer := time.NewTimer(c.drain)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+	// the flush may still be running after a timeout, failed is copied
+	report := FlushError{}
+	c.m.Lock()
+	m.Lock()
+	for key, err := range failed {
+		report[key] = err
+	}
+	m.Unlock()
+	for key := range c.pending {
+		if _, ok := report[key]; !ok {
+			report[key] = ErrFlushTimeout
+		}
+	}
+	if len(report) > 0 {
+		c.failed = report
+	}
+	c.m.Unlock()
+	if c.errFn != nil {
+		for key, err := range report {
+			c.errFn(key, err)
+		}
+	}
+}
+
+// flushOps writes the queued writes, passing the failed ones to report. The
+// writes replaced, or written directly, since the flush started are skipped.
+// They are written in groups of the batch size, see writeOps.
+func (c *WriteBehindCache) flushOps(report WriteErrorFunc) {
+	c.m.Lock()
+	keys := make([]string, 0, len(c.pending))
+	ops := make(map[string]*writeOp, len(c.pending))
+	for key, op := range c.pending {
+		keys = append(keys, key)
+		ops[key] = op
+	}
+	c.m.Unlock()
+	for len(keys) > 0 {
+		n := c.batch
+		if n <= 0 || n > len(keys) {
+			n = len(keys)
+		}
+		group := make(map[string]*writeOp, n)
+		done := make(chan struct{})
+		c.m.Lock()
+		for _, key := range keys[:n] {
+			if c.pending[key] != ops[key] {
+				continue
+			}
+			group[key] = ops[key]
+			c.writing[key] = done
+		}
+		c.m.Unlock()
+		keys = keys[n:]
+		failed := c.writeOps(group)
+		c.m.Lock()
+		for key, op := range group {
+			delete(c.writing, key)
+			if c.pending[key] == op {
+				delete(c.pending, key)
+			}
+		}
+		c.m.Unlock()
+		close(done)
+		for key, err := range failed {
+			report(key, err)
+		}
+	}
+}
+
+// writeOps writes ops to the wrapped cache, in one round trip as a pipeline
+// when it can batch, else with MSet and MDel when it implements IMulti. It
+// returns the error of each failed write, all the writes of a failed
+// pipeline fail.
+func (c *WriteBehindCache) writeOps(ops map[string]*writeOp) map[string]error {
+	failed := make(map[string]error)
+	if len(ops) == 0 {
+		return failed
+	}
+	if b, ok := c.pipeline(); ok {
+		for key, op := range ops {
+			switch {
+			case op.del:
+				b.Del(key)
+			case op.hasTTL:
+				b.SetWithTTL(key, op.value, op.ttl)
+			default:
+				b.Set(key, op.value)
+			}
+		}
+		if err := b.Exec(); err != nil {
+			for key := range ops {
+				failed[key] = err
+			}
+		}
+		return failed
+	}
+	m, ok := c.c.(IMulti)
+	if !ok {
+		for key, op := range ops {
+			if err := c.write(key, op); err != nil {
+				failed[key] = err
+			}
+		}
+		return failed
+	}
+	sets := make(map[string]interface{})
+	var dels []string
+	for key, op := range ops {
+		switch {
+		case op.del:
+			dels = append(dels, key)
+		case op.hasTTL:
+			// MSet only writes with the default expiration
+			if err := c.write(key, op); err != nil {
+				failed[key] = err
+			}
+		default:
+			sets[key] = op.value
+		}
+	}
+	if len(sets) > 0 {
+		if err := m.MSet(sets); err != nil {
+			for key := range sets {
+				failed[key] = err
+			}
+		}
+	}
+	if len(dels) > 0 {
+		if err := m.MDel(dels...); err != nil {
+			for _, key := range dels {
+				failed[key] = err
+			}
+		}
+	}
+	return failed
+}
+
+// pipeline returns a pipeline of the wrapped cache, ok is false when it can
+// not batch.
+func (c *WriteBehindCache) pipeline() (b Batch, ok bool) {
+	switch cc := c.c.(type) {
+	case IBatch:
+		return cc.Pipeline(), true
+	case *Cache:
+		b, err := cc.Pipeline()
+		return b, err == nil
+	}
+	return nil, false
+}
+
+// write writes op for key to the wrapped cache.
+func (c *WriteBehindCache) write(key string, op *writeOp) error {
+	switch {
+	case op.del:
+		return c.c.Del(key)
+	case op.hasTTL:
+		return c.c.SetWithTTL(key, op.value, op.ttl)
+	}
+	return c.c.Set(key, op.value)
+}
+
+func (c *WriteBehindCache) Set(key string, value interface{}) error {
+	return c.enqueue(key, &writeOp{value: value, data: EncodeBytes(value)})
+}
+
+func (c *WriteBehindCache) SetWithExpire(key string, value interface{}, expireSec int) error {
+	return c.SetWithTTL(key, value, time.Duration(expireSec)*time.Second)
+}
+
+func (c *WriteBehindCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.enqueue(key, &writeOp{value: value, data: EncodeBytes(value), ttl: ttl, hasTTL: true})
+}
+
+// Ping pings the wrapped cache.
+func (c *WriteBehindCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.c)
+}
+
+// Close flushes the queued writes a last time, for at most the drain
+// timeout, then closes the wrapped cache. It returns a FlushError with the
+// writes not persisted, also passed to the WriteBehindWithErrorFunc.
+func (c *WriteBehindCache) Close() error {
+	c.bg.Stop()
+	c.m.Lock()
+	failed := c.failed
+	c.failed = nil
+	c.m.Unlock()
+	err := closeCache(c.c)
+	if failed != nil {
+		return failed
+	}
+	return err
+}
+
+func (c *WriteBehindCache) Del(key string) error {
+	return c.enqueue(key, &writeOp{del: true})
+}
+
+// queued returns the queued write of key, ok is false when there is none.
+func (c *WriteBehindCache) queued(key string) (op *writeOp, ok bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	op, ok = c.pending[key]
+	return op, ok
+}
+
+// queuedGetters returns the typed getters of the queued write of key, which
+// parse its encoded value, ok is false when there is none.
+func (c *WriteBehindCache) queuedGetters(key string) (g ByteGetters, ok bool) {
+	op, ok := c.queued(key)
+	if !ok {
+		return g, false
+	}
+	return ByteGetters{get: func(string) (interface{}, error) {
+		if op.del {
+			return nil, nil
+		}
+		return op.data, nil
+	}}, true
+}
+
+// Get returns the value of the queued write of key as it was set, else the
+// value of the wrapped cache.
+func (c *WriteBehindCache) Get(key string) (interface{}, error) {
+	if op, ok := c.queued(key); ok {
+		if op.del {
+			return nil, nil
+		}
+		return op.value, nil
+	}
+	return c.c.Get(key)
+}
+
+func (c *WriteBehindCache) GetInt(key string) (*int64, error) {
+	if g, ok := c.queuedGetters(key); ok {
+		return g.GetInt(key)
+	}
+	return c.c.GetInt(key)
+}
+
+func (c *WriteBehindCache) GetUint(key string) (*uint64, error) {
+	if g, ok := c.queuedGetters(key); ok {
+		return g.GetUint(key)
+	}
+	return c.c.GetUint(key)
+}
+
+func (c *WriteBehindCache) GetFloat(key string) (*float64, error) {
+	if g, ok := c.queuedGetters(key); ok {
+		return g.GetFloat(key)
+	}
+	return c.c.GetFloat(key)
+}
+
+func (c *WriteBehindCache) GetString(key string) (string, error) {
+	if g, ok := c.queuedGetters(key); ok {
+		return g.GetString(key)
+	}
+	return c.c.GetString(key)
+}
+
+func (c *WriteBehindCache) GetBytes(key string) ([]byte, error) {
+	if g, ok := c.queuedGetters(key); ok {
+		return g.GetBytes(key)
+	}
+	return c.c.GetBytes(key)
+}
+
+func (c *WriteBehindCache) GetBool(key string) (*bool, error) {
+	if g, ok := c.queuedGetters(key); ok {
+		return g.GetBool(key)
+	}
+	return c.c.GetBool(key)
+}
+
+func (c *WriteBehindCache) GetTime(key string) (*time.Time, error) {
+	if g, ok := c.queuedGetters(key); ok {
+		return g.GetTime(key)
+	}
+	return c.c.GetTime(key)
+}
+
+func (c *WriteBehindCache) GetDuration(key string) (*time.Duration, error) {
+	if g, ok := c.queuedGetters(key); ok {
+		return g.GetDuration(key)
+	}
+	return c.c.GetDuration(key)
+}
+
+func (c *WriteBehindCache) GetStringSlice(key string) ([]string, error) {
+	if g, ok := c.queuedGetters(key); ok {
+		return g.GetStringSlice(key)
+	}
+	return c.c.GetStringSlice(key)
+}
+
+func (c *WriteBehindCache) GetIntSlice(key string) ([]int64, error) {
+	if g, ok := c.queuedGetters(key); ok {
+		return g.GetIntSlice(key)
+	}
+	return c.c.GetIntSlice(key)
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriteBehindSet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c := NewWriteBehindCache(ctx, l, WriteBehindWithFlushInterval(50*time.Millisecond))
+	c.Set("test:123", 3)
+	data, _ := c.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	data, _ = l.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v flushed early error", *data)
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+	data, _ = l.GetInt("test:123")
+	if data == nil || *data != 3 {
+		t.Errorf("%v flush value error", data)
+		return
+	}
+	c.Del("test:123")
+	data, _ = c.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v value error", *data)
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+	data, _ = l.GetInt("test:123")
+	if data != nil {
+		t.Errorf("%v flush value error", *data)
+		return
+	}
+}
+
+func TestWriteBehindBatchSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c := NewWriteBehindCache(ctx, l, WriteBehindWithFlushInterval(time.Hour), WriteBehindWithBatchSize(2))
+	c.Set("test:1", "a")
+	c.Set("test:2", "b")
+	time.Sleep(50 * time.Millisecond)
+	data, _ := l.GetString("test:2")
+	if data != "b" {
+		t.Errorf("%v value error", data)
+		return
+	}
+}
+
+func TestWriteBehindMaxQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c := NewWriteBehindCache(ctx, l, WriteBehindWithFlushInterval(time.Hour), WriteBehindWithMaxQueue(1))
+	if err := c.Set("test:1", "a"); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := c.Set("test:1", "b"); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if err := c.Set("test:2", "a"); err != ErrQueueFull {
+		t.Errorf("%v error", err)
+		return
+	}
+}
+
+func TestWriteBehindErrorFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	c := NewWriteBehindCache(ctx, NewGoredisCache(nil), WriteBehindWithFlushInterval(10*time.Millisecond),
+		WriteBehindWithErrorFunc(func(key string, err error) {
+			errs <- err
+		}))
+	c.Set("test:123", 3)
+	select {
+	case err := <-errs:
+		if err != ErrNoRedis {
+			t.Errorf("%v error", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no write error")
+	}
+}
+
+// slowCache delays the writes of the wrapped cache.
+type slowCache struct {
+	ICache
+	d time.Duration
+}
+
+func (c *slowCache) Set(key string, value interface{}) error {
+	time.Sleep(c.d)
+	return c.ICache.Set(key, value)
+}
+
+func TestWriteBehindClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	c := NewWriteBehindCache(ctx, l, WriteBehindWithFlushInterval(time.Hour))
+	c.Set("test:1", "a")
+	c.Set("test:2", "b")
+	if err := c.Close(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if data, _ := l.GetString("test:2"); data != "b" {
+		t.Errorf("%v value error", data)
+		return
+	}
+	c.Set("test:3", "c")
+	if data, _ := l.GetString("test:3"); data != "c" {
+		t.Errorf("%v value error", data)
+		return
+	}
+
+	c = NewWriteBehindCache(ctx, NewGoredisCache(nil), WriteBehindWithFlushInterval(time.Hour))
+	c.Set("test:1", "a")
+	if err, ok := c.Close().(FlushError); !ok || err["test:1"] != ErrNoRedis {
+		t.Errorf("%v error", err)
+		return
+	}
+
+	errs := make(chan error, 2)
+	c = NewWriteBehindCache(ctx, &slowCache{ICache: l, d: 50 * time.Millisecond}, WriteBehindWithFlushInterval(time.Hour),
+		WriteBehindWithDrainTimeout(10*time.Millisecond), WriteBehindWithErrorFunc(func(key string, err error) {
+			errs <- err
+		}))
+	c.Set("test:1", "a")
+	c.Set("test:2", "b")
+	err, ok := c.Close().(FlushError)
+	if !ok || len(err) != 2 || err["test:1"] != ErrFlushTimeout {
+		t.Errorf("%v error", err)
+		return
+	}
+	if len(errs) != 2 {
+		t.Errorf("%v value error", len(errs))
+	}
+}
+
+// blockCache blocks the writes of the value block until release is closed.
+type blockCache struct {
+	ICache
+	block   interface{}
+	release chan struct{}
+}
+
+func (c *blockCache) Set(key string, value interface{}) error {
+	if value == c.block {
+		<-c.release
+	}
+	return c.ICache.Set(key, value)
+}
+
+func TestWriteBehindCloseOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewLocalCache(ctx, LocalWithEncoding(), LocalWithExpire(10))
+	release := make(chan struct{})
+	c := NewWriteBehindCache(ctx, &blockCache{ICache: l, block: "old", release: release},
+		WriteBehindWithFlushInterval(time.Hour), WriteBehindWithDrainTimeout(10*time.Millisecond))
+	c.Set("test:1", "old")
+	if _, ok := c.Close().(FlushError); !ok {
+		t.Errorf("close error")
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Set("test:1", "new")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-done
+	time.Sleep(20 * time.Millisecond)
+	if data, _ := l.GetString("test:1"); data != "new" {
+		t.Errorf("%v value error", data)
+	}
+}
+
+// multiCache counts the MSet and MDel calls made on l.
+type multiCache struct {
+	ICache
+	l            *Cache
+	msets, mdels int32
+}
+
+func (c *multiCache) MGet(keys ...string) ([]interface{}, error) {
+	return c.l.MGet(keys...)
+}
+
+func (c *multiCache) MSet(entries map[string]interface{}) error {
+	atomic.AddInt32(&c.msets, 1)
+	return c.l.MSet(entries)
+}
+
+func (c *multiCache) MDel(keys ...string) error {
+	atomic.AddInt32(&c.mdels, 1)
+	return c.l.MDel(keys...)
+}
+
+func TestWriteBehindMulti(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewLocalCache(ctx, LocalWithExpire(10))
+	l.Set("test:4", 4)
+	l.Set("test:5", 5)
+	m := &multiCache{ICache: l, l: l}
+	c := NewWriteBehindCache(ctx, m, WriteBehindWithFlushInterval(time.Hour))
+	c.Set("test:1", 1)
+	c.Set("test:2", 2)
+	c.SetWithTTL("test:3", 3, time.Minute)
+	c.Del("test:4")
+	c.Del("test:5")
+	if data, _ := c.Get("test:1"); data != 1 {
+		t.Errorf("%v value error", data)
+		return
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	if m.msets != 1 || m.mdels != 1 {
+		t.Errorf("%v %v calls error", m.msets, m.mdels)
+		return
+	}
+	values, _ := l.MGet("test:1", "test:2", "test:3", "test:4", "test:5")
+	if values[0] != 1 || values[1] != 2 || values[2] != 3 || values[3] != nil || values[4] != nil {
+		t.Errorf("%v value error", values)
+		return
+	}
+}
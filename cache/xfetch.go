@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// xfetchSuffix names the key holding the recompute cost and expiration
+	// of a key stored by GetOrSetWithTTL.
+	xfetchSuffix = ":xfetch"
+	// xfetchBeta scales how early keys are recomputed, 1 is the value
+	// recommended by the XFetch paper.
+	xfetchBeta = 1.0
+)
+
+// xfetchMeta returns the recompute cost and expiration stored for key, ok is
+// false when they are missing or malformed.
+func (c *Cache) xfetchMeta(key string) (delta time.Duration, expireAt int64, ok bool) {
+	meta, err := c.cache.GetString(key + xfetchSuffix)
+	if err != nil || meta == "" {
+		return 0, 0, false
+	}
+	i := strings.IndexByte(meta, ':')
+	if i < 0 {
+		return 0, 0, false
+	}
+	d, err1 := strconv.ParseInt(meta[:i], 10, 64)
+	exp, err2 := strconv.ParseInt(meta[i+1:], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return time.Duration(d), exp, true
+}
+
+// xfetchEarly reports whether a reader should recompute at now a value
+// costing delta to compute and expiring at expireAt, with a probability
+// growing as expireAt comes closer and with the cost.
+func xfetchEarly(now time.Time, delta time.Duration, expireAt int64) bool {
+	gap := -float64(delta) * xfetchBeta * math.Log(1-rand.Float64())
+	return float64(now.UnixNano())+gap >= float64(expireAt)
+}
+
+// GetOrSetWithTTL is GetOrSet storing the loaded value with ttl, protected
+// from stampedes with the XFetch algorithm: the time the loader took is
+// stored next to the value, under key + ":xfetch", and each reader may call
+// the loader before the value expires, more likely the closer the expiration
+// and the slower the loader. One reader then recomputes the value while the
+// others are still served the old one. When an early recompute fails, the
+// value cached is still returned, the error is only counted in the Stats.
+func (c *Cache) GetOrSetWithTTL(key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	value, err := c.Get(key)
+	ok, err := c.found(value, err)
+	if err != nil {
+		return nil, err
+	}
+	load := func() (interface{}, error) {
+		return c.xfetchLoad(key, ttl, loader)
+	}
+	if !ok {
+		return c.flight.Do(key, load)
+	}
+	delta, expireAt, ok := c.xfetchMeta(key)
+	if !ok || !xfetchEarly(c.clk.Now(), delta, expireAt) {
+		return value, nil
+	}
+	fresh, err := c.flight.Do(key, load)
+	if err != nil {
+		return value, nil
+	}
+	return fresh, nil
+}
+
+// xfetchLoad calls loader and stores its value with ttl, next to the time
+// it took.
+func (c *Cache) xfetchLoad(key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	start := c.clk.Now()
+	value, err := c.stats.load(key, loader)
+	if err != nil {
+		return nil, err
+	}
+	delta := c.clk.Now().Sub(start)
+	if err := c.SetWithTTL(key, value, ttl); err != nil {
+		return nil, err
+	}
+	meta := strconv.FormatInt(int64(delta), 10) + ":" + strconv.FormatInt(start.Add(delta+ttl).UnixNano(), 10)
+	if err := c.cache.SetWithTTL(key+xfetchSuffix, meta, ttl); err != nil {
+		c.stats.write(0, err)
+		return nil, err
+	}
+	return value, nil
+}
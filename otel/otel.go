@@ -0,0 +1,78 @@
+/*
+Package otel adapts the OpenTelemetry tracers to the Tracer of the cache and
+bloom packages, so their spans join the traces of the application:
+
+	// otelapi is go.opentelemetry.io/otel
+	tracer := otel.NewTracer(otelapi.Tracer("mcache"))
+	c := cache.NewTracedCache(ctx, cache.NewGoredisCache(client), tracer)
+
+	func handler(w http.ResponseWriter, r *http.Request) {
+		value, err := c.GetCtx(r.Context(), "user:42")
+		...
+	}
+
+The spans are of kind client, and an error sets their status to error.
+*/
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"mcache/bloom"
+	"mcache/cache"
+)
+
+// Span is an OpenTelemetry span, as a Span of the cache and bloom packages.
+type Span struct {
+	span trace.Span
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	s.span.SetAttributes(attribute.String(key, value))
+}
+
+func (s *Span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *Span) End() {
+	s.span.End()
+}
+
+func start(ctx context.Context, tracer trace.Tracer, name string) *Span {
+	_, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return &Span{span: span}
+}
+
+// Tracer starts the spans of a cache.TracedCache with an OpenTelemetry
+// tracer.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+func NewTracer(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+func (t *Tracer) Start(ctx context.Context, name string) cache.Span {
+	return start(ctx, t.tracer, name)
+}
+
+// BloomTracer starts the spans of a bloom.TracedBitMap with an
+// OpenTelemetry tracer.
+type BloomTracer struct {
+	tracer trace.Tracer
+}
+
+func NewBloomTracer(tracer trace.Tracer) *BloomTracer {
+	return &BloomTracer{tracer: tracer}
+}
+
+func (t *BloomTracer) Start(ctx context.Context, name string) bloom.Span {
+	return start(ctx, t.tracer, name)
+}
@@ -0,0 +1,75 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"mcache/bloom"
+	"mcache/cache"
+)
+
+func newRecorder() (*tracetest.SpanRecorder, *sdktrace.TracerProvider) {
+	sr := tracetest.NewSpanRecorder()
+	return sr, sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+}
+
+// attr returns the value of the attribute key of span.
+func attr(span sdktrace.ReadOnlySpan, key string) string {
+	for _, kv := range span.Attributes() {
+		if kv.Key == attribute.Key(key) {
+			return kv.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func TestTracer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sr, tp := newRecorder()
+	c := cache.NewTracedCache(ctx, cache.NewLocalCache(ctx), NewTracer(tp.Tracer("test")), cache.TracedWithBackend("local"))
+	reqCtx, req := tp.Tracer("test").Start(ctx, "request")
+	c.SetCtx(reqCtx, "test:1", 1)
+	c.GetCtx(reqCtx, "test:missing")
+	req.End()
+
+	spans := sr.Ended()
+	if len(spans) != 3 {
+		t.Errorf("%v value error", len(spans))
+		return
+	}
+	want := []struct{ name, result string }{
+		{"cache.Set", "ok"},
+		{"cache.Get", "miss"},
+	}
+	for i, w := range want {
+		s := spans[i]
+		if s.Name() != w.name || attr(s, "cache.result") != w.result || attr(s, "cache.backend") != "local" ||
+			attr(s, "cache.key_prefix") != "test" || s.Parent().SpanID() != req.SpanContext().SpanID() {
+			t.Errorf("%v %v value error", s.Name(), s.Attributes())
+		}
+	}
+}
+
+func TestBloomTracer(t *testing.T) {
+	sr, tp := newRecorder()
+	f := bloom.NewLocal(1000, 4)
+	bloom.BloomWithTracer(context.Background(), NewBloomTracer(tp.Tracer("test")), "local")(f)
+	reqCtx, req := tp.Tracer("test").Start(context.Background(), "request")
+	f.TestAndAddStringCtx(reqCtx, "Bess")
+	req.End()
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Errorf("%v value error", len(spans))
+		return
+	}
+	s := spans[0]
+	if s.Name() != "bloom.TestAndAdd" || attr(s, "bloom.result") != "absent" || s.Parent().SpanID() != req.SpanContext().SpanID() {
+		t.Errorf("%v %v value error", s.Name(), s.Attributes())
+	}
+}
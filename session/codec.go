@@ -0,0 +1,49 @@
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec serializes the values of a session stored in the cache.
+type Codec interface {
+	Encode(values map[string]interface{}) ([]byte, error)
+	Decode(data []byte) (map[string]interface{}, error)
+}
+
+// JSONCodec stores the values as a JSON object. They are decoded as JSON
+// does into an interface{}, numbers as float64, objects as maps.
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(values map[string]interface{}) ([]byte, error) {
+	return json.Marshal(values)
+}
+
+func (JSONCodec) Decode(data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// GobCodec stores the values with encoding/gob, keeping their types. The
+// types other than the basic ones must be registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) Encode(values map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Decode(data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+var ErrKeyType = errors.New("session value key type error")
+
+// GorillaStore is a Store as a github.com/gorilla/sessions store, for the
+// frameworks and middlewares built on it. The cookie named as the session
+// keeps its id, the keys of its values must be strings.
+type GorillaStore struct {
+	s *Store
+	// Options are the default options of the new sessions.
+	Options *sessions.Options
+}
+
+// NewGorillaStore returns s as a gorilla/sessions store. The default options
+// are the cookie attributes of s with its ttl as max age. The sessions expire
+// in the cache after the ttl of s whatever the max age of their cookie.
+func NewGorillaStore(s *Store) *GorillaStore {
+	return &GorillaStore{
+		s: s,
+		Options: &sessions.Options{
+			Path:     s.cookie.Path,
+			Domain:   s.cookie.Domain,
+			MaxAge:   s.maxAge(),
+			Secure:   s.cookie.Secure,
+			HttpOnly: s.cookie.HttpOnly,
+			SameSite: s.cookie.SameSite,
+		},
+	}
+}
+
+// Get returns the session name of r, loaded once per request by the
+// registry of gorilla/sessions.
+func (g *GorillaStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(g, name)
+}
+
+// New returns the session of the cookie name of r, or a new one when r has
+// no such cookie or its session expired.
+func (g *GorillaStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	gs := sessions.NewSession(g, name)
+	opts := *g.Options
+	gs.Options = &opts
+	gs.IsNew = true
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return gs, nil
+	}
+	sess, err := g.s.Load(cookie.Value)
+	if err == ErrNotFound || err == ErrInvalidID {
+		return gs, nil
+	}
+	if err != nil {
+		return gs, err
+	}
+	for k, v := range sess.Values {
+		gs.Values[k] = v
+	}
+	gs.ID = sess.id
+	gs.IsNew = false
+	return gs, nil
+}
+
+// Save stores the session and sets its cookie on w, starting its ttl again.
+// A negative max age destroys the session and deletes its cookie.
+func (g *GorillaStore) Save(r *http.Request, w http.ResponseWriter, gs *sessions.Session) error {
+	if gs.Options.MaxAge < 0 {
+		if validID(gs.ID) {
+			if err := g.s.Destroy(&Session{id: gs.ID}); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(gs.Name(), "", gs.Options))
+		return nil
+	}
+	sess := &Session{Values: make(map[string]interface{}, len(gs.Values)), id: gs.ID}
+	for k, v := range gs.Values {
+		key, ok := k.(string)
+		if !ok {
+			return ErrKeyType
+		}
+		sess.Values[key] = v
+	}
+	if !validID(sess.id) {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		sess.id = id
+	}
+	if err := g.s.Save(sess); err != nil {
+		return err
+	}
+	gs.ID = sess.id
+	gs.IsNew = false
+	http.SetCookie(w, sessions.NewCookie(gs.Name(), gs.ID, gs.Options))
+	return nil
+}
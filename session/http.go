@@ -0,0 +1,76 @@
+package session
+
+import (
+	"net/http"
+	"time"
+)
+
+// Cookie is the attributes of the cookie keeping the session id. Its max age
+// is the ttl of the store.
+type Cookie struct {
+	Name     string
+	Path     string
+	Domain   string
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+var defaultCookie = Cookie{
+	Name:     "session",
+	Path:     "/",
+	HttpOnly: true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+// maxAge returns the ttl in seconds, rounded up.
+func (s *Store) maxAge() int {
+	return int((s.ttl + time.Second - 1) / time.Second)
+}
+
+func (s *Store) httpCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.cookie.Name,
+		Value:    value,
+		Path:     s.cookie.Path,
+		Domain:   s.cookie.Domain,
+		MaxAge:   maxAge,
+		Secure:   s.cookie.Secure,
+		HttpOnly: s.cookie.HttpOnly,
+		SameSite: s.cookie.SameSite,
+	}
+}
+
+// Get returns the session of the cookie of r, or a new one when r has no
+// session cookie or its session expired.
+func (s *Store) Get(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(s.cookie.Name)
+	if err != nil {
+		return s.New()
+	}
+	sess, err := s.Load(cookie.Value)
+	if err == ErrNotFound || err == ErrInvalidID {
+		return s.New()
+	}
+	return sess, err
+}
+
+// Write saves the session and sets its cookie on w, it must be called
+// before the body is written. Writing the session on every request keeps it
+// alive while it is used.
+func (s *Store) Write(w http.ResponseWriter, sess *Session) error {
+	if err := s.Save(sess); err != nil {
+		return err
+	}
+	http.SetCookie(w, s.httpCookie(sess.id, s.maxAge()))
+	return nil
+}
+
+// Remove destroys the session and deletes its cookie on w.
+func (s *Store) Remove(w http.ResponseWriter, sess *Session) error {
+	if err := s.Destroy(sess); err != nil {
+		return err
+	}
+	http.SetCookie(w, s.httpCookie("", -1))
+	return nil
+}
@@ -0,0 +1,228 @@
+/*
+Package session stores web sessions in any cache of mcache/cache.
+
+A session is a map of values under a random id, stored by a Store in the
+cache with an idle timeout: each save starts its ttl again, and so does each
+load when the cache slides its expiration on reads, the default of e.g.
+LocalCache and the redis caches. With absolute expiration, e.g.
+cache.LocalWithAbsoluteExpire, a session expires once it is not saved for
+the ttl. The http helper Write saves it on every request. The values are
+serialized by a Codec, JSON by default.
+
+The http helpers keep the id in a cookie, for any framework built on
+net/http:
+
+	store := session.NewStore(cache.NewGoredisCache(client))
+
+	func handler(w http.ResponseWriter, r *http.Request) {
+		s, err := store.Get(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.Values["user"] = "bob"
+		if err := store.Write(w, s); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+GorillaStore serves the same sessions to the frameworks built on
+github.com/gorilla/sessions:
+
+	store := session.NewGorillaStore(session.NewStore(cache.NewGoredisCache(client)))
+
+	func handler(w http.ResponseWriter, r *http.Request) {
+		s, err := store.Get(r, "session")
+		...
+		s.Values["user"] = "bob"
+		err = s.Save(r, w)
+	}
+*/
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"mcache/cache"
+)
+
+const (
+	defaultPrefix = "session:"
+	defaultTTL    = 30 * time.Minute
+	idBytes       = 32
+)
+
+var (
+	ErrNotFound  = errors.New("session not found error")
+	ErrInvalidID = errors.New("invalid session id error")
+)
+
+// Session is the values of a session and its id.
+type Session struct {
+	Values map[string]interface{}
+	id     string
+	isNew  bool
+}
+
+// ID returns the id of the session.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// IsNew reports whether the session was not saved yet.
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// newID returns a random session id, 32 bytes from crypto/rand encoded as
+// URL safe base64.
+func newID() (string, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validID reports whether id may be a session id, so the ids sent by clients
+// never reach the cache as arbitrary keys.
+func validID(id string) bool {
+	if len(id) != base64.RawURLEncoding.EncodedLen(idBytes) {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(id)
+	return err == nil
+}
+
+// Store creates, loads, saves and destroys the sessions kept in a cache.
+type Store struct {
+	c      cache.ICache
+	prefix string
+	ttl    time.Duration
+	codec  Codec
+	cookie Cookie
+}
+
+type StoreOption func(s *Store)
+
+// StoreWithPrefix namespaces the keys of the sessions, "session:" by
+// default.
+func StoreWithPrefix(prefix string) StoreOption {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// StoreWithTTL sets the idle timeout of the sessions, 30 minutes by default.
+func StoreWithTTL(ttl time.Duration) StoreOption {
+	return func(s *Store) {
+		s.ttl = ttl
+	}
+}
+
+// StoreWithCodec serializes the values with codec instead of JSONCodec.
+func StoreWithCodec(codec Codec) StoreOption {
+	return func(s *Store) {
+		s.codec = codec
+	}
+}
+
+// StoreWithCookie sets the attributes of the session cookie.
+func StoreWithCookie(cookie Cookie) StoreOption {
+	return func(s *Store) {
+		s.cookie = cookie
+	}
+}
+
+func NewStore(c cache.ICache, opts ...StoreOption) *Store {
+	s := &Store{
+		c:      c,
+		prefix: defaultPrefix,
+		ttl:    defaultTTL,
+		codec:  JSONCodec{},
+		cookie: defaultCookie,
+	}
+	for _, fn := range opts {
+		fn(s)
+	}
+	return s
+}
+
+// New returns a new empty session with a random id. It is stored by Save.
+func (s *Store) New() (*Session, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		Values: make(map[string]interface{}),
+		id:     id,
+		isNew:  true,
+	}, nil
+}
+
+// Load returns the session id, or ErrNotFound when it expired or was
+// destroyed and ErrInvalidID when id is not a session id. Loading reads the
+// cache like any Get: it starts the ttl again when the cache slides its
+// expiration on reads, not when it uses absolute expiration.
+func (s *Store) Load(id string) (*Session, error) {
+	if !validID(id) {
+		return nil, ErrInvalidID
+	}
+	data, err := s.c.GetString(s.prefix + id)
+	if err == cache.ErrCacheMiss || (err == nil && data == "") {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	values, err := s.codec.Decode([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	return &Session{Values: values, id: id}, nil
+}
+
+// Save stores the session, starting its ttl again.
+func (s *Store) Save(sess *Session) error {
+	data, err := s.codec.Encode(sess.Values)
+	if err != nil {
+		return err
+	}
+	if err := s.c.SetWithTTL(s.prefix+sess.id, string(data), s.ttl); err != nil {
+		return err
+	}
+	sess.isNew = false
+	return nil
+}
+
+// Destroy deletes the session and clears its values.
+func (s *Store) Destroy(sess *Session) error {
+	if err := s.c.Del(s.prefix + sess.id); err != nil {
+		return err
+	}
+	sess.Values = make(map[string]interface{})
+	return nil
+}
+
+// Regenerate gives the session a new id, keeping its values, and deletes it
+// under the old one, e.g. on login against session fixation. It is stored
+// under the new id by Save.
+func (s *Store) Regenerate(sess *Session) error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	if !sess.isNew {
+		if err := s.c.Del(s.prefix + sess.id); err != nil {
+			return err
+		}
+	}
+	sess.id = id
+	sess.isNew = true
+	return nil
+}
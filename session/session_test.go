@@ -0,0 +1,226 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mcache/cache"
+)
+
+func TestStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := cache.NewLocalCache(ctx)
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+		s := NewStore(c, StoreWithCodec(codec))
+		sess, err := s.New()
+		if err != nil || !sess.IsNew() || !validID(sess.ID()) {
+			t.Errorf("%v %v value error", sess, err)
+			return
+		}
+		sess.Values["user"] = "bob"
+		if err := s.Save(sess); err != nil || sess.IsNew() {
+			t.Errorf("%v error", err)
+			return
+		}
+		loaded, err := s.Load(sess.ID())
+		if err != nil || loaded.Values["user"] != "bob" {
+			t.Errorf("%v %v value error", loaded, err)
+			return
+		}
+
+		old := sess.ID()
+		if err := s.Regenerate(sess); err != nil || sess.ID() == old {
+			t.Errorf("%v %v error", sess.ID(), err)
+			return
+		}
+		s.Save(sess)
+		if _, err := s.Load(old); err != ErrNotFound {
+			t.Errorf("%v error", err)
+			return
+		}
+		if err := s.Destroy(sess); err != nil || len(sess.Values) != 0 {
+			t.Errorf("%v %v error", sess.Values, err)
+			return
+		}
+		if _, err := s.Load(sess.ID()); err != ErrNotFound {
+			t.Errorf("%v error", err)
+			return
+		}
+	}
+	s := NewStore(c)
+	if _, err := s.Load("../../etc"); err != ErrInvalidID {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestStoreRollingExpire(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewStore(cache.NewLocalCache(ctx, cache.LocalWithAbsoluteExpire()), StoreWithTTL(200*time.Millisecond))
+	sess, _ := s.New()
+	s.Save(sess)
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if err := s.Save(sess); err != nil {
+			t.Errorf("%v error", err)
+			return
+		}
+	}
+	if _, err := s.Load(sess.ID()); err != nil {
+		t.Errorf("%v error", err)
+		return
+	}
+	time.Sleep(300 * time.Millisecond)
+	if _, err := s.Load(sess.ID()); err != ErrNotFound {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestStoreHTTP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewStore(cache.NewLocalCache(ctx), StoreWithCookie(Cookie{Name: "sid", Path: "/", HttpOnly: true, Secure: true}))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, err := s.Get(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/logout" {
+			s.Remove(w, sess)
+			return
+		}
+		n, _ := sess.Values["n"].(float64)
+		sess.Values["n"] = n + 1
+		if err := s.Write(w, sess); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "sid" || !cookies[0].Secure || cookies[0].MaxAge != 1800 {
+		t.Errorf("%v value error", cookies)
+		return
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookies[0])
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	sess, err := s.Load(cookies[0].Value)
+	if err != nil || sess.Values["n"] != 2.0 {
+		t.Errorf("%v %v value error", sess, err)
+		return
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/logout", nil)
+	r.AddCookie(cookies[0])
+	handler.ServeHTTP(w, r)
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].MaxAge >= 0 {
+		t.Errorf("%v value error", c)
+		return
+	}
+	if _, err := s.Load(cookies[0].Value); err != ErrNotFound {
+		t.Errorf("%v error", err)
+		return
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "sid", Value: "forged"})
+	sess, err = s.Get(r)
+	if err != nil || !sess.IsNew() || sess.ID() == "forged" {
+		t.Errorf("%v %v value error", sess, err)
+	}
+}
+
+func TestStoreLoadKeepsTTL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewStore(cache.NewLocalCache(ctx, cache.LocalWithAbsoluteExpire()), StoreWithTTL(200*time.Millisecond))
+	sess, _ := s.New()
+	s.Save(sess)
+	time.Sleep(150 * time.Millisecond)
+	s.Load(sess.ID())
+	time.Sleep(100 * time.Millisecond)
+	if _, err := s.Load(sess.ID()); err != ErrNotFound {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestStoreLoadSlidesTTL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewStore(cache.NewLocalCache(ctx), StoreWithTTL(200*time.Millisecond))
+	sess, _ := s.New()
+	s.Save(sess)
+	time.Sleep(150 * time.Millisecond)
+	s.Load(sess.ID())
+	time.Sleep(100 * time.Millisecond)
+	if _, err := s.Load(sess.ID()); err != nil {
+		t.Errorf("%v error", err)
+	}
+}
+
+func TestGorillaStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewStore(cache.NewLocalCache(ctx))
+	g := NewGorillaStore(s)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, err := g.Get(r, "sid")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/logout" {
+			sess.Options.MaxAge = -1
+		} else {
+			n, _ := sess.Values["n"].(float64)
+			sess.Values["n"] = n + 1
+		}
+		if err := sess.Save(r, w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "sid" || !validID(cookies[0].Value) || cookies[0].MaxAge != 1800 {
+		t.Errorf("%v value error", cookies)
+		return
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookies[0])
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	sess, err := s.Load(cookies[0].Value)
+	if err != nil || sess.Values["n"] != 2.0 {
+		t.Errorf("%v %v value error", sess, err)
+		return
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/logout", nil)
+	r.AddCookie(cookies[0])
+	handler.ServeHTTP(w, r)
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].MaxAge >= 0 {
+		t.Errorf("%v value error", c)
+		return
+	}
+	if _, err := s.Load(cookies[0].Value); err != ErrNotFound {
+		t.Errorf("%v error", err)
+		return
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	gs, _ := g.New(r, "sid")
+	gs.Values[1] = "bob"
+	if err := g.Save(r, httptest.NewRecorder(), gs); err != ErrKeyType {
+		t.Errorf("%v error", err)
+	}
+}